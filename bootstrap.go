@@ -122,6 +122,11 @@ func (bootstrap *Bootstrap) Start() {
 	_ = pugEngine.RegisterTag("archiveFilters", tags.TagArchiveFiltersParser)
 	_ = pugEngine.RegisterTag("userDetail", tags.TagUserDetailParser)
 	_ = pugEngine.RegisterTag("userGroupDetail", tags.TagUserGroupDetailParser)
+	_ = pugEngine.RegisterTag("favoriteList", tags.TagFavoriteListParser)
+	_ = pugEngine.RegisterTag("favoriteStatus", tags.TagFavoriteStatusParser)
+	_ = pugEngine.RegisterTag("historyList", tags.TagHistoryListParser)
+	_ = pugEngine.RegisterTag("pointList", tags.TagPointListParser)
+	_ = pugEngine.RegisterTag("notificationCount", tags.TagNotificationCountParser)
 
 	bootstrap.viewEngine = pugEngine
 	// 模板在最后加载，避免因为模板而导致程序无法运行
@@ -0,0 +1,16 @@
+package config
+
+type PluginCommentConfig struct {
+	Open bool `json:"open"` // 开启垃圾评论过滤
+	// 规则评分，每命中一项增加对应的分数
+	MaxLinks      int    `json:"max_links"`      // 评论中最多允许的链接数量，超过时计入垃圾分数，0 为不检查
+	SpamKeywords  string `json:"spam_keywords"`  // 垃圾关键词，一行一个，命中时计入垃圾分数
+	HoneypotField string `json:"honeypot_field"` // 蜜罐字段名，表单中隐藏该字段，机器人填写后直接判定为垃圾评论
+	UseBayes      bool   `json:"use_bayes"`      // 使用后台审核结果训练的贝叶斯分类器
+	// 限流
+	IpHourLimit   int `json:"ip_hour_limit"`   // 同一IP每小时最多发布的评论数，0 为不限制
+	UserHourLimit int `json:"user_hour_limit"` // 同一用户每小时最多发布的评论数，0 为不限制
+	// 自动处理的阈值，分数为 0-100
+	HoldScore int `json:"hold_score"` // 达到该分数时进入待审核，低于该分数的评论直接通过
+	SpamScore int `json:"spam_score"` // 达到该分数时直接标记为垃圾评论
+}
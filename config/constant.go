@@ -94,6 +94,34 @@ const (
 	WithdrawStatusCanceled = -1
 )
 
+const (
+	PointActionRegister = "register" // 注册
+	PointActionLogin    = "login"    // 每日登录
+	PointActionComment  = "comment"  // 评论审核通过
+	PointActionPublish  = "publish"  // 投稿发布
+	PointActionOrder    = "order"    // 订单支付
+	PointActionDeduct   = "deduct"   // 下单抵扣
+	PointActionUnlock   = "unlock"   // 解锁文档
+	PointActionRefund   = "refund"   // 订单取消或退款退回
+	PointActionAdmin    = "admin"    // 后台调整
+)
+
+const (
+	MembershipActionNew       = "new"       // 开通
+	MembershipActionRenew     = "renew"     // 续费
+	MembershipActionUpgrade   = "upgrade"   // 升级
+	MembershipActionDowngrade = "downgrade" // 降级
+	MembershipActionExpire    = "expire"    // 到期
+	MembershipActionRemind    = "remind"    // 到期提醒
+	MembershipActionAdmin     = "admin"     // 后台调整
+)
+
+const (
+	UserDeletionStatusWaiting  = 0
+	UserDeletionStatusFinished = 1
+	UserDeletionStatusCanceled = -1
+)
+
 const (
 	EnginBaidu  = "baidu" // or empty
 	Engin360    = "360"
@@ -126,3 +154,89 @@ const (
 	OrderTypeGoods = "goods"
 	OrderTypeVip   = "vip"
 )
+
+// 商品交付方式，默认为实物发货
+const (
+	DeliveryTypeNormal   = ""         // 实物发货
+	DeliveryTypeDownload = "download" // 下载受保护的文件
+	DeliveryTypeLicense  = "license"  // 分配授权码/激活码
+
+	LicenseKeyStatusAvailable = 0 // 未分配
+	LicenseKeyStatusUsed      = 1 // 已分配
+)
+
+// 收货地址状态
+const (
+	OrderAddressStatusOrder = 0 // 订单使用的地址
+	OrderAddressStatusBook  = 1 // 地址簿中的地址
+)
+
+// 运费模板计费方式
+const (
+	ShippingTypeFlat     = "flat"     // 固定运费
+	ShippingTypeWeight   = "weight"   // 按重量
+	ShippingTypeQuantity = "quantity" // 按件数
+)
+
+// 用户验证方式和验证码用途
+const (
+	VerifyWayEmail = "email"
+	VerifyWayPhone = "phone"
+
+	VerifyPurposeVerify = "verify" // 验证邮箱或手机号
+	VerifyPurposeReset  = "reset"  // 重置密码
+)
+
+// 第三方登录绑定的账号类型
+const (
+	OauthTypeUser  = "user"
+	OauthTypeAdmin = "admin"
+)
+
+// 站内通知类型
+const (
+	NotificationTypeSystem   = "system"   // 后台发送的广播
+	NotificationTypeComment  = "comment"  // 评论回复
+	NotificationTypeOrder    = "order"    // 订单状态变化
+	NotificationTypeWithdraw = "withdraw" // 提现审核
+	NotificationTypeVip      = "vip"      // VIP 到期
+)
+
+// 评论树
+const (
+	CommentTreeDefaultDepth = 3  // 默认加载的层数，包含顶层评论
+	CommentTreeMaxDepth     = 10 // 最多加载的层数
+)
+
+// 跳转规则的匹配方式
+const (
+	RedirectMatchExact    = 0 // 完全匹配
+	RedirectMatchWildcard = 1 // 通配符，* 匹配任意字符，跳转链接中使用 $1、$2 引用
+	RedirectMatchRegex    = 2 // 正则表达式，跳转链接中使用 $1、$2 引用分组
+)
+
+// 搜索引擎推送队列
+const (
+	PushStatusWait   = 0 // 待推送
+	PushStatusOk     = 1 // 推送成功
+	PushStatusRetry  = 2 // 推送失败，等待重试
+	PushStatusFailed = 3 // 重试次数用完或链接被拒绝
+
+	PushActionAdd    = "add"    // 新发布
+	PushActionUpdate = "update" // 内容更新
+	PushActionDelete = "delete" // 内容删除
+
+	PushEngineBaidu = "baidu"
+	PushEngineBing  = "bing"
+	// IndexNow 引擎在队列中记录为 indexnow:名称
+	PushEngineIndexNow = "indexnow"
+)
+
+// IndexNowEngines 支持 IndexNow 协议的搜索引擎，提交到其中一个也会共享给其他引擎
+var IndexNowEngines = map[string]string{
+	"indexnow": "https://api.indexnow.org/indexnow",
+	"bing":     "https://www.bing.com/indexnow",
+	"yandex":   "https://yandex.com/indexnow",
+	"seznam":   "https://search.seznam.cz/indexnow",
+	"naver":    "https://searchadvisor.naver.com/indexnow",
+}
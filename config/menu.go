@@ -221,6 +221,24 @@ var DefaultMenuGroups = []*MenuGroup{
 				Name:     "用户组VIP",
 				Backend:  "/plugin/user/group",
 			},
+			{
+				Path:     "/plugin/oauth",
+				GroupKey: "plugin",
+				Name:     "第三方登录",
+				Backend:  "/plugin/oauth",
+			},
+			{
+				Path:     "/plugin/notification",
+				GroupKey: "plugin",
+				Name:     "站内通知",
+				Backend:  "/plugin/notification",
+			},
+			{
+				Path:     "/plugin/point",
+				GroupKey: "plugin",
+				Name:     "用户积分",
+				Backend:  "/plugin/point",
+			},
 			{
 				Path:     "/plugin/wechat",
 				GroupKey: "plugin",
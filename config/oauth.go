@@ -0,0 +1,35 @@
+package config
+
+// PluginOauthConfig 通用 OAuth2 / OpenID Connect 登录配置
+type PluginOauthConfig struct {
+	Open         bool   `json:"open"`          // 开启前台用户的第三方登录
+	AdminOpen    bool   `json:"admin_open"`    // 开启后台管理员的单点登录
+	Name         string `json:"name"`          // 登录按钮上显示的名称
+	DiscoveryUrl string `json:"discovery_url"` // OIDC 的 .well-known/openid-configuration 地址
+	ClientId     string `json:"client_id"`
+	ClientSecret string `json:"client_secret"`
+	Scopes       string `json:"scopes"` // 多个用空格分隔，默认 openid profile email
+	// 不支持自动发现的 OAuth2 服务，需要手动填写以下地址，填写后会覆盖自动发现的地址
+	AuthorizeUrl string `json:"authorize_url"`
+	TokenUrl     string `json:"token_url"`
+	UserInfoUrl  string `json:"user_info_url"`
+	Issuer       string `json:"issuer"` // id_token 的签发者，填写后会覆盖自动发现的 issuer
+	// claim 与用户字段的对应关系，支持 a.b 的形式读取嵌套字段
+	ClaimId       string `json:"claim_id"`
+	ClaimUserName string `json:"claim_user_name"`
+	ClaimRealName string `json:"claim_real_name"`
+	ClaimEmail    string `json:"claim_email"`
+	ClaimPhone    string `json:"claim_phone"`
+	ClaimAvatar   string `json:"claim_avatar"`
+	ClaimGroup    string `json:"claim_group"` // 用户组或角色的 claim，值可以是字符串或数组
+
+	AutoRegister      bool                `json:"auto_register"`       // 找不到对应用户时自动注册
+	LinkByEmail       bool                `json:"link_by_email"`       // 使用已验证的邮箱关联已有用户
+	GroupMapping      []OauthGroupMapping `json:"group_mapping"`       // claim 值对应的用户组
+	AdminGroupMapping []OauthGroupMapping `json:"admin_group_mapping"` // claim 值对应的管理员分组，匹配到时自动创建管理员
+}
+
+type OauthGroupMapping struct {
+	Value   string `json:"value"`
+	GroupId uint   `json:"group_id"`
+}
@@ -27,4 +27,24 @@ type PluginOrderConfig struct {
 	AutoFinishDay   int   `json:"auto_finish_day"`   // 自动完成订单时间
 	AutoCloseMinute int64 `json:"auto_close_minute"` // 自动关闭订单时间
 	SellerPercent   int64 `json:"seller_percent"`    // 商家销售获得收益比例
+
+	DownloadExpireHour int  `json:"download_expire_hour"` // 数字商品下载链接有效时间
+	DeliveryMail       bool `json:"delivery_mail"`        // 数字商品交付后是否发送邮件给用户
+
+	InvoicePrefix string `json:"invoice_prefix"` // 发票号前缀
+	InvoiceMail   bool   `json:"invoice_mail"`   // 支付成功后，是否发送带发票的确认邮件给用户
+
+	AddressRules []AddressRule `json:"address_rules"` // 按国家/地区设置地址必填项
+}
+
+// AddressRule 地址必填项，Country 为空的规则作为默认规则
+type AddressRule struct {
+	Country  string   `json:"country"`
+	Required []string `json:"required"` // 支持 name|phone|country|province|city|address_info|postcode
+}
+
+var DefaultAddressRules = []AddressRule{
+	{Country: "", Required: []string{"name", "phone", "address_info"}},
+	{Country: "中国", Required: []string{"name", "phone", "province", "city", "address_info"}},
+	{Country: "CN", Required: []string{"name", "phone", "province", "city", "address_info"}},
 }
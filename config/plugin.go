@@ -11,10 +11,14 @@ type CodeItem struct {
 }
 
 type PluginPushConfig struct {
-	BaiduApi string     `json:"baidu_api"`
-	BingApi  string     `json:"bing_api"`
-	JsCode   string     `json:"js_code"`
-	JsCodes  []CodeItem `json:"js_codes"`
+	BaiduApi        string     `json:"baidu_api"`
+	BingApi         string     `json:"bing_api"`
+	JsCode          string     `json:"js_code"`
+	JsCodes         []CodeItem `json:"js_codes"`
+	IndexNowKey     string     `json:"index_now_key"`     // 为空时自动生成，并在网站根目录生成验证文件
+	IndexNowEngines []string   `json:"index_now_engines"` // 推送的 IndexNow 引擎，见 IndexNowEngines
+	PushUpdate      int        `json:"push_update"`       // 1 内容修改后也推送
+	PushDelete      int        `json:"push_delete"`       // 1 内容删除或下线后推送，通知搜索引擎更新
 }
 
 type PluginSitemapConfig struct {
@@ -22,6 +26,38 @@ type PluginSitemapConfig struct {
 	Type        string `json:"type"`
 	UpdatedTime int64  `json:"updated_time"`
 	SitemapURL  string `json:"sitemap_url"`
+	SplitModule int    `json:"split_module"` // 1 文档按模型分别生成
+	SplitPage   int    `json:"split_page"`   // 1 单页面单独生成
+	// 以下仅对 xml 格式生效
+	ImageSitemap int                `json:"image_sitemap"`  // 1 写入文档的图片
+	NewsModuleId uint               `json:"news_module_id"` // 生成新闻sitemap的模型，0 不生成
+	NewsName     string             `json:"news_name"`      // 新闻发布方名称，为空时使用网站名称
+	NewsLanguage string             `json:"news_language"`
+	Rules        []SitemapRule      `json:"rules"`
+	Language     string             `json:"language"` // 当前站点的 hreflang，为空时使用网站语言
+	Alternates   []SitemapAlternate `json:"alternates"`
+}
+
+// SitemapRule 按类型设置 changefreq 和 priority
+type SitemapRule struct {
+	Type       string `json:"type"`      // index|category|page|archive|tag
+	ModuleId   uint   `json:"module_id"` // 只对 archive 生效，0 表示所有模型
+	ChangeFreq string `json:"changefreq"`
+	Priority   string `json:"priority"`
+}
+
+// SitemapAlternate 其他语言的站点，链接结构需要与当前站点一致
+type SitemapAlternate struct {
+	Language string `json:"language"`
+	BaseUrl  string `json:"base_url"`
+}
+
+type PluginBrokenLinkConfig struct {
+	AutoCheck     int   `json:"auto_check"`     // 自动扫描的间隔天数，0 不自动扫描
+	CheckExternal int   `json:"check_external"` // 1 同时检查站外链接
+	Concurrency   int   `json:"concurrency"`    // 检查站外链接的并发数
+	Timeout       int   `json:"timeout"`        // 检查站外链接的超时时间，单位秒
+	LastCheckTime int64 `json:"last_check_time"`
 }
 
 type PluginAnchorConfig struct {
@@ -0,0 +1,15 @@
+package config
+
+type PluginPointConfig struct {
+	Open bool `json:"open"`
+	// 获取积分的规则
+	RegisterPoints int64 `json:"register_points"` // 注册奖励
+	LoginPoints    int64 `json:"login_points"`    // 每天第一次登录奖励
+	CommentPoints  int64 `json:"comment_points"`  // 评论审核通过奖励
+	PublishPoints  int64 `json:"publish_points"`  // 投稿发布奖励
+	OrderPoints    int64 `json:"order_points"`    // 订单每支付1元奖励的积分
+	DailyLimit     int64 `json:"daily_limit"`     // 每天通过评论和投稿最多获得的积分，0 为不限制
+	// 使用积分
+	DeductRate    int64 `json:"deduct_rate"`    // 多少积分可以抵扣1元，0 为不能抵扣
+	DeductPercent int64 `json:"deduct_percent"` // 最多抵扣订单商品金额的百分比，订单至少需要支付一部分金额
+}
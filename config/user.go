@@ -3,4 +3,23 @@ package config
 type PluginUserConfig struct {
 	Fields         []*CustomField `json:"fields"`
 	DefaultGroupId uint           `json:"default_group_id"`
+
+	VerifyBeforeLogin bool   `json:"verify_before_login"` // 未验证邮箱或手机号的用户不能登录
+	VerifyBeforeOrder bool   `json:"verify_before_order"` // 未验证邮箱或手机号的用户不能下单
+	SmsDriver         string `json:"sms_driver"`          // 短信发送驱动，默认为 log，只记录到日志
+
+	// 登录保护
+	LoginMaxFailures   int  `json:"login_max_failures"`    // 同一账号连续失败多少次后临时锁定，默认5次
+	LoginIpMaxFailures int  `json:"login_ip_max_failures"` // 同一IP连续失败多少次后临时锁定，默认20次
+	LoginLockMinutes   int  `json:"login_lock_minutes"`    // 临时锁定的时长，默认15分钟
+	LoginLockMail      bool `json:"login_lock_mail"`       // 账号被锁定时，发送邮件通知用户
+	// 密码策略
+	PasswordMinLength   int  `json:"password_min_length"`   // 密码最小长度，默认6位
+	PasswordComplexity  int  `json:"password_complexity"`   // 密码至少需要包含几种字符：小写字母、大写字母、数字、符号
+	PasswordCheckBreach bool `json:"password_check_breach"` // 禁止使用常见的泄露密码
+	// 会员有效期
+	VipRemindDays int `json:"vip_remind_days"` // 到期前多少天提醒续费，0 为不提醒
+	VipGraceDays  int `json:"vip_grace_days"`  // 到期后的宽限天数，宽限期内保留会员组，续费时从原到期时间延长
+	// 注销账号
+	DeletionWaitDays int `json:"deletion_wait_days"` // 申请注销后等待多少天再执行，等待期间可以撤销，0 为立即执行
 }
@@ -0,0 +1,95 @@
+package controller
+
+import (
+	"github.com/kataras/iris/v12"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/provider"
+	"kandaoni.com/anqicms/request"
+)
+
+// ApiCommentTree 按评论树获取文档的评论，分页按顶层评论计算
+func ApiCommentTree(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	archiveId := uint(ctx.URLParamIntDefault("archive_id", 0))
+	order := ctx.URLParamDefault("order", "id desc")
+	if order != "id desc" && order != "id asc" && order != "vote_count desc" {
+		order = "id desc"
+	}
+	depth := ctx.URLParamIntDefault("depth", config.CommentTreeDefaultDepth)
+	replyLimit := ctx.URLParamIntDefault("reply_limit", 5)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 10)
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+	if replyLimit < 0 || replyLimit > 100 {
+		replyLimit = 5
+	}
+
+	comments, total := currentSite.GetCommentTree(archiveId, order, depth, currentPage, pageSize, replyLimit)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  comments,
+	})
+}
+
+// ApiCommentReplies 分页获取一条评论下的回复，用于评论树中加载更多
+func ApiCommentReplies(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	parentId := uint(ctx.URLParamIntDefault("parent_id", 0))
+	depth := ctx.URLParamIntDefault("depth", 1)
+	replyLimit := ctx.URLParamIntDefault("reply_limit", 5)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 10)
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 10
+	}
+	if replyLimit < 0 || replyLimit > 100 {
+		replyLimit = 5
+	}
+
+	comments, total := currentSite.GetCommentReplies(parentId, depth, currentPage, pageSize, replyLimit)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  comments,
+	})
+}
+
+// ApiCommentSubscribe 登录用户设置是否接收评论提醒邮件
+func ApiCommentSubscribe(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.CommentSubscribeRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+	err := currentSite.UnsubscribeCommentMail(userId, "", !req.Subscribe)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("保存成功"),
+	})
+}
@@ -0,0 +1,205 @@
+package controller
+
+import (
+	"github.com/kataras/iris/v12"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/provider"
+	"kandaoni.com/anqicms/request"
+)
+
+func ApiGetUserFavorites(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	archives, total := currentSite.GetUserFavoriteArchives(userId, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  archives,
+	})
+}
+
+func ApiCheckUserFavorite(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	archiveId := uint(ctx.URLParamIntDefault("archive_id", 0))
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": currentSite.CheckUserFavorite(userId, archiveId),
+	})
+}
+
+func ApiAddUserFavorite(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.UserFavoriteRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	err := currentSite.AddUserFavorite(userId, req.ArchiveId)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("收藏成功"),
+	})
+}
+
+func ApiDeleteUserFavorite(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.UserFavoriteRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+	if req.ArchiveId > 0 {
+		req.ArchiveIds = append(req.ArchiveIds, req.ArchiveId)
+	}
+	for _, archiveId := range req.ArchiveIds {
+		err := currentSite.RemoveUserFavorite(userId, archiveId)
+		if err != nil {
+			ctx.JSON(iris.Map{
+				"code": config.StatusFailed,
+				"msg":  err.Error(),
+			})
+			return
+		}
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("已取消收藏"),
+	})
+}
+
+func ApiGetUserHistories(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	histories, total := currentSite.GetUserHistories(userId, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  histories,
+	})
+}
+
+// ApiRecordUserHistory 前端上报阅读进度，用于继续阅读
+func ApiRecordUserHistory(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.UserFavoriteRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+	archive, err := currentSite.GetArchiveById(req.ArchiveId)
+	if err != nil || archive.Status != config.ContentStatusOK {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  currentSite.Lang("文档不存在"),
+		})
+		return
+	}
+	if req.Progress < 0 {
+		req.Progress = 0
+	}
+
+	currentSite.RecordUserHistory(userId, archive.Id, req.Progress)
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+	})
+}
+
+// ApiDeleteUserHistory 不传文档ID时，清空全部阅读记录
+func ApiDeleteUserHistory(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.UserFavoriteRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+	if req.ArchiveId > 0 {
+		req.ArchiveIds = append(req.ArchiveIds, req.ArchiveId)
+	}
+
+	err := currentSite.DeleteUserHistories(userId, req.ArchiveIds)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("删除成功"),
+	})
+}
+
+func ApiGetUserContinueReading(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	history, err := currentSite.GetUserContinueReading(userId)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  currentSite.Lang("没有可以继续阅读的内容"),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": history,
+	})
+}
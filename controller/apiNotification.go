@@ -0,0 +1,75 @@
+package controller
+
+import (
+	"github.com/kataras/iris/v12"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/provider"
+	"kandaoni.com/anqicms/request"
+)
+
+func ApiGetNotifications(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	notifyType := ctx.URLParam("type")
+	unread := ctx.URLParamIntDefault("unread", 0) == 1
+	userInfo, _ := ctx.Values().Get("userInfo").(*model.User)
+
+	notifications, total := currentSite.GetNotificationList(userInfo, notifyType, unread, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  notifications,
+	})
+}
+
+func ApiGetUnreadNotificationCount(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	userInfo, _ := ctx.Values().Get("userInfo").(*model.User)
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": currentSite.GetUnreadNotificationCount(userInfo),
+	})
+}
+
+// ApiReadNotifications 不传ID时，全部标记为已读
+func ApiReadNotifications(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.NotificationRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if req.Id > 0 {
+		req.Ids = append(req.Ids, req.Id)
+	}
+	userInfo, _ := ctx.Values().Get("userInfo").(*model.User)
+
+	err := currentSite.ReadNotifications(userInfo, req.Ids)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+	})
+}
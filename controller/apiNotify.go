@@ -90,7 +90,10 @@ func NotifyWechatPay(ctx iris.Context) {
 
 	if refundId != "" {
 		// this is a refund order
-		refund, err := currentSite.GetOrderRefundByOrderId(order.OrderId)
+		refund, err := currentSite.GetOrderRefundByRefundId(notifyReq.GetString("out_refund_no"))
+		if err != nil {
+			refund, err = currentSite.GetOrderRefundByOrderId(order.OrderId)
+		}
 		if err == nil {
 			if notifyReq.GetString("refund_status") == "SUCCESS" {
 				//退款成功
@@ -206,7 +209,10 @@ func NotifyAlipay(ctx iris.Context) {
 	refundId := bm.GetString("refund_id")
 	if refundId != "" {
 		// this is a refund order
-		refund, err := currentSite.GetOrderRefundByOrderId(order.OrderId)
+		refund, err := currentSite.GetOrderRefundByRefundId(bm.GetString("out_refund_no"))
+		if err != nil {
+			refund, err = currentSite.GetOrderRefundByOrderId(order.OrderId)
+		}
 		if err == nil {
 			if bm.GetString("refund_status") == "SUCCESS" {
 				//退款成功
@@ -0,0 +1,98 @@
+package controller
+
+import (
+	"github.com/kataras/iris/v12"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/provider"
+	"kandaoni.com/anqicms/request"
+	"time"
+)
+
+// ApiOauthLogin 跳转到第三方平台登录，已登录的用户传 bind=1 时为绑定第三方账号
+func ApiOauthLogin(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var bindId uint
+	if ctx.URLParamIntDefault("bind", 0) == 1 {
+		bindId = ctx.Values().GetUintDefault("userId", 0)
+		if bindId == 0 {
+			ShowMessage(ctx, currentSite.Lang("请登录后再绑定"), nil)
+			return
+		}
+	}
+
+	link, nonce, err := currentSite.GetOauthAuthorizeUrl(config.OauthTypeUser, bindId, ctx.URLParam("redirect"))
+	if err != nil {
+		ShowMessage(ctx, err.Error(), nil)
+		return
+	}
+	ctx.SetCookieKV("oauth_nonce", nonce, iris.CookiePath("/api/oauth"), iris.CookieExpires(10*time.Minute))
+
+	ctx.Redirect(link, iris.StatusFound)
+}
+
+func ApiOauthCallback(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	if ctx.URLParam("error") != "" {
+		ShowMessage(ctx, currentSite.Lang("第三方登录已取消"), nil)
+		return
+	}
+	nonce := ctx.GetCookie("oauth_nonce")
+	ctx.RemoveCookie("oauth_nonce", iris.CookiePath("/api/oauth"))
+
+	user, redirect, err := currentSite.LoginViaOauth(ctx.URLParam("state"), nonce, ctx.URLParam("code"))
+	if err != nil {
+		ShowMessage(ctx, err.Error(), nil)
+		return
+	}
+	if currentSite.PluginUser.VerifyBeforeLogin && user.Verified == 0 {
+		ShowMessage(ctx, currentSite.Lang("请先完成账号验证"), nil)
+		return
+	}
+
+	ctx.SetCookieKV("token", user.Token, iris.CookiePath("/"), iris.CookieExpires(24*time.Hour))
+	if redirect == "" {
+		redirect = "/"
+	}
+
+	ctx.Redirect(currentSite.System.BaseUrl+redirect, iris.StatusFound)
+}
+
+func ApiGetOauthAccounts(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	accounts := currentSite.GetOauthAccounts(config.OauthTypeUser, userId)
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": accounts,
+	})
+}
+
+func ApiUnbindOauthAccount(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	userId := ctx.Values().GetUintDefault("userId", 0)
+	var req request.OauthAccountRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	err := currentSite.UnbindOauthAccount(config.OauthTypeUser, userId, req.Id)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("已解除绑定"),
+	})
+}
@@ -57,6 +57,7 @@ func ApiGetOrderDetail(ctx iris.Context) {
 		})
 		return
 	}
+	currentSite.LoadOrderDelivery(order)
 
 	ctx.JSON(iris.Map{
 		"code": config.StatusOK,
@@ -65,6 +66,26 @@ func ApiGetOrderDetail(ctx iris.Context) {
 	})
 }
 
+func ApiOrderDownload(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	orderId := ctx.URLParam("order_id")
+	detailId := uint(ctx.URLParamIntDefault("detail_id", 0))
+	expire := ctx.URLParamInt64Default("expire", 0)
+	sign := ctx.URLParam("sign")
+
+	filePath, fileName, err := currentSite.VerifyOrderDownload(orderId, detailId, expire, sign)
+	if err != nil {
+		ctx.StatusCode(iris.StatusForbidden)
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.SendFile(filePath, fileName)
+}
+
 func ApiCreateOrder(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
 	var req request.OrderRequest
@@ -93,6 +114,93 @@ func ApiCreateOrder(ctx iris.Context) {
 	})
 }
 
+// ApiOrderInvoice 下载订单发票，format 支持 pdf|html
+func ApiOrderInvoice(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	orderId := ctx.URLParam("order_id")
+	format := ctx.URLParamDefault("format", "pdf")
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	order, err := currentSite.GetOrderInfoByOrderId(orderId)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if order.UserId != userId {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  currentSite.Lang("该订单不可操作"),
+		})
+		return
+	}
+	invoice, err := currentSite.GetOrderInvoice(order)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if format == "html" {
+		content, err := currentSite.RenderOrderInvoiceHtml(order)
+		if err != nil {
+			ctx.JSON(iris.Map{
+				"code": config.StatusFailed,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		ctx.ContentType("text/html; charset=utf-8")
+		_, _ = ctx.Write(content)
+		return
+	}
+	content, err := currentSite.RenderOrderInvoicePdf(order)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	ctx.ContentType("application/pdf")
+	ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.pdf\"", invoice.InvoiceNo))
+	_, _ = ctx.Write(content)
+}
+
+// ApiOrderShippingFee 下单前预览运费
+func ApiOrderShippingFee(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.OrderRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	shippingAmount, err := currentSite.PreviewShippingFee(userId, &req)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": iris.Map{
+			"shipping_amount": shippingAmount,
+		},
+	})
+}
+
 func ApiCancelOrder(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
 	var req request.OrderRequest
@@ -138,7 +246,7 @@ func ApiCancelOrder(ctx iris.Context) {
 
 func ApiApplyRefundOrder(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
-	var req request.OrderRequest
+	var req request.OrderRefundRequest
 	if err := ctx.ReadJSON(&req); err != nil {
 		ctx.JSON(iris.Map{
 			"code": config.StatusFailed,
@@ -164,7 +272,7 @@ func ApiApplyRefundOrder(ctx iris.Context) {
 		return
 	}
 
-	err = currentSite.ApplyOrderRefund(order)
+	_, err = currentSite.ApplyOrderRefund(order, &req)
 	if err != nil {
 		ctx.JSON(iris.Map{
 			"code": config.StatusFailed,
@@ -242,6 +350,19 @@ func ApiGetOrderAddress(ctx iris.Context) {
 	})
 }
 
+func ApiGetOrderAddressList(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	addresses := currentSite.GetOrderAddressList(userId)
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  nil,
+		"data": addresses,
+	})
+}
+
 func ApiSaveOrderAddress(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
 	var req request.OrderAddressRequest
@@ -254,7 +375,7 @@ func ApiSaveOrderAddress(ctx iris.Context) {
 	}
 	userId := ctx.Values().GetUintDefault("userId", 0)
 
-	address, err := currentSite.GetOrderAddressByUserId(userId)
+	address, err := currentSite.SaveOrderAddress(currentSite.DB, userId, &req)
 	if err != nil {
 		ctx.JSON(iris.Map{
 			"code": config.StatusFailed,
@@ -270,6 +391,60 @@ func ApiSaveOrderAddress(ctx iris.Context) {
 	})
 }
 
+func ApiDeleteOrderAddress(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.OrderAddressRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	err := currentSite.DeleteOrderAddress(userId, req.Id)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("已删除"),
+	})
+}
+
+func ApiSetDefaultOrderAddress(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.OrderAddressRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	err := currentSite.SetDefaultOrderAddress(userId, req.Id)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("已设为默认地址"),
+	})
+}
+
 func ApiCreateOrderPayment(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
 	var req request.PaymentRequest
@@ -567,7 +742,7 @@ func ApiArchiveOrderCheck(ctx iris.Context) {
 			"data": false,
 		})
 	}
-	if archiveDetail.Price == 0 && archiveDetail.ReadLevel == 0 {
+	if archiveDetail.Price == 0 && archiveDetail.ReadLevel == 0 && archiveDetail.ReadPoints == 0 {
 		archiveDetail.HasOrdered = true
 	}
 	if userId > 0 {
@@ -583,6 +758,9 @@ func ApiArchiveOrderCheck(ctx iris.Context) {
 				archiveDetail.HasOrdered = true
 			}
 		}
+		if archiveDetail.ReadPoints > 0 && !archiveDetail.HasOrdered {
+			archiveDetail.HasOrdered = currentSite.CheckArchivePointUnlocked(userId, archiveDetail.Id)
+		}
 	}
 
 	ctx.JSON(iris.Map{
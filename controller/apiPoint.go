@@ -0,0 +1,70 @@
+package controller
+
+import (
+	"github.com/kataras/iris/v12"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/provider"
+	"kandaoni.com/anqicms/request"
+)
+
+// ApiGetUserPoints 积分明细，同时返回当前积分
+func ApiGetUserPoints(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	action := ctx.URLParam("action")
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	userInfo := ctx.Values().Get("userInfo").(*model.User)
+
+	points, total := currentSite.GetUserPointList(userInfo.Id, action, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":   config.StatusOK,
+		"msg":    "",
+		"total":  total,
+		"points": userInfo.Points,
+		"data":   points,
+	})
+}
+
+// ApiUnlockArchiveByPoints 使用积分解锁文档
+func ApiUnlockArchiveByPoints(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.UserPointRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+	archive, err := currentSite.GetArchiveById(req.ArchiveId)
+	if err != nil || archive.Status != config.ContentStatusOK {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  currentSite.Lang("文档不存在"),
+		})
+		return
+	}
+
+	err = currentSite.UnlockArchiveByPoints(userId, archive)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("解锁成功"),
+	})
+}
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"encoding/json"
 	"fmt"
 	"gorm.io/gorm"
 	"math"
@@ -38,7 +39,7 @@ func ApiArchiveDetail(ctx iris.Context) {
 
 	userId := ctx.Values().GetUintDefault("userId", 0)
 	// if read level larger than 0, then need to check permission
-	if archive.Price == 0 && archive.ReadLevel == 0 {
+	if archive.Price == 0 && archive.ReadLevel == 0 && archive.ReadPoints == 0 {
 		archive.HasOrdered = true
 	}
 	if userId > 0 {
@@ -59,12 +60,19 @@ func ApiArchiveDetail(ctx iris.Context) {
 				archive.HasOrdered = true
 			}
 		}
+		if archive.ReadPoints > 0 && !archive.HasOrdered {
+			archive.HasOrdered = currentSite.CheckArchivePointUnlocked(userId, archive.Id)
+		}
 	}
 	// if read level larger than 0, then need to check permission
 	if archive.ReadLevel > 0 && !archive.HasOrdered {
 		archive.ArchiveData = &model.ArchiveData{
 			Content: fmt.Sprintf(currentSite.Lang("该内容需要用户等级%d以上才能阅读"), archive.ReadLevel),
 		}
+	} else if archive.ReadPoints > 0 && !archive.HasOrdered {
+		archive.ArchiveData = &model.ArchiveData{
+			Content: fmt.Sprintf(currentSite.Lang("该内容需要%d积分解锁后才能阅读"), archive.ReadPoints),
+		}
 	} else {
 		// 读取data
 		archive.ArchiveData, _ = currentSite.GetArchiveDataById(archive.Id)
@@ -417,7 +425,7 @@ func ApiArchiveParams(ctx iris.Context) {
 	archiveParams := currentSite.GetArchiveExtra(archiveDetail.ModuleId, archiveDetail.Id)
 	userId := ctx.Values().GetUintDefault("userId", 0)
 	// if read level larger than 0, then need to check permission
-	if archiveDetail.Price == 0 && archiveDetail.ReadLevel == 0 {
+	if archiveDetail.Price == 0 && archiveDetail.ReadLevel == 0 && archiveDetail.ReadPoints == 0 {
 		archiveDetail.HasOrdered = true
 	}
 	if userId > 0 {
@@ -433,6 +441,9 @@ func ApiArchiveParams(ctx iris.Context) {
 				archiveDetail.HasOrdered = true
 			}
 		}
+		if archiveDetail.ReadPoints > 0 && !archiveDetail.HasOrdered {
+			archiveDetail.HasOrdered = currentSite.CheckArchivePointUnlocked(userId, archiveDetail.Id)
+		}
 	}
 	for i := range archiveParams {
 		if archiveParams[i].Value == nil || archiveParams[i].Value == "" {
@@ -567,7 +578,7 @@ func ApiCommentList(ctx iris.Context) {
 		}
 	}
 
-	commentList, total, _ := currentSite.GetCommentList(archiveId, userId, order, currentPage, limit, offset)
+	commentList, total, _ := currentSite.GetCommentList(archiveId, userId, -1, order, currentPage, limit, offset)
 
 	ctx.JSON(iris.Map{
 		"code":  config.StatusOK,
@@ -904,30 +915,44 @@ func ApiAttachmentUpload(ctx iris.Context) {
 func ApiCommentPublish(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
 	var req request.PluginComment
-	var err error
-	if err = ctx.ReadJSON(&req); err != nil {
+	// 需要同时读取蜜罐字段，因此先读取 body
+	body, err := ctx.GetBody()
+	if err == nil {
+		err = json.Unmarshal(body, &req)
+	}
+	if err != nil {
 		ctx.JSON(iris.Map{
 			"code": config.StatusFailed,
 			"msg":  err.Error(),
 		})
 		return
 	}
-
-	userId := ctx.Values().GetIntDefault("userId", 0)
-	if userId > 0 {
-		req.Status = 1
+	var extra map[string]interface{}
+	_ = json.Unmarshal(body, &extra)
+	var honeypot string
+	if val, ok := extra[currentSite.PluginComment.HoneypotField]; ok && val != nil {
+		honeypot = strings.TrimSpace(fmt.Sprintf("%v", val))
 	}
 
+	userId := ctx.Values().GetIntDefault("userId", 0)
+	// 新评论先进入待审核，开启垃圾评论过滤时由评分决定是否直接通过
+	req.Status = model.StatusWait
 	req.UserId = uint(userId)
-	if req.Ip == "" {
-		req.Ip = ctx.RemoteAddr()
-	}
+	// 频率限制按 IP 计算，不能使用客户端提交的值
+	req.Ip = ctx.RemoteAddr()
 	if req.ParentId > 0 {
 		parent, err := currentSite.GetCommentById(req.ParentId)
 		if err == nil {
 			req.ToUid = parent.UserId
 		}
 	}
+	if err = currentSite.CheckCommentSpam(&req, honeypot); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
 
 	comment, err := currentSite.SaveComment(&req)
 	if err != nil {
@@ -936,6 +961,7 @@ func ApiCommentPublish(ctx iris.Context) {
 			"code": config.StatusFailed,
 			"msg":  msg,
 		})
+		return
 	}
 
 	msg := currentSite.Lang("发布成功")
@@ -1027,6 +1053,7 @@ func ApiGuestbookForm(ctx iris.Context) {
 
 	//先填充默认字段
 	guestbook := &model.Guestbook{
+		UserId:    ctx.Values().GetUintDefault("userId", 0),
 		UserName:  result["user_name"],
 		Contact:   result["contact"],
 		Content:   result["content"],
@@ -1,6 +1,7 @@
 package controller
 
 import (
+	"errors"
 	"github.com/kataras/iris/v12"
 	"kandaoni.com/anqicms/config"
 	"kandaoni.com/anqicms/model"
@@ -39,6 +40,16 @@ func ApiRegister(ctx iris.Context) {
 		})
 		return
 	}
+	if currentSite.PluginUser.VerifyBeforeLogin {
+		// 需要先完成验证才能登录
+		user.Token = ""
+		ctx.JSON(iris.Map{
+			"code": config.StatusOK,
+			"msg":  currentSite.Lang("注册成功，请查收验证码完成验证"),
+			"data": user,
+		})
+		return
+	}
 
 	// set token to cookie
 	t := iris.CookieExpires(24 * time.Hour)
@@ -141,15 +152,25 @@ func ApiLogin(ctx iris.Context) {
 			return
 		}
 
+		// 检查账号和IP是否被临时锁定
+		if err = currentSite.CheckUserLoginLimit(req.UserName, ctx.RemoteAddr()); err != nil {
+			ctx.JSON(iris.Map{
+				"code": config.StatusFailed,
+				"msg":  err.Error(),
+			})
+			return
+		}
 		//开始登录用户
 		user, err = currentSite.LoginViaPassword(&req)
 		if err != nil {
+			currentSite.RecordUserLoginFailure(req.UserName, ctx.RemoteAddr())
 			ctx.JSON(iris.Map{
 				"code": config.StatusFailed,
 				"msg":  currentSite.Lang("登录失败"),
 			})
 			return
 		}
+		currentSite.ClearUserLoginFailure(req.UserName)
 	}
 
 	if user == nil {
@@ -159,6 +180,14 @@ func ApiLogin(ctx iris.Context) {
 		})
 		return
 	}
+	// 所有登录方式都需要先完成账号验证
+	if currentSite.PluginUser.VerifyBeforeLogin && user.Verified == 0 {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  currentSite.Lang("请先完成账号验证"),
+		})
+		return
+	}
 
 	// set token to cookie
 	t := iris.CookieExpires(24 * time.Hour)
@@ -268,6 +297,7 @@ func ApiGetUserGroupDetail(ctx iris.Context) {
 			if discount > 0 {
 				group.FavorablePrice = group.Price * discount / 100
 			}
+			group.ProrateAmount = currentSite.GetVipOrderProration(userInfo, group)
 		}
 	}
 
@@ -278,6 +308,29 @@ func ApiGetUserGroupDetail(ctx iris.Context) {
 	})
 }
 
+// ApiGetUserMemberships 会员开通、续费、升级等记录
+func ApiGetUserMemberships(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 || pageSize > 100 {
+		pageSize = 20
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	memberships, total := currentSite.GetUserMemberships(userId, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  memberships,
+	})
+}
+
 func ApiUpdateUserPassword(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
 	var req request.UserPasswordRequest
@@ -290,10 +343,10 @@ func ApiUpdateUserPassword(ctx iris.Context) {
 	}
 	req.Password = strings.TrimSpace(req.Password)
 	req.OldPassword = strings.TrimSpace(req.OldPassword)
-	if len(req.Password) < 6 {
+	if err := currentSite.CheckPasswordPolicy(req.Password); err != nil {
 		ctx.JSON(iris.Map{
 			"code": config.StatusFailed,
-			"msg":  currentSite.Lang("请填写6位以上的密码"),
+			"msg":  err.Error(),
 		})
 		return
 	}
@@ -332,3 +385,187 @@ func ApiUpdateUserPassword(ctx iris.Context) {
 		"msg":  currentSite.Lang("密码修改成功"),
 	})
 }
+
+// ApiSendUserVerify 发送账号验证码，已登录时发送给当前用户，未登录时发送给 account 对应的用户
+func ApiSendUserVerify(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.ApiUserVerifyRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	user, err := getVerifyUser(ctx, &req)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if user == nil || (user.Verified == 1 && ctx.Values().GetUintDefault("userId", 0) == 0) {
+		// 未登录时不透露账号是否存在
+		ctx.JSON(iris.Map{
+			"code": config.StatusOK,
+			"msg":  currentSite.Lang("如果该账号存在，验证码已发送"),
+		})
+		return
+	}
+	if user.Verified == 1 {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  currentSite.Lang("该账号已验证"),
+		})
+		return
+	}
+
+	err = currentSite.SendUserVerification(user, req.Way)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("验证码已发送"),
+	})
+}
+
+func ApiUserVerify(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.ApiUserVerifyRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	user, err := getVerifyUser(ctx, &req)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if user == nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  currentSite.Lang("验证码不正确"),
+		})
+		return
+	}
+
+	err = currentSite.VerifyUser(user, req.Way, strings.TrimSpace(req.Code))
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("验证成功"),
+	})
+}
+
+// ApiUserVerifyLink 邮件中的验证链接，验证成功后跳转到首页
+func ApiUserVerifyLink(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	userId := uint(ctx.URLParamIntDefault("user_id", 0))
+	expire := ctx.URLParamInt64Default("expire", 0)
+	sign := ctx.URLParam("sign")
+
+	err := currentSite.VerifyUserLink(userId, expire, sign)
+	if err != nil {
+		ctx.StatusCode(iris.StatusBadRequest)
+		ctx.WriteString(err.Error())
+		return
+	}
+
+	ctx.Redirect(currentSite.System.BaseUrl+"/", iris.StatusFound)
+}
+
+func ApiPasswordResetRequest(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.ApiPasswordResetRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	err := currentSite.SendPasswordReset(strings.TrimSpace(req.Account))
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("如果该账号存在，验证码已发送"),
+	})
+}
+
+func ApiPasswordResetConfirm(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.ApiPasswordResetRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	err := currentSite.ResetUserPassword(strings.TrimSpace(req.Account), strings.TrimSpace(req.Code), strings.TrimSpace(req.Password))
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("密码已重置，请重新登录"),
+	})
+}
+
+// getVerifyUser 未登录时按 account 查找用户，账号不存在时返回 nil，由调用方返回与账号存在时相同的提示
+func getVerifyUser(ctx iris.Context, req *request.ApiUserVerifyRequest) (*model.User, error) {
+	currentSite := provider.CurrentSite(ctx)
+	userId := ctx.Values().GetUintDefault("userId", 0)
+	if userId > 0 {
+		return currentSite.GetUserInfoById(userId)
+	}
+	req.Account = strings.TrimSpace(req.Account)
+	if req.Account == "" {
+		return nil, errors.New(currentSite.Lang("请输入账号"))
+	}
+	user, err := currentSite.GetUserByAccount(req.Account)
+	if err != nil {
+		return nil, nil
+	}
+	if req.Account == user.Email {
+		req.Way = config.VerifyWayEmail
+	} else {
+		req.Way = config.VerifyWayPhone
+	}
+
+	return user, nil
+}
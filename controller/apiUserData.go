@@ -0,0 +1,140 @@
+package controller
+
+import (
+	"fmt"
+	"github.com/kataras/iris/v12"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/provider"
+	"kandaoni.com/anqicms/request"
+	"strings"
+	"time"
+)
+
+// ApiExportUserData 导出个人数据，format=zip 时下载压缩包，默认返回json
+func ApiExportUserData(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	userId := ctx.Values().GetUintDefault("userId", 0)
+	fileName := fmt.Sprintf("user-data-%d-%s", userId, time.Now().Format("20060102"))
+
+	if ctx.URLParam("format") == "zip" {
+		content, err := currentSite.ExportUserDataZip(userId)
+		if err != nil {
+			ctx.JSON(iris.Map{
+				"code": config.StatusFailed,
+				"msg":  err.Error(),
+			})
+			return
+		}
+		ctx.ContentType("application/zip")
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.zip\"", fileName))
+		_, _ = ctx.Write(content)
+		return
+	}
+
+	data, err := currentSite.ExportUserData(userId)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if ctx.URLParam("format") == "json" {
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.json\"", fileName))
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": data,
+	})
+}
+
+func ApiGetUserDeletion(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	deletion, err := currentSite.GetUserDeletion(userId)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusOK,
+			"msg":  "",
+			"data": nil,
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": deletion,
+	})
+}
+
+// ApiRequestUserDeletion 申请注销账号，设置过密码的用户需要验证密码
+func ApiRequestUserDeletion(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.UserDeletionRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	userId := ctx.Values().GetUintDefault("userId", 0)
+	user, err := currentSite.GetUserInfoById(userId)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  currentSite.Lang("请登录"),
+		})
+		return
+	}
+	if user.Password != "" && !user.CheckPassword(strings.TrimSpace(req.Password)) {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  currentSite.Lang("密码错误"),
+		})
+		return
+	}
+
+	deletion, err := currentSite.RequestUserDeletion(user, strings.TrimSpace(req.Reason))
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	msg := currentSite.Lang("已提交注销申请")
+	if deletion.Status == config.UserDeletionStatusFinished {
+		msg = currentSite.Lang("账号已注销")
+		ctx.RemoveCookie("token", iris.CookiePath("/"))
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  msg,
+		"data": deletion,
+	})
+}
+
+func ApiCancelUserDeletion(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	userId := ctx.Values().GetUintDefault("userId", 0)
+
+	err := currentSite.CancelUserDeletion(userId)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  currentSite.Lang("已撤销注销申请"),
+	})
+}
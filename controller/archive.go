@@ -94,9 +94,15 @@ func ArchiveDetail(ctx iris.Context) {
 				archive.HasOrdered = true
 			}
 		}
+		if archive.ReadPoints > 0 && !archive.HasOrdered {
+			archive.HasOrdered = currentSite.CheckArchivePointUnlocked(userId, archive.Id)
+		}
 	}
 
 	_ = archive.AddViews(currentSite.DB)
+	if userId > 0 {
+		currentSite.RecordUserView(userId, archive.Id)
+	}
 
 	if webInfo, ok := ctx.Value("webInfo").(*response.WebInfo); ok {
 		webInfo.Title = archive.Title
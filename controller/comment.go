@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/kataras/iris/v12"
 	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
 	"kandaoni.com/anqicms/provider"
 	"kandaoni.com/anqicms/request"
 	"kandaoni.com/anqicms/response"
@@ -25,27 +26,21 @@ func CommentPublish(ctx iris.Context) {
 		return
 	}
 
-	//登录状态的用户，发布不进审核，否则进审核
-	status := uint(0)
 	userId := ctx.Values().GetIntDefault("adminId", 0)
-	if userId > 0 {
-		status = 1
-	}
 
 	var req request.PluginComment
 	// 采用post接收
 	req.ArchiveId = uint(ctx.PostValueIntDefault("archive_id", 0))
 	req.UserName = ctx.PostValueTrim("user_name")
-	req.Ip = ctx.PostValueTrim("ip")
 	req.Content = ctx.PostValueTrim("content")
 	req.ParentId = uint(ctx.PostValueIntDefault("parent_id", 0))
 	req.ToUid = uint(ctx.PostValueIntDefault("to_uid", 0))
 
-	req.Status = status
+	// 新评论先进入待审核，开启垃圾评论过滤时由评分决定是否直接通过
+	req.Status = model.StatusWait
 	req.UserId = uint(userId)
-	if req.Ip == "" {
-		req.Ip = ctx.RemoteAddr()
-	}
+	// 频率限制按 IP 计算，不能使用客户端提交的值
+	req.Ip = ctx.RemoteAddr()
 	if req.ParentId > 0 {
 		parent, err := currentSite.GetCommentById(req.ParentId)
 		if err == nil {
@@ -53,6 +48,18 @@ func CommentPublish(ctx iris.Context) {
 		}
 	}
 
+	if err := currentSite.CheckCommentSpam(&req, ctx.PostValueTrim(currentSite.PluginComment.HoneypotField)); err != nil {
+		if returnType == "json" {
+			ctx.JSON(iris.Map{
+				"code": config.StatusFailed,
+				"msg":  err.Error(),
+			})
+		} else {
+			ShowMessage(ctx, err.Error(), nil)
+		}
+		return
+	}
+
 	comment, err := currentSite.SaveComment(&req)
 	if err != nil {
 		msg := currentSite.Lang("保存失败")
@@ -164,3 +171,21 @@ func CommentList(ctx iris.Context) {
 		ctx.Values().Set("message", err.Error())
 	}
 }
+
+// CommentUnsubscribe 评论提醒邮件中的退订链接
+func CommentUnsubscribe(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	userId := uint(ctx.URLParamIntDefault("uid", 0))
+	sign := ctx.URLParam("sign")
+	if sign == "" {
+		ShowMessage(ctx, currentSite.Lang("退订链接无效"), nil)
+		return
+	}
+	err := currentSite.UnsubscribeCommentMail(userId, sign, true)
+	if err != nil {
+		ShowMessage(ctx, err.Error(), nil)
+		return
+	}
+
+	ShowMessage(ctx, currentSite.Lang("已退订评论提醒邮件"), nil)
+}
@@ -12,7 +12,6 @@ import (
 	"kandaoni.com/anqicms/response"
 	"net/url"
 	"os"
-	"regexp"
 	"strings"
 	"time"
 	"unicode/utf8"
@@ -47,6 +46,32 @@ func NotFound(ctx iris.Context) {
 	}
 }
 
+// Gone 已永久删除的页面，模板中没有 410 页面时显示提示信息
+func Gone(ctx iris.Context) {
+	webInfo := &response.WebInfo{}
+	currentSite := provider.CurrentSite(ctx)
+	if currentSite != nil {
+		webInfo.Title = currentSite.Lang("410 Gone")
+	} else {
+		webInfo.Title = "410 Gone"
+	}
+	ctx.ViewData("webInfo", webInfo)
+
+	tplName := ""
+	if ViewExists(ctx, "errors_410.html") {
+		tplName = "errors_410.html"
+	} else if ViewExists(ctx, "errors/410.html") {
+		tplName = "errors/410.html"
+	}
+	ctx.StatusCode(410)
+	if tplName != "" {
+		if err := ctx.View(GetViewPath(ctx, tplName)); err == nil {
+			return
+		}
+	}
+	ShowMessage(ctx, "410 Gone", nil)
+}
+
 func ShowMessage(ctx iris.Context, message string, buttons []Button) {
 	currentSite := provider.CurrentSite(ctx)
 	var lang func(str string) string
@@ -286,7 +311,8 @@ func FileServe(ctx iris.Context) bool {
 }
 
 func ReRouteContext(ctx iris.Context) {
-	params, _ := parseRoute(ctx)
+	currentSite := provider.CurrentSite(ctx)
+	params, _ := currentSite.ParseRoute(ctx.Params().Get("path"))
 	defer LogAccess(ctx)
 	// 先验证文件是否真的存在，如果存在，则fileServe
 	exists := FileServe(ctx)
@@ -335,196 +361,6 @@ func ReRouteContext(ctx iris.Context) {
 	NotFound(ctx)
 }
 
-func parseRoute(ctx iris.Context) (map[string]string, bool) {
-	currentSite := provider.CurrentSite(ctx)
-	//这里总共有6条正则规则，需要逐一匹配
-	// 由于用户可能会采用相同的配置，因此这里需要尝试多次读取
-	matchMap := map[string]string{}
-	paramValue := ctx.Params().Get("path")
-	paramValue = strings.TrimLeft(strings.TrimPrefix(paramValue, strings.Trim(currentSite.BaseURI, "/")), "/")
-	// index
-	if paramValue == "" {
-		matchMap["match"] = "index"
-		return matchMap, true
-	}
-	// 静态资源直接返回
-	if strings.HasPrefix(paramValue, "uploads/") ||
-		strings.HasPrefix(paramValue, "static/") ||
-		strings.HasPrefix(paramValue, "system/") {
-		return matchMap, true
-	}
-	// 如果匹配到固化链接，则直接返回
-	archiveId := currentSite.GetFixedLinkFromCache("/" + paramValue)
-	if archiveId > 0 {
-		matchMap["match"] = "archive"
-		matchMap["id"] = fmt.Sprintf("%d", archiveId)
-		return matchMap, true
-	}
-	// 搜索
-	if paramValue == "search" {
-		matchMap["match"] = "search"
-		return matchMap, true
-	}
-	rewritePattern := currentSite.ParsePatten(false)
-	//archivePage
-	reg := regexp.MustCompile(rewritePattern.ArchiveIndexRule)
-	match := reg.FindStringSubmatch(paramValue)
-	if len(match) > 0 {
-		matchMap["match"] = "archiveIndex"
-		for i, v := range match {
-			key := rewritePattern.ArchiveIndexTags[i]
-			if i == 0 {
-				key = "route"
-			}
-			matchMap[key] = v
-		}
-		// 这个规则可能与下面的冲突，因此检查一遍
-		module := currentSite.GetModuleFromCacheByToken(matchMap["module"])
-		if module != nil {
-			return matchMap, true
-		}
-		matchMap = map[string]string{}
-	}
-	// people
-	reg = regexp.MustCompile("people/([\\d]+).html")
-	match = reg.FindStringSubmatch(paramValue)
-
-	if len(match) > 1 {
-		matchMap["match"] = "user"
-		for i, v := range match {
-			key := "id"
-			if i == 0 {
-				key = "route"
-			}
-			matchMap[key] = v
-		}
-		return matchMap, true
-	}
-	//tagIndex
-	reg = regexp.MustCompile(rewritePattern.TagIndexRule)
-	match = reg.FindStringSubmatch(paramValue)
-	if len(match) > 1 {
-		matchMap["match"] = "tagIndex"
-		for i, v := range match {
-			key := rewritePattern.TagIndexTags[i]
-			if i == 0 {
-				key = "route"
-			}
-			matchMap[key] = v
-		}
-		return matchMap, true
-	}
-	//tag
-	reg = regexp.MustCompile(rewritePattern.TagRule)
-	match = reg.FindStringSubmatch(paramValue)
-	if len(match) > 1 {
-		matchMap["match"] = "tag"
-		for i, v := range match {
-			key := rewritePattern.TagTags[i]
-			if i == 0 {
-				key = "route"
-			}
-			matchMap[key] = v
-		}
-		return matchMap, true
-	}
-	//page
-	reg = regexp.MustCompile(rewritePattern.PageRule)
-	match = reg.FindStringSubmatch(paramValue)
-	if len(match) > 1 {
-		matchMap["match"] = "page"
-		for i, v := range match {
-			key := rewritePattern.PageTags[i]
-			if i == 0 {
-				key = "route"
-			}
-			matchMap[key] = v
-		}
-		if matchMap["filename"] != "" {
-			// 这个规则可能与下面的冲突，因此检查一遍
-			category := currentSite.GetCategoryFromCacheByToken(matchMap["filename"])
-			if category != nil && category.Type == config.CategoryTypePage {
-				return matchMap, true
-			}
-		} else {
-			return matchMap, true
-		}
-		matchMap = map[string]string{}
-	}
-	//category
-	reg = regexp.MustCompile(rewritePattern.CategoryRule)
-	match = reg.FindStringSubmatch(paramValue)
-	if len(match) > 1 {
-		matchMap["match"] = "category"
-		for i, v := range match {
-			key := rewritePattern.CategoryTags[i]
-			if i == 0 {
-				key = "route"
-			}
-			matchMap[key] = v
-		}
-		if matchMap["catname"] != "" {
-			matchMap["filename"] = matchMap["catname"]
-		}
-		if matchMap["multicatname"] != "" {
-			chunkCatNames := strings.Split(matchMap["multicatname"], "/")
-			matchMap["filename"] = chunkCatNames[len(chunkCatNames)-1]
-		}
-		if matchMap["module"] != "" {
-			// 需要先验证是否是module
-			module := currentSite.GetModuleFromCacheByToken(matchMap["module"])
-			if module != nil {
-				if matchMap["filename"] != "" {
-					// 这个规则可能与下面的冲突，因此检查一遍
-					category := currentSite.GetCategoryFromCacheByToken(matchMap["filename"])
-					if category != nil && category.Type != config.CategoryTypePage {
-						return matchMap, true
-					}
-				} else {
-					return matchMap, true
-				}
-			}
-		} else {
-			if matchMap["filename"] != "" {
-				// 这个规则可能与下面的冲突，因此检查一遍
-				category := currentSite.GetCategoryFromCacheByToken(matchMap["filename"])
-				if category != nil && category.Type != config.CategoryTypePage {
-					return matchMap, true
-				}
-			} else {
-				return matchMap, true
-			}
-		}
-		matchMap = map[string]string{}
-	}
-	//最后archive
-	reg = regexp.MustCompile(rewritePattern.ArchiveRule)
-	match = reg.FindStringSubmatch(paramValue)
-	if len(match) > 1 {
-		matchMap["match"] = "archive"
-		for i, v := range match {
-			key := rewritePattern.ArchiveTags[i]
-			if i == 0 {
-				key = "route"
-			}
-			matchMap[key] = v
-		}
-		if matchMap["module"] != "" {
-			// 需要先验证是否是module
-			module := currentSite.GetModuleFromCacheByToken(matchMap["module"])
-			if module != nil {
-				return matchMap, true
-			}
-		} else {
-			return matchMap, true
-		}
-	}
-
-	//不存在，定义到notfound
-	matchMap["match"] = "notfound"
-	return matchMap, true
-}
-
 // GetViewPath
 // 区分mobile的模板和pc的模板
 func GetViewPath(ctx iris.Context, tplName string) string {
@@ -645,7 +481,13 @@ func LogAccess(ctx iris.Context) {
 		currentPath = currentPath[:250]
 	}
 
+	referer := ctx.GetHeader("Referer")
+	if len(referer) > 250 {
+		referer = referer[:250]
+	}
+
 	statistic := &model.Statistic{
+		Referer:   referer,
 		Spider:    spider,
 		Host:      ctx.Request().Host,
 		Url:       currentPath,
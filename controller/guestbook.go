@@ -99,6 +99,7 @@ func GuestbookForm(ctx iris.Context) {
 
 	//先填充默认字段
 	guestbook := &model.Guestbook{
+		UserId:    ctx.Values().GetUintDefault("userId", 0),
 		UserName:  req["user_name"],
 		Contact:   req["contact"],
 		Content:   req["content"],
@@ -86,6 +86,9 @@ func ArchiveList(ctx iris.Context) {
 			}
 		}
 	}
+	if !recycle {
+		currentSite.AttachArchiveSeoAudits(archives)
+	}
 
 	ctx.JSON(iris.Map{
 		"code":  config.StatusOK,
@@ -447,3 +450,131 @@ func UpdateArchiveCategory(ctx iris.Context) {
 		"msg":  "文章已更新",
 	})
 }
+
+// ArchiveSimilar 按文档ID或者内容查找相似的文档
+func ArchiveSimilar(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.ArchiveSimilarRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	list, err := currentSite.SearchSimilarArchives(req.Id, req.Title, req.Content, req.Similarity)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": list,
+	})
+}
+
+// ArchiveDuplicateGroups 相似文档分组，similarity 是相似度百分比，默认90
+func ArchiveDuplicateGroups(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	similarity := ctx.URLParamIntDefault("similarity", provider.DuplicateDefaultSimilarity)
+
+	groups, total := currentSite.GetDuplicateGroups(similarity, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  groups,
+	})
+}
+
+// ArchiveDuplicateResolve 处理重复文档，保留一篇，其他的删除并跳转到保留的文档
+func ArchiveDuplicateResolve(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.ArchiveDuplicateRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	total, err := currentSite.ResolveDuplicateArchives(req.KeepId, req.Ids, req.Action)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("处理重复文档：%s %v => %d", req.Action, req.Ids, req.KeepId))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  fmt.Sprintf("已处理 %d 篇文档", total),
+	})
+}
+
+// ArchiveExtract 根据标题和正文生成关键词、描述，并从标签库中推荐标签
+func ArchiveExtract(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.ArchiveExtractRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	result := currentSite.ExtractArchive(req.Title, req.Content)
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": result,
+	})
+}
+
+// ArchiveExtractRegenerate 批量重新生成关键词、描述和标签，不指定 ids 时在后台处理全部文档
+func ArchiveExtractRegenerate(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.ArchiveExtractRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	total, err := currentSite.RegenerateArchives(&req)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("重新生成文档关键词和描述：%v %v", req.Fields, req.Ids))
+
+	msg := "已开始在后台生成"
+	if len(req.Ids) > 0 {
+		msg = fmt.Sprintf("已处理 %d 篇文档", total)
+	}
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  msg,
+	})
+}
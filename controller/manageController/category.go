@@ -140,6 +140,13 @@ func CategoryDelete(ctx iris.Context) {
 
 	currentSite.DeleteCacheCategories()
 	currentSite.DeleteCacheIndex()
+	link := currentSite.GetUrl("category", category, 0)
+	if currentSite.PluginSitemap.AutoBuild == 1 {
+		_ = currentSite.RemoveSitemap("category", link)
+	}
+	go currentSite.PushChangedLinks(config.PushActionDelete, link)
+	currentSite.DeleteSeoAudit("category", category.Id)
+	currentSite.DeleteInternalLinks("category", category.Id)
 
 	ctx.JSON(iris.Map{
 		"code": config.StatusOK,
@@ -326,3 +326,94 @@ func PluginAnchorSettingForm(ctx iris.Context) {
 		"msg":  "配置已更新",
 	})
 }
+
+// PluginAnchorOrphans 没有站内链接指向的文档
+func PluginAnchorOrphans(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	moduleId := uint(ctx.URLParamIntDefault("module_id", 0))
+
+	archives, total := currentSite.GetOrphanArchives(moduleId, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  archives,
+	})
+}
+
+// PluginAnchorLinked 入链最多的页面
+func PluginAnchorLinked(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	targetType := ctx.URLParam("target_type")
+
+	targets, total := currentSite.GetMostLinkedTargets(targetType, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  targets,
+	})
+}
+
+// PluginAnchorDistribution 指向某个链接的锚文字分布
+func PluginAnchorDistribution(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	targetUrl := ctx.URLParam("url")
+	if targetUrl == "" {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  "请填写链接",
+		})
+		return
+	}
+
+	anchors := currentSite.GetAnchorDistribution(targetUrl)
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": anchors,
+	})
+}
+
+// PluginAnchorOveruse 内链数量超过锚文本密度的页面
+func PluginAnchorOveruse(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+
+	sources, total := currentSite.GetOverOptimizedSources(currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  sources,
+	})
+}
+
+// PluginAnchorRebuildLinks 重建全站的站内链接关系，在后台进行
+func PluginAnchorRebuildLinks(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	err := currentSite.StartInternalLinkRebuild()
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("重建站内链接关系"))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "已开始重建",
+	})
+}
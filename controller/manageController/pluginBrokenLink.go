@@ -0,0 +1,158 @@
+package manageController
+
+import (
+	"fmt"
+	"github.com/kataras/iris/v12"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/provider"
+	"kandaoni.com/anqicms/request"
+)
+
+// PluginBrokenLink 失效链接扫描配置和当前的扫描进度
+func PluginBrokenLink(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": iris.Map{
+			"setting": currentSite.PluginBrokenLink,
+			"status":  currentSite.GetBrokenLinkStatus(),
+		},
+	})
+}
+
+func PluginBrokenLinkForm(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req config.PluginBrokenLinkConfig
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if req.Concurrency <= 0 || req.Concurrency > 20 {
+		req.Concurrency = 5
+	}
+	if req.Timeout <= 0 || req.Timeout > 60 {
+		req.Timeout = 10
+	}
+
+	currentSite.PluginBrokenLink.AutoCheck = req.AutoCheck
+	currentSite.PluginBrokenLink.CheckExternal = req.CheckExternal
+	currentSite.PluginBrokenLink.Concurrency = req.Concurrency
+	currentSite.PluginBrokenLink.Timeout = req.Timeout
+
+	err := currentSite.SaveSettingValue(provider.BrokenLinkSettingKey, currentSite.PluginBrokenLink)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("更新失效链接扫描配置"))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "配置已更新",
+	})
+}
+
+// PluginBrokenLinkStart 手动开始扫描，扫描在后台进行，通过配置接口查看进度
+func PluginBrokenLinkStart(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	err := currentSite.StartBrokenLinkCheck()
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("开始扫描失效链接"))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "已开始扫描",
+		"data": currentSite.GetBrokenLinkStatus(),
+	})
+}
+
+func PluginBrokenLinkList(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	itemType := ctx.URLParam("item_type")
+	linkType := ctx.URLParam("link_type")
+	keyword := ctx.URLParam("keyword")
+
+	list, total := currentSite.GetBrokenLinks(itemType, linkType, keyword, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  list,
+	})
+}
+
+// PluginBrokenLinkFix 批量修复：action=remove 删除链接或图片，action=replace 替换为新的链接
+func PluginBrokenLinkFix(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.PluginBrokenLinkRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	total, err := currentSite.FixBrokenLinks(req.Ids, req.Action, req.ToUrl)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("修复失效链接：%s %d 条", req.Action, total))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  fmt.Sprintf("已处理 %d 条链接", total),
+	})
+}
+
+func PluginBrokenLinkDelete(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.PluginBrokenLinkRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	err := currentSite.DeleteBrokenLinks(req.Ids)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("删除失效链接记录：%v", req.Ids))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "已删除",
+	})
+}
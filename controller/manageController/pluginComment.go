@@ -8,13 +8,16 @@ import (
 	"kandaoni.com/anqicms/model"
 	"kandaoni.com/anqicms/provider"
 	"kandaoni.com/anqicms/request"
+	"strings"
 )
 
 func PluginCommentList(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
 	currentPage := ctx.URLParamIntDefault("current", 1)
 	pageSize := ctx.URLParamIntDefault("pageSize", 20)
-	comments, total, err := currentSite.GetCommentList(0, 0, "id desc", currentPage, pageSize, 0)
+	// 默认不显示垃圾评论，status=2 时查看垃圾评论
+	status := ctx.URLParamIntDefault("status", -1)
+	comments, total, err := currentSite.GetCommentList(0, 0, status, "id desc", currentPage, pageSize, 0)
 	if err != nil {
 		ctx.JSON(iris.Map{
 			"code": config.StatusFailed,
@@ -166,6 +169,18 @@ func PluginCommentCheck(ctx iris.Context) {
 			return
 		}
 
+		var comments []*model.Comment
+		currentSite.DB.Where("`id` IN (?)", req.Ids).Find(&comments)
+		for _, comment := range comments {
+			// 审核通过和标记为垃圾都用于训练垃圾评论分类器
+			currentSite.TrainCommentSpam(comment, req.Status)
+			comment.UpdateCommentCount(currentSite.DB)
+			if req.Status == model.StatusOk {
+				currentSite.NotifyCommentReply(comment)
+				currentSite.AwardUserPoints(comment.UserId, config.PointActionComment, comment.Id)
+			}
+		}
+
 		currentSite.AddAdminLog(ctx, fmt.Sprintf("批量审核评论：%v", req.Ids))
 
 		ctx.JSON(iris.Map{
@@ -184,7 +199,9 @@ func PluginCommentCheck(ctx iris.Context) {
 		return
 	}
 
-	if comment.Status != model.StatusOk {
+	if req.Status == model.StatusSpam {
+		comment.Status = model.StatusSpam
+	} else if comment.Status != model.StatusOk {
 		comment.Status = model.StatusOk
 	} else {
 		comment.Status = model.StatusWait
@@ -198,6 +215,12 @@ func PluginCommentCheck(ctx iris.Context) {
 		return
 	}
 
+	currentSite.TrainCommentSpam(comment, comment.Status)
+	currentSite.NotifyCommentReply(comment)
+	if comment.Status == model.StatusOk {
+		currentSite.AwardUserPoints(comment.UserId, config.PointActionComment, comment.Id)
+	}
+
 	currentSite.AddAdminLog(ctx, fmt.Sprintf("审核评论：%d", comment.Id))
 
 	ctx.JSON(iris.Map{
@@ -205,3 +228,53 @@ func PluginCommentCheck(ctx iris.Context) {
 		"msg":  "评论已更新",
 	})
 }
+
+func PluginCommentConfig(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	setting := currentSite.PluginComment
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": setting,
+	})
+}
+
+func PluginCommentConfigForm(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req config.PluginCommentConfig
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	req.HoneypotField = strings.TrimSpace(req.HoneypotField)
+	if req.HoldScore > 0 && req.SpamScore > 0 && req.HoldScore > req.SpamScore {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  "待审核分数不能大于垃圾评论分数",
+		})
+		return
+	}
+
+	currentSite.PluginComment = req
+	err := currentSite.SaveSettingValue(provider.CommentSettingKey, currentSite.PluginComment)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	// 重新加载，补全默认值
+	currentSite.LoadCommentSetting()
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("修改评论过滤配置"))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "配置已更新",
+	})
+}
@@ -0,0 +1,81 @@
+package manageController
+
+import (
+	"fmt"
+	"github.com/kataras/iris/v12"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/provider"
+	"kandaoni.com/anqicms/request"
+)
+
+func PluginNotificationList(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+
+	notifications, total := currentSite.GetBroadcastNotifications(currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  notifications,
+	})
+}
+
+func PluginNotificationBroadcast(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.NotificationRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	notification, err := currentSite.BroadcastNotification(&req)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("发送站内通知：%d => %s", notification.Id, notification.Title))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "通知已发送",
+		"data": notification,
+	})
+}
+
+func PluginNotificationDelete(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.NotificationRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	err := currentSite.DeleteBroadcastNotification(req.Id)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("删除站内通知：%d", req.Id))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "通知已删除",
+	})
+}
@@ -0,0 +1,211 @@
+package manageController
+
+import (
+	"fmt"
+	"github.com/kataras/iris/v12"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/controller"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/provider"
+	"kandaoni.com/anqicms/request"
+	"strings"
+	"time"
+)
+
+func PluginOauthConfig(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	setting := currentSite.PluginOauth
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": iris.Map{
+			"setting":            setting,
+			"redirect_uri":       currentSite.GetOauthRedirectUri(config.OauthTypeUser),
+			"admin_redirect_uri": currentSite.GetOauthRedirectUri(config.OauthTypeAdmin),
+		},
+	})
+}
+
+func PluginOauthConfigForm(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req config.PluginOauthConfig
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	req.DiscoveryUrl = strings.TrimSpace(req.DiscoveryUrl)
+	req.ClientId = strings.TrimSpace(req.ClientId)
+	req.ClientSecret = strings.TrimSpace(req.ClientSecret)
+	req.Issuer = strings.TrimSpace(req.Issuer)
+	if (req.Open || req.AdminOpen) && (req.ClientId == "" || (req.DiscoveryUrl == "" && (req.AuthorizeUrl == "" || req.TokenUrl == ""))) {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  "第三方登录配置不完整",
+		})
+		return
+	}
+
+	currentSite.PluginOauth = req
+	err := currentSite.SaveSettingValue(provider.OauthSettingKey, currentSite.PluginOauth)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	// 重新加载，补全默认值
+	currentSite.LoadOauthSetting()
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("修改第三方登录配置"))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "配置已更新",
+	})
+}
+
+// AdminOauthLogin 后台单点登录入口，跳转到第三方平台
+func AdminOauthLogin(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	link, nonce, err := currentSite.GetOauthAuthorizeUrl(config.OauthTypeAdmin, 0, "")
+	if err != nil {
+		controller.ShowMessage(ctx, err.Error(), nil)
+		return
+	}
+	ctx.SetCookieKV("admin_oauth_nonce", nonce, iris.CookiePath("/system/api/oauth"), iris.CookieExpires(10*time.Minute))
+
+	ctx.Redirect(link, iris.StatusFound)
+}
+
+func AdminOauthCallback(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	if ctx.URLParam("error") != "" {
+		controller.ShowMessage(ctx, "第三方登录已取消", nil)
+		return
+	}
+	nonce := ctx.GetCookie("admin_oauth_nonce")
+	ctx.RemoveCookie("admin_oauth_nonce", iris.CookiePath("/system/api/oauth"))
+
+	admin, err := currentSite.LoginAdminViaOauth(ctx.URLParam("state"), nonce, ctx.URLParam("code"))
+	if err != nil {
+		adminLog := model.AdminLoginLog{
+			AdminId:  0,
+			Ip:       ctx.RemoteAddr(),
+			Status:   0,
+			UserName: "oauth",
+		}
+		currentSite.DB.Create(&adminLog)
+
+		controller.ShowMessage(ctx, err.Error(), nil)
+		return
+	}
+
+	// 记录日志
+	adminLog := model.AdminLoginLog{
+		AdminId:  admin.Id,
+		Ip:       ctx.RemoteAddr(),
+		Status:   1,
+		UserName: admin.UserName,
+		Password: "",
+	}
+	currentSite.DB.Create(&adminLog)
+
+	code := currentSite.CreateAdminOauthCode(admin.Id)
+	ctx.Redirect(currentSite.GetAdminOauthLoginRedirect(code), iris.StatusFound)
+}
+
+// AdminOauthExchange 后台页面使用单点登录回调带回的一次性授权码换取 token
+func AdminOauthExchange(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.AdminOauthExchangeRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	admin, err := currentSite.ExchangeAdminOauthCode(req.Code)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "登录成功",
+		"data": admin,
+	})
+}
+
+// AdminOauthBind 已登录的管理员绑定第三方账号，返回跳转地址
+func AdminOauthBind(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	adminId := ctx.Values().GetUintDefault("adminId", 0)
+	link, nonce, err := currentSite.GetOauthAuthorizeUrl(config.OauthTypeAdmin, adminId, "")
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	ctx.SetCookieKV("admin_oauth_nonce", nonce, iris.CookiePath("/system/api/oauth"), iris.CookieExpires(10*time.Minute))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": link,
+	})
+}
+
+func AdminOauthAccounts(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	adminId := ctx.Values().GetUintDefault("adminId", 0)
+
+	accounts := currentSite.GetOauthAccounts(config.OauthTypeAdmin, adminId)
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": accounts,
+	})
+}
+
+func AdminOauthUnbind(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	adminId := ctx.Values().GetUintDefault("adminId", 0)
+	var req request.OauthAccountRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	err := currentSite.UnbindOauthAccount(config.OauthTypeAdmin, adminId, req.Id)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("解除第三方账号绑定：%d", req.Id))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "已解除绑定",
+	})
+}
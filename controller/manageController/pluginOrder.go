@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/kataras/iris/v12"
 	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
 	"kandaoni.com/anqicms/provider"
 	"kandaoni.com/anqicms/request"
 )
@@ -43,6 +44,7 @@ func PluginOrderDetail(ctx iris.Context) {
 	if order.ShareParentUserId > 0 {
 		order.ParentUser, _ = currentSite.GetUserInfoById(order.ShareParentUserId)
 	}
+	currentSite.LoadOrderDelivery(order)
 
 	ctx.JSON(iris.Map{
 		"code": config.StatusOK,
@@ -166,7 +168,19 @@ func PluginOrderSetRefund(ctx iris.Context) {
 		return
 	}
 
-	err = currentSite.SetOrderRefund(order, req.Status)
+	var refund *model.OrderRefund
+	if req.RefundId != "" {
+		refund, err = currentSite.GetOrderRefundByRefundId(req.RefundId)
+		if err != nil {
+			ctx.JSON(iris.Map{
+				"code": config.StatusFailed,
+				"msg":  err.Error(),
+			})
+			return
+		}
+	}
+
+	err = currentSite.SetOrderRefund(order, refund, req.Status)
 	if err != nil {
 		ctx.JSON(iris.Map{
 			"code": config.StatusFailed,
@@ -201,7 +215,7 @@ func PluginOrderApplyRefund(ctx iris.Context) {
 		return
 	}
 
-	err = currentSite.ApplyOrderRefund(order)
+	refund, err := currentSite.ApplyOrderRefund(order, &req)
 	if err != nil {
 		ctx.JSON(iris.Map{
 			"code": config.StatusFailed,
@@ -210,7 +224,7 @@ func PluginOrderApplyRefund(ctx iris.Context) {
 		return
 	}
 
-	err = currentSite.SetOrderRefund(order, 1)
+	err = currentSite.SetOrderRefund(order, refund, 1)
 	if err != nil {
 		ctx.JSON(iris.Map{
 			"code": config.StatusFailed,
@@ -251,6 +265,11 @@ func PluginOrderConfigForm(ctx iris.Context) {
 	currentSite.PluginOrder.AutoFinishDay = req.AutoFinishDay
 	currentSite.PluginOrder.AutoCloseMinute = req.AutoCloseMinute
 	currentSite.PluginOrder.SellerPercent = req.SellerPercent
+	currentSite.PluginOrder.DownloadExpireHour = req.DownloadExpireHour
+	currentSite.PluginOrder.DeliveryMail = req.DeliveryMail
+	currentSite.PluginOrder.InvoicePrefix = req.InvoicePrefix
+	currentSite.PluginOrder.InvoiceMail = req.InvoiceMail
+	currentSite.PluginOrder.AddressRules = req.AddressRules
 
 	err := currentSite.SaveSettingValue(provider.OrderSettingKey, currentSite.PluginOrder)
 	if err != nil {
@@ -294,3 +313,262 @@ func PluginOrderExport(ctx iris.Context) {
 		},
 	})
 }
+
+// PluginOrderInvoice 下载订单发票，format 支持 pdf|html
+func PluginOrderInvoice(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	orderId := ctx.URLParam("order_id")
+	format := ctx.URLParamDefault("format", "pdf")
+
+	order, err := currentSite.GetOrderInfoByOrderId(orderId)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	invoice, err := currentSite.GetOrderInvoice(order)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	var content []byte
+	if format == "html" {
+		content, err = currentSite.RenderOrderInvoiceHtml(order)
+	} else {
+		content, err = currentSite.RenderOrderInvoicePdf(order)
+	}
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if format == "html" {
+		ctx.ContentType("text/html; charset=utf-8")
+	} else {
+		ctx.ContentType("application/pdf")
+		ctx.Header("Content-Disposition", fmt.Sprintf("attachment; filename=\"%s.pdf\"", invoice.InvoiceNo))
+	}
+	_, _ = ctx.Write(content)
+}
+
+func PluginOrderRefundList(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	orderId := ctx.URLParam("order_id")
+	status := ctx.URLParam("status")
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+
+	refunds, total := currentSite.GetOrderRefundList(orderId, status, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  refunds,
+	})
+}
+
+func PluginOrderRefundExport(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.OrderExportRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	header, content := currentSite.ExportOrderRefunds(&req)
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("导出退款记录"))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": iris.Map{
+			"header":  header,
+			"content": content,
+		},
+	})
+}
+
+func PluginOrderDeliveryUpload(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	file, info, err := ctx.FormFile("file")
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	defer file.Close()
+
+	fileName, err := currentSite.SaveDeliveryFile(file, info)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("上传数字商品文件：%s", info.Filename))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "上传成功",
+		"data": iris.Map{
+			"delivery_file": fileName,
+		},
+	})
+}
+
+func PluginLicenseKeyList(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	archiveId := uint(ctx.URLParamIntDefault("archive_id", 0))
+	status := ctx.URLParamIntDefault("status", -1)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+
+	keys, total := currentSite.GetLicenseKeyList(archiveId, status, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  keys,
+	})
+}
+
+func PluginLicenseKeyImport(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.LicenseKeyRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	total, err := currentSite.ImportLicenseKeys(req.ArchiveId, req.Content)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("导入授权码：%d => %d", req.ArchiveId, total))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  fmt.Sprintf("成功导入了%d个授权码", total),
+	})
+}
+
+func PluginLicenseKeyDelete(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.LicenseKeyRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	err := currentSite.DeleteLicenseKeys(req.Ids)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("删除授权码：%v", req.Ids))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "已执行删除操作",
+	})
+}
+
+func PluginShippingTemplateList(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	templates := currentSite.GetShippingTemplates()
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": templates,
+	})
+}
+
+func PluginShippingTemplateForm(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.ShippingTemplateRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	template, err := currentSite.SaveShippingTemplate(&req)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("保存运费模板：%d => %s", template.Id, template.Title))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "保存成功",
+		"data": template,
+	})
+}
+
+func PluginShippingTemplateDelete(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.ShippingTemplateRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	err := currentSite.DeleteShippingTemplate(req.Id)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("删除运费模板：%d", req.Id))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "已执行删除操作",
+	})
+}
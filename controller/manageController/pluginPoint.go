@@ -0,0 +1,106 @@
+package manageController
+
+import (
+	"fmt"
+	"github.com/kataras/iris/v12"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/provider"
+	"kandaoni.com/anqicms/request"
+	"strings"
+)
+
+func PluginPointConfig(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	setting := currentSite.PluginPoint
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": setting,
+	})
+}
+
+func PluginPointConfigForm(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req config.PluginPointConfig
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.PluginPoint = req
+	err := currentSite.SaveSettingValue(provider.PointSettingKey, currentSite.PluginPoint)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	// 重新加载，补全默认值
+	currentSite.LoadPointSetting()
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("修改积分配置"))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "配置已更新",
+	})
+}
+
+func PluginPointList(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	userId := uint(ctx.URLParamIntDefault("user_id", 0))
+	action := ctx.URLParam("action")
+
+	points, total := currentSite.GetUserPointList(userId, action, currentPage, pageSize)
+	for _, v := range points {
+		user, err := currentSite.GetUserInfoById(v.UserId)
+		if err == nil {
+			v.UserName = user.UserName
+		}
+	}
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  points,
+	})
+}
+
+// PluginPointAdjust 手动增加或扣减用户积分，需要填写原因
+func PluginPointAdjust(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.UserPointRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	req.Remark = strings.TrimSpace(req.Remark)
+
+	record, err := currentSite.AdminAdjustUserPoints(&req)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("调整用户积分：%d => %d，%s", req.UserId, req.Points, req.Remark))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "积分已调整",
+		"data": record,
+	})
+}
@@ -5,8 +5,13 @@ import (
 	"github.com/kataras/iris/v12"
 	"kandaoni.com/anqicms/config"
 	"kandaoni.com/anqicms/provider"
+	"kandaoni.com/anqicms/request"
+	"regexp"
+	"strings"
 )
 
+var indexNowKeyRe = regexp.MustCompile(`^[a-zA-Z0-9-]{8,128}$`)
+
 func PluginPush(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
 	pluginPush := currentSite.PluginPush
@@ -48,9 +53,30 @@ func PluginPushForm(ctx iris.Context) {
 		return
 	}
 
+	req.IndexNowKey = strings.TrimSpace(req.IndexNowKey)
+	if req.IndexNowKey != "" && !indexNowKeyRe.MatchString(req.IndexNowKey) {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  "IndexNow密钥只能包含字母、数字和-，长度为8-128位",
+		})
+		return
+	}
+	var engines []string
+	for _, v := range req.IndexNowEngines {
+		if _, ok := config.IndexNowEngines[v]; ok {
+			engines = append(engines, v)
+		}
+	}
+	oldKey := currentSite.PluginPush.IndexNowKey
+
 	currentSite.PluginPush.BaiduApi = req.BaiduApi
 	currentSite.PluginPush.BingApi = req.BingApi
 	currentSite.PluginPush.JsCodes = req.JsCodes
+	currentSite.PluginPush.IndexNowKey = req.IndexNowKey
+	currentSite.PluginPush.IndexNowEngines = engines
+	currentSite.PluginPush.PushUpdate = req.PushUpdate
+	currentSite.PluginPush.PushDelete = req.PushDelete
+	currentSite.CheckIndexNowKey(oldKey)
 
 	err := currentSite.SaveSettingValue(provider.PushSettingKey, currentSite.PluginPush)
 	if err != nil {
@@ -69,3 +95,135 @@ func PluginPushForm(ctx iris.Context) {
 		"msg":  "配置已更新",
 	})
 }
+
+// PluginPushQueue 推送队列，每个链接在各搜索引擎的推送状态
+func PluginPushQueue(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	engine := ctx.URLParam("engine")
+	status := ctx.URLParamIntDefault("status", -1)
+	keyword := ctx.URLParam("keyword")
+
+	list, total := currentSite.GetPushQueueList(engine, status, keyword, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  list,
+	})
+}
+
+// PluginPushQueueSummary 各搜索引擎的推送统计和百度剩余配额
+func PluginPushQueueSummary(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	summary := currentSite.GetPushQueueSummary()
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": summary,
+	})
+}
+
+// PluginPushSubmit 手动提交链接到推送队列
+func PluginPushSubmit(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.PluginPushQueueRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if req.Action != config.PushActionUpdate && req.Action != config.PushActionDelete {
+		req.Action = config.PushActionAdd
+	}
+	var links []string
+	for _, v := range req.Urls {
+		v = strings.TrimSpace(v)
+		if strings.HasPrefix(v, "http") {
+			links = append(links, v)
+		}
+	}
+	if len(links) == 0 {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  "请填写需要推送的链接",
+		})
+		return
+	}
+	if len(currentSite.GetPushEngines()) == 0 {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  "没有配置推送的搜索引擎",
+		})
+		return
+	}
+	currentSite.AddPushQueue(req.Action, links...)
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("手动提交推送链接：%d 条", len(links)))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "已加入推送队列",
+	})
+}
+
+func PluginPushQueueRetry(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.PluginPushQueueRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	err := currentSite.RetryPushQueue(req.Ids)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("重新推送链接：%v", req.Ids))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "已重新加入推送队列",
+	})
+}
+
+func PluginPushQueueDelete(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.PluginPushQueueRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	err := currentSite.DeletePushQueue(req.Ids)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("删除推送记录：%v", req.Ids))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "已删除",
+	})
+}
@@ -45,6 +45,25 @@ func PluginRedirectDetailForm(ctx iris.Context) {
 		return
 	}
 
+	req.FromUrl = strings.TrimSpace(req.FromUrl)
+	req.ToUrl = strings.TrimSpace(req.ToUrl)
+	if req.StatusCode == 0 {
+		req.StatusCode = 301
+	}
+	if req.StatusCode != 301 && req.StatusCode != 302 && req.StatusCode != 307 && req.StatusCode != 308 && req.StatusCode != 410 {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  "不支持的跳转状态码",
+		})
+		return
+	}
+	if req.FromUrl == "" || (req.ToUrl == "" && req.StatusCode != 410) {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  "请填写源链接和跳转链接",
+		})
+		return
+	}
 	if req.FromUrl == req.ToUrl {
 		ctx.JSON(iris.Map{
 			"code": config.StatusFailed,
@@ -52,12 +71,22 @@ func PluginRedirectDetailForm(ctx iris.Context) {
 		})
 		return
 	}
-	if !strings.HasPrefix(req.FromUrl, "http") && !strings.HasPrefix(req.FromUrl, "/") {
+	// 正则规则保持原样，其他规则补全开头的 /
+	if req.MatchType != config.RedirectMatchRegex && !strings.HasPrefix(req.FromUrl, "http") && !strings.HasPrefix(req.FromUrl, "/") {
 		req.FromUrl = "/" + req.FromUrl
 	}
-	if !strings.HasPrefix(req.ToUrl, "http") && !strings.HasPrefix(req.ToUrl, "/") {
+	if req.ToUrl != "" && !strings.HasPrefix(req.ToUrl, "http") && !strings.HasPrefix(req.ToUrl, "/") {
 		req.ToUrl = "/" + req.ToUrl
 	}
+	if req.MatchType != config.RedirectMatchExact {
+		if _, err := provider.CompileRedirectPattern(req.FromUrl, req.MatchType); err != nil {
+			ctx.JSON(iris.Map{
+				"code": config.StatusFailed,
+				"msg":  fmt.Sprintf("规则格式错误：%s", err.Error()),
+			})
+			return
+		}
+	}
 
 	var redirect *model.Redirect
 	var err error
@@ -93,6 +122,10 @@ func PluginRedirectDetailForm(ctx iris.Context) {
 	}
 	redirect.FromUrl = req.FromUrl
 	redirect.ToUrl = req.ToUrl
+	redirect.MatchType = req.MatchType
+	redirect.StatusCode = req.StatusCode
+	redirect.KeepQuery = req.KeepQuery
+	redirect.Priority = req.Priority
 
 	err = currentSite.DB.Save(redirect).Error
 	if err != nil {
@@ -103,7 +136,7 @@ func PluginRedirectDetailForm(ctx iris.Context) {
 		return
 	}
 
-	currentSite.AddAdminLog(ctx, fmt.Sprintf("更新301跳转链接：%s => %s", redirect.FromUrl, redirect.ToUrl))
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("更新跳转链接：%s => %s (%d)", redirect.FromUrl, redirect.ToUrl, redirect.StatusCode))
 
 	currentSite.DeleteCacheRedirects()
 
@@ -183,3 +216,36 @@ func PluginRedirectImport(ctx iris.Context) {
 		"data": result,
 	})
 }
+
+// PluginRedirectTest 测试链接会匹配到哪一条跳转规则，不计入命中次数
+func PluginRedirectTest(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	link := strings.TrimSpace(ctx.URLParam("url"))
+	if link == "" {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  "请填写要测试的链接",
+		})
+		return
+	}
+	uri := provider.GetRedirectPath(link)
+	if !strings.HasPrefix(uri, "/") {
+		uri = "/" + uri
+	}
+
+	result := currentSite.MatchRedirect(uri)
+	if result == nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusOK,
+			"msg":  "没有匹配的跳转规则",
+			"data": nil,
+		})
+		return
+	}
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": result,
+	})
+}
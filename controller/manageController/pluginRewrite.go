@@ -30,6 +30,7 @@ func PluginRewriteForm(ctx iris.Context) {
 	}
 
 	if currentSite.PluginRewrite.Mode != req.Mode || currentSite.PluginRewrite.Patten != req.Patten {
+		oldSetting := currentSite.PluginRewrite
 		currentSite.PluginRewrite.Mode = req.Mode
 		currentSite.PluginRewrite.Patten = req.Patten
 		err := currentSite.SaveSettingValue(provider.RewriteSettingKey, currentSite.PluginRewrite)
@@ -43,6 +44,8 @@ func PluginRewriteForm(ctx iris.Context) {
 
 		currentSite.ParsePatten(true)
 		currentSite.DeleteCacheIndex()
+		// 按旧规则生成跳转，数据较多时需要较长时间，放到后台执行
+		go currentSite.RegenerateRedirectsFromRewrite(oldSetting)
 	}
 
 	currentSite.AddAdminLog(ctx, fmt.Sprintf("调整伪静态配置：%d", req.Mode))
@@ -52,3 +55,33 @@ func PluginRewriteForm(ctx iris.Context) {
 		"msg":  "配置已更新",
 	})
 }
+
+// PluginRewriteRedirect 按填写的旧伪静态规则，为所有链接发生变化的页面生成跳转
+func PluginRewriteRedirect(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req config.PluginRewriteConfig
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if req.Mode == currentSite.PluginRewrite.Mode && req.Patten == currentSite.PluginRewrite.Patten {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  "旧规则与当前规则相同，无需生成跳转",
+		})
+		return
+	}
+
+	// 需要遍历全站的链接，数据较多时需要较长时间，放到后台执行
+	go currentSite.RegenerateRedirectsFromRewrite(req)
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("按旧伪静态规则生成跳转：%d", req.Mode))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "正在后台生成跳转，完成后可在跳转列表中查看",
+	})
+}
@@ -5,8 +5,20 @@ import (
 	"github.com/kataras/iris/v12"
 	"kandaoni.com/anqicms/config"
 	"kandaoni.com/anqicms/provider"
+	"strconv"
+	"strings"
 )
 
+var sitemapChangeFreqs = map[string]bool{
+	"always":  true,
+	"hourly":  true,
+	"daily":   true,
+	"weekly":  true,
+	"monthly": true,
+	"yearly":  true,
+	"never":   true,
+}
+
 func PluginSitemap(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
 	pluginSitemap := currentSite.PluginSitemap
@@ -33,13 +45,16 @@ func PluginSitemapForm(ctx iris.Context) {
 		})
 		return
 	}
-	if req.Type != "xml" {
-		req.Type = "txt"
+	err := applySitemapSetting(currentSite, &req)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
 	}
-	currentSite.PluginSitemap.AutoBuild = req.AutoBuild
-	currentSite.PluginSitemap.Type = req.Type
 
-	err := currentSite.SaveSettingValue(provider.SitemapSettingKey, currentSite.PluginSitemap)
+	err = currentSite.SaveSettingValue(provider.SitemapSettingKey, currentSite.PluginSitemap)
 	if err != nil {
 		ctx.JSON(iris.Map{
 			"code": config.StatusFailed,
@@ -66,14 +81,17 @@ func PluginSitemapBuild(ctx iris.Context) {
 		})
 		return
 	}
-	if req.Type != "xml" {
-		req.Type = "txt"
-	}
 	//先保存一次
-	currentSite.PluginSitemap.AutoBuild = req.AutoBuild
-	currentSite.PluginSitemap.Type = req.Type
+	err := applySitemapSetting(currentSite, &req)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
 
-	err := currentSite.SaveSettingValue(provider.SitemapSettingKey, currentSite.PluginSitemap)
+	err = currentSite.SaveSettingValue(provider.SitemapSettingKey, currentSite.PluginSitemap)
 	if err != nil {
 		ctx.JSON(iris.Map{
 			"code": config.StatusFailed,
@@ -107,3 +125,58 @@ func PluginSitemapBuild(ctx iris.Context) {
 		"data": pluginSitemap,
 	})
 }
+
+// applySitemapSetting 校验提交的配置并写入当前站点
+func applySitemapSetting(currentSite *provider.Website, req *config.PluginSitemapConfig) error {
+	if req.Type != "xml" {
+		req.Type = "txt"
+	}
+	rules := make([]config.SitemapRule, 0, len(req.Rules))
+	for _, v := range req.Rules {
+		v.ChangeFreq = strings.TrimSpace(v.ChangeFreq)
+		v.Priority = strings.TrimSpace(v.Priority)
+		if v.ChangeFreq == "" && v.Priority == "" {
+			continue
+		}
+		if v.ChangeFreq != "" && !sitemapChangeFreqs[v.ChangeFreq] {
+			return fmt.Errorf("不支持的更新频率：%s", v.ChangeFreq)
+		}
+		if v.Priority != "" {
+			priority, err := strconv.ParseFloat(v.Priority, 64)
+			if err != nil || priority < 0 || priority > 1 {
+				return fmt.Errorf("优先级需要在0到1之间：%s", v.Priority)
+			}
+			v.Priority = strconv.FormatFloat(priority, 'f', 1, 64)
+		}
+		if v.Type != "archive" {
+			v.ModuleId = 0
+		}
+		rules = append(rules, v)
+	}
+	alternates := make([]config.SitemapAlternate, 0, len(req.Alternates))
+	for _, v := range req.Alternates {
+		v.Language = strings.TrimSpace(v.Language)
+		v.BaseUrl = strings.TrimRight(strings.TrimSpace(v.BaseUrl), "/")
+		if v.Language == "" || v.BaseUrl == "" {
+			continue
+		}
+		if !strings.HasPrefix(v.BaseUrl, "http") {
+			return fmt.Errorf("多语言站点地址需要以http开头：%s", v.BaseUrl)
+		}
+		alternates = append(alternates, v)
+	}
+
+	currentSite.PluginSitemap.AutoBuild = req.AutoBuild
+	currentSite.PluginSitemap.Type = req.Type
+	currentSite.PluginSitemap.SplitModule = req.SplitModule
+	currentSite.PluginSitemap.SplitPage = req.SplitPage
+	currentSite.PluginSitemap.ImageSitemap = req.ImageSitemap
+	currentSite.PluginSitemap.NewsModuleId = req.NewsModuleId
+	currentSite.PluginSitemap.NewsName = strings.TrimSpace(req.NewsName)
+	currentSite.PluginSitemap.NewsLanguage = strings.TrimSpace(req.NewsLanguage)
+	currentSite.PluginSitemap.Rules = rules
+	currentSite.PluginSitemap.Language = strings.TrimSpace(req.Language)
+	currentSite.PluginSitemap.Alternates = alternates
+
+	return nil
+}
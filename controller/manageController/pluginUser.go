@@ -66,6 +66,67 @@ func PluginUserFieldsSettingForm(ctx iris.Context) {
 	})
 }
 
+func PluginUserConfig(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	setting := currentSite.PluginUser
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": iris.Map{
+			"setting":     setting,
+			"sms_drivers": provider.GetSmsDrivers(),
+		},
+	})
+}
+
+func PluginUserConfigForm(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req config.PluginUserConfig
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	if req.DefaultGroupId > 0 {
+		currentSite.PluginUser.DefaultGroupId = req.DefaultGroupId
+	}
+	currentSite.PluginUser.VerifyBeforeLogin = req.VerifyBeforeLogin
+	currentSite.PluginUser.VerifyBeforeOrder = req.VerifyBeforeOrder
+	currentSite.PluginUser.SmsDriver = req.SmsDriver
+	currentSite.PluginUser.LoginMaxFailures = req.LoginMaxFailures
+	currentSite.PluginUser.LoginIpMaxFailures = req.LoginIpMaxFailures
+	currentSite.PluginUser.LoginLockMinutes = req.LoginLockMinutes
+	currentSite.PluginUser.LoginLockMail = req.LoginLockMail
+	currentSite.PluginUser.PasswordMinLength = req.PasswordMinLength
+	currentSite.PluginUser.PasswordComplexity = req.PasswordComplexity
+	currentSite.PluginUser.PasswordCheckBreach = req.PasswordCheckBreach
+	currentSite.PluginUser.DeletionWaitDays = req.DeletionWaitDays
+	currentSite.PluginUser.VipRemindDays = req.VipRemindDays
+	currentSite.PluginUser.VipGraceDays = req.VipGraceDays
+
+	err := currentSite.SaveSettingValue(provider.UserSettingKey, currentSite.PluginUser)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	// 重新加载，补全默认值
+	currentSite.LoadUserSetting()
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("更新用户配置信息"))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "配置已更新",
+	})
+}
+
 func PluginUserList(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
 	currentPage := ctx.URLParamIntDefault("current", 1)
@@ -179,6 +240,96 @@ func PluginUserDelete(ctx iris.Context) {
 	})
 }
 
+func PluginUserMembershipList(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	userId := uint(ctx.URLParamIntDefault("user_id", 0))
+
+	memberships, total := currentSite.GetUserMemberships(userId, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  memberships,
+	})
+}
+
+func PluginUserDeletionList(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	status := ctx.URLParamIntDefault("status", -2)
+
+	deletions, total := currentSite.GetUserDeletions(status, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  deletions,
+	})
+}
+
+// PluginUserDeletionExecute 不等待到期，立即执行注销申请
+func PluginUserDeletionExecute(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.UserDeletionRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	deletion, err := currentSite.GetUserDeletionById(req.Id)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  "注销申请不存在",
+		})
+		return
+	}
+
+	err = currentSite.ExecuteUserDeletion(deletion)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("执行用户注销：%d => %s", deletion.UserId, deletion.UserName))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "用户已注销",
+	})
+}
+
+// PluginUserExport 导出指定用户的数据
+func PluginUserExport(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	userId := uint(ctx.URLParamIntDefault("id", 0))
+
+	data, err := currentSite.ExportUserData(userId)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("导出用户数据：%d", userId))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": data,
+	})
+}
+
 func PluginUserGroupList(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
 	groups := currentSite.GetUserGroups()
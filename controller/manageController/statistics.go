@@ -1,11 +1,13 @@
 package manageController
 
 import (
+	"fmt"
 	"github.com/jinzhu/now"
 	"github.com/kataras/iris/v12"
 	"kandaoni.com/anqicms/config"
 	"kandaoni.com/anqicms/model"
 	"kandaoni.com/anqicms/provider"
+	"kandaoni.com/anqicms/request"
 	"kandaoni.com/anqicms/response"
 	"time"
 )
@@ -126,3 +128,183 @@ func GetSpiderInclude(ctx iris.Context) {
 		"data": result,
 	})
 }
+
+// StatisticNotFound 404链接汇总
+func StatisticNotFound(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	keyword := ctx.URLParam("keyword")
+
+	list, total := currentSite.GetNotFoundList(keyword, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  list,
+	})
+}
+
+func StatisticNotFoundDetail(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	link := ctx.URLParam("url")
+
+	detail := currentSite.GetNotFoundDetail(link)
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": detail,
+	})
+}
+
+func StatisticNotFoundIgnores(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	ignores := currentSite.GetNotFoundIgnores()
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": ignores,
+	})
+}
+
+func StatisticNotFoundIgnoreForm(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.NotFoundRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	ignore, err := currentSite.SaveNotFoundIgnore(req.Url)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("添加404忽略链接：%s", ignore.Url))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "已忽略",
+		"data": ignore,
+	})
+}
+
+func StatisticNotFoundIgnoreDelete(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.NotFoundRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	err := currentSite.DeleteNotFoundIgnore(req.Id)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("删除404忽略链接：%d", req.Id))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "已删除",
+	})
+}
+
+// StatisticNotFoundRedirect 将404链接转为跳转规则
+func StatisticNotFoundRedirect(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	var req request.NotFoundRequest
+	if err := ctx.ReadJSON(&req); err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+	if req.StatusCode == 0 {
+		req.StatusCode = 301
+	}
+
+	err := currentSite.CreateNotFoundRedirect(req.Url, req.ToUrl, req.StatusCode)
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("404链接添加跳转：%s => %s", req.Url, req.ToUrl))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "跳转规则已添加",
+	})
+}
+
+// StatisticSeoAudit 全站页面SEO检查的汇总
+func StatisticSeoAudit(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	summary := currentSite.GetSeoAuditSummary()
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "",
+		"data": summary,
+	})
+}
+
+// StatisticSeoAuditList 检查结果列表，可按内容类型和问题筛选
+func StatisticSeoAuditList(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	currentPage := ctx.URLParamIntDefault("current", 1)
+	pageSize := ctx.URLParamIntDefault("pageSize", 20)
+	itemType := ctx.URLParam("item_type")
+	issue := ctx.URLParam("issue")
+
+	list, total := currentSite.GetSeoAuditList(itemType, issue, currentPage, pageSize)
+
+	ctx.JSON(iris.Map{
+		"code":  config.StatusOK,
+		"msg":   "",
+		"total": total,
+		"data":  list,
+	})
+}
+
+// StatisticSeoAuditStart 重新检查全站，检查在后台进行
+func StatisticSeoAuditStart(ctx iris.Context) {
+	currentSite := provider.CurrentSite(ctx)
+	err := currentSite.StartSeoAudit()
+	if err != nil {
+		ctx.JSON(iris.Map{
+			"code": config.StatusFailed,
+			"msg":  err.Error(),
+		})
+		return
+	}
+
+	currentSite.AddAdminLog(ctx, fmt.Sprintf("重新检查全站页面SEO"))
+
+	ctx.JSON(iris.Map{
+		"code": config.StatusOK,
+		"msg":  "已开始检查",
+	})
+}
@@ -25,8 +25,26 @@ func Crond() {
 	crontab.AddFunc("1 * * * * *", AutoCheckOrders)
 	// 每天检查VIP
 	crontab.AddFunc("@daily", CleanUserVip)
+	// 每天上午提醒即将到期的会员
+	crontab.AddFunc("1 0 10 * * *", RemindUserVip)
+	// 每小时执行到期的注销申请
+	crontab.AddFunc("1 10 * * * *", CheckUserDeletions)
 	// 每小时检查一次账号状态
 	crontab.AddFunc("1 30 * * * *", CheckAuthValid)
+	// 每分钟处理搜索引擎推送队列
+	crontab.AddFunc("1 * * * * *", ProcessPushQueue)
+	// 每天清理推送记录
+	crontab.AddFunc("@daily", CleanPushQueue)
+	// 每小时更新新闻sitemap，移除超过48小时的文档
+	crontab.AddFunc("1 40 * * * *", BuildNewsSitemap)
+	// 每天检查是否需要自动扫描失效链接
+	crontab.AddFunc("1 20 3 * * *", CheckBrokenLinks)
+	// 每天重新检查全站的页面SEO
+	crontab.AddFunc("1 50 4 * * *", RunSeoAudit)
+	// 每周重建站内链接关系
+	crontab.AddFunc("@weekly", RebuildInternalLinks)
+	// 每天为还没有指纹的文档补充指纹
+	crontab.AddFunc("1 10 5 * * *", RebuildArchiveFingerprints)
 	crontab.Start()
 }
 
@@ -120,9 +138,99 @@ func CleanUserVip() {
 	}
 }
 
+func RemindUserVip() {
+	websites := provider.GetWebsites()
+	for _, w := range websites {
+		if !w.Initialed {
+			continue
+		}
+		w.RemindUserVip()
+	}
+}
+
+func CheckUserDeletions() {
+	websites := provider.GetWebsites()
+	for _, w := range websites {
+		if !w.Initialed {
+			continue
+		}
+		w.CheckUserDeletions()
+	}
+}
+
 func CheckAuthValid() {
 	rand.Seed(time.Now().UnixNano())
 	time.Sleep(time.Duration(rand.Intn(600)+1) * time.Second)
 	defaultSite := provider.CurrentSite(nil)
 	defaultSite.AnqiCheckLogin(false)
 }
+
+func BuildNewsSitemap() {
+	websites := provider.GetWebsites()
+	for _, w := range websites {
+		if !w.Initialed {
+			continue
+		}
+		w.BuildNewsSitemap()
+	}
+}
+
+func ProcessPushQueue() {
+	websites := provider.GetWebsites()
+	for _, w := range websites {
+		if !w.Initialed {
+			continue
+		}
+		w.ProcessPushQueue()
+	}
+}
+
+func CleanPushQueue() {
+	websites := provider.GetWebsites()
+	for _, w := range websites {
+		if !w.Initialed {
+			continue
+		}
+		w.CleanPushQueue()
+	}
+}
+
+func CheckBrokenLinks() {
+	websites := provider.GetWebsites()
+	for _, w := range websites {
+		if !w.Initialed {
+			continue
+		}
+		w.CheckBrokenLinks()
+	}
+}
+
+func RunSeoAudit() {
+	websites := provider.GetWebsites()
+	for _, w := range websites {
+		if !w.Initialed {
+			continue
+		}
+		w.RunSeoAudit()
+	}
+}
+
+func RebuildInternalLinks() {
+	websites := provider.GetWebsites()
+	for _, w := range websites {
+		if !w.Initialed {
+			continue
+		}
+		w.RebuildInternalLinks()
+	}
+}
+
+func RebuildArchiveFingerprints() {
+	websites := provider.GetWebsites()
+	for _, w := range websites {
+		if !w.Initialed {
+			continue
+		}
+		w.RebuildArchiveFingerprints()
+	}
+}
@@ -0,0 +1,23 @@
+package library
+
+import (
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/russross/blackfriday/v2"
+)
+
+var markdownPolicy = newMarkdownPolicy()
+
+func newMarkdownPolicy() *bluemonday.Policy {
+	policy := bluemonday.UGCPolicy()
+	policy.RequireNoFollowOnLinks(true)
+	policy.AddTargetBlankToFullyQualifiedLinks(true)
+
+	return policy
+}
+
+// RenderMarkdown 将用户提交的 Markdown 渲染为 HTML，并过滤掉脚本、事件属性等不安全的内容
+func RenderMarkdown(content string) string {
+	unsafe := blackfriday.Run([]byte(content), blackfriday.WithExtensions(blackfriday.CommonExtensions|blackfriday.HardLineBreak))
+
+	return string(markdownPolicy.SanitizeBytes(unsafe))
+}
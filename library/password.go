@@ -0,0 +1,51 @@
+package library
+
+import (
+	_ "embed"
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// password.txt 收录了常见的泄露密码，每行一个
+//
+//go:embed password.txt
+var breachedPasswordData string
+
+var breachedPasswords map[string]struct{}
+var breachedPasswordsOnce sync.Once
+
+// IsBreachedPassword 检查密码是否在常见泄露密码列表中，不区分大小写
+func IsBreachedPassword(password string) bool {
+	breachedPasswordsOnce.Do(func() {
+		breachedPasswords = map[string]struct{}{}
+		for _, line := range strings.Split(breachedPasswordData, "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" {
+				breachedPasswords[strings.ToLower(line)] = struct{}{}
+			}
+		}
+	})
+	_, ok := breachedPasswords[strings.ToLower(password)]
+
+	return ok
+}
+
+// PasswordComplexity 返回密码中包含的字符种类数量：小写字母、大写字母、数字、符号
+func PasswordComplexity(password string) int {
+	var lower, upper, digit, symbol int
+	for _, r := range password {
+		switch {
+		case unicode.IsLower(r):
+			lower = 1
+		case unicode.IsUpper(r):
+			upper = 1
+		case unicode.IsDigit(r):
+			digit = 1
+		default:
+			symbol = 1
+		}
+	}
+
+	return lower + upper + digit + symbol
+}
@@ -0,0 +1,23 @@
+package library
+
+import (
+	"testing"
+)
+
+func TestIsBreachedPassword(t *testing.T) {
+	if !IsBreachedPassword("Password123") {
+		t.Error("Password123 should be a breached password")
+	}
+	if IsBreachedPassword("kT9#vQ2!mZ") {
+		t.Error("kT9#vQ2!mZ should not be a breached password")
+	}
+}
+
+func TestPasswordComplexity(t *testing.T) {
+	if n := PasswordComplexity("abc123"); n != 2 {
+		t.Errorf("expect 2, got %d", n)
+	}
+	if n := PasswordComplexity("Abc123!"); n != 4 {
+		t.Errorf("expect 4, got %d", n)
+	}
+}
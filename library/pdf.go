@@ -0,0 +1,57 @@
+package library
+
+import (
+	"bytes"
+	"fmt"
+	"image"
+	"image/jpeg"
+)
+
+// ImagesToPdf 将图片按页生成 PDF 文件，每张图片铺满一页 A4 纸
+func ImagesToPdf(pages []image.Image) ([]byte, error) {
+	// A4 纸，单位 pt
+	const pageWidth, pageHeight = 595, 842
+	var buf bytes.Buffer
+	var offsets []int
+	writeObject := func(content string, stream []byte) {
+		offsets = append(offsets, buf.Len())
+		buf.WriteString(fmt.Sprintf("%d 0 obj\n%s", len(offsets), content))
+		if stream != nil {
+			buf.WriteString("\nstream\n")
+			buf.Write(stream)
+			buf.WriteString("\nendstream")
+		}
+		buf.WriteString("\nendobj\n")
+	}
+
+	buf.WriteString("%PDF-1.4\n")
+	// 1 catalog, 2 pages, 之后每页占用 page, image, content 三个对象
+	var kids string
+	for i := range pages {
+		kids += fmt.Sprintf("%d 0 R ", 3+i*3)
+	}
+	writeObject("<< /Type /Catalog /Pages 2 0 R >>", nil)
+	writeObject(fmt.Sprintf("<< /Type /Pages /Kids [%s] /Count %d >>", kids, len(pages)), nil)
+	for i, img := range pages {
+		var imgBuf bytes.Buffer
+		err := jpeg.Encode(&imgBuf, img, &jpeg.Options{Quality: 90})
+		if err != nil {
+			return nil, err
+		}
+		pageId := 3 + i*3
+		writeObject(fmt.Sprintf("<< /Type /Page /Parent 2 0 R /MediaBox [0 0 %d %d] /Resources << /XObject << /Im%d %d 0 R >> >> /Contents %d 0 R >>", pageWidth, pageHeight, i, pageId+1, pageId+2), nil)
+		bounds := img.Bounds()
+		writeObject(fmt.Sprintf("<< /Type /XObject /Subtype /Image /Width %d /Height %d /ColorSpace /DeviceRGB /BitsPerComponent 8 /Filter /DCTDecode /Length %d >>", bounds.Dx(), bounds.Dy(), imgBuf.Len()), imgBuf.Bytes())
+		content := []byte(fmt.Sprintf("q %d 0 0 %d 0 0 cm /Im%d Do Q", pageWidth, pageHeight, i))
+		writeObject(fmt.Sprintf("<< /Length %d >>", len(content)), content)
+	}
+
+	xref := buf.Len()
+	buf.WriteString(fmt.Sprintf("xref\n0 %d\n0000000000 65535 f \n", len(offsets)+1))
+	for _, offset := range offsets {
+		buf.WriteString(fmt.Sprintf("%010d 00000 n \n", offset))
+	}
+	buf.WriteString(fmt.Sprintf("trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(offsets)+1, xref))
+
+	return buf.Bytes(), nil
+}
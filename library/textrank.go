@@ -0,0 +1,347 @@
+package library
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	textRankDamping    = 0.85
+	textRankIterations = 30
+	textRankWindow     = 5
+	// 摘要参与计算的最大句子数，句子间相似度是 n² 的计算量
+	summaryMaxSentences = 200
+)
+
+// 句子分隔符
+const sentenceSeparators = "。！？!?；;\n\r"
+
+// 没有实际意义的常见词，不作为关键词
+var keywordStopWords = map[string]bool{
+	"我们": true, "你们": true, "他们": true, "它们": true, "自己": true, "这个": true, "那个": true,
+	"这些": true, "那些": true, "什么": true, "怎么": true, "如何": true, "可以": true, "没有": true,
+	"就是": true, "还是": true, "因为": true, "所以": true, "但是": true, "如果": true, "以及": true,
+	"进行": true, "通过": true, "已经": true, "一个": true, "一些": true, "时候": true, "问题": true,
+	"the": true, "and": true, "for": true, "with": true, "that": true, "this": true, "from": true,
+	"are": true, "was": true, "were": true, "have": true, "has": true, "you": true, "your": true,
+	"not": true, "but": true, "can": true, "will": true, "all": true, "our": true, "they": true,
+	"nbsp": true, "http": true, "https": true, "www": true, "com": true,
+}
+
+type textRankWord struct {
+	text string
+	// 原始写法，英文词保留大小写
+	origin string
+	pos    string
+	freq   int
+}
+
+// segmentTextWords 分词并过滤标点和空白，保留词性和词频
+func segmentTextWords(text string) []textRankWord {
+	if !dictLoaded {
+		initDict()
+	}
+	segments := segmenter.Segment([]byte(text))
+	words := make([]textRankWord, 0, len(segments))
+	for _, seg := range segments {
+		token := seg.Token()
+		word := strings.TrimSpace(token.Text())
+		if word == "" || (utf8.RuneCountInString(word) == 1 && strings.ContainsAny(word, removeWord)) {
+			continue
+		}
+		words = append(words, textRankWord{
+			text:   strings.ToLower(word),
+			origin: word,
+			pos:    token.Pos(),
+			freq:   token.Frequency(),
+		})
+	}
+
+	return words
+}
+
+// isKeywordCandidate 名词、动名词和英文单词才作为候选关键词
+func isKeywordCandidate(word textRankWord) bool {
+	if keywordStopWords[word.text] {
+		return false
+	}
+	runes := []rune(word.text)
+	if isAsciiWord(word.text) {
+		return len(runes) >= 3
+	}
+	if len(runes) < 2 {
+		return false
+	}
+	for _, r := range runes {
+		if unicode.IsDigit(r) || unicode.IsPunct(r) || unicode.IsSpace(r) {
+			return false
+		}
+	}
+
+	return strings.HasPrefix(word.pos, "n") || word.pos == "vn" || word.pos == "eng" || word.pos == "x"
+}
+
+func isAsciiWord(s string) bool {
+	for _, r := range s {
+		if r > unicode.MaxASCII || !(unicode.IsLetter(r) || r == '-' || r == '+' || r == '#') {
+			return false
+		}
+	}
+	return s != ""
+}
+
+// wordIdf 用词典词频近似逆文档频率，词典中没有的词视为稀有词
+func wordIdf(freq int) float64 {
+	total := float64(segmenter.Dictionary().TotalFrequency())
+	if total <= 0 {
+		return 1
+	}
+	if freq < 1 {
+		freq = 1
+	}
+
+	return math.Log(total / float64(freq+1))
+}
+
+// ExtractKeywords 使用 TextRank 结合词典 IDF 权重提取关键词，按重要程度排序
+func ExtractKeywords(text string, limit int) []string {
+	if limit <= 0 {
+		limit = 5
+	}
+	words := segmentTextWords(text)
+	var candidates []textRankWord
+	for _, w := range words {
+		if isKeywordCandidate(w) {
+			candidates = append(candidates, w)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil
+	}
+	// 在窗口内共现的词之间建立边
+	index := map[string]int{}
+	var vocab []textRankWord
+	for _, w := range candidates {
+		if _, ok := index[w.text]; !ok {
+			index[w.text] = len(vocab)
+			vocab = append(vocab, w)
+		}
+	}
+	edges := make([]map[int]float64, len(vocab))
+	for i := range edges {
+		edges[i] = map[int]float64{}
+	}
+	for i := range candidates {
+		a := index[candidates[i].text]
+		for j := i + 1; j < len(candidates) && j < i+textRankWindow; j++ {
+			b := index[candidates[j].text]
+			if a == b {
+				continue
+			}
+			edges[a][b]++
+			edges[b][a]++
+		}
+	}
+	ranks := textRank(edges)
+
+	scores := make([]float64, len(vocab))
+	for i, w := range vocab {
+		scores[i] = ranks[i] * wordIdf(w.freq)
+	}
+	order := make([]int, len(vocab))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return scores[order[i]] > scores[order[j]]
+	})
+	var keywords []string
+	for _, i := range order {
+		if len(keywords) >= limit {
+			break
+		}
+		keywords = append(keywords, vocab[i].origin)
+	}
+
+	return keywords
+}
+
+// ExtractSummary 使用 TextRank 挑选最重要的句子组成摘要，句子按原文顺序排列，长度不超过 maxLength 个字
+func ExtractSummary(text string, maxLength int) string {
+	if maxLength <= 0 {
+		maxLength = 150
+	}
+	sentences := splitSentences(text)
+	if len(sentences) == 0 {
+		return ""
+	}
+	if len(sentences) > summaryMaxSentences {
+		sentences = sentences[:summaryMaxSentences]
+	}
+	if len(sentences) == 1 {
+		return truncateRunes(sentences[0], maxLength)
+	}
+	sentenceWords := make([]map[string]bool, len(sentences))
+	for i, s := range sentences {
+		sentenceWords[i] = map[string]bool{}
+		for _, w := range segmentTextWords(s) {
+			if utf8.RuneCountInString(w.text) > 1 && !keywordStopWords[w.text] {
+				sentenceWords[i][w.text] = true
+			}
+		}
+	}
+	edges := make([]map[int]float64, len(sentences))
+	for i := range edges {
+		edges[i] = map[int]float64{}
+	}
+	for i := range sentences {
+		for j := i + 1; j < len(sentences); j++ {
+			similarity := sentenceSimilarity(sentenceWords[i], sentenceWords[j])
+			if similarity > 0 {
+				edges[i][j] = similarity
+				edges[j][i] = similarity
+			}
+		}
+	}
+	ranks := textRank(edges)
+	order := make([]int, len(sentences))
+	for i := range order {
+		order[i] = i
+	}
+	sort.SliceStable(order, func(i, j int) bool {
+		return ranks[order[i]] > ranks[order[j]]
+	})
+
+	// 得分低于平均值的句子与主题关系不大，不用来凑字数
+	average := 0.0
+	for _, rank := range ranks {
+		average += rank
+	}
+	average /= float64(len(ranks))
+	var picked []int
+	length := 0
+	for _, i := range order {
+		if len(picked) > 0 && ranks[i] < average {
+			break
+		}
+		sentenceLength := utf8.RuneCountInString(sentences[i])
+		if length+sentenceLength > maxLength {
+			continue
+		}
+		picked = append(picked, i)
+		length += sentenceLength
+	}
+	if len(picked) == 0 {
+		return truncateRunes(sentences[order[0]], maxLength)
+	}
+	sort.Ints(picked)
+	var builder strings.Builder
+	for n, i := range picked {
+		if n > 0 {
+			last, _ := utf8.DecodeLastRuneInString(sentences[picked[n-1]])
+			if last <= unicode.MaxASCII {
+				builder.WriteByte(' ')
+			}
+		}
+		builder.WriteString(sentences[i])
+	}
+
+	return builder.String()
+}
+
+// splitSentences 按标点切分句子，句末标点保留在句子中，英文句子之间保留空格
+func splitSentences(text string) []string {
+	var sentences []string
+	var builder strings.Builder
+	flush := func() {
+		sentence := strings.TrimSpace(builder.String())
+		builder.Reset()
+		if utf8.RuneCountInString(sentence) >= 5 {
+			sentences = append(sentences, sentence)
+		}
+	}
+	var prev rune
+	for _, r := range text {
+		if r == '\n' || r == '\r' {
+			flush()
+			continue
+		}
+		// 英文句号后跟空格才算句子结束，避免切开小数和网址
+		if prev == '.' && unicode.IsSpace(r) {
+			flush()
+		}
+		prev = r
+		builder.WriteRune(r)
+		if strings.ContainsRune(sentenceSeparators, r) {
+			flush()
+		}
+	}
+	flush()
+
+	return sentences
+}
+
+// sentenceSimilarity TextRank 论文中的句子相似度：共同词数 / (log|Si| + log|Sj|)
+func sentenceSimilarity(a, b map[string]bool) float64 {
+	if len(a) == 0 || len(b) == 0 {
+		return 0
+	}
+	common := 0
+	for w := range a {
+		if b[w] {
+			common++
+		}
+	}
+	if common == 0 {
+		return 0
+	}
+	denominator := math.Log(float64(len(a))+1) + math.Log(float64(len(b))+1)
+
+	return float64(common) / denominator
+}
+
+// textRank 对带权无向图迭代计算各节点的得分
+func textRank(edges []map[int]float64) []float64 {
+	n := len(edges)
+	ranks := make([]float64, n)
+	outWeights := make([]float64, n)
+	for i := range edges {
+		ranks[i] = 1
+		for _, weight := range edges[i] {
+			outWeights[i] += weight
+		}
+	}
+	for iter := 0; iter < textRankIterations; iter++ {
+		next := make([]float64, n)
+		maxDiff := 0.0
+		for i := range edges {
+			sum := 0.0
+			for j, weight := range edges[i] {
+				if outWeights[j] > 0 {
+					sum += weight / outWeights[j] * ranks[j]
+				}
+			}
+			next[i] = 1 - textRankDamping + textRankDamping*sum
+			if diff := math.Abs(next[i] - ranks[i]); diff > maxDiff {
+				maxDiff = diff
+			}
+		}
+		ranks = next
+		if maxDiff < 0.0001 {
+			break
+		}
+	}
+
+	return ranks
+}
+
+func truncateRunes(s string, length int) string {
+	runes := []rune(s)
+	if len(runes) > length {
+		return string(runes[:length])
+	}
+	return s
+}
@@ -139,8 +139,14 @@ func AdminPermission(ctx iris.Context) {
 func Check301(ctx iris.Context) {
 	currentSite := provider.CurrentSite(ctx)
 	uri := ctx.Request().RequestURI
-	val := currentSite.GetRedirectFromCache(uri)
-	if val != "" {
+	result := currentSite.MatchRedirect(uri)
+	if result != nil {
+		if result.StatusCode == iris.StatusGone {
+			go currentSite.RecordRedirectHit(result.Redirect.Id)
+			ctx.StopWithStatus(iris.StatusGone)
+			return
+		}
+		val := result.ToUrl
 		// 验证hosts
 		if strings.HasPrefix(val, "http") {
 			urlParsed, err := url.Parse(val)
@@ -156,7 +162,8 @@ func Check301(ctx iris.Context) {
 			}
 		}
 		if val != "" {
-			ctx.Redirect(val, 301)
+			go currentSite.RecordRedirectHit(result.Redirect.Id)
+			ctx.Redirect(val, result.StatusCode)
 			return
 		}
 	}
@@ -39,6 +39,7 @@ func ParseUserToken(ctx iris.Context) {
 					if err == nil {
 						ctx.Values().Set("userId", userID)
 						ctx.Values().Set("userInfo", userInfo)
+						currentSite.AwardDailyLoginPoints(userInfo.Id)
 
 						userGroup, _ := currentSite.GetUserGroupInfo(userInfo.GroupId)
 						ctx.Values().Set("userGroup", userGroup)
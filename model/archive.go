@@ -9,25 +9,32 @@ import (
 
 type Archive struct {
 	Model
-	Title        string         `json:"title" gorm:"column:title;type:varchar(250) not null;default:''"`
-	SeoTitle     string         `json:"seo_title" gorm:"column:seo_title;type:varchar(250) not null;default:''"`
-	UrlToken     string         `json:"url_token" gorm:"column:url_token;type:varchar(190) not null;default:'';index"`
-	Keywords     string         `json:"keywords" gorm:"column:keywords;type:varchar(250) not null;default:''"`
-	Description  string         `json:"description" gorm:"column:description;type:varchar(1000) not null;default:''"`
-	ModuleId     uint           `json:"module_id" gorm:"column:module_id;type:int(10) unsigned not null;default:1;index:idx_module_id"`
-	CategoryId   uint           `json:"category_id" gorm:"column:category_id;type:int(10) unsigned not null;default:0;index:idx_category_id"`
-	Views        uint           `json:"views" gorm:"column:views;type:int(10) unsigned not null;default:0;index:idx_views"`
-	CommentCount uint           `json:"comment_count" gorm:"column:comment_count;type:int(10) unsigned not null;default:0;index"`
-	Images       pq.StringArray `json:"images" gorm:"column:images;type:text default null"`
-	Template     string         `json:"template" gorm:"column:template;type:varchar(250) not null;default:''"`
-	Status       uint           `json:"status" gorm:"column:status;type:tinyint(1) unsigned not null;default:0"`
-	CanonicalUrl string         `json:"canonical_url" gorm:"column:canonical_url;type:varchar(250) not null;default:''"`         // 规范链接
-	FixedLink    string         `json:"fixed_link" gorm:"column:fixed_link;type:varchar(190) default null;index:idx_fixed_link"` // 固化的链接
-	Flag         string         `json:"flag" gorm:"column:flag;type:set('c','h','p','f','s','j','a','b') default null;index"`    //推荐标签
-	UserId       uint           `json:"user_id" gorm:"column:user_id;type:int(10) unsigned not null;default:0;index"`
-	Price        int64          `json:"price" gorm:"column:price;type:bigint(20) not null;default:0"`
-	Stock        int64          `json:"stock" gorm:"column:stock;type:bigint(20) not null;default:9999999"`
-	ReadLevel    int            `json:"read_level" gorm:"column:read_level;type:int(10) not null;default:0"` // 阅读关联 group level
+	Title         string         `json:"title" gorm:"column:title;type:varchar(250) not null;default:''"`
+	SeoTitle      string         `json:"seo_title" gorm:"column:seo_title;type:varchar(250) not null;default:''"`
+	UrlToken      string         `json:"url_token" gorm:"column:url_token;type:varchar(190) not null;default:'';index"`
+	Keywords      string         `json:"keywords" gorm:"column:keywords;type:varchar(250) not null;default:''"`
+	AutoKeywords  int            `json:"auto_keywords" gorm:"column:auto_keywords;type:tinyint(1) not null;default:0"` // 关键词是自动提取的，不用来生成锚文本
+	Description   string         `json:"description" gorm:"column:description;type:varchar(1000) not null;default:''"`
+	ModuleId      uint           `json:"module_id" gorm:"column:module_id;type:int(10) unsigned not null;default:1;index:idx_module_id"`
+	CategoryId    uint           `json:"category_id" gorm:"column:category_id;type:int(10) unsigned not null;default:0;index:idx_category_id"`
+	Views         uint           `json:"views" gorm:"column:views;type:int(10) unsigned not null;default:0;index:idx_views"`
+	CommentCount  uint           `json:"comment_count" gorm:"column:comment_count;type:int(10) unsigned not null;default:0;index"`
+	FavoriteCount uint           `json:"favorite_count" gorm:"column:favorite_count;type:int(10) unsigned not null;default:0;index"`
+	Images        pq.StringArray `json:"images" gorm:"column:images;type:text default null"`
+	Template      string         `json:"template" gorm:"column:template;type:varchar(250) not null;default:''"`
+	Status        uint           `json:"status" gorm:"column:status;type:tinyint(1) unsigned not null;default:0"`
+	CanonicalUrl  string         `json:"canonical_url" gorm:"column:canonical_url;type:varchar(250) not null;default:''"`         // 规范链接
+	FixedLink     string         `json:"fixed_link" gorm:"column:fixed_link;type:varchar(190) default null;index:idx_fixed_link"` // 固化的链接
+	Flag          string         `json:"flag" gorm:"column:flag;type:set('c','h','p','f','s','j','a','b') default null;index"`    //推荐标签
+	UserId        uint           `json:"user_id" gorm:"column:user_id;type:int(10) unsigned not null;default:0;index"`
+	Price         int64          `json:"price" gorm:"column:price;type:bigint(20) not null;default:0"`
+	Stock         int64          `json:"stock" gorm:"column:stock;type:bigint(20) not null;default:9999999"`
+	ReadLevel     int            `json:"read_level" gorm:"column:read_level;type:int(10) not null;default:0"`             // 阅读关联 group level
+	ReadPoints    int64          `json:"read_points" gorm:"column:read_points;type:bigint(20) not null;default:0"`        // 阅读需要消耗的积分
+	DeliveryType  string         `json:"delivery_type" gorm:"column:delivery_type;type:varchar(20) not null;default:''"`  // 交付方式，为空时跟随模型
+	DeliveryFile  string         `json:"delivery_file" gorm:"column:delivery_file;type:varchar(250) not null;default:''"` // 受保护的下载文件，存放在 data/delivery
+	ShippingId    uint           `json:"shipping_id" gorm:"column:shipping_id;type:int(10) unsigned not null;default:0"`  // 运费模板，为0时跟随模型
+	Weight        int64          `json:"weight" gorm:"column:weight;type:bigint(20) not null;default:0"`                  // 商品重量，单位克
 	//采集专用
 	HasPseudo   int    `json:"has_pseudo" gorm:"column:has_pseudo;type:tinyint(1) not null;default:0"`
 	KeywordId   uint   `json:"keyword_id" gorm:"column:keyword_id;type:bigint(20) not null;default:0"`
@@ -44,6 +51,7 @@ type Archive struct {
 	Tags           []string                `json:"tags,omitempty" gorm:"-"`
 	HasOrdered     bool                    `json:"has_ordered" gorm:"-"` // 是否订购了
 	FavorablePrice int64                   `json:"favorable_price" gorm:"-"`
+	SeoAudit       *SeoAudit               `json:"seo_audit,omitempty" gorm:"-"`
 }
 
 type ArchiveData struct {
@@ -77,3 +85,11 @@ func (a *Archive) GetThumb(storageUrl, defaultThumb string) string {
 
 	return a.Thumb
 }
+
+// ArchiveFingerprint 文档内容的 SimHash 指纹，Id 与文档ID相同，用于查找重复和相似的文档
+type ArchiveFingerprint struct {
+	Id          uint   `json:"id" gorm:"column:id;type:int(10) unsigned not null;primaryKey;autoIncrement:false"`
+	Simhash     uint64 `json:"simhash" gorm:"column:simhash;type:bigint(20) unsigned not null;default:0"`
+	Tokens      int    `json:"tokens" gorm:"column:tokens;type:int(10) not null;default:0"` // 参与计算的词数，太少的不参与比较
+	UpdatedTime int64  `json:"updated_time" gorm:"column:updated_time;type:int(11);autoUpdateTime"`
+}
@@ -7,6 +7,7 @@ import (
 const (
 	StatusWait = uint(0)
 	StatusOk   = uint(1)
+	StatusSpam = uint(2) // 垃圾内容，与待审核分开存放，误判时可以恢复
 )
 
 /**
@@ -0,0 +1,15 @@
+package model
+
+// BrokenLink 失效链接扫描结果，每个内容中的每个失效链接或图片一条记录
+type BrokenLink struct {
+	Model
+	ItemType   string `json:"item_type" gorm:"column:item_type;type:varchar(20) not null;default:'';index:idx_item"` // archive|category|page
+	ItemId     uint   `json:"item_id" gorm:"column:item_id;type:int(10) unsigned not null;default:0;index:idx_item"`
+	Title      string `json:"title" gorm:"column:title;type:varchar(250) not null;default:''"`
+	Link       string `json:"link" gorm:"column:link;type:varchar(1000) not null;default:''"`
+	LinkType   string `json:"link_type" gorm:"column:link_type;type:varchar(10) not null;default:''"` // link|image
+	Anchor     string `json:"anchor" gorm:"column:anchor;type:varchar(250) not null;default:''"`
+	External   int    `json:"external" gorm:"column:external;type:tinyint(1) not null;default:0"`
+	StatusCode int    `json:"status_code" gorm:"column:status_code;type:int(10) not null;default:0"`
+	Reason     string `json:"reason" gorm:"column:reason;type:varchar(250) not null;default:''"`
+}
@@ -15,9 +15,16 @@ type Comment struct {
 	ParentId  uint     `json:"parent_id" gorm:"column:parent_id;type:int(10) unsigned not null;default:0;index:idx_parent_id"`
 	ToUid     uint     `json:"to_uid" gorm:"column:to_uid;type:int(10) unsigned not null;default:0;index:idx_to_uid"`
 	Status    uint     `json:"status" gorm:"column:status;type:tinyint(1) unsigned not null;default:0;index:idx_status"`
+	SpamScore int      `json:"spam_score" gorm:"column:spam_score;type:int(10) not null;default:0"`
+	SpamInfo  string   `json:"spam_info" gorm:"column:spam_info;type:varchar(250) not null;default:''"`
+	Trained   uint     `json:"trained" gorm:"column:trained;type:tinyint(1) unsigned not null;default:0"` // 用于训练垃圾评论分类器的类别，0 未训练，1 正常，2 垃圾
 	ItemTitle string   `json:"item_title" gorm:"-"`
 	Parent    *Comment `json:"parent" gorm:"-"`
 	Active    bool     `json:"active" gorm:"-"`
+	// 评论树
+	ContentHtml string     `json:"content_html" gorm:"-"` // Markdown 渲染并过滤后的内容
+	ReplyCount  int64      `json:"reply_count" gorm:"-"`
+	Children    []*Comment `json:"children,omitempty" gorm:"-"`
 }
 
 func (comment *Comment) Save(db *gorm.DB) error {
@@ -41,6 +48,14 @@ func (comment *Comment) Delete(db *gorm.DB) error {
 func (comment *Comment) UpdateCommentCount(db *gorm.DB) {
 	// 更新数量
 	var total int64
-	db.Model(&Comment{}).Where("`archive_id` = ?", comment.ArchiveId).Count(&total)
+	db.Model(&Comment{}).Where("`archive_id` = ? and `status` != ?", comment.ArchiveId, StatusSpam).Count(&total)
 	db.Model(&Archive{}).Where("`id` = ?", comment.ArchiveId).UpdateColumn("comment_count", total)
 }
+
+// CommentSpamToken 垃圾评论分类器的词频，由后台审核评论时训练
+type CommentSpamToken struct {
+	Id        uint   `json:"id" gorm:"column:id;type:int(10) unsigned not null AUTO_INCREMENT;primaryKey"`
+	Token     string `json:"token" gorm:"column:token;type:varchar(64) not null;default:'';uniqueIndex:idx_token"`
+	SpamCount int64  `json:"spam_count" gorm:"column:spam_count;type:int(10) not null;default:0"`
+	HamCount  int64  `json:"ham_count" gorm:"column:ham_count;type:int(10) not null;default:0"`
+}
@@ -0,0 +1,21 @@
+package model
+
+// UserFavorite 用户收藏的文档
+type UserFavorite struct {
+	Model
+	UserId    uint `json:"user_id" gorm:"column:user_id;type:int(10) unsigned not null;default:0;uniqueIndex:idx_user_archive"`
+	ArchiveId uint `json:"archive_id" gorm:"column:archive_id;type:int(10) unsigned not null;default:0;uniqueIndex:idx_user_archive;index"`
+
+	Archive *Archive `json:"archive,omitempty" gorm:"-"`
+}
+
+// UserHistory 用户的阅读记录，同一篇文档只保留一条，updated_time 为最后阅读时间
+type UserHistory struct {
+	Model
+	UserId    uint `json:"user_id" gorm:"column:user_id;type:int(10) unsigned not null;default:0;uniqueIndex:idx_user_archive"`
+	ArchiveId uint `json:"archive_id" gorm:"column:archive_id;type:int(10) unsigned not null;default:0;uniqueIndex:idx_user_archive"`
+	Views     int  `json:"views" gorm:"column:views;type:int(10) not null;default:0"`
+	Progress  int  `json:"progress" gorm:"column:progress;type:int(10) not null;default:0"` // 阅读进度，0-100
+
+	Archive *Archive `json:"archive,omitempty" gorm:"-"`
+}
@@ -7,6 +7,7 @@ import (
 
 type Guestbook struct {
 	Model
+	UserId    uint      `json:"user_id" gorm:"column:user_id;type:int(10) unsigned not null;default:0;index"`
 	UserName  string    `json:"user_name" gorm:"column:user_name;type:varchar(250) not null;default:''"`
 	Contact   string    `json:"contact" gorm:"column:contact;type:varchar(250) not null;default:''"`
 	Content   string    `json:"content" gorm:"column:content;type:text default null"`
@@ -0,0 +1,14 @@
+package model
+
+// InternalLink 站内链接关系，内容中的每个站内链接一条记录
+type InternalLink struct {
+	Model
+	SourceType   string `json:"source_type" gorm:"column:source_type;type:varchar(20) not null;default:'';index:idx_source"` // archive|category
+	SourceId     uint   `json:"source_id" gorm:"column:source_id;type:int(10) unsigned not null;default:0;index:idx_source"`
+	SourceLength int    `json:"source_length" gorm:"column:source_length;type:int(10) not null;default:0"`                   // 来源内容的纯文本字数
+	TargetType   string `json:"target_type" gorm:"column:target_type;type:varchar(20) not null;default:'';index:idx_target"` // archive|category|tag|index|other
+	TargetId     uint   `json:"target_id" gorm:"column:target_id;type:int(10) unsigned not null;default:0;index:idx_target"`
+	TargetUrl    string `json:"target_url" gorm:"column:target_url;type:varchar(250) not null;default:'';index"`
+	AnchorText   string `json:"anchor_text" gorm:"column:anchor_text;type:varchar(250) not null;default:''"`
+	AnchorId     uint   `json:"anchor_id" gorm:"column:anchor_id;type:int(10) unsigned not null;default:0"` // 锚文本插件插入的链接
+}
@@ -0,0 +1,18 @@
+package model
+
+// UserMembership 用户的会员组变更记录
+type UserMembership struct {
+	Model
+	UserId        uint   `json:"user_id" gorm:"column:user_id;type:int(10) unsigned not null;default:0;index"`
+	GroupId       uint   `json:"group_id" gorm:"column:group_id;type:int(10) unsigned not null;default:0"`
+	FromGroupId   uint   `json:"from_group_id" gorm:"column:from_group_id;type:int(10) unsigned not null;default:0"`
+	OrderId       string `json:"order_id" gorm:"column:order_id;type:varchar(36) not null;default:'';index"`
+	Action        string `json:"action" gorm:"column:action;type:varchar(20) not null;default:''"`
+	StartTime     int64  `json:"start_time" gorm:"column:start_time;type:int(11) not null;default:0"`
+	ExpireTime    int64  `json:"expire_time" gorm:"column:expire_time;type:int(11) not null;default:0"`
+	ProrateAmount int64  `json:"prorate_amount" gorm:"column:prorate_amount;type:bigint(20) not null;default:0"` // 升级时抵扣的金额，或降级时折算成时长的金额
+	Remark        string `json:"remark" gorm:"column:remark;type:varchar(250) not null;default:''"`
+
+	Group     *UserGroup `json:"group,omitempty" gorm:"-"`
+	FromGroup *UserGroup `json:"from_group,omitempty" gorm:"-"`
+}
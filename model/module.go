@@ -18,6 +18,10 @@ type Module struct {
 	IsSystem  int          `json:"is_system" gorm:"column:is_system;type:tinyint(1) unsigned not null;default:0"`
 	TitleName string       `json:"title_name" gorm:"column:title_name;type:varchar(50) not null;default:''"`
 	Status    uint         `json:"status" gorm:"column:status;type:tinyint(1) unsigned not null;default:0"`
+	// 该模型下商品的默认交付方式，支持 download|license，为空表示实物发货
+	DeliveryType string `json:"delivery_type" gorm:"column:delivery_type;type:varchar(20) not null;default:''"`
+	// 该模型下商品的默认运费模板
+	ShippingId uint `json:"shipping_id" gorm:"column:shipping_id;type:int(10) unsigned not null;default:0"`
 
 	Database string `json:"-" gorm:"-"`
 }
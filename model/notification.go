@@ -0,0 +1,22 @@
+package model
+
+// Notification 站内通知，UserId 为0时是广播消息，GroupId 大于0时只发给该用户组
+type Notification struct {
+	Model
+	UserId   uint   `json:"user_id" gorm:"column:user_id;type:int(10) unsigned not null;default:0;index"`
+	GroupId  uint   `json:"group_id" gorm:"column:group_id;type:int(10) unsigned not null;default:0"`
+	Type     string `json:"type" gorm:"column:type;type:varchar(20) not null;default:'';index"`
+	Title    string `json:"title" gorm:"column:title;type:varchar(250) not null;default:''"`
+	Content  string `json:"content" gorm:"column:content;type:text default null"`
+	Link     string `json:"link" gorm:"column:link;type:varchar(250) not null;default:''"`
+	IsRead   int    `json:"is_read" gorm:"column:is_read;type:tinyint(1) not null;default:0"`
+	ReadTime int64  `json:"read_time" gorm:"column:read_time;type:int(11) not null;default:0"`
+}
+
+// NotificationRead 广播消息的已读记录
+type NotificationRead struct {
+	Id             uint  `json:"id" gorm:"column:id;type:int(10) unsigned not null AUTO_INCREMENT;primaryKey"`
+	NotificationId uint  `json:"notification_id" gorm:"column:notification_id;type:int(10) unsigned not null;default:0;uniqueIndex:idx_notification_user"`
+	UserId         uint  `json:"user_id" gorm:"column:user_id;type:int(10) unsigned not null;default:0;uniqueIndex:idx_notification_user"`
+	ReadTime       int64 `json:"read_time" gorm:"column:read_time;type:int(11) not null;default:0"`
+}
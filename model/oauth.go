@@ -0,0 +1,11 @@
+package model
+
+// OauthAccount 第三方登录账号与本站用户或管理员的绑定关系
+type OauthAccount struct {
+	Model
+	Type     string `json:"type" gorm:"column:type;type:varchar(10) not null;default:'';uniqueIndex:idx_type_subject"` // user | admin
+	Subject  string `json:"subject" gorm:"column:subject;type:varchar(128) not null;default:'';uniqueIndex:idx_type_subject"`
+	TargetId uint   `json:"target_id" gorm:"column:target_id;type:int(10) unsigned not null;default:0;index"`
+	Email    string `json:"email" gorm:"column:email;type:varchar(100) not null;default:''"`
+	Nickname string `json:"nickname" gorm:"column:nickname;type:varchar(64) not null;default:''"`
+}
@@ -32,6 +32,10 @@ type Order struct {
 	ShareParentAmount int64  `json:"share_parent_amount" gorm:"column:share_parent_amount;type:bigint(20) not null;default:0;comment:奖励金，上级"` // 分销可得金额
 	ExpressCompany    string `json:"express_company" gorm:"column:express_company;type:varchar(100) not null;default:''"`                     // 快递公司
 	TrackingNumber    string `json:"tracking_number" gorm:"column:tracking_number;type:varchar(100) not null;default:''"`                     // 快递单号
+	ShippingAmount    int64  `json:"shipping_amount" gorm:"column:shipping_amount;type:bigint(20) not null;default:0;comment:运费"`
+	RefundAmount      int64  `json:"refund_amount" gorm:"column:refund_amount;type:bigint(20) not null;default:0;comment:已退款金额"` // 累计已退款金额
+	UsedPoints        int64  `json:"used_points" gorm:"column:used_points;type:bigint(20) not null;default:0;comment:抵扣使用的积分"`
+	PointAmount       int64  `json:"point_amount" gorm:"column:point_amount;type:bigint(20) not null;default:0;comment:积分抵扣金额"` // 已计入优惠金额
 
 	OrderAddress *OrderAddress  `json:"order_address,omitempty" gorm:"-"`
 	User         *User          `json:"user" gorm:"-"`
@@ -39,6 +43,7 @@ type Order struct {
 	ParentUser   *User          `json:"parent_user,omitempty" gorm:"-"`
 	IsUpdated    int            `json:"is_updated" gorm:"-"`
 	Details      []*OrderDetail `json:"details" gorm:"-"`
+	Refunds      []*OrderRefund `json:"refunds,omitempty" gorm:"-"`
 }
 
 func (o *Order) AfterCreate(tx *gorm.DB) (err error) {
@@ -67,6 +72,10 @@ type OrderDetail struct {
 	Status       int        `json:"status" gorm:"column:status;type:tinyint(1) not null;default:0"`
 	Goods        *Archive   `json:"goods" gorm:"-"`
 	Group        *UserGroup `json:"group" gorm:"-"`
+	// 数字商品交付内容
+	DeliveryType string   `json:"delivery_type,omitempty" gorm:"-"`
+	DownloadUrl  string   `json:"download_url,omitempty" gorm:"-"`
+	LicenseKeys  []string `json:"license_keys,omitempty" gorm:"-"`
 }
 
 type OrderAddress struct {
@@ -79,7 +88,17 @@ type OrderAddress struct {
 	Country     string `json:"country" gorm:"column:country;type:varchar(100) not null;default:''"`
 	AddressInfo string `json:"address_info" gorm:"column:address_info;type:varchar(255) not null;default:''"`
 	Postcode    string `json:"postcode" gorm:"column:postcode;type:varchar(36) not null;default:''"`
-	Status      int    `json:"status" gorm:"column:status;type:tinyint(1) not null;default:0"`
+	Status      int    `json:"status" gorm:"column:status;type:tinyint(1) not null;default:0"` // 1 地址簿中的地址，0 订单使用的地址
+	IsDefault   int    `json:"is_default" gorm:"column:is_default;type:tinyint(1) not null;default:0"`
+}
+
+// OrderInvoice 订单发票/收据，发票号在每个站点内按顺序生成
+type OrderInvoice struct {
+	Model
+	InvoiceNo string `json:"invoice_no" gorm:"column:invoice_no;type:varchar(36) not null;default:'';index"`
+	OrderId   string `json:"order_id" gorm:"column:order_id;type:varchar(36) not null;unique"`
+	UserId    uint   `json:"user_id" gorm:"column:user_id;type:int(10) unsigned not null;default:0;index"`
+	Amount    int64  `json:"amount" gorm:"column:amount;type:bigint(20) not null;default:0"`
 }
 
 // OrderRefund 退款记录
@@ -95,6 +114,9 @@ type OrderRefund struct {
 	ErrorTimes int    `json:"error_times" gorm:"column:error_times;type:int(10) not null;default:0"` // 执行错误次数
 	LastTime   int64  `json:"last_time" gorm:"column:last_time;type:int(10) not null;default:0"`     // 上次执行时间
 	Remark     string `json:"remark" gorm:"column:remark;type:varchar(255) default null"`            //备注
+	Reason     string `json:"reason" gorm:"column:reason;type:varchar(255) default null"`            // 退款原因
+
+	Detail *OrderDetail `json:"detail,omitempty" gorm:"-"`
 }
 
 func (o *OrderRefund) AfterCreate(tx *gorm.DB) (err error) {
@@ -109,6 +131,18 @@ func (o *OrderRefund) AfterCreate(tx *gorm.DB) (err error) {
 	return
 }
 
+// LicenseKey 授权码池，订单支付后按订购数量分配
+type LicenseKey struct {
+	Model
+	ArchiveId uint   `json:"archive_id" gorm:"column:archive_id;type:int(10) unsigned not null;default:0;index"`
+	Code      string `json:"code" gorm:"column:code;type:varchar(190) not null;default:''"`
+	Status    int    `json:"status" gorm:"column:status;type:tinyint(1) not null;default:0;index"`
+	OrderId   string `json:"order_id" gorm:"column:order_id;type:varchar(36) not null;default:'';index"`
+	DetailId  uint   `json:"detail_id" gorm:"column:detail_id;type:int(10) unsigned not null;default:0"`
+	UserId    uint   `json:"user_id" gorm:"column:user_id;type:int(10) unsigned not null;default:0;index"`
+	UsedTime  int64  `json:"used_time" gorm:"column:used_time;type:int(10) not null;default:0"` // 分配时间
+}
+
 type Payment struct {
 	Model
 	PaymentId string `json:"payment_id" gorm:"column:payment_id;type:varchar(36) not null;unique"`
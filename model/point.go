@@ -0,0 +1,14 @@
+package model
+
+// UserPoint 用户积分明细，Points 为正数时是获得，负数时是消耗
+type UserPoint struct {
+	Model
+	UserId      uint   `json:"user_id" gorm:"column:user_id;type:int(10) unsigned not null;default:0;index:idx_user_action"`
+	Action      string `json:"action" gorm:"column:action;type:varchar(20) not null;default:'';index:idx_user_action"`
+	TargetId    uint   `json:"target_id" gorm:"column:target_id;type:int(10) unsigned not null;default:0"` // 关联的评论、文档等ID
+	OrderId     string `json:"order_id" gorm:"column:order_id;type:varchar(36) not null;default:'';index"`
+	Points      int64  `json:"points" gorm:"column:points;type:bigint(20) not null;default:0"`
+	AfterPoints int64  `json:"after_points" gorm:"column:after_points;type:bigint(20) not null;default:0"` // 变更后的积分
+	Remark      string `json:"remark" gorm:"column:remark;type:varchar(250) not null;default:''"`
+	UserName    string `json:"user_name" gorm:"-"`
+}
@@ -0,0 +1,14 @@
+package model
+
+// PushQueue 搜索引擎推送队列，每个链接在每个搜索引擎各一条记录
+type PushQueue struct {
+	Model
+	Url        string `json:"url" gorm:"column:url;type:varchar(250) not null;default:'';index"`
+	Engine     string `json:"engine" gorm:"column:engine;type:varchar(30) not null;default:'';index"`
+	Action     string `json:"action" gorm:"column:action;type:varchar(10) not null;default:''"`
+	Status     int    `json:"status" gorm:"column:status;type:tinyint(1) not null;default:0;index"`
+	Retries    int    `json:"retries" gorm:"column:retries;type:int(10) not null;default:0"`
+	NextTime   int64  `json:"next_time" gorm:"column:next_time;type:int(11) not null;default:0;index"`
+	PushedTime int64  `json:"pushed_time" gorm:"column:pushed_time;type:int(11) not null;default:0"`
+	Result     string `json:"result" gorm:"column:result;type:varchar(250) not null;default:''"`
+}
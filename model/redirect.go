@@ -2,7 +2,13 @@ package model
 
 type Redirect struct {
 	Model
-	FromUrl string `json:"from_url" gorm:"column:from_url;type:varchar(190) not null;default:'';unique"`
-	ToUrl   string `json:"to_url" gorm:"column:to_url;type:varchar(250) not null;default:''"`
-	SiteId  uint   `json:"-" gorm:"-"`
+	FromUrl     string `json:"from_url" gorm:"column:from_url;type:varchar(190) not null;default:'';unique"`
+	ToUrl       string `json:"to_url" gorm:"column:to_url;type:varchar(250) not null;default:''"`
+	MatchType   int    `json:"match_type" gorm:"column:match_type;type:tinyint(1) not null;default:0"`    // 0 完全匹配，1 通配符，2 正则
+	StatusCode  int    `json:"status_code" gorm:"column:status_code;type:int(10) not null;default:301"`   // 301/302/307/308/410
+	KeepQuery   int    `json:"keep_query" gorm:"column:keep_query;type:tinyint(1) not null;default:0"`    // 是否将请求的查询参数附加到跳转链接
+	Priority    int    `json:"priority" gorm:"column:priority;type:int(10) not null;default:0"`           // 规则匹配的优先级，越大越优先
+	Hits        int64  `json:"hits" gorm:"column:hits;type:bigint(20) not null;default:0"`                // 命中次数
+	LastHitTime int64  `json:"last_hit_time" gorm:"column:last_hit_time;type:int(11) not null;default:0"` // 最后命中时间
+	SiteId      uint   `json:"-" gorm:"-"`
 }
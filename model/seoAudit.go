@@ -0,0 +1,16 @@
+package model
+
+import "github.com/lib/pq"
+
+// SeoAudit 文档、分类的页面SEO检查结果，Issues 保存问题代码
+type SeoAudit struct {
+	Model
+	ItemType      string         `json:"item_type" gorm:"column:item_type;type:varchar(20) not null;default:'';index:idx_item"` // archive|category
+	ItemId        uint           `json:"item_id" gorm:"column:item_id;type:int(10) unsigned not null;default:0;index:idx_item"`
+	Title         string         `json:"title" gorm:"column:title;type:varchar(250) not null;default:''"`
+	Score         int            `json:"score" gorm:"column:score;type:int(10) not null;default:0;index"`
+	Issues        pq.StringArray `json:"issues" gorm:"column:issues;type:text default null"`
+	WordCount     int            `json:"word_count" gorm:"column:word_count;type:int(10) not null;default:0"`
+	InternalLinks int            `json:"internal_links" gorm:"column:internal_links;type:int(10) not null;default:0"`
+	Messages      []string       `json:"messages" gorm:"-"`
+}
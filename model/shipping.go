@@ -0,0 +1,53 @@
+package model
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+)
+
+// ShippingTemplate 运费模板，金额单位为分，重量单位为克
+type ShippingTemplate struct {
+	Model
+	Title          string          `json:"title" gorm:"column:title;type:varchar(100) not null;default:''"`
+	Type           string          `json:"type" gorm:"column:type;type:varchar(20) not null;default:'flat'"` // 计费方式，支持 flat|weight|quantity
+	FirstUnit      int64           `json:"first_unit" gorm:"column:first_unit;type:bigint(20) not null;default:0"`
+	FirstFee       int64           `json:"first_fee" gorm:"column:first_fee;type:bigint(20) not null;default:0"`
+	AdditionalUnit int64           `json:"additional_unit" gorm:"column:additional_unit;type:bigint(20) not null;default:0"`
+	AdditionalFee  int64           `json:"additional_fee" gorm:"column:additional_fee;type:bigint(20) not null;default:0"`
+	FreeAmount     int64           `json:"free_amount" gorm:"column:free_amount;type:bigint(20) not null;default:0"` // 商品金额达到该值时包邮，0 表示不包邮
+	Regions        ShippingRegions `json:"regions" gorm:"column:regions;type:text default null"`                     // 按地区单独设置的运费
+	IsDefault      int             `json:"is_default" gorm:"column:is_default;type:tinyint(1) not null;default:0"`
+}
+
+// ShippingRegion 地区运费，City 为空时对整个省份生效
+type ShippingRegion struct {
+	Province      string `json:"province"`
+	City          string `json:"city"`
+	FirstFee      int64  `json:"first_fee"`
+	AdditionalFee int64  `json:"additional_fee"`
+	FreeAmount    int64  `json:"free_amount"`
+	NoDelivery    bool   `json:"no_delivery"` // 不配送的地区
+}
+
+type ShippingRegions []ShippingRegion
+
+// Value implements the driver.Valuer interface.
+func (s ShippingRegions) Value() (driver.Value, error) {
+	return json.Marshal(s)
+}
+
+// Scan implements the sql.Scanner interface.
+func (s *ShippingRegions) Scan(src interface{}) error {
+	switch src := src.(type) {
+	case []byte:
+		return json.Unmarshal(src, &s)
+	case string:
+		return json.Unmarshal([]byte(src), &s)
+	case nil:
+		*s = nil
+		return nil
+	}
+
+	return fmt.Errorf("pq: cannot convert %T", src)
+}
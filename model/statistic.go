@@ -9,4 +9,11 @@ type Statistic struct {
 	Device    string `json:"device" gorm:"column:device;type:varchar(20) not null;default:'';index"`
 	HttpCode  int    `json:"http_code" gorm:"column:http_code;type:int(3) not null;default:0"`
 	UserAgent string `json:"user_agent" gorm:"column:user_agent;type:varchar(255) not null;default:''"`
+	Referer   string `json:"referer" gorm:"column:referer;type:varchar(250) not null;default:''"`
+}
+
+// NotFoundIgnore 404报告中忽略的链接，以 * 结尾时按前缀忽略
+type NotFoundIgnore struct {
+	Model
+	Url string `json:"url" gorm:"column:url;type:varchar(190) not null;default:'';unique"`
 }
@@ -29,10 +29,14 @@ type User struct {
 	Status      int    `json:"status" gorm:"column:status;type:tinyint(1) not null;default:0"`
 	IsRetailer  int    `json:"is_retailer" gorm:"column:is_retailer;type:tinyint(1) not null;default:0"` // 是否是分销员
 	Balance     int64  `json:"balance" gorm:"column:balance;type:bigint(20) not null;default:0;comment:'用户余额'"`
+	Points      int64  `json:"points" gorm:"column:points;type:bigint(20) not null;default:0;comment:'用户积分'"`
 	TotalReward int64  `json:"total_reward" gorm:"column:total_reward;type:bigint(20) not null;default:0;comment:''"` // 分销员累计收益
 	InviteCode  string `json:"invite_code" gorm:"column:invite_code;type:varchar(100) not null;default:'';index:idx_invite_code"`
 	LastLogin   int64  `json:"last_login" gorm:"column:last_login;type:int(11);default:0"`
 	ExpireTime  int64  `json:"expire_time" gorm:"column:expire_time;type:int(11);default:0"`
+	Verified    int    `json:"verified" gorm:"column:verified;type:tinyint(1) not null;default:0"` // 是否已验证邮箱或手机号
+	// 是否退订评论回复邮件
+	UnsubscribeComment int `json:"unsubscribe_comment" gorm:"column:unsubscribe_comment;type:tinyint(1) not null;default:0"`
 
 	Token         string     `json:"token" gorm:"-"`
 	Group         *UserGroup `json:"group" gorm:"-"`
@@ -49,6 +53,18 @@ type UserGroup struct {
 	Price          int64            `json:"price" gorm:"column:price;type:bigint(20) not null;default:0"`
 	Setting        UserGroupSetting `json:"setting" gorm:"setting;type:text DEFAULT NULL; COMMENT '配置信息'"` //配置
 	FavorablePrice int64            `json:"favorable_price" gorm:"-"`
+	ProrateAmount  int64            `json:"prorate_amount" gorm:"-"` // 从当前会员组升级时可以抵扣的金额
+}
+
+// UserDeletion 用户注销申请，到达执行时间后匿名化用户资料，订单、佣金、提现等财务记录会保留
+type UserDeletion struct {
+	Model
+	UserId       uint   `json:"user_id" gorm:"column:user_id;type:int(10) unsigned not null;default:0;index"`
+	UserName     string `json:"user_name" gorm:"column:user_name;type:varchar(64) not null;default:''"`
+	Reason       string `json:"reason" gorm:"column:reason;type:varchar(250) not null;default:''"`
+	Status       int    `json:"status" gorm:"column:status;type:tinyint(1) not null;default:0"`            // 0 等待执行，1 已注销，-1 已撤销
+	ExecuteTime  int64  `json:"execute_time" gorm:"column:execute_time;type:int(11) not null;default:0"`   // 计划执行时间
+	FinishedTime int64  `json:"finished_time" gorm:"column:finished_time;type:int(11) not null;default:0"` // 实际注销时间
 }
 
 type UserGroupSetting struct {
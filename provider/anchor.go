@@ -155,6 +155,7 @@ func (w *Website) CleanAnchor(anchorId uint) {
 				//更新内容
 				archiveData.Content, _ = doc.Find("body").Html()
 				w.DB.Save(archiveData)
+				w.BuildInternalLinks("archive", archiveData.Id, archiveData.Content)
 			}
 		}
 		//删除当前item
@@ -207,6 +208,7 @@ func (w *Website) ChangeAnchor(anchor *model.Anchor, changeTitle bool) {
 				//更新内容
 				archiveData.Content, _ = doc.Find("body").Html()
 				w.DB.Save(archiveData)
+				w.BuildInternalLinks("archive", archiveData.Id, archiveData.Content)
 			}
 		}
 	}
@@ -416,6 +418,7 @@ func (w *Website) ReplaceContent(anchors []*model.Anchor, itemType string, itemI
 		//内容有更新，执行更新
 		archiveData.Content = content
 		w.DB.Save(archiveData)
+		w.BuildInternalLinks(itemType, itemId, content)
 	}
 
 	return content
@@ -143,11 +143,16 @@ func (w *Website) SaveArchive(req *request.Archive) (archive *model.Archive, err
 	}
 
 	newPost := false
+	// 已发布的文档记录原来的链接，链接变化后自动添加跳转
+	var oldLink string
 	if req.Id > 0 {
 		archive, err = w.GetArchiveById(req.Id)
 		if err != nil {
 			return nil, err
 		}
+		if archive.Status == config.ContentStatusOK {
+			oldLink = w.GetUrl("archive", archive, 0)
+		}
 	} else {
 		newPost = true
 		archive = &model.Archive{
@@ -176,6 +181,10 @@ func (w *Website) SaveArchive(req *request.Archive) (archive *model.Archive, err
 	archive.ModuleId = category.ModuleId
 	archive.Title = req.Title
 	archive.SeoTitle = req.SeoTitle
+	// 编辑时提交的仍是之前自动提取的关键词，保留自动提取的标记
+	if req.Keywords != archive.Keywords {
+		archive.AutoKeywords = 0
+	}
 	archive.Keywords = req.Keywords
 	archive.Description = req.Description
 	archive.CategoryId = req.CategoryId
@@ -188,6 +197,11 @@ func (w *Website) SaveArchive(req *request.Archive) (archive *model.Archive, err
 	archive.Price = req.Price
 	archive.Stock = req.Stock
 	archive.ReadLevel = req.ReadLevel
+	archive.ReadPoints = req.ReadPoints
+	archive.DeliveryType = req.DeliveryType
+	archive.DeliveryFile = req.DeliveryFile
+	archive.ShippingId = req.ShippingId
+	archive.Weight = req.Weight
 	if req.UserId > 0 {
 		archive.UserId = req.UserId
 	}
@@ -261,13 +275,15 @@ func (w *Website) SaveArchive(req *request.Archive) (archive *model.Archive, err
 			baseHost = urls.Host
 		}
 
-		//提取描述
-		if req.Description == "" {
-			textRune := []rune(strings.ReplaceAll(CleanTagsAndSpaces(doc.Text()), "\n", " "))
-			if len(textRune) > 150 {
-				archive.Description = string(textRune[:150])
-			} else {
-				archive.Description = string(textRune)
+		//提取描述和关键词
+		if req.Description == "" || req.Keywords == "" {
+			text := CleanTagsAndSpaces(doc.Text())
+			if req.Description == "" {
+				archive.Description = w.ExtractSummary(text, extractSummaryLength)
+			}
+			if req.Keywords == "" {
+				archive.Keywords = strings.Join(w.ExtractKeywords(req.Title, text, extractKeywordLimit), ",")
+				archive.AutoKeywords = 1
 			}
 		}
 		//下载远程图片
@@ -395,6 +411,13 @@ func (w *Website) SaveArchive(req *request.Archive) (archive *model.Archive, err
 	if oldFixedLink != "" || archive.FixedLink != "" {
 		w.DeleteCacheFixedLinks()
 	}
+	newLink := w.GetUrl("archive", archive, 0)
+	if oldLink != "" {
+		w.AddAutoRedirect(oldLink, newLink)
+		if oldLink != newLink && w.PluginSitemap.AutoBuild == 1 {
+			_ = w.RemoveSitemap("archive", oldLink)
+		}
+	}
 
 	// 尝试添加全文索引
 	w.AddFulltextIndex(&TinyArchive{
@@ -404,32 +427,53 @@ func (w *Website) SaveArchive(req *request.Archive) (archive *model.Archive, err
 		Keywords: archive.Keywords,
 		Content:  archiveData.Content,
 	})
+	w.AuditArchive(archive, archiveData.Content)
+	w.BuildInternalLinks("archive", archive.Id, archiveData.Content)
+	w.SaveArchiveFingerprint(archive.Id, archive.Title, archiveData.Content)
 
-	err = w.SuccessReleaseArchive(archive, newPost)
+	// 已发布的文档链接没有变化时，sitemap 中已经有了，不需要重写
+	err = w.releaseArchive(archive, newPost, oldLink != newLink)
 	return
 }
 
 func (w *Website) SuccessReleaseArchive(archive *model.Archive, newPost bool) error {
+	return w.releaseArchive(archive, newPost, true)
+}
+
+// releaseArchive sitemapChanged 为 false 时表示文档之前已发布且链接不变，不需要更新 sitemap
+func (w *Website) releaseArchive(archive *model.Archive, newPost bool, sitemapChanged bool) error {
 	archive.GetThumb(w.PluginStorage.StorageUrl, w.Content.DefaultThumb)
 	archive.Link = w.GetUrl("archive", archive, 0)
 	//添加锚文本
 	if w.PluginAnchor.ReplaceWay == 1 {
 		go w.ReplaceContent(nil, "archive", archive.Id, archive.Link)
 	}
-	//提取锚文本
-	if w.PluginAnchor.KeywordWay == 1 && archive.Status == config.ContentStatusOK {
+	//提取锚文本，自动提取的关键词不作为锚文本
+	if w.PluginAnchor.KeywordWay == 1 && archive.Status == config.ContentStatusOK && archive.AutoKeywords == 0 {
 
 		go w.AutoInsertAnchor(archive.Id, archive.Keywords, archive.Link)
 	}
 
 	w.DeleteCacheIndex()
+	w.awardPublishPoints(archive)
 
-	//新发布的文章，执行推送
-	if newPost && archive.Status == config.ContentStatusOK {
-		go w.PushArchive(archive.Link)
+	if archive.Status == config.ContentStatusOK {
+		//新发布的文章，执行推送
+		if newPost {
+			go w.PushArchive(archive.Link)
+		} else {
+			go w.PushChangedLinks(config.PushActionUpdate, archive.Link)
+		}
+		// 由草稿或待审核改为发布的文档也要加入sitemap，已存在的链接只更新
+		if w.PluginSitemap.AutoBuild == 1 && sitemapChanged {
+			_ = w.AddonSitemap("archive", archive.Link, time.Unix(archive.UpdatedTime, 0).Format("2006-01-02"), archive)
+		}
+	} else if !newPost {
+		// 修改为草稿或待发布的文档移出sitemap
 		if w.PluginSitemap.AutoBuild == 1 {
-			_ = w.AddonSitemap("archive", archive.Link, time.Unix(archive.UpdatedTime, 0).Format("2006-01-02"))
+			_ = w.RemoveSitemap("archive", archive.Link)
 		}
+		go w.PushChangedLinks(config.PushActionDelete, archive.Link)
 	}
 
 	return nil
@@ -456,15 +500,23 @@ func (w *Website) RecoverArchive(archive *model.Archive) error {
 		w.DeleteCacheFixedLinks()
 	}
 	w.DeleteCacheIndex()
+	if archive.Status == config.ContentStatusOK && w.PluginSitemap.AutoBuild == 1 {
+		_ = w.AddonSitemap("archive", w.GetUrl("archive", archive, 0), time.Unix(archive.UpdatedTime, 0).Format("2006-01-02"), archive)
+	}
 	var doc TinyArchive
 	w.DB.Table("`archives` as a").Joins("left join `archive_data` as d on a.id=d.id").Select("a.id,a.title,a.keywords,a.module_id,d.content").Where("a.`id` > ?", archive.Id).Take(&doc)
 	// 尝试添加全文索引
 	w.AddFulltextIndex(&doc)
+	if archiveData, err := w.GetArchiveDataById(archive.Id); err == nil {
+		w.SaveArchiveFingerprint(archive.Id, archive.Title, archiveData.Content)
+	}
 
 	return nil
 }
 
 func (w *Website) DeleteArchive(archive *model.Archive) error {
+	// 回收站中的文档已经移出sitemap
+	published := !archive.DeletedAt.Valid && archive.Status == config.ContentStatusOK
 	if archive.DeletedAt.Valid {
 		if err := w.DB.Unscoped().Delete(archive).Error; err != nil {
 			return err
@@ -480,6 +532,16 @@ func (w *Website) DeleteArchive(archive *model.Archive) error {
 	}
 	w.DeleteCacheIndex()
 	w.RemoveFulltextIndex(archive.Id)
+	w.DeleteSeoAudit("archive", archive.Id)
+	w.DeleteInternalLinks("archive", archive.Id)
+	w.DeleteArchiveFingerprint(archive.Id)
+	if published {
+		link := w.GetUrl("archive", archive, 0)
+		if w.PluginSitemap.AutoBuild == 1 {
+			_ = w.RemoveSitemap("archive", link)
+		}
+		go w.PushChangedLinks(config.PushActionDelete, link)
+	}
 
 	return nil
 }
@@ -502,6 +564,9 @@ func (w *Website) UpdateArchiveStatus(req *request.ArchivesUpdateRequest) error
 	if req.Status == config.ContentStatusOK {
 		w.DB.Model(&model.Archive{}).Where("`id` IN (?) and `created_time` > ?", req.Ids, time.Now().Unix()).UpdateColumn("created_time", time.Now().Unix())
 	}
+	if err == nil {
+		w.syncArchivesStatus(req.Ids)
+	}
 	return err
 }
 
@@ -523,9 +588,14 @@ func (w *Website) UpdateArchiveCategory(req *request.ArchivesUpdateRequest) erro
 	if len(req.Ids) == 0 {
 		return errors.New(w.Lang("无可操作的文档"))
 	}
+	snapshot := w.snapshotArchiveLinks(req.Ids)
 	err := w.DB.Model(&model.Archive{}).Where("id IN (?)", req.Ids).UpdateColumn("category_id", req.CategoryId).Error
+	if err != nil {
+		return err
+	}
+	w.redirectChangedLinks(snapshot)
 
-	return err
+	return nil
 }
 
 // DeleteCacheFixedLinks 固定链接
@@ -584,13 +654,14 @@ func (w *Website) PublishPlanArchives() {
 			link := w.GetUrl("archive", archive, 0)
 
 			//提取锚文本
-			if w.PluginAnchor.KeywordWay == 1 {
+			if w.PluginAnchor.KeywordWay == 1 && archive.AutoKeywords == 0 {
 				go w.AutoInsertAnchor(archive.Id, archive.Keywords, link)
 			}
 			go w.PushArchive(link)
 			if w.PluginSitemap.AutoBuild == 1 {
-				_ = w.AddonSitemap("archive", link, time.Unix(archive.UpdatedTime, 0).Format("2006-01-02"))
+				_ = w.AddonSitemap("archive", link, time.Unix(archive.UpdatedTime, 0).Format("2006-01-02"), archive)
 			}
+			w.awardPublishPoints(archive)
 		}
 	}
 }
@@ -0,0 +1,665 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/PuerkitoBio/goquery"
+	"github.com/lib/pq"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/response"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	LinkCheckTypeLink  = "link"
+	LinkCheckTypeImage = "image"
+
+	LinkCheckFixRemove  = "remove"
+	LinkCheckFixReplace = "replace"
+)
+
+// linkCheckItem 扫描时需要检查的一个链接
+type linkCheckItem struct {
+	itemType string
+	itemId   uint
+	title    string
+	link     string
+	linkType string
+	anchor   string
+}
+
+// StartBrokenLinkCheck 后台扫描文档、分类和单页内容中的失效链接和图片，已在扫描中的返回错误
+func (w *Website) StartBrokenLinkCheck() error {
+	if w.DB == nil {
+		return errors.New(w.Lang("站点未初始化"))
+	}
+	w.brokenLinkMutex.Lock()
+	if w.brokenLinkStatus != nil && w.brokenLinkStatus.Running {
+		w.brokenLinkMutex.Unlock()
+		return errors.New(w.Lang("正在扫描中，请稍后再试"))
+	}
+	w.brokenLinkStatus = &response.BrokenLinkStatus{
+		Running:   true,
+		StartTime: time.Now().Unix(),
+	}
+	w.brokenLinkMutex.Unlock()
+	go w.runBrokenLinkCheck()
+
+	return nil
+}
+
+// GetBrokenLinkStatus 返回扫描进度的副本，扫描过程中进度会在后台更新
+func (w *Website) GetBrokenLinkStatus() *response.BrokenLinkStatus {
+	w.brokenLinkMutex.Lock()
+	defer w.brokenLinkMutex.Unlock()
+	if w.brokenLinkStatus == nil {
+		return &response.BrokenLinkStatus{}
+	}
+	status := *w.brokenLinkStatus
+
+	return &status
+}
+
+// updateBrokenLinkStatus 在锁内更新扫描进度
+func (w *Website) updateBrokenLinkStatus(fn func(status *response.BrokenLinkStatus)) {
+	w.brokenLinkMutex.Lock()
+	defer w.brokenLinkMutex.Unlock()
+	if w.brokenLinkStatus != nil {
+		fn(w.brokenLinkStatus)
+	}
+}
+
+// CheckBrokenLinks 计划任务按设置的间隔天数自动扫描
+func (w *Website) CheckBrokenLinks() {
+	if w.DB == nil || w.PluginBrokenLink.AutoCheck <= 0 {
+		return
+	}
+	if time.Now().Unix()-w.PluginBrokenLink.LastCheckTime < int64(w.PluginBrokenLink.AutoCheck)*86400 {
+		return
+	}
+	_ = w.StartBrokenLinkCheck()
+}
+
+func (w *Website) runBrokenLinkCheck() {
+	defer func() {
+		err := recover()
+		endTime := time.Now().Unix()
+		w.updateBrokenLinkStatus(func(status *response.BrokenLinkStatus) {
+			if err != nil {
+				status.Message = fmt.Sprintf("%v", err)
+			}
+			status.Running = false
+			status.EndTime = endTime
+		})
+		w.PluginBrokenLink.LastCheckTime = endTime
+		_ = w.SaveSettingValue(BrokenLinkSettingKey, w.PluginBrokenLink)
+	}()
+
+	w.DB.Unscoped().Where("1 = 1").Delete(&model.BrokenLink{})
+	var archiveTotal, categoryTotal int64
+	w.DB.Model(&model.Archive{}).Count(&archiveTotal)
+	w.DB.Model(&model.Category{}).Count(&categoryTotal)
+	w.updateBrokenLinkStatus(func(status *response.BrokenLinkStatus) {
+		status.Total = archiveTotal + categoryTotal
+	})
+
+	// 站内链接在多篇内容中重复出现，检查结果缓存起来
+	internalResults := map[string]string{}
+	var externals []*linkCheckItem
+	checkItems := func(items []*linkCheckItem) {
+		for _, item := range items {
+			if !w.isInternalLink(item.link) {
+				if w.PluginBrokenLink.CheckExternal == 1 {
+					externals = append(externals, item)
+				}
+				continue
+			}
+			linkPath := GetRedirectPath(item.link)
+			reason, ok := internalResults[linkPath]
+			if !ok {
+				reason = w.checkInternalLink(linkPath, item.linkType)
+				internalResults[linkPath] = reason
+			}
+			if reason != "" {
+				w.saveBrokenLink(item, 404, reason, false)
+			}
+		}
+	}
+
+	var lastId uint
+	for {
+		var archives []*model.Archive
+		w.DB.Model(&model.Archive{}).Select("id", "title", "images").Where("`id` > ?", lastId).Order("`id` asc").Limit(100).Find(&archives)
+		if len(archives) == 0 {
+			break
+		}
+		ids := make([]uint, 0, len(archives))
+		for _, v := range archives {
+			ids = append(ids, v.Id)
+		}
+		var archiveData []*model.ArchiveData
+		w.DB.Where("`id` IN (?)", ids).Find(&archiveData)
+		contents := map[uint]string{}
+		for _, v := range archiveData {
+			contents[v.Id] = v.Content
+		}
+		for _, archive := range archives {
+			items := extractCheckLinks("archive", archive.Id, archive.Title, contents[archive.Id])
+			for _, img := range archive.Images {
+				items = append(items, &linkCheckItem{
+					itemType: "archive",
+					itemId:   archive.Id,
+					title:    archive.Title,
+					link:     img,
+					linkType: LinkCheckTypeImage,
+				})
+			}
+			checkItems(items)
+			w.updateBrokenLinkStatus(func(status *response.BrokenLinkStatus) {
+				status.Finished++
+			})
+		}
+		lastId = archives[len(archives)-1].Id
+	}
+
+	lastId = 0
+	for {
+		var categories []*model.Category
+		w.DB.Where("`id` > ?", lastId).Order("`id` asc").Limit(100).Find(&categories)
+		if len(categories) == 0 {
+			break
+		}
+		for _, category := range categories {
+			itemType := "category"
+			if category.Type == config.CategoryTypePage {
+				itemType = "page"
+			}
+			items := extractCheckLinks(itemType, category.Id, category.Title, category.Content)
+			for _, img := range category.Images {
+				items = append(items, &linkCheckItem{
+					itemType: itemType,
+					itemId:   category.Id,
+					title:    category.Title,
+					link:     img,
+					linkType: LinkCheckTypeImage,
+				})
+			}
+			checkItems(items)
+			w.updateBrokenLinkStatus(func(status *response.BrokenLinkStatus) {
+				status.Finished++
+			})
+		}
+		lastId = categories[len(categories)-1].Id
+	}
+
+	if len(externals) > 0 {
+		w.checkExternalLinks(externals)
+	}
+}
+
+// extractCheckLinks 提取内容中的链接和图片
+func extractCheckLinks(itemType string, itemId uint, title, content string) []*linkCheckItem {
+	var items []*linkCheckItem
+	if strings.TrimSpace(content) == "" {
+		return items
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return items
+	}
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		href, _ := s.Attr("href")
+		items = append(items, &linkCheckItem{
+			itemType: itemType,
+			itemId:   itemId,
+			title:    title,
+			link:     strings.TrimSpace(href),
+			linkType: LinkCheckTypeLink,
+			anchor:   strings.TrimSpace(s.Text()),
+		})
+	})
+	doc.Find("img[src]").Each(func(i int, s *goquery.Selection) {
+		src, _ := s.Attr("src")
+		alt, _ := s.Attr("alt")
+		items = append(items, &linkCheckItem{
+			itemType: itemType,
+			itemId:   itemId,
+			title:    title,
+			link:     strings.TrimSpace(src),
+			linkType: LinkCheckTypeImage,
+			anchor:   strings.TrimSpace(alt),
+		})
+	})
+	// 锚点、脚本、邮件、电话、内嵌图片和相对路径不检查
+	var result []*linkCheckItem
+	for _, item := range items {
+		lower := strings.ToLower(item.link)
+		if item.link == "" || strings.HasPrefix(lower, "#") ||
+			strings.HasPrefix(lower, "javascript:") ||
+			strings.HasPrefix(lower, "mailto:") ||
+			strings.HasPrefix(lower, "tel:") ||
+			strings.HasPrefix(lower, "data:") {
+			continue
+		}
+		if !strings.HasPrefix(lower, "http://") && !strings.HasPrefix(lower, "https://") && !strings.HasPrefix(item.link, "/") {
+			continue
+		}
+		result = append(result, item)
+	}
+
+	return result
+}
+
+// isInternalLink 以 / 开头的路径，或域名是网站、手机端、存储地址的都是站内链接
+func (w *Website) isInternalLink(link string) bool {
+	if strings.HasPrefix(link, "/") && !strings.HasPrefix(link, "//") {
+		return true
+	}
+	parsed, err := url.Parse(link)
+	if err != nil {
+		return false
+	}
+	for _, v := range []string{w.System.BaseUrl, w.System.MobileUrl, w.PluginStorage.StorageUrl} {
+		if v == "" {
+			continue
+		}
+		if base, err := url.Parse(v); err == nil && base.Host != "" && strings.EqualFold(base.Host, parsed.Host) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkInternalLink 检查站内链接，返回失效原因，正常的返回空字符串
+func (w *Website) checkInternalLink(linkPath, linkType string) string {
+	if idx := strings.IndexAny(linkPath, "?#"); idx >= 0 {
+		linkPath = linkPath[:idx]
+	}
+	if unescaped, err := url.PathUnescape(linkPath); err == nil {
+		linkPath = unescaped
+	}
+	if linkPath == "" || linkPath == "/" {
+		return ""
+	}
+	// 公共目录中存在的文件
+	filePath := strings.TrimPrefix(linkPath, "/")
+	if info, err := os.Stat(w.PublicPath + filePath); err == nil && !info.IsDir() {
+		return ""
+	}
+	if linkType == LinkCheckTypeImage || strings.HasPrefix(filePath, "uploads/") || strings.HasPrefix(filePath, "static/") {
+		// 上传的文件可能保存在云存储中，以附件记录为准
+		if strings.HasPrefix(filePath, "uploads/") {
+			location := path.Join(path.Dir(filePath), strings.TrimPrefix(path.Base(filePath), "thumb_"))
+			var total int64
+			w.DB.Model(&model.Attachment{}).Where("`file_location` = ?", location).Count(&total)
+			if total > 0 {
+				return ""
+			}
+			return w.Lang("附件不存在")
+		}
+		if linkType == LinkCheckTypeImage || strings.HasPrefix(filePath, "static/") {
+			return w.Lang("文件不存在")
+		}
+	}
+	if redirect := w.MatchRedirect(linkPath); redirect != nil {
+		if redirect.StatusCode == 410 {
+			return w.Lang("链接已设置为永久删除")
+		}
+		return ""
+	}
+	params, _ := w.ParseRoute(linkPath)
+	switch params["match"] {
+	case "notfound":
+		return w.Lang("页面不存在")
+	case "archive":
+		var archive *model.Archive
+		if id, err := strconv.Atoi(params["id"]); err == nil && id > 0 {
+			archive, _ = w.GetArchiveById(uint(id))
+		} else if params["filename"] != "" {
+			archive, _ = w.GetArchiveByUrlToken(params["filename"])
+		}
+		if archive == nil {
+			return w.Lang("文档不存在")
+		}
+		if archive.Status != config.ContentStatusOK {
+			return w.Lang("文档未发布")
+		}
+	case "category", "page":
+		var category *model.Category
+		if id, err := strconv.Atoi(params["id"]); err == nil && id > 0 {
+			category = w.GetCategoryFromCache(uint(id))
+		} else if params["filename"] != "" {
+			category = w.GetCategoryFromCacheByToken(params["filename"])
+		}
+		if category == nil {
+			return w.Lang("分类不存在")
+		}
+		if category.Status != config.ContentStatusOK {
+			return w.Lang("分类已隐藏")
+		}
+	case "tag":
+		var tag *model.Tag
+		if id, err := strconv.Atoi(params["id"]); err == nil && id > 0 {
+			tag, _ = w.GetTagById(uint(id))
+		} else if params["filename"] != "" {
+			tag, _ = w.GetTagByUrlToken(params["filename"])
+		}
+		if tag == nil {
+			return w.Lang("标签不存在")
+		}
+	}
+
+	return ""
+}
+
+// checkExternalLinks 按设置的并发数检查站外链接，同一个链接只请求一次
+func (w *Website) checkExternalLinks(items []*linkCheckItem) {
+	groups := map[string][]*linkCheckItem{}
+	for _, item := range items {
+		groups[item.link] = append(groups[item.link], item)
+	}
+	client := w.newLinkCheckClient(time.Duration(w.PluginBrokenLink.Timeout) * time.Second)
+	sem := make(chan struct{}, w.PluginBrokenLink.Concurrency)
+	var wg sync.WaitGroup
+	var mu sync.Mutex
+	for link, list := range groups {
+		wg.Add(1)
+		sem <- struct{}{}
+		go func(link string, list []*linkCheckItem) {
+			defer func() {
+				<-sem
+				wg.Done()
+			}()
+			statusCode, err := requestLinkStatus(client, link)
+			if err == nil && statusCode < 400 {
+				return
+			}
+			reason := fmt.Sprintf("HTTP %d", statusCode)
+			if err != nil {
+				reason = err.Error()
+			}
+			mu.Lock()
+			for _, item := range list {
+				w.saveBrokenLink(item, statusCode, reason, true)
+			}
+			mu.Unlock()
+		}(link, list)
+	}
+	wg.Wait()
+}
+
+// newLinkCheckClient 检查站外链接的客户端，只允许连接公网地址。
+// 在建立连接时校验解析后的IP，跳转后的链接和域名重新解析的情况同样会被拦截
+func (w *Website) newLinkCheckClient(timeout time.Duration) *http.Client {
+	errPrivate := errors.New(w.Lang("不允许访问内网地址"))
+	dialer := &net.Dialer{
+		Timeout: timeout,
+		Control: func(network, address string, c syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return errPrivate
+			}
+			return nil
+		},
+	}
+	transport := &http.Transport{
+		DialContext:         dialer.DialContext,
+		TLSHandshakeTimeout: timeout,
+		MaxIdleConnsPerHost: 2,
+	}
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			if len(via) >= 10 {
+				return errors.New("stopped after 10 redirects")
+			}
+			if req.URL.Scheme != "http" && req.URL.Scheme != "https" {
+				return errors.New("unsupported redirect scheme: " + req.URL.Scheme)
+			}
+			return nil
+		},
+	}
+}
+
+// isPublicIP 排除本机、内网、链路本地、组播和未指定地址
+func isPublicIP(ip net.IP) bool {
+	if ip.IsLoopback() || ip.IsPrivate() || ip.IsUnspecified() ||
+		ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() {
+		return false
+	}
+	// 0.0.0.0/8 和运营商级NAT 100.64.0.0/10
+	if ip4 := ip.To4(); ip4 != nil && (ip4[0] == 0 || (ip4[0] == 100 && ip4[1]&0xc0 == 64)) {
+		return false
+	}
+
+	return true
+}
+
+// requestLinkStatus 先用 HEAD 请求，不支持 HEAD 的站点再用 GET 请求
+func requestLinkStatus(client *http.Client, link string) (int, error) {
+	var statusCode int
+	for _, method := range []string{http.MethodHead, http.MethodGet} {
+		req, err := http.NewRequest(method, link, nil)
+		if err != nil {
+			return 0, err
+		}
+		req.Header.Set("User-Agent", "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/94.0.4606.81 Safari/537.36")
+		resp, err := client.Do(req)
+		if err != nil {
+			return 0, err
+		}
+		_ = resp.Body.Close()
+		statusCode = resp.StatusCode
+		if statusCode != http.StatusMethodNotAllowed && statusCode != http.StatusForbidden && statusCode != http.StatusNotImplemented {
+			break
+		}
+	}
+
+	return statusCode, nil
+}
+
+func (w *Website) saveBrokenLink(item *linkCheckItem, statusCode int, reason string, external bool) {
+	if len(item.link) > 1000 {
+		return
+	}
+	brokenLink := model.BrokenLink{
+		ItemType:   item.itemType,
+		ItemId:     item.itemId,
+		Title:      truncateBrokenLinkText(item.title),
+		Link:       item.link,
+		LinkType:   item.linkType,
+		Anchor:     truncateBrokenLinkText(item.anchor),
+		StatusCode: statusCode,
+		Reason:     truncateBrokenLinkText(reason),
+	}
+	if external {
+		brokenLink.External = 1
+	}
+	if w.DB.Create(&brokenLink).Error == nil {
+		w.updateBrokenLinkStatus(func(status *response.BrokenLinkStatus) {
+			status.Found++
+		})
+	}
+}
+
+func truncateBrokenLinkText(text string) string {
+	if utf8.RuneCountInString(text) > 250 {
+		text = string([]rune(text)[:250])
+	}
+
+	return text
+}
+
+func (w *Website) GetBrokenLinks(itemType, linkType, keyword string, currentPage, pageSize int) ([]*model.BrokenLink, int64) {
+	var links []*model.BrokenLink
+	var total int64
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	offset := (currentPage - 1) * pageSize
+	builder := w.DB.Model(&model.BrokenLink{})
+	if itemType != "" {
+		builder = builder.Where("`item_type` = ?", itemType)
+	}
+	if linkType != "" {
+		builder = builder.Where("`link_type` = ?", linkType)
+	}
+	if keyword != "" {
+		builder = builder.Where("`link` LIKE ? OR `title` LIKE ?", "%"+keyword+"%", "%"+keyword+"%")
+	}
+	builder.Count(&total).Order("`id` desc").Limit(pageSize).Offset(offset).Find(&links)
+
+	return links, total
+}
+
+// FixBrokenLinks 批量修复失效链接：删除链接时保留链接文字，删除图片时整个图片标签去掉；替换时改为新的链接。
+// 内容的替换使用内容替换插件的正则规则
+func (w *Website) FixBrokenLinks(ids []uint, action, toUrl string) (int, error) {
+	if len(ids) == 0 {
+		return 0, errors.New(w.Lang("请选择要处理的链接"))
+	}
+	toUrl = strings.TrimSpace(toUrl)
+	if action == LinkCheckFixReplace && toUrl == "" {
+		return 0, errors.New(w.Lang("请填写新的链接"))
+	}
+	if action != LinkCheckFixReplace && action != LinkCheckFixRemove {
+		return 0, errors.New(w.Lang("不支持的操作"))
+	}
+	var brokenLinks []*model.BrokenLink
+	w.DB.Where("`id` IN (?)", ids).Find(&brokenLinks)
+	// 同一篇内容的多个链接一次处理
+	type fixTarget struct {
+		itemType string
+		itemId   uint
+	}
+	targets := map[fixTarget][]*model.BrokenLink{}
+	for _, v := range brokenLinks {
+		key := fixTarget{itemType: v.ItemType, itemId: v.ItemId}
+		targets[key] = append(targets[key], v)
+	}
+	var total int
+	var fixedIds []uint
+	for target, links := range targets {
+		replacer := make([]config.ReplaceKeyword, 0, len(links))
+		images := map[string]struct{}{}
+		for _, v := range links {
+			replacer = append(replacer, brokenLinkReplacer(v, action, toUrl))
+			if v.LinkType == LinkCheckTypeImage {
+				images[v.Link] = struct{}{}
+			}
+			fixedIds = append(fixedIds, v.Id)
+		}
+		if target.itemType == "archive" {
+			// 组图需要使用数据库中的原始地址，不能用补全了存储地址的
+			var archive model.Archive
+			if err := w.DB.Select("id", "images").Where("`id` = ?", target.itemId).Take(&archive).Error; err != nil {
+				continue
+			}
+			archiveData, err := w.GetArchiveDataById(target.itemId)
+			if err == nil {
+				content := w.replaceContentText(archiveData.Content, replacer)
+				if content != archiveData.Content {
+					w.DB.Model(archiveData).UpdateColumn("content", content)
+					w.BuildInternalLinks("archive", archiveData.Id, content)
+				}
+			}
+			if newImages, changed := fixBrokenImages(archive.Images, images, action, toUrl); changed {
+				w.DB.Model(&archive).UpdateColumn("images", pq.StringArray(newImages))
+			}
+		} else {
+			var category model.Category
+			if err := w.DB.Where("`id` = ?", target.itemId).Take(&category).Error; err != nil {
+				continue
+			}
+			content := w.replaceContentText(category.Content, replacer)
+			if content != category.Content {
+				w.DB.Model(&category).UpdateColumn("content", content)
+				w.BuildInternalLinks("category", category.Id, content)
+			}
+			if newImages, changed := fixBrokenImages(category.Images, images, action, toUrl); changed {
+				w.DB.Model(&category).UpdateColumn("images", pq.StringArray(newImages))
+			}
+			w.DeleteCacheCategories()
+		}
+		total += len(links)
+	}
+	if len(fixedIds) > 0 {
+		w.DB.Unscoped().Where("`id` IN (?)", fixedIds).Delete(&model.BrokenLink{})
+	}
+
+	return total, nil
+}
+
+// brokenLinkReplacer 生成内容替换插件使用的正则规则，以 {} 包裹表示正则
+func brokenLinkReplacer(link *model.BrokenLink, action, toUrl string) config.ReplaceKeyword {
+	// 内容中的 & 可能被转义为 &amp;
+	quoted := strings.ReplaceAll(regexp.QuoteMeta(link.Link), "&", "(?:&|&amp;)")
+	if action == LinkCheckFixReplace {
+		return config.ReplaceKeyword{
+			From: `{(href|src)=(["'])` + quoted + `(["'])}`,
+			To:   "${1}=${2}" + strings.ReplaceAll(toUrl, "$", "$$") + "${3}",
+		}
+	}
+	if link.LinkType == LinkCheckTypeImage {
+		return config.ReplaceKeyword{
+			From: `{<img\s[^>]*src=["']` + quoted + `["'][^>]*>}`,
+			To:   "",
+		}
+	}
+
+	return config.ReplaceKeyword{
+		From: `{<a\s[^>]*href=["']` + quoted + `["'][^>]*>([\s\S]*?)</a>}`,
+		To:   "${1}",
+	}
+}
+
+// fixBrokenImages 处理组图中的失效图片
+func fixBrokenImages(images []string, broken map[string]struct{}, action, toUrl string) ([]string, bool) {
+	if len(broken) == 0 || len(images) == 0 {
+		return images, false
+	}
+	var changed bool
+	result := make([]string, 0, len(images))
+	for _, img := range images {
+		if _, ok := broken[img]; ok {
+			changed = true
+			if action == LinkCheckFixReplace {
+				result = append(result, toUrl)
+			}
+			continue
+		}
+		result = append(result, img)
+	}
+
+	return result, changed
+}
+
+func (w *Website) DeleteBrokenLinks(ids []uint) error {
+	if len(ids) == 0 {
+		return errors.New(w.Lang("请选择要删除的链接"))
+	}
+
+	return w.DB.Unscoped().Where("`id` IN (?)", ids).Delete(&model.BrokenLink{}).Error
+}
@@ -62,11 +62,16 @@ func (w *Website) GetCategoryByFunc(ops func(tx *gorm.DB) *gorm.DB) (*model.Cate
 
 func (w *Website) SaveCategory(req *request.Category) (category *model.Category, err error) {
 	newPost := false
+	// 分类的链接名或上级调整后，分类和其中文档的链接可能会变化，先记录原来的链接
+	var snapshot *linkSnapshot
 	if req.Id > 0 {
 		category, err = w.GetCategoryById(req.Id)
 		if err != nil {
 			return nil, err
 		}
+		if category.UrlToken != library.ParseUrlToken(req.UrlToken) || category.ParentId != req.ParentId {
+			snapshot = w.snapshotCategoryLinks(category.Id)
+		}
 	} else {
 		category = &model.Category{
 			Status: 1,
@@ -128,12 +133,7 @@ func (w *Website) SaveCategory(req *request.Category) (category *model.Category,
 
 		//提取描述
 		if category.Description == "" {
-			textRune := []rune(strings.ReplaceAll(CleanTagsAndSpaces(doc.Text()), "\n", " "))
-			if len(textRune) > 150 {
-				category.Description = string(textRune[:150])
-			} else {
-				category.Description = string(textRune)
-			}
+			category.Description = w.ExtractSummary(CleanTagsAndSpaces(doc.Text()), extractSummaryLength)
 		}
 		//下载远程图片
 		if w.Content.RemoteDownload == 1 {
@@ -196,12 +196,25 @@ func (w *Website) SaveCategory(req *request.Category) (category *model.Category,
 		link := w.GetUrl("category", category, 0)
 		go w.PushArchive(link)
 		if w.PluginSitemap.AutoBuild == 1 {
-			_ = w.AddonSitemap("category", link, time.Unix(category.UpdatedTime, 0).Format("2006-01-02"))
+			_ = w.AddonSitemap("category", link, time.Unix(category.UpdatedTime, 0).Format("2006-01-02"), category)
+		}
+	} else if !newPost && category.Status == config.ContentStatusOK {
+		go w.PushChangedLinks(config.PushActionUpdate, w.GetUrl("category", category, 0))
+	} else if !newPost {
+		link := w.GetUrl("category", category, 0)
+		if w.PluginSitemap.AutoBuild == 1 {
+			_ = w.RemoveSitemap("category", link)
 		}
+		go w.PushChangedLinks(config.PushActionDelete, link)
 	}
+	w.AuditCategory(category)
+	w.BuildInternalLinks("category", category.Id, category.Content)
 	category.GetThumb(w.PluginStorage.StorageUrl, w.Content.DefaultThumb)
 	w.DeleteCacheCategories()
 	w.DeleteCacheIndex()
+	if snapshot != nil {
+		go w.redirectChangedLinks(snapshot)
+	}
 
 	return
 }
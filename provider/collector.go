@@ -87,6 +87,9 @@ func (w *Website) SaveUserCollectorSetting(req config.CollectorJson, focus bool)
 		if req.DailyLimit > 0 {
 			collector.DailyLimit = req.DailyLimit
 		}
+		if req.DuplicateThreshold > 0 {
+			collector.DuplicateThreshold = req.DuplicateThreshold
+		}
 	}
 
 	_ = w.SaveSettingValue(CollectorSettingKey, collector)
@@ -222,6 +225,9 @@ func (w *Website) SaveCollectArticle(archive *request.Archive, keyword *model.Ke
 		//log.Println("已存在于数据库", archive.OriginTitle)
 		return errors.New(w.Lang("已存在于数据库"))
 	}
+	if w.CheckContentDuplicate(archive.Title, archive.Content) {
+		return errors.New(w.Lang("与已有文档内容重复"))
+	}
 
 	archive.KeywordId = keyword.Id
 	categoryId := keyword.CategoryId
@@ -109,6 +109,10 @@ func (w *Website) GenerateCombination(keyword *model.Keyword) (int, error) {
 	} else {
 		archive.Draft = false
 	}
+	if w.CheckContentDuplicate(archive.Title, archive.Content) {
+		log.Println("与已有文档内容重复：", archive.Title)
+		return 0, nil
+	}
 	res, err := w.SaveArchive(&archive)
 	if err != nil {
 		log.Println("保存组合文章出错：", archive.Title, err.Error())
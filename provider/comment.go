@@ -1,6 +1,8 @@
 package provider
 
 import (
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/library"
 	"kandaoni.com/anqicms/model"
 	"kandaoni.com/anqicms/request"
 )
@@ -13,7 +15,9 @@ func (w *Website) SaveComment(req *request.PluginComment) (comment *model.Commen
 		}
 	} else {
 		comment = &model.Comment{
-			Status:    0,
+			Status:    req.Status,
+			SpamScore: req.SpamScore,
+			SpamInfo:  req.SpamInfo,
 			ArchiveId: req.ArchiveId,
 			UserId:    req.UserId,
 			Ip:        req.Ip,
@@ -26,10 +30,15 @@ func (w *Website) SaveComment(req *request.PluginComment) (comment *model.Commen
 	comment.Content = req.Content
 
 	err = comment.Save(w.DB)
+	if err == nil && req.Id == 0 && comment.Status == model.StatusOk {
+		w.NotifyCommentReply(comment)
+		w.AwardUserPoints(comment.UserId, config.PointActionComment, comment.Id)
+	}
 	return
 }
 
-func (w *Website) GetCommentList(archiveId, userId uint, order string, currentPage int, pageSize int, offset int) ([]*model.Comment, int64, error) {
+// GetCommentList status 小于 0 时返回除垃圾评论外的所有评论
+func (w *Website) GetCommentList(archiveId, userId uint, status int, order string, currentPage int, pageSize int, offset int) ([]*model.Comment, int64, error) {
 	var comments []*model.Comment
 	if currentPage > 1 {
 		offset = (currentPage - 1) * pageSize
@@ -43,6 +52,11 @@ func (w *Website) GetCommentList(archiveId, userId uint, order string, currentPa
 	if userId > 0 {
 		builder = builder.Where("user_id = ?", userId)
 	}
+	if status >= 0 {
+		builder = builder.Where("status = ?", status)
+	} else {
+		builder = builder.Where("status != ?", model.StatusSpam)
+	}
 	if order != "" {
 		builder = builder.Order(order)
 	}
@@ -50,6 +64,7 @@ func (w *Website) GetCommentList(archiveId, userId uint, order string, currentPa
 		return nil, 0, err
 	}
 	for i, v := range comments {
+		comments[i].ContentHtml = library.RenderMarkdown(v.Content)
 		if v.ParentId > 0 {
 			var parent model.Comment
 			if err := w.DB.Where("id = ?", v.ParentId).First(&parent).Error; err == nil {
@@ -61,6 +76,90 @@ func (w *Website) GetCommentList(archiveId, userId uint, order string, currentPa
 	return comments, total, nil
 }
 
+// GetCommentTree 按主题分页获取评论树，depth 为包含顶层评论在内的层数，
+// 每条评论最多带出 replyLimit 条回复，更多的回复通过 GetCommentReplies 分页加载
+func (w *Website) GetCommentTree(archiveId uint, order string, depth, currentPage, pageSize, replyLimit int) ([]*model.Comment, int64) {
+	var comments []*model.Comment
+	var total int64
+	offset := (currentPage - 1) * pageSize
+	if order == "" {
+		order = "id desc"
+	}
+	w.DB.Model(&model.Comment{}).Where("`archive_id` = ? and `parent_id` = 0 and `status` != ?", archiveId, model.StatusSpam).
+		Count(&total).Order(order).Limit(pageSize).Offset(offset).Find(&comments)
+	w.loadCommentChildren(comments, depth, replyLimit)
+
+	return comments, total
+}
+
+// GetCommentReplies 分页获取一条评论的直接回复，用于评论树中的单个主题翻页
+func (w *Website) GetCommentReplies(parentId uint, depth, currentPage, pageSize, replyLimit int) ([]*model.Comment, int64) {
+	var comments []*model.Comment
+	var total int64
+	offset := (currentPage - 1) * pageSize
+	w.DB.Model(&model.Comment{}).Where("`parent_id` = ? and `status` != ?", parentId, model.StatusSpam).
+		Count(&total).Order("id asc").Limit(pageSize).Offset(offset).Find(&comments)
+	w.loadCommentChildren(comments, depth, replyLimit)
+
+	return comments, total
+}
+
+// loadCommentChildren 逐层加载回复，每层只查询一次，最后一层只统计回复数量
+func (w *Website) loadCommentChildren(comments []*model.Comment, depth, replyLimit int) {
+	if depth < 1 {
+		depth = 1
+	}
+	if depth > config.CommentTreeMaxDepth {
+		depth = config.CommentTreeMaxDepth
+	}
+	level := comments
+	for _, v := range level {
+		v.ContentHtml = library.RenderMarkdown(v.Content)
+	}
+	for i := 1; i < depth && len(level) > 0; i++ {
+		parents := make(map[uint]*model.Comment, len(level))
+		ids := make([]uint, 0, len(level))
+		for _, v := range level {
+			parents[v.Id] = v
+			ids = append(ids, v.Id)
+		}
+		var children []*model.Comment
+		w.DB.Where("`parent_id` IN (?) and `status` != ?", ids, model.StatusSpam).Order("id asc").Find(&children)
+		var next []*model.Comment
+		for _, child := range children {
+			parent := parents[child.ParentId]
+			parent.ReplyCount++
+			if len(parent.Children) >= replyLimit {
+				continue
+			}
+			child.ContentHtml = library.RenderMarkdown(child.Content)
+			parent.Children = append(parent.Children, child)
+			next = append(next, child)
+		}
+		level = next
+	}
+	if len(level) == 0 {
+		return
+	}
+	parents := make(map[uint]*model.Comment, len(level))
+	ids := make([]uint, 0, len(level))
+	for _, v := range level {
+		parents[v.Id] = v
+		ids = append(ids, v.Id)
+	}
+	var counts []struct {
+		ParentId uint
+		Total    int64
+	}
+	w.DB.Model(&model.Comment{}).Where("`parent_id` IN (?) and `status` != ?", ids, model.StatusSpam).
+		Select("`parent_id`, count(1) as total").Group("parent_id").Scan(&counts)
+	for _, v := range counts {
+		if parent, ok := parents[v.ParentId]; ok {
+			parent.ReplyCount = v.Total
+		}
+	}
+}
+
 func (w *Website) GetCommentById(id uint) (*model.Comment, error) {
 	var comment model.Comment
 	if err := w.DB.Where("id = ?", id).First(&comment).Error; err != nil {
@@ -0,0 +1,237 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"kandaoni.com/anqicms/library"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/request"
+	"math"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	CommentTrainedHam  = uint(1)
+	CommentTrainedSpam = uint(2)
+
+	// 正常评论和垃圾评论都至少训练了这么多条之后，分类器的结果才参与评分
+	commentSpamMinTrained = 10
+	// 参与计算的最有区分度的词数量
+	commentSpamMaxTokens = 15
+)
+
+var commentLinkRe = regexp.MustCompile(`(?i)(?:https?://|www\.)[^\s<>"']+`)
+
+// CheckCommentSpam 评论发布前的检查：限流超出时直接返回错误，
+// 否则根据蜜罐字段、链接数量、垃圾关键词和贝叶斯分类器计算垃圾分数，
+// 达到阈值的评论进入待审核或标记为垃圾评论，低于待审核分数的直接通过
+func (w *Website) CheckCommentSpam(req *request.PluginComment, honeypot string) error {
+	setting := w.PluginComment
+	if !setting.Open {
+		return nil
+	}
+	hourStamp := time.Now().Unix() - 3600
+	if setting.IpHourLimit > 0 && req.Ip != "" {
+		var total int64
+		w.DB.Model(&model.Comment{}).Where("`ip` = ? and `created_time` >= ?", req.Ip, hourStamp).Count(&total)
+		if total >= int64(setting.IpHourLimit) {
+			return errors.New(w.Lang("评论太频繁，请稍后再试"))
+		}
+	}
+	if setting.UserHourLimit > 0 && req.UserId > 0 {
+		var total int64
+		w.DB.Model(&model.Comment{}).Where("`user_id` = ? and `created_time` >= ?", req.UserId, hourStamp).Count(&total)
+		if total >= int64(setting.UserHourLimit) {
+			return errors.New(w.Lang("评论太频繁，请稍后再试"))
+		}
+	}
+
+	var score int
+	var reasons []string
+	if honeypot != "" {
+		score = 100
+		reasons = append(reasons, "honeypot")
+	} else {
+		if setting.MaxLinks > 0 {
+			links := len(commentLinkRe.FindAllString(req.Content, -1))
+			if links > setting.MaxLinks {
+				score += 50
+				reasons = append(reasons, fmt.Sprintf("links:%d", links))
+			}
+		}
+		if setting.SpamKeywords != "" {
+			content := strings.ToLower(req.Content + " " + req.UserName)
+			keywords := strings.Split(setting.SpamKeywords, "\n")
+			for _, v := range keywords {
+				v = strings.ToLower(strings.TrimSpace(v))
+				if v != "" && strings.Contains(content, v) {
+					score += 50
+					reasons = append(reasons, "keyword:"+v)
+					break
+				}
+			}
+		}
+		if setting.UseBayes {
+			prob := w.ClassifyCommentSpam(req.Content)
+			// 只有倾向于垃圾的结果才计分，0.5 计 0 分，1 计 100 分
+			if prob > 0.5 {
+				score += int((prob - 0.5) * 200)
+				reasons = append(reasons, fmt.Sprintf("bayes:%.2f", prob))
+			}
+		}
+	}
+	if score > 100 {
+		score = 100
+	}
+	req.SpamScore = score
+	req.SpamInfo = strings.Join(reasons, ",")
+	if utf8.RuneCountInString(req.SpamInfo) > 250 {
+		req.SpamInfo = string([]rune(req.SpamInfo)[:250])
+	}
+	if score >= setting.SpamScore {
+		req.Status = model.StatusSpam
+	} else if score >= setting.HoldScore {
+		req.Status = model.StatusWait
+	} else {
+		req.Status = model.StatusOk
+	}
+
+	return nil
+}
+
+// ClassifyCommentSpam 计算内容是垃圾评论的概率，训练数据不足或没有可用的词时返回 -1
+func (w *Website) ClassifyCommentSpam(content string) float64 {
+	var hamDocs, spamDocs int64
+	w.DB.Model(&model.Comment{}).Where("`trained` = ?", CommentTrainedHam).Count(&hamDocs)
+	w.DB.Model(&model.Comment{}).Where("`trained` = ?", CommentTrainedSpam).Count(&spamDocs)
+	if hamDocs < commentSpamMinTrained || spamDocs < commentSpamMinTrained {
+		return -1
+	}
+	tokens := commentSpamTokens(content)
+	if len(tokens) == 0 {
+		return -1
+	}
+	var rows []*model.CommentSpamToken
+	w.DB.Where("`token` IN (?)", tokens).Find(&rows)
+	var probs []float64
+	for _, v := range rows {
+		spamFreq := float64(v.SpamCount) / float64(spamDocs)
+		hamFreq := float64(v.HamCount) / float64(hamDocs)
+		if spamFreq+hamFreq == 0 {
+			continue
+		}
+		// 出现次数少的词向 0.5 靠拢
+		n := float64(v.SpamCount + v.HamCount)
+		prob := (0.5 + n*spamFreq/(spamFreq+hamFreq)) / (1 + n)
+		prob = math.Max(0.01, math.Min(0.99, prob))
+		probs = append(probs, prob)
+	}
+	if len(probs) == 0 {
+		return -1
+	}
+	sort.Slice(probs, func(i, j int) bool {
+		return math.Abs(probs[i]-0.5) > math.Abs(probs[j]-0.5)
+	})
+	if len(probs) > commentSpamMaxTokens {
+		probs = probs[:commentSpamMaxTokens]
+	}
+	var logSum float64
+	for _, p := range probs {
+		logSum += math.Log(1-p) - math.Log(p)
+	}
+
+	return 1 / (1 + math.Exp(logSum))
+}
+
+// TrainCommentSpam 后台审核通过或标记为垃圾时训练分类器，已训练为其他类别的评论会先撤销原来的训练
+func (w *Website) TrainCommentSpam(comment *model.Comment, status uint) {
+	var trained uint
+	if status == model.StatusOk {
+		trained = CommentTrainedHam
+	} else if status == model.StatusSpam {
+		trained = CommentTrainedSpam
+	} else {
+		return
+	}
+	if comment.Trained == trained {
+		return
+	}
+	tokens := commentSpamTokens(comment.Content)
+	if len(tokens) > 0 {
+		if comment.Trained > 0 {
+			w.updateCommentSpamTokens(tokens, comment.Trained, -1)
+		}
+		w.updateCommentSpamTokens(tokens, trained, 1)
+	}
+	comment.Trained = trained
+	w.DB.Model(&model.Comment{}).Where("`id` = ?", comment.Id).UpdateColumn("trained", trained)
+}
+
+func (w *Website) updateCommentSpamTokens(tokens []string, trained uint, delta int) {
+	column := "ham_count"
+	if trained == CommentTrainedSpam {
+		column = "spam_count"
+	}
+	if delta < 0 {
+		w.DB.Model(&model.CommentSpamToken{}).Where("`token` IN (?) and `"+column+"` > 0", tokens).
+			UpdateColumn(column, gorm.Expr("`"+column+"` - 1"))
+		return
+	}
+	rows := make([]*model.CommentSpamToken, 0, len(tokens))
+	for _, v := range tokens {
+		row := &model.CommentSpamToken{Token: v}
+		if trained == CommentTrainedSpam {
+			row.SpamCount = 1
+		} else {
+			row.HamCount = 1
+		}
+		rows = append(rows, row)
+	}
+	w.DB.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "token"}},
+		DoUpdates: clause.Assignments(map[string]interface{}{column: gorm.Expr("`" + column + "` + 1")}),
+	}).CreateInBatches(rows, 100)
+}
+
+// commentSpamTokens 评论分词，链接只保留域名，去掉单字和重复的词
+func commentSpamTokens(content string) []string {
+	content = strings.ToLower(library.StripTags(content))
+	var tokens []string
+	exists := map[string]struct{}{}
+	addToken := func(token string) {
+		token = strings.TrimSpace(token)
+		if utf8.RuneCountInString(token) < 2 {
+			return
+		}
+		if utf8.RuneCountInString(token) > 64 {
+			token = string([]rune(token)[:64])
+		}
+		if _, ok := exists[token]; ok {
+			return
+		}
+		exists[token] = struct{}{}
+		tokens = append(tokens, token)
+	}
+	content = commentLinkRe.ReplaceAllStringFunc(content, func(link string) string {
+		link = strings.TrimPrefix(strings.TrimPrefix(link, "http://"), "https://")
+		if idx := strings.IndexAny(link, "/?#"); idx > 0 {
+			link = link[:idx]
+		}
+		addToken("link:" + link)
+		return " "
+	})
+	for _, v := range library.WordSplit(content, false) {
+		addToken(v)
+		if len(tokens) >= 300 {
+			break
+		}
+	}
+
+	return tokens
+}
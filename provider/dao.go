@@ -101,6 +101,12 @@ func AutoMigrateDB(db *gorm.DB) error {
 		&model.MaterialCategory{},
 		&model.MaterialData{},
 		&model.Statistic{},
+		&model.NotFoundIgnore{},
+		&model.PushQueue{},
+		&model.BrokenLink{},
+		&model.SeoAudit{},
+		&model.InternalLink{},
+		&model.ArchiveFingerprint{},
 		&model.Tag{},
 		&model.TagData{},
 		&model.Redirect{},
@@ -120,6 +126,18 @@ func AutoMigrateDB(db *gorm.DB) error {
 		&model.OrderDetail{},
 		&model.OrderAddress{},
 		&model.OrderRefund{},
+		&model.LicenseKey{},
+		&model.ShippingTemplate{},
+		&model.OauthAccount{},
+		&model.UserFavorite{},
+		&model.UserHistory{},
+		&model.Notification{},
+		&model.NotificationRead{},
+		&model.OrderInvoice{},
+		&model.UserDeletion{},
+		&model.UserPoint{},
+		&model.UserMembership{},
+		&model.CommentSpamToken{},
 		&model.Payment{},
 		&model.Finance{},
 		&model.Commission{},
@@ -218,4 +236,7 @@ func (w *Website) InitModelData() {
 	if groupNum == 0 {
 		w.DB.CreateInBatches(userGeroups, 10)
 	}
+	// 历史订单改为使用订单地址
+	w.migrateOrderAddresses()
+	w.migrateUserVerified()
 }
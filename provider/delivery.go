@@ -0,0 +1,310 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"io"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/library"
+	"kandaoni.com/anqicms/model"
+	"log"
+	"mime/multipart"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// GetArchiveDeliveryType 文档的交付方式，文档没有单独设置时，使用模型的设置
+func (w *Website) GetArchiveDeliveryType(archive *model.Archive) string {
+	if archive == nil {
+		return config.DeliveryTypeNormal
+	}
+	if archive.DeliveryType != "" {
+		return archive.DeliveryType
+	}
+	module := w.GetModuleFromCache(archive.ModuleId)
+	if module != nil {
+		return module.DeliveryType
+	}
+
+	return config.DeliveryTypeNormal
+}
+
+// CheckOrderIsDigital 订单中的商品都是数字商品时，不需要走发货流程
+func (w *Website) CheckOrderIsDigital(order *model.Order) bool {
+	if order.Type == config.OrderTypeVip || len(order.Details) == 0 {
+		return false
+	}
+	for _, detail := range order.Details {
+		if w.GetArchiveDeliveryType(detail.Goods) == config.DeliveryTypeNormal {
+			return false
+		}
+	}
+
+	return true
+}
+
+// DeliverDigitalOrder 订单支付成功后，交付订单中的数字商品
+func (w *Website) DeliverDigitalOrder(order *model.Order) error {
+	if order.Type == config.OrderTypeVip {
+		return nil
+	}
+	if len(order.Details) == 0 {
+		fullOrder, err := w.GetOrderInfoByOrderId(order.OrderId)
+		if err != nil {
+			return err
+		}
+		order.Details = fullOrder.Details
+	}
+	var delivered bool
+	var deliverErr error
+	for _, detail := range order.Details {
+		deliveryType := w.GetArchiveDeliveryType(detail.Goods)
+		if deliveryType == config.DeliveryTypeLicense {
+			err := w.AllocateLicenseKeys(order, detail)
+			if err != nil {
+				deliverErr = err
+			}
+		}
+		if deliveryType != config.DeliveryTypeNormal {
+			delivered = true
+		}
+	}
+	if !delivered {
+		return nil
+	}
+	w.LoadOrderDelivery(order)
+
+	if w.PluginOrder.DeliveryMail {
+		user, err := w.GetUserInfoById(order.UserId)
+		if err == nil && user.Email != "" {
+			subject, content := w.getDeliveryMailContent(order)
+			err = w.SendMail(subject, content, user.Email)
+			if err != nil {
+				log.Println("发送交付邮件失败：", err.Error())
+			}
+		}
+	}
+
+	return deliverErr
+}
+
+// AllocateLicenseKeys 从授权码池中，为订单详情分配授权码
+func (w *Website) AllocateLicenseKeys(order *model.Order, detail *model.OrderDetail) error {
+	quantity := detail.Quantity
+	if quantity <= 0 {
+		quantity = 1
+	}
+	var exists int64
+	w.DB.Model(&model.LicenseKey{}).Where("`order_id` = ? and `detail_id` = ?", order.OrderId, detail.Id).Count(&exists)
+	if int(exists) >= quantity {
+		// 已分配过
+		return nil
+	}
+	need := quantity - int(exists)
+	err := w.DB.Transaction(func(tx *gorm.DB) error {
+		var keys []*model.LicenseKey
+		tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("`archive_id` = ? and `status` = ?", detail.GoodsId, config.LicenseKeyStatusAvailable).
+			Order("id asc").Limit(need).Find(&keys)
+		if len(keys) < need {
+			return errors.New(w.Lang("授权码库存不足"))
+		}
+		for _, key := range keys {
+			key.Status = config.LicenseKeyStatusUsed
+			key.OrderId = order.OrderId
+			key.DetailId = detail.Id
+			key.UserId = order.UserId
+			key.UsedTime = time.Now().Unix()
+			if err := tx.Save(key).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+
+	return err
+}
+
+// LoadOrderDelivery 为已支付的订单填充数字商品的交付内容，已退款的商品不再交付
+// 会生成下载链接和查询授权码，只在订单详情和交付时调用
+func (w *Website) LoadOrderDelivery(order *model.Order) {
+	if order.Type == config.OrderTypeVip || order.PaidTime == 0 || order.Status == config.OrderStatusRefunded {
+		return
+	}
+	for _, detail := range order.Details {
+		detail.DeliveryType = w.GetArchiveDeliveryType(detail.Goods)
+		if detail.Status == config.OrderStatusRefunded {
+			continue
+		}
+		if detail.DeliveryType == config.DeliveryTypeDownload {
+			detail.DownloadUrl = w.GetOrderDownloadUrl(order.OrderId, detail.Id)
+		} else if detail.DeliveryType == config.DeliveryTypeLicense {
+			detail.LicenseKeys = nil
+			w.DB.Model(&model.LicenseKey{}).Where("`order_id` = ? and `detail_id` = ?", order.OrderId, detail.Id).Order("id asc").Pluck("code", &detail.LicenseKeys)
+		}
+	}
+}
+
+// GetOrderDownloadUrl 生成带签名的限时下载链接
+func (w *Website) GetOrderDownloadUrl(orderId string, detailId uint) string {
+	expireHour := w.PluginOrder.DownloadExpireHour
+	if expireHour <= 0 {
+		expireHour = 24
+	}
+	expire := time.Now().Add(time.Duration(expireHour) * time.Hour).Unix()
+	query := url.Values{}
+	query.Set("order_id", orderId)
+	query.Set("detail_id", fmt.Sprintf("%d", detailId))
+	query.Set("expire", fmt.Sprintf("%d", expire))
+	query.Set("sign", w.signDownload(orderId, detailId, expire))
+
+	return w.System.BaseUrl + "/api/order/download?" + query.Encode()
+}
+
+// VerifyOrderDownload 校验下载链接，并返回可供下载的文件路径和文件名
+func (w *Website) VerifyOrderDownload(orderId string, detailId uint, expire int64, sign string) (string, string, error) {
+	if expire < time.Now().Unix() {
+		return "", "", errors.New(w.Lang("下载链接已过期"))
+	}
+	if !hmac.Equal([]byte(sign), []byte(w.signDownload(orderId, detailId, expire))) {
+		return "", "", errors.New(w.Lang("下载链接无效"))
+	}
+	order, err := w.GetOrderInfoByOrderId(orderId)
+	if err != nil {
+		return "", "", err
+	}
+	if order.PaidTime == 0 || order.Status == config.OrderStatusRefunded {
+		return "", "", errors.New(w.Lang("订单未支付"))
+	}
+	for _, detail := range order.Details {
+		if detail.Id != detailId || detail.Goods == nil {
+			continue
+		}
+		if detail.Status == config.OrderStatusRefunded {
+			return "", "", errors.New(w.Lang("该商品已退款"))
+		}
+		if w.GetArchiveDeliveryType(detail.Goods) != config.DeliveryTypeDownload || detail.Goods.DeliveryFile == "" {
+			break
+		}
+		filePath := w.DataPath + "delivery/" + filepath.Base(detail.Goods.DeliveryFile)
+		_, err = os.Stat(filePath)
+		if err != nil {
+			return "", "", errors.New(w.Lang("文件不存在"))
+		}
+		fileName := detail.Goods.Title + filepath.Ext(filePath)
+
+		return filePath, fileName, nil
+	}
+
+	return "", "", errors.New(w.Lang("文件不存在"))
+}
+
+func (w *Website) signDownload(orderId string, detailId uint, expire int64) string {
+	mac := hmac.New(sha256.New, []byte(config.Server.Server.TokenSecret))
+	mac.Write([]byte(fmt.Sprintf("%d-%s-%d-%d", w.Id, orderId, detailId, expire)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func (w *Website) getDeliveryMailContent(order *model.Order) (string, string) {
+	subject := fmt.Sprintf(w.Lang("%s订单%s的商品已交付"), w.System.SiteName, order.OrderId)
+	var lines []string
+	for _, detail := range order.Details {
+		if detail.DeliveryType == config.DeliveryTypeNormal || detail.Goods == nil {
+			continue
+		}
+		lines = append(lines, w.Lang("商品：")+detail.Goods.Title)
+		if detail.DownloadUrl != "" {
+			lines = append(lines, w.Lang("下载地址：")+detail.DownloadUrl)
+		}
+		for _, code := range detail.LicenseKeys {
+			lines = append(lines, w.Lang("授权码：")+code)
+		}
+		lines = append(lines, "")
+	}
+
+	return subject, strings.Join(lines, "\n")
+}
+
+func (w *Website) GetLicenseKeyList(archiveId uint, status int, page, pageSize int) ([]*model.LicenseKey, int64) {
+	var keys []*model.LicenseKey
+	var total int64
+	offset := (page - 1) * pageSize
+	tx := w.DB.Model(&model.LicenseKey{})
+	if archiveId > 0 {
+		tx = tx.Where("`archive_id` = ?", archiveId)
+	}
+	if status >= 0 {
+		tx = tx.Where("`status` = ?", status)
+	}
+	tx.Count(&total).Order("id desc").Limit(pageSize).Offset(offset).Find(&keys)
+
+	return keys, total
+}
+
+// ImportLicenseKeys 导入授权码，每行一个，已存在的授权码会被跳过
+func (w *Website) ImportLicenseKeys(archiveId uint, content string) (int, error) {
+	archive, err := w.GetArchiveById(archiveId)
+	if err != nil {
+		return 0, err
+	}
+	var total int
+	lines := strings.Split(content, "\n")
+	for _, line := range lines {
+		code := strings.TrimSpace(line)
+		if code == "" {
+			continue
+		}
+		var exists int64
+		w.DB.Model(&model.LicenseKey{}).Where("`archive_id` = ? and `code` = ?", archive.Id, code).Count(&exists)
+		if exists > 0 {
+			continue
+		}
+		key := model.LicenseKey{
+			ArchiveId: archive.Id,
+			Code:      code,
+			Status:    config.LicenseKeyStatusAvailable,
+		}
+		if err = w.DB.Create(&key).Error; err == nil {
+			total++
+		}
+	}
+
+	return total, nil
+}
+
+// DeleteLicenseKeys 只能删除未分配的授权码
+func (w *Website) DeleteLicenseKeys(ids []uint) error {
+	if len(ids) == 0 {
+		return nil
+	}
+
+	return w.DB.Where("`id` IN(?) and `status` = ?", ids, config.LicenseKeyStatusAvailable).Delete(&model.LicenseKey{}).Error
+}
+
+// SaveDeliveryFile 保存受保护的下载文件，文件不放在 public 目录，只能通过签名链接下载
+func (w *Website) SaveDeliveryFile(file multipart.File, info *multipart.FileHeader) (string, error) {
+	buff, err := io.ReadAll(file)
+	if err != nil {
+		return "", err
+	}
+	fileName := library.Md5Bytes(buff) + strings.ToLower(filepath.Ext(info.Filename))
+	filePath := w.DataPath + "delivery/" + fileName
+	err = os.MkdirAll(filepath.Dir(filePath), os.ModePerm)
+	if err != nil {
+		return "", err
+	}
+	err = os.WriteFile(filePath, buff, 0644)
+	if err != nil {
+		return "", err
+	}
+
+	return fileName, nil
+}
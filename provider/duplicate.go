@@ -0,0 +1,427 @@
+package provider
+
+import (
+	"errors"
+	"hash/fnv"
+	"kandaoni.com/anqicms/library"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/response"
+	"math/bits"
+	"sort"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+const (
+	// 词数少于这个数量的内容指纹不稳定，不参与比较
+	fingerprintMinTokens = 20
+	// 默认的相似度，百分比
+	DuplicateDefaultSimilarity = 90
+
+	DuplicateActionRedirect = "redirect"
+	DuplicateActionMerge    = "merge"
+)
+
+// ArchiveSimhash 计算标题和正文的 SimHash，返回指纹和参与计算的词数
+func ArchiveSimhash(title, content string) (uint64, int) {
+	text := strings.ToLower(title + "\n" + library.StripTags(content))
+	weights := map[string]int{}
+	var tokens int
+	for _, word := range library.WordSplit(text, false) {
+		word = strings.TrimSpace(word)
+		// 单个汉字和标点区分度太低
+		if word == "" || (utf8.RuneCountInString(word) < 2 && !unicode.IsDigit([]rune(word)[0])) {
+			continue
+		}
+		weights[word]++
+		tokens++
+	}
+	var vector [64]int
+	for word, weight := range weights {
+		h := fnv.New64a()
+		_, _ = h.Write([]byte(word))
+		sum := h.Sum64()
+		for i := 0; i < 64; i++ {
+			if sum&(1<<uint(i)) != 0 {
+				vector[i] += weight
+			} else {
+				vector[i] -= weight
+			}
+		}
+	}
+	var simhash uint64
+	for i := 0; i < 64; i++ {
+		if vector[i] > 0 {
+			simhash |= 1 << uint(i)
+		}
+	}
+
+	return simhash, tokens
+}
+
+// simhashSimilarity 两个指纹的相似度百分比
+func simhashSimilarity(a, b uint64) int {
+	return (64 - bits.OnesCount64(a^b)) * 100 / 64
+}
+
+// similarityDistance 相似度百分比对应的最大海明距离
+func similarityDistance(similarity int) int {
+	if similarity <= 0 || similarity > 100 {
+		similarity = DuplicateDefaultSimilarity
+	}
+
+	return 64 * (100 - similarity) / 100
+}
+
+// SaveArchiveFingerprint 保存文档后更新指纹
+func (w *Website) SaveArchiveFingerprint(archiveId uint, title, content string) {
+	if w.DB == nil {
+		return
+	}
+	simhash, tokens := ArchiveSimhash(title, content)
+	w.DB.Save(&model.ArchiveFingerprint{
+		Id:      archiveId,
+		Simhash: simhash,
+		Tokens:  tokens,
+	})
+	w.MemCache.Delete("duplicateGroups")
+}
+
+func (w *Website) DeleteArchiveFingerprint(archiveId uint) {
+	if w.DB == nil {
+		return
+	}
+	w.DB.Where("`id` = ?", archiveId).Delete(&model.ArchiveFingerprint{})
+	w.MemCache.Delete("duplicateGroups")
+}
+
+// eachFingerprints 分批遍历参与比较的指纹，fn 返回 false 时停止遍历
+func (w *Website) eachFingerprints(fn func(fingerprint *model.ArchiveFingerprint) bool) {
+	var lastId uint
+	for {
+		var fingerprints []*model.ArchiveFingerprint
+		w.DB.Where("`id` > ? and `tokens` >= ?", lastId, fingerprintMinTokens).Order("`id` asc").Limit(5000).Find(&fingerprints)
+		if len(fingerprints) == 0 {
+			break
+		}
+		for _, v := range fingerprints {
+			if !fn(v) {
+				return
+			}
+		}
+		lastId = fingerprints[len(fingerprints)-1].Id
+	}
+}
+
+// FindSimilarArchives 查找与指纹相似的文档，相似度高的在前
+func (w *Website) FindSimilarArchives(simhash uint64, excludeId uint, similarity, limit int) []*response.SimilarArchive {
+	distance := similarityDistance(similarity)
+	var matches []*response.SimilarArchive
+	w.eachFingerprints(func(fingerprint *model.ArchiveFingerprint) bool {
+		if fingerprint.Id == excludeId || bits.OnesCount64(fingerprint.Simhash^simhash) > distance {
+			return true
+		}
+		matches = append(matches, &response.SimilarArchive{
+			Id:         fingerprint.Id,
+			Similarity: simhashSimilarity(fingerprint.Simhash, simhash),
+		})
+		return true
+	})
+	sort.SliceStable(matches, func(i, j int) bool {
+		return matches[i].Similarity > matches[j].Similarity
+	})
+	if limit > 0 && len(matches) > limit {
+		matches = matches[:limit]
+	}
+
+	return w.fillSimilarArchives(matches)
+}
+
+// SearchSimilarArchives 按文档ID或者一段内容查找相似的文档
+func (w *Website) SearchSimilarArchives(archiveId uint, title, content string, similarity int) ([]*response.SimilarArchive, error) {
+	var simhash uint64
+	var tokens int
+	if archiveId > 0 {
+		var fingerprint model.ArchiveFingerprint
+		if err := w.DB.Where("`id` = ?", archiveId).Take(&fingerprint).Error; err != nil {
+			archiveData, err := w.GetArchiveDataById(archiveId)
+			archive, err2 := w.GetArchiveById(archiveId)
+			if err != nil || err2 != nil {
+				return nil, errors.New(w.Lang("文档不存在"))
+			}
+			w.SaveArchiveFingerprint(archiveId, archive.Title, archiveData.Content)
+			fingerprint.Simhash, fingerprint.Tokens = ArchiveSimhash(archive.Title, archiveData.Content)
+		}
+		simhash, tokens = fingerprint.Simhash, fingerprint.Tokens
+	} else {
+		simhash, tokens = ArchiveSimhash(title, content)
+	}
+	if tokens < fingerprintMinTokens {
+		return nil, errors.New(w.Lang("内容太少，无法比较相似度"))
+	}
+
+	return w.FindSimilarArchives(simhash, archiveId, similarity, 50), nil
+}
+
+// CheckContentDuplicate 采集入库前检查，与已有文档的相似度达到设置的阈值时返回true
+func (w *Website) CheckContentDuplicate(title, content string) bool {
+	threshold := w.CollectorConfig.DuplicateThreshold
+	if threshold <= 0 {
+		return false
+	}
+	simhash, tokens := ArchiveSimhash(title, content)
+	if tokens < fingerprintMinTokens {
+		return false
+	}
+	distance := similarityDistance(threshold)
+	found := false
+	// 找到一篇相似的就可以停止
+	w.eachFingerprints(func(fingerprint *model.ArchiveFingerprint) bool {
+		found = bits.OnesCount64(fingerprint.Simhash^simhash) <= distance
+		return !found
+	})
+
+	return found
+}
+
+// GetDuplicateGroups 相似文档分组。按鸽巢原理把指纹分成 distance+1 段，
+// 海明距离不超过 distance 的两个指纹至少有一段相同，只需要比较同一段相同的指纹
+func (w *Website) GetDuplicateGroups(similarity, currentPage, pageSize int) ([]*response.DuplicateGroup, int) {
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	distance := similarityDistance(similarity)
+	// 各相似度的分组缓存在一起，文档修改后一起清除
+	cached, ok := w.MemCache.Get("duplicateGroups").(map[int][]*response.DuplicateGroup)
+	if !ok {
+		cached = map[int][]*response.DuplicateGroup{}
+	}
+	groups, ok := cached[distance]
+	if !ok {
+		groups = w.buildDuplicateGroups(distance)
+		cached[distance] = groups
+		w.MemCache.Delete("duplicateGroups")
+		w.MemCache.Set("duplicateGroups", cached, 600)
+	}
+	total := len(groups)
+	start := (currentPage - 1) * pageSize
+	if start >= total {
+		return []*response.DuplicateGroup{}, total
+	}
+	end := start + pageSize
+	if end > total {
+		end = total
+	}
+	result := groups[start:end]
+	for _, group := range result {
+		group.Archives = w.fillSimilarArchives(group.Archives)
+	}
+
+	return result, total
+}
+
+func (w *Website) buildDuplicateGroups(distance int) []*response.DuplicateGroup {
+	var fingerprints []*model.ArchiveFingerprint
+	w.eachFingerprints(func(fingerprint *model.ArchiveFingerprint) bool {
+		fingerprints = append(fingerprints, fingerprint)
+		return true
+	})
+
+	return groupFingerprints(fingerprints, distance)
+}
+
+// groupFingerprints 用并查集把海明距离不超过 distance 的指纹合并为一组，只有一篇的不算分组
+func groupFingerprints(fingerprints []*model.ArchiveFingerprint, distance int) []*response.DuplicateGroup {
+	parents := make([]int, len(fingerprints))
+	for i := range parents {
+		parents[i] = i
+	}
+	var find func(i int) int
+	find = func(i int) int {
+		if parents[i] != i {
+			parents[i] = find(parents[i])
+		}
+		return parents[i]
+	}
+	bands := distance + 1
+	bandBits := 64 / bands
+	for band := 0; band < bands; band++ {
+		shift := uint(band * bandBits)
+		width := bandBits
+		if band == bands-1 {
+			width = 64 - band*bandBits
+		}
+		mask := uint64(1)<<uint(width) - 1
+		buckets := map[uint64][]int{}
+		for i, v := range fingerprints {
+			key := (v.Simhash >> shift) & mask
+			buckets[key] = append(buckets[key], i)
+		}
+		for _, bucket := range buckets {
+			for x := 0; x < len(bucket); x++ {
+				for y := x + 1; y < len(bucket); y++ {
+					a, b := find(bucket[x]), find(bucket[y])
+					if a == b {
+						continue
+					}
+					if bits.OnesCount64(fingerprints[bucket[x]].Simhash^fingerprints[bucket[y]].Simhash) <= distance {
+						parents[b] = a
+					}
+				}
+			}
+		}
+	}
+	members := map[int][]int{}
+	for i := range fingerprints {
+		root := find(i)
+		members[root] = append(members[root], i)
+	}
+	var groups []*response.DuplicateGroup
+	for _, list := range members {
+		if len(list) < 2 {
+			continue
+		}
+		// 最早发布的作为原文，其他的相似度都与原文比较
+		sort.Slice(list, func(i, j int) bool {
+			return fingerprints[list[i]].Id < fingerprints[list[j]].Id
+		})
+		origin := fingerprints[list[0]]
+		group := &response.DuplicateGroup{Id: origin.Id}
+		for _, i := range list {
+			group.Archives = append(group.Archives, &response.SimilarArchive{
+				Id:         fingerprints[i].Id,
+				Similarity: simhashSimilarity(origin.Simhash, fingerprints[i].Simhash),
+			})
+		}
+		groups = append(groups, group)
+	}
+	sort.SliceStable(groups, func(i, j int) bool {
+		if len(groups[i].Archives) != len(groups[j].Archives) {
+			return len(groups[i].Archives) > len(groups[j].Archives)
+		}
+		return groups[i].Id > groups[j].Id
+	})
+
+	return groups
+}
+
+// fillSimilarArchives 补充文档标题和链接，已删除的文档去掉
+func (w *Website) fillSimilarArchives(list []*response.SimilarArchive) []*response.SimilarArchive {
+	if len(list) == 0 {
+		return []*response.SimilarArchive{}
+	}
+	ids := make([]uint, 0, len(list))
+	for _, v := range list {
+		ids = append(ids, v.Id)
+	}
+	var archives []*model.Archive
+	w.DB.Where("`id` IN (?)", ids).Find(&archives)
+	archiveMap := map[uint]*model.Archive{}
+	for _, v := range archives {
+		archiveMap[v.Id] = v
+	}
+	result := make([]*response.SimilarArchive, 0, len(list))
+	for _, v := range list {
+		archive, ok := archiveMap[v.Id]
+		if !ok {
+			continue
+		}
+		v.Title = archive.Title
+		v.Status = archive.Status
+		v.Views = archive.Views
+		v.CreatedTime = archive.CreatedTime
+		v.Link = w.GetUrl("archive", archive, 0)
+		result = append(result, v)
+	}
+
+	return result
+}
+
+// ResolveDuplicateArchives 处理重复文档：保留一篇，其他的删除并301跳转到保留的文档。
+// 合并时还会把其他文档的标签、评论和浏览量转移到保留的文档
+func (w *Website) ResolveDuplicateArchives(keepId uint, ids []uint, action string) (int, error) {
+	if action != DuplicateActionRedirect && action != DuplicateActionMerge {
+		return 0, errors.New(w.Lang("不支持的操作"))
+	}
+	keep, err := w.GetArchiveById(keepId)
+	if err != nil {
+		return 0, errors.New(w.Lang("保留的文档不存在"))
+	}
+	var archives []*model.Archive
+	w.DB.Where("`id` IN (?) and `id` != ?", ids, keepId).Find(&archives)
+	if len(archives) == 0 {
+		return 0, errors.New(w.Lang("请选择要处理的文档"))
+	}
+	var tagNames []string
+	existTags := map[string]struct{}{}
+	addTags := func(archiveId uint) {
+		for _, tag := range w.GetTagsByItemId(archiveId) {
+			if _, ok := existTags[tag.Title]; !ok {
+				existTags[tag.Title] = struct{}{}
+				tagNames = append(tagNames, tag.Title)
+			}
+		}
+	}
+	if action == DuplicateActionMerge {
+		addTags(keep.Id)
+	}
+	var total int
+	for _, archive := range archives {
+		oldLink := w.GetUrl("archive", archive, 0)
+		if action == DuplicateActionMerge {
+			addTags(archive.Id)
+			w.DB.Model(&model.Comment{}).Where("`archive_id` = ?", archive.Id).UpdateColumn("archive_id", keep.Id)
+			keep.Views += archive.Views
+		}
+		if err = w.DeleteArchive(archive); err != nil {
+			continue
+		}
+		w.addAutoRedirect(oldLink, keep.Link)
+		total++
+	}
+	if action == DuplicateActionMerge {
+		_ = w.SaveTagData(keep.Id, tagNames)
+		var comment model.Comment
+		comment.ArchiveId = keep.Id
+		comment.UpdateCommentCount(w.DB)
+		w.DB.Model(&model.Archive{}).Where("`id` = ?", keep.Id).UpdateColumn("views", keep.Views)
+	}
+	w.DeleteCacheRedirects()
+	w.MemCache.Delete("duplicateGroups")
+
+	return total, nil
+}
+
+// RebuildArchiveFingerprints 为还没有指纹的文档补充指纹
+func (w *Website) RebuildArchiveFingerprints() {
+	if w.DB == nil {
+		return
+	}
+	var lastId uint
+	for {
+		var archives []*model.Archive
+		w.DB.Select("id", "title").Where("`id` > ? and NOT EXISTS (SELECT 1 FROM `archive_fingerprints` AS f WHERE f.`id` = `archives`.`id`)", lastId).
+			Order("`id` asc").Limit(100).Find(&archives)
+		if len(archives) == 0 {
+			break
+		}
+		ids := make([]uint, 0, len(archives))
+		for _, v := range archives {
+			ids = append(ids, v.Id)
+		}
+		var archiveData []*model.ArchiveData
+		w.DB.Where("`id` IN (?)", ids).Find(&archiveData)
+		contents := map[uint]string{}
+		for _, v := range archiveData {
+			contents[v.Id] = v.Content
+		}
+		for _, v := range archives {
+			w.SaveArchiveFingerprint(v.Id, v.Title, contents[v.Id])
+		}
+		lastId = archives[len(archives)-1].Id
+	}
+}
@@ -0,0 +1,166 @@
+package provider
+
+import (
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"math/bits"
+	"os"
+	"testing"
+)
+
+func TestSimilarityDistance(t *testing.T) {
+	cases := []struct {
+		similarity int
+		distance   int
+	}{
+		{100, 0},
+		{90, 6},
+		{80, 12},
+		{50, 32},
+		{1, 63},
+		// 超出范围的使用默认相似度
+		{0, 6},
+		{-10, 6},
+		{101, 6},
+	}
+	for _, c := range cases {
+		if distance := similarityDistance(c.similarity); distance != c.distance {
+			t.Errorf("similarityDistance(%d) = %d, expected %d", c.similarity, distance, c.distance)
+		}
+	}
+}
+
+func TestSimhashSimilarity(t *testing.T) {
+	cases := []struct {
+		a, b       uint64
+		similarity int
+	}{
+		{0, 0, 100},
+		{0x5555555555555555, 0x5555555555555555, 100},
+		{0, 1, 98},
+		{0, 0xF, 93},
+		{0, 0xFFFFFFFF, 50},
+		{0, 0xFFFFFFFFFFFFFFFF, 0},
+	}
+	for _, c := range cases {
+		if similarity := simhashSimilarity(c.a, c.b); similarity != c.similarity {
+			t.Errorf("simhashSimilarity(%x, %x) = %d, expected %d", c.a, c.b, similarity, c.similarity)
+		}
+	}
+}
+
+func TestArchiveSimhash(t *testing.T) {
+	if _, err := os.Stat(config.ExecPath + "dictionary.txt"); err != nil {
+		t.Skip("dictionary.txt not found")
+	}
+	content := "<p>安企CMS是一款使用Go语言开发的企业网站内容管理系统，部署简单，运行速度快，适合各类企业官网使用。</p>" +
+		"<p>系统支持多站点、多语言、自定义内容模型和伪静态规则，内置了搜索引擎推送、sitemap生成和锚文本等常用的优化功能。</p>" +
+		"<p>模板使用类似Django的语法，可以快速地把静态页面改造成网站模板，也可以在后台在线编辑模板文件。</p>"
+	edited := content + "<p>欢迎下载使用。</p>"
+	other := "<p>今天的天气非常好，我们一起去公园散步，湖边的柳树已经发芽了，孩子们在草地上放风筝，老人们在树下下棋聊天。</p>" +
+		"<p>傍晚的时候下起了小雨，大家纷纷回家，街道上的路灯一盏一盏地亮了起来，空气里都是泥土和青草的味道。</p>"
+
+	base, tokens := ArchiveSimhash("安企CMS介绍", content)
+	if tokens < fingerprintMinTokens {
+		t.Fatalf("expected at least %d tokens, got %d", fingerprintMinTokens, tokens)
+	}
+	cases := []struct {
+		name    string
+		title   string
+		content string
+		min     int
+		max     int
+	}{
+		// 去掉标签和大小写后相同的内容指纹相同
+		{"same", "安企cms介绍", "安企CMS是一款使用Go语言开发的企业网站内容管理系统，部署简单，运行速度快，适合各类企业官网使用。" +
+			"系统支持多站点、多语言、自定义内容模型和伪静态规则，内置了搜索引擎推送、sitemap生成和锚文本等常用的优化功能。" +
+			"模板使用类似Django的语法，可以快速地把静态页面改造成网站模板，也可以在后台在线编辑模板文件。", 100, 100},
+		{"edited", "安企CMS介绍", edited, DuplicateDefaultSimilarity, 100},
+		{"other", "周末", other, 0, 80},
+	}
+	for _, c := range cases {
+		simhash, _ := ArchiveSimhash(c.title, c.content)
+		similarity := simhashSimilarity(base, simhash)
+		if similarity < c.min || similarity > c.max {
+			t.Errorf("%s: similarity %d not in [%d, %d]", c.name, similarity, c.min, c.max)
+		}
+	}
+
+	if _, tokens = ArchiveSimhash("短内容", "<p>只有一句话。</p>"); tokens >= fingerprintMinTokens {
+		t.Errorf("expected short content to have less than %d tokens, got %d", fingerprintMinTokens, tokens)
+	}
+}
+
+func TestGroupFingerprints(t *testing.T) {
+	base := uint64(0x5555555555555555)
+	fingerprints := []*model.ArchiveFingerprint{
+		{Id: 1, Simhash: 0},
+		{Id: 2, Simhash: 0x7},
+		// 与 1 的距离为 4，与 2 的距离为 1，通过 2 合并到同一组
+		{Id: 3, Simhash: 0xF},
+		{Id: 4, Simhash: 0xFFFFFFFFFFFFFFFF},
+		{Id: 5, Simhash: 0xFFFFFFFFFFFFFFFE},
+		// 不同的位分布在不同的段中
+		{Id: 6, Simhash: base},
+		{Id: 7, Simhash: base ^ (1 | 1<<21 | 1<<42)},
+		{Id: 8, Simhash: 0x0F0F0F0F0F0F0F0F},
+	}
+
+	cases := []struct {
+		distance int
+		groups   [][]uint
+	}{
+		{0, nil},
+		{1, [][]uint{{4, 5}, {2, 3}}},
+		{3, [][]uint{{1, 2, 3}, {6, 7}, {4, 5}}},
+	}
+	for _, c := range cases {
+		groups := groupFingerprints(fingerprints, c.distance)
+		if len(groups) != len(c.groups) {
+			t.Errorf("distance %d: expected %d groups, got %d", c.distance, len(c.groups), len(groups))
+			continue
+		}
+		for i, group := range groups {
+			expected := c.groups[i]
+			if group.Id != expected[0] || len(group.Archives) != len(expected) {
+				t.Errorf("distance %d: group %d expected %v, got id %d with %d archives", c.distance, i, expected, group.Id, len(group.Archives))
+				continue
+			}
+			// 组内的相似度都与最早的一篇比较
+			origin := fingerprintById(fingerprints, group.Id)
+			for j, archive := range group.Archives {
+				if archive.Id != expected[j] {
+					t.Errorf("distance %d: group %d expected %v, got archive %d at %d", c.distance, i, expected, archive.Id, j)
+				}
+				if archive.Similarity != simhashSimilarity(origin, fingerprintById(fingerprints, archive.Id)) {
+					t.Errorf("distance %d: archive %d similarity %d", c.distance, archive.Id, archive.Similarity)
+				}
+			}
+		}
+	}
+
+	// 每一组内的指纹至少与组内另一篇的距离不超过 distance
+	for _, group := range groupFingerprints(fingerprints, 3) {
+		for _, a := range group.Archives {
+			near := false
+			for _, b := range group.Archives {
+				if a.Id != b.Id && bits.OnesCount64(fingerprintById(fingerprints, a.Id)^fingerprintById(fingerprints, b.Id)) <= 3 {
+					near = true
+				}
+			}
+			if !near {
+				t.Errorf("archive %d has no near neighbour in group %d", a.Id, group.Id)
+			}
+		}
+	}
+}
+
+func fingerprintById(fingerprints []*model.ArchiveFingerprint, id uint) uint64 {
+	for _, v := range fingerprints {
+		if v.Id == id {
+			return v.Simhash
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,192 @@
+package provider
+
+import (
+	"errors"
+	"kandaoni.com/anqicms/library"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/request"
+	"kandaoni.com/anqicms/response"
+	"strings"
+)
+
+const (
+	extractKeywordLimit  = 5
+	extractSummaryLength = 150
+	// 用于匹配标签库的候选关键词数量
+	extractTagCandidates = 30
+)
+
+// ExtractKeywords 从标题和正文中提取关键词，标题重复一次以提高其中词语的权重
+func (w *Website) ExtractKeywords(title, text string, limit int) []string {
+	if strings.TrimSpace(title+text) == "" {
+		return nil
+	}
+	return library.ExtractKeywords(title+"。"+title+"。"+text, limit)
+}
+
+// ExtractSummary 提取正文摘要，提取不到完整句子时截取开头
+func (w *Website) ExtractSummary(text string, maxLength int) string {
+	summary := library.ExtractSummary(text, maxLength)
+	if summary == "" {
+		textRune := []rune(strings.TrimSpace(strings.ReplaceAll(text, "\n", " ")))
+		if len(textRune) > maxLength {
+			textRune = textRune[:maxLength]
+		}
+		summary = string(textRune)
+	}
+
+	return summary
+}
+
+// SuggestTags 从已有的标签库中挑选与内容相关的标签，按相关程度排序
+func (w *Website) SuggestTags(title, text string, limit int) []*model.Tag {
+	tags := []*model.Tag{}
+	keywords := w.ExtractKeywords(title, text, extractTagCandidates)
+	if len(keywords) == 0 {
+		return tags
+	}
+	var existTags []*model.Tag
+	w.DB.Where("`status` = 1 AND `title` IN (?)", keywords).Find(&existTags)
+	tagMap := make(map[string]*model.Tag, len(existTags))
+	for _, v := range existTags {
+		tagMap[strings.ToLower(v.Title)] = v
+	}
+	for _, keyword := range keywords {
+		if tag, ok := tagMap[strings.ToLower(keyword)]; ok {
+			tag.Link = w.GetUrl("tag", tag, 0)
+			tags = append(tags, tag)
+			delete(tagMap, strings.ToLower(keyword))
+			if limit > 0 && len(tags) >= limit {
+				break
+			}
+		}
+	}
+
+	return tags
+}
+
+// ExtractArchive 根据标题和正文生成关键词、摘要和推荐标签，供编辑时预览
+func (w *Website) ExtractArchive(title, content string) *response.ArchiveExtract {
+	text := CleanTagsAndSpaces(content)
+	return &response.ArchiveExtract{
+		Keywords:    w.ExtractKeywords(title, text, extractKeywordLimit),
+		Description: w.ExtractSummary(text, extractSummaryLength),
+		Tags:        w.SuggestTags(title, text, extractKeywordLimit),
+	}
+}
+
+// RegenerateArchives 批量重新生成关键词、描述和标签。指定了文档时直接处理，否则在后台处理全部文档
+func (w *Website) RegenerateArchives(req *request.ArchiveExtractRequest) (int, error) {
+	if w.DB == nil {
+		return 0, errors.New(w.Lang("站点未初始化"))
+	}
+	if len(req.Fields) == 0 {
+		req.Fields = []string{"keywords", "description"}
+	}
+	for _, field := range req.Fields {
+		if field != "keywords" && field != "description" && field != "tags" {
+			return 0, errors.New(w.Lang("不支持的字段") + ": " + field)
+		}
+	}
+	if len(req.Ids) > 0 {
+		var archives []*model.Archive
+		w.DB.Where("`id` IN (?)", req.Ids).Find(&archives)
+		total := 0
+		for _, archive := range archives {
+			if w.regenerateArchive(archive, req.Fields, req.Overwrite) {
+				total++
+			}
+		}
+		return total, nil
+	}
+	w.archiveExtractMutex.Lock()
+	if w.archiveExtracting {
+		w.archiveExtractMutex.Unlock()
+		return 0, errors.New(w.Lang("正在生成中，请稍后再试"))
+	}
+	w.archiveExtracting = true
+	w.archiveExtractMutex.Unlock()
+	go func() {
+		defer func() {
+			w.archiveExtractMutex.Lock()
+			w.archiveExtracting = false
+			w.archiveExtractMutex.Unlock()
+		}()
+		var lastId uint
+		for {
+			var archives []*model.Archive
+			tx := w.DB.Where("`id` > ?", lastId)
+			if req.CategoryId > 0 {
+				tx = tx.Where("`category_id` = ?", req.CategoryId)
+			}
+			tx.Order("`id` asc").Limit(100).Find(&archives)
+			if len(archives) == 0 {
+				break
+			}
+			for _, archive := range archives {
+				w.regenerateArchive(archive, req.Fields, req.Overwrite)
+			}
+			lastId = archives[len(archives)-1].Id
+		}
+	}()
+
+	return 0, nil
+}
+
+// regenerateArchive 未开启覆盖时只填充空的关键词和描述，标签只会追加不会删除
+func (w *Website) regenerateArchive(archive *model.Archive, fields []string, overwrite bool) bool {
+	var content string
+	if archiveData, err := w.GetArchiveDataById(archive.Id); err == nil {
+		content = archiveData.Content
+	}
+	text := CleanTagsAndSpaces(content)
+	updates := map[string]interface{}{}
+	changed := false
+	for _, field := range fields {
+		switch field {
+		case "keywords":
+			if overwrite || archive.Keywords == "" {
+				keywords := strings.Join(w.ExtractKeywords(archive.Title, text, extractKeywordLimit), ",")
+				if keywords != "" && keywords != archive.Keywords {
+					archive.Keywords = keywords
+					updates["keywords"] = keywords
+					updates["auto_keywords"] = 1
+				}
+			}
+		case "description":
+			if (overwrite || archive.Description == "") && strings.TrimSpace(text) != "" {
+				description := w.ExtractSummary(text, extractSummaryLength)
+				if description != "" && description != archive.Description {
+					archive.Description = description
+					updates["description"] = description
+				}
+			}
+		case "tags":
+			existTags := w.GetTagsByItemId(archive.Id)
+			tagNames := make([]string, 0, len(existTags))
+			exists := map[uint]bool{}
+			for _, v := range existTags {
+				tagNames = append(tagNames, v.Title)
+				exists[v.Id] = true
+			}
+			added := false
+			for _, v := range w.SuggestTags(archive.Title, text, extractKeywordLimit) {
+				if !exists[v.Id] {
+					tagNames = append(tagNames, v.Title)
+					added = true
+				}
+			}
+			if added {
+				_ = w.SaveTagData(archive.Id, tagNames)
+				changed = true
+			}
+		}
+	}
+	if len(updates) > 0 {
+		w.DB.Model(archive).UpdateColumns(updates)
+		w.AuditArchive(archive, content)
+		changed = true
+	}
+
+	return changed
+}
@@ -0,0 +1,194 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"sync"
+	"time"
+)
+
+// 同一用户浏览同一篇文档，间隔内只记录一次，单位秒
+const userViewInterval = 600
+
+// 记录浏览文档的最后记录时间，过期的定期清理
+var userViewTimes = struct {
+	sync.Mutex
+	times     map[string]int64
+	cleanTime int64
+}{times: map[string]int64{}}
+
+// AddUserFavorite 收藏文档，重复收藏不会报错
+func (w *Website) AddUserFavorite(userId, archiveId uint) error {
+	archive, err := w.GetArchiveById(archiveId)
+	if err != nil || archive.Status != config.ContentStatusOK {
+		return errors.New(w.Lang("文档不存在"))
+	}
+	if w.CheckUserFavorite(userId, archiveId) {
+		return nil
+	}
+	favorite := model.UserFavorite{
+		UserId:    userId,
+		ArchiveId: archiveId,
+	}
+	err = w.DB.Create(&favorite).Error
+	if err != nil {
+		return err
+	}
+	w.DB.Model(&model.Archive{}).Where("`id` = ?", archiveId).UpdateColumn("favorite_count", gorm.Expr("`favorite_count` + 1"))
+
+	return nil
+}
+
+func (w *Website) RemoveUserFavorite(userId, archiveId uint) error {
+	result := w.DB.Unscoped().Where("`user_id` = ? and `archive_id` = ?", userId, archiveId).Delete(&model.UserFavorite{})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected > 0 {
+		w.DB.Model(&model.Archive{}).Where("`id` = ? and `favorite_count` > 0", archiveId).UpdateColumn("favorite_count", gorm.Expr("`favorite_count` - 1"))
+	}
+
+	return nil
+}
+
+func (w *Website) CheckUserFavorite(userId, archiveId uint) bool {
+	if userId == 0 || archiveId == 0 {
+		return false
+	}
+	var total int64
+	w.DB.Model(&model.UserFavorite{}).Where("`user_id` = ? and `archive_id` = ?", userId, archiveId).Count(&total)
+
+	return total > 0
+}
+
+// GetUserFavoriteArchives 用户收藏的文档，按收藏时间倒序
+func (w *Website) GetUserFavoriteArchives(userId uint, currentPage, pageSize int, offsets ...int) ([]*model.Archive, int64) {
+	archives, total, _ := w.GetArchiveList(func(tx *gorm.DB) *gorm.DB {
+		return tx.Joins("INNER JOIN `user_favorites` as f ON f.archive_id = archives.id AND f.`user_id` = ? AND f.`deleted_at` IS NULL", userId).
+			Where("archives.`status` = ?", config.ContentStatusOK).Order("f.id desc")
+	}, currentPage, pageSize, offsets...)
+
+	return archives, total
+}
+
+// RecordUserHistory 记录阅读记录，progress 小于0时不更新阅读进度
+func (w *Website) RecordUserHistory(userId, archiveId uint, progress int) {
+	if userId == 0 || archiveId == 0 {
+		return
+	}
+	if progress > 100 {
+		progress = 100
+	}
+	var history model.UserHistory
+	err := w.DB.Where("`user_id` = ? and `archive_id` = ?", userId, archiveId).Take(&history).Error
+	if err != nil {
+		history = model.UserHistory{
+			UserId:    userId,
+			ArchiveId: archiveId,
+			Views:     1,
+		}
+		if progress > 0 {
+			history.Progress = progress
+		}
+		w.DB.Create(&history)
+		return
+	}
+	updates := map[string]interface{}{
+		"updated_time": time.Now().Unix(),
+	}
+	if progress >= 0 {
+		updates["progress"] = progress
+	} else {
+		updates["views"] = gorm.Expr("`views` + 1")
+	}
+	w.DB.Model(&history).UpdateColumns(updates)
+}
+
+// RecordUserView 前台浏览文档时记录阅读记录。浏览是访问量最大的页面，间隔内重复浏览不再记录，并且在后台写入
+func (w *Website) RecordUserView(userId, archiveId uint) {
+	if userId == 0 || archiveId == 0 {
+		return
+	}
+	now := time.Now().Unix()
+	key := fmt.Sprintf("%d-%d-%d", w.Id, userId, archiveId)
+	userViewTimes.Lock()
+	if userViewTimes.times[key] > now-userViewInterval {
+		userViewTimes.Unlock()
+		return
+	}
+	userViewTimes.times[key] = now
+	if userViewTimes.cleanTime < now-userViewInterval {
+		userViewTimes.cleanTime = now
+		for k, v := range userViewTimes.times {
+			if v <= now-userViewInterval {
+				delete(userViewTimes.times, k)
+			}
+		}
+	}
+	userViewTimes.Unlock()
+
+	go w.RecordUserHistory(userId, archiveId, -1)
+}
+
+// GetUserHistories 阅读记录，按最后阅读时间倒序
+func (w *Website) GetUserHistories(userId uint, currentPage, pageSize int, offsets ...int) ([]*model.UserHistory, int64) {
+	var histories []*model.UserHistory
+	var total int64
+	offset := (currentPage - 1) * pageSize
+	if len(offsets) > 0 {
+		offset = offsets[0]
+	}
+	tx := w.DB.Model(&model.UserHistory{}).Where("`user_id` = ?", userId)
+	tx.Count(&total).Order("updated_time desc").Limit(pageSize).Offset(offset).Find(&histories)
+	w.fillUserHistoryArchives(histories)
+
+	return histories, total
+}
+
+// GetUserContinueReading 继续阅读：最近阅读且没有读完的文档
+func (w *Website) GetUserContinueReading(userId uint) (*model.UserHistory, error) {
+	var history model.UserHistory
+	err := w.DB.Where("`user_id` = ? and `progress` < 100", userId).Order("updated_time desc").Take(&history).Error
+	if err != nil {
+		return nil, err
+	}
+	w.fillUserHistoryArchives([]*model.UserHistory{&history})
+	if history.Archive == nil {
+		return nil, errors.New(w.Lang("文档不存在"))
+	}
+
+	return &history, nil
+}
+
+func (w *Website) DeleteUserHistories(userId uint, archiveIds []uint) error {
+	tx := w.DB.Unscoped().Where("`user_id` = ?", userId)
+	if len(archiveIds) > 0 {
+		tx = tx.Where("`archive_id` IN(?)", archiveIds)
+	}
+
+	return tx.Delete(&model.UserHistory{}).Error
+}
+
+func (w *Website) fillUserHistoryArchives(histories []*model.UserHistory) {
+	if len(histories) == 0 {
+		return
+	}
+	var archiveIds = make([]uint, 0, len(histories))
+	for _, v := range histories {
+		archiveIds = append(archiveIds, v.ArchiveId)
+	}
+	archives, _, _ := w.GetArchiveList(func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("`id` IN(?) and `status` = ?", archiveIds, config.ContentStatusOK)
+	}, 0, len(archiveIds))
+	for _, v := range histories {
+		for _, archive := range archives {
+			if archive.Id == v.ArchiveId {
+				v.Archive = archive
+				break
+			}
+		}
+	}
+}
@@ -0,0 +1,329 @@
+package provider
+
+import (
+	"errors"
+	"github.com/PuerkitoBio/goquery"
+	"gorm.io/gorm"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/library"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/response"
+	"math"
+	"net/url"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+// internalLinkTarget 链接解析出的目标页面
+type internalLinkTarget struct {
+	targetType string
+	targetId   uint
+}
+
+// BuildInternalLinks 重新记录一篇内容中的站内链接
+func (w *Website) BuildInternalLinks(sourceType string, sourceId uint, content string) {
+	w.buildInternalLinks(sourceType, sourceId, content, nil)
+}
+
+// buildInternalLinks resolved 用于批量重建时缓存链接的解析结果
+func (w *Website) buildInternalLinks(sourceType string, sourceId uint, content string, resolved map[string]*internalLinkTarget) {
+	if w.DB == nil {
+		return
+	}
+	w.DeleteInternalLinks(sourceType, sourceId)
+	if strings.TrimSpace(content) == "" {
+		return
+	}
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return
+	}
+	sourceLength := utf8.RuneCountInString(library.StripTags(content))
+	var links []*model.InternalLink
+	doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+		href := strings.TrimSpace(s.AttrOr("href", ""))
+		if href == "" || strings.HasPrefix(href, "#") || !w.isInternalLink(href) {
+			return
+		}
+		targetUrl := GetRedirectPath(href)
+		if idx := strings.IndexAny(targetUrl, "?#"); idx >= 0 {
+			targetUrl = targetUrl[:idx]
+		}
+		if targetUrl == "" || len(targetUrl) > 250 {
+			return
+		}
+		var target *internalLinkTarget
+		if resolved != nil {
+			target = resolved[targetUrl]
+		}
+		if target == nil {
+			target = w.resolveInternalLink(targetUrl)
+			if resolved != nil {
+				resolved[targetUrl] = target
+			}
+		}
+		if target.targetType == "" {
+			return
+		}
+		anchorId, _ := strconv.Atoi(s.AttrOr("data-anchor", ""))
+		links = append(links, &model.InternalLink{
+			SourceType:   sourceType,
+			SourceId:     sourceId,
+			SourceLength: sourceLength,
+			TargetType:   target.targetType,
+			TargetId:     target.targetId,
+			TargetUrl:    targetUrl,
+			AnchorText:   truncateBrokenLinkText(strings.TrimSpace(s.Text())),
+			AnchorId:     uint(anchorId),
+		})
+	})
+	if len(links) > 0 {
+		w.DB.CreateInBatches(links, 100)
+	}
+}
+
+// resolveInternalLink 按伪静态规则解析链接指向的页面，附件和不存在的页面返回空类型
+func (w *Website) resolveInternalLink(linkPath string) *internalLinkTarget {
+	target := &internalLinkTarget{}
+	if unescaped, err := url.PathUnescape(linkPath); err == nil {
+		linkPath = unescaped
+	}
+	params, _ := w.ParseRoute(linkPath)
+	id, _ := strconv.Atoi(params["id"])
+	switch params["match"] {
+	case "index":
+		target.targetType = "index"
+	case "archive":
+		if id == 0 && params["filename"] != "" {
+			if archive, err := w.GetArchiveByUrlToken(params["filename"]); err == nil {
+				id = int(archive.Id)
+			}
+		}
+		if id > 0 {
+			target.targetType = "archive"
+		}
+	case "category", "page":
+		if id == 0 && params["filename"] != "" {
+			if category := w.GetCategoryFromCacheByToken(params["filename"]); category != nil {
+				id = int(category.Id)
+			}
+		}
+		if id > 0 {
+			target.targetType = "category"
+		}
+	case "tag":
+		if id == 0 && params["filename"] != "" {
+			if tag, err := w.GetTagByUrlToken(params["filename"]); err == nil {
+				id = int(tag.Id)
+			}
+		}
+		if id > 0 {
+			target.targetType = "tag"
+		}
+	case "", "notfound":
+	default:
+		target.targetType = "other"
+		id = 0
+	}
+	target.targetId = uint(id)
+
+	return target
+}
+
+func (w *Website) DeleteInternalLinks(sourceType string, sourceId uint) {
+	if w.DB == nil {
+		return
+	}
+	w.DB.Unscoped().Where("`source_type` = ? and `source_id` = ?", sourceType, sourceId).Delete(&model.InternalLink{})
+}
+
+// StartInternalLinkRebuild 后台重建全站的站内链接关系
+func (w *Website) StartInternalLinkRebuild() error {
+	if w.DB == nil {
+		return errors.New(w.Lang("站点未初始化"))
+	}
+	w.internalLinkMutex.Lock()
+	if w.internalLinkRebuilding {
+		w.internalLinkMutex.Unlock()
+		return errors.New(w.Lang("正在重建中，请稍后再试"))
+	}
+	w.internalLinkRebuilding = true
+	w.internalLinkMutex.Unlock()
+	go func() {
+		defer func() {
+			w.internalLinkMutex.Lock()
+			w.internalLinkRebuilding = false
+			w.internalLinkMutex.Unlock()
+		}()
+		w.rebuildInternalLinks()
+	}()
+
+	return nil
+}
+
+// RebuildInternalLinks 计划任务定期重建，链接名调整后原来的解析结果会变化
+func (w *Website) RebuildInternalLinks() {
+	_ = w.StartInternalLinkRebuild()
+}
+
+func (w *Website) rebuildInternalLinks() {
+	w.DB.Unscoped().Where("1 = 1").Delete(&model.InternalLink{})
+	resolved := map[string]*internalLinkTarget{}
+	var lastId uint
+	for {
+		var archiveData []*model.ArchiveData
+		w.DB.Where("`id` > ?", lastId).Order("`id` asc").Limit(100).Find(&archiveData)
+		if len(archiveData) == 0 {
+			break
+		}
+		for _, v := range archiveData {
+			w.buildInternalLinks("archive", v.Id, v.Content, resolved)
+		}
+		lastId = archiveData[len(archiveData)-1].Id
+	}
+	var categories []*model.Category
+	w.DB.Select("id", "content").Find(&categories)
+	for _, v := range categories {
+		w.buildInternalLinks("category", v.Id, v.Content, resolved)
+	}
+}
+
+// GetOrphanArchives 已发布但没有任何站内链接指向的文档
+func (w *Website) GetOrphanArchives(moduleId uint, currentPage, pageSize int) ([]*model.Archive, int64) {
+	var archives []*model.Archive
+	var total int64
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	offset := (currentPage - 1) * pageSize
+	builder := w.DB.Model(&model.Archive{}).Where("`status` = ?", config.ContentStatusOK).
+		Where("NOT EXISTS (SELECT 1 FROM `internal_links` AS l WHERE l.`target_type` = 'archive' AND l.`target_id` = `archives`.`id`)")
+	if moduleId > 0 {
+		builder = builder.Where("`module_id` = ?", moduleId)
+	}
+	builder.Count(&total).Order("`id` desc").Limit(pageSize).Offset(offset).Find(&archives)
+	for _, v := range archives {
+		v.Link = w.GetUrl("archive", v, 0)
+	}
+
+	return archives, total
+}
+
+// GetMostLinkedTargets 入链最多的页面
+func (w *Website) GetMostLinkedTargets(targetType string, currentPage, pageSize int) ([]*response.InternalLinkTarget, int64) {
+	var targets []*response.InternalLinkTarget
+	var total int64
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	offset := (currentPage - 1) * pageSize
+	builder := func() *gorm.DB {
+		tx := w.DB.Model(&model.InternalLink{})
+		if targetType != "" {
+			tx = tx.Where("`target_type` = ?", targetType)
+		}
+		return tx
+	}
+	builder().Distinct("target_url").Count(&total)
+	builder().Select("`target_type`, `target_id`, `target_url`, count(1) AS total, count(DISTINCT `source_type`, `source_id`) AS sources").
+		Group("target_type, target_id, target_url").Order("total desc").Limit(pageSize).Offset(offset).Scan(&targets)
+	for _, v := range targets {
+		v.Title = w.getInternalLinkTitle(v.TargetType, v.TargetId)
+	}
+
+	return targets, total
+}
+
+// GetAnchorDistribution 指向某个链接的锚文字分布
+func (w *Website) GetAnchorDistribution(targetUrl string) []*response.InternalLinkAnchor {
+	anchors := []*response.InternalLinkAnchor{}
+	targetUrl = GetRedirectPath(targetUrl)
+	w.DB.Model(&model.InternalLink{}).Where("`target_url` = ?", targetUrl).
+		Select("`anchor_text`, count(1) AS total").Group("anchor_text").Order("total desc").Limit(100).Scan(&anchors)
+	var sum int64
+	for _, v := range anchors {
+		sum += v.Total
+	}
+	for _, v := range anchors {
+		if sum > 0 {
+			v.Percent = math.Round(float64(v.Total)*1000/float64(sum)) / 10
+		}
+	}
+
+	return anchors
+}
+
+// GetOverOptimizedSources 内链数量超过锚文本密度允许数量的页面，每 AnchorDensity 字允许一个链接
+func (w *Website) GetOverOptimizedSources(currentPage, pageSize int) ([]*response.InternalLinkSource, int64) {
+	var sources []*response.InternalLinkSource
+	var total int64
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	offset := (currentPage - 1) * pageSize
+	density := w.PluginAnchor.AnchorDensity
+	if density < 20 {
+		density = 200
+	}
+	builder := func() *gorm.DB {
+		return w.DB.Model(&model.InternalLink{}).
+			Select("`source_type`, `source_id`, max(`source_length`) AS source_length, count(1) AS total").
+			Group("source_type, source_id").Having("count(1) > CEIL(max(`source_length`) / ?)", density)
+	}
+	w.DB.Table("(?) AS t", builder()).Count(&total)
+	builder().Order("total desc").Limit(pageSize).Offset(offset).Scan(&sources)
+	for _, v := range sources {
+		v.MaxLinks = int(math.Ceil(float64(v.SourceLength) / float64(density)))
+		v.Title = w.getInternalLinkTitle(v.SourceType, v.SourceId)
+		v.Link = w.getInternalLinkUrl(v.SourceType, v.SourceId)
+	}
+
+	return sources, total
+}
+
+func (w *Website) getInternalLinkTitle(itemType string, itemId uint) string {
+	switch itemType {
+	case "archive":
+		var archive model.Archive
+		if w.DB.Select("id", "title").Where("`id` = ?", itemId).Take(&archive).Error == nil {
+			return archive.Title
+		}
+	case "category":
+		if category := w.GetCategoryFromCache(itemId); category != nil {
+			return category.Title
+		}
+	case "tag":
+		if tag, err := w.GetTagById(itemId); err == nil {
+			return tag.Title
+		}
+	case "index":
+		return w.Index.SeoTitle
+	}
+
+	return ""
+}
+
+func (w *Website) getInternalLinkUrl(itemType string, itemId uint) string {
+	switch itemType {
+	case "archive":
+		if archive, err := w.GetArchiveById(itemId); err == nil {
+			return archive.Link
+		}
+	case "category":
+		if category := w.GetCategoryFromCache(itemId); category != nil {
+			return w.GetUrl("category", category, 0)
+		}
+	}
+
+	return ""
+}
@@ -0,0 +1,413 @@
+package provider
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"github.com/golang/freetype"
+	"github.com/golang/freetype/truetype"
+	"golang.org/x/image/font"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"html/template"
+	"image"
+	"image/color"
+	"image/draw"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/library"
+	"kandaoni.com/anqicms/model"
+	"log"
+	"strconv"
+	"time"
+)
+
+type OrderInvoiceLine struct {
+	Title    string
+	Price    string
+	Quantity int
+	Amount   string
+}
+
+// OrderInvoiceData 发票中展示的内容，金额已格式化为元
+type OrderInvoiceData struct {
+	InvoiceNo      string
+	IssueDate      string
+	SiteName       string
+	Seller         config.ContactConfig
+	OrderId        string
+	PaidTime       string
+	PayWay         string
+	BuyerName      string
+	BuyerPhone     string
+	BuyerAddress   string
+	Lines          []OrderInvoiceLine
+	Subtotal       string
+	DiscountAmount string
+	ShippingAmount string
+	RefundAmount   string
+	Amount         string
+}
+
+// 站点内已使用的最大发票序号，存放在 settings 表中
+const invoiceCounterKey = "invoice_counter"
+
+// GetOrderInvoice 获取订单的发票记录，不存在时生成新的发票号
+func (w *Website) GetOrderInvoice(order *model.Order) (*model.OrderInvoice, error) {
+	if order.PaidTime == 0 {
+		return nil, errors.New(w.Lang("订单未支付"))
+	}
+	var invoice model.OrderInvoice
+	err := w.DB.Where("`order_id` = ?", order.OrderId).Take(&invoice).Error
+	if err == nil && invoice.InvoiceNo != "" {
+		return &invoice, nil
+	}
+	// 首次创建计数器时可能与其他请求冲突，重试一次
+	for i := 0; i < 2; i++ {
+		err = w.DB.Transaction(func(tx *gorm.DB) error {
+			return w.createOrderInvoice(tx, order, &invoice)
+		})
+		if err == nil {
+			return &invoice, nil
+		}
+	}
+
+	return nil, err
+}
+
+// createOrderInvoice 锁定计数器后再分配发票号，保证并发时不重复，插入失败时计数器随事务回滚，发票号连续
+func (w *Website) createOrderInvoice(tx *gorm.DB, order *model.Order, invoice *model.OrderInvoice) error {
+	var counter model.Setting
+	err := tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("`key` = ?", invoiceCounterKey).Take(&counter).Error
+	if err != nil {
+		// 以前的发票号使用自增ID，从最大ID开始继续编号
+		var maxId int64
+		tx.Model(&model.OrderInvoice{}).Select("COALESCE(MAX(`id`), 0)").Scan(&maxId)
+		counter = model.Setting{Key: invoiceCounterKey, Value: strconv.FormatInt(maxId, 10)}
+		if err = tx.Create(&counter).Error; err != nil {
+			return err
+		}
+	}
+	*invoice = model.OrderInvoice{}
+	if tx.Where("`order_id` = ?", order.OrderId).Take(invoice).Error == nil && invoice.InvoiceNo != "" {
+		// 已被其他请求创建
+		return nil
+	}
+	next, _ := strconv.ParseInt(counter.Value, 10, 64)
+	next++
+	prefix := w.PluginOrder.InvoicePrefix
+	if prefix == "" {
+		prefix = "INV"
+	}
+	invoice.OrderId = order.OrderId
+	invoice.UserId = order.UserId
+	invoice.Amount = order.Amount
+	invoice.InvoiceNo = fmt.Sprintf("%s%08d", prefix, next)
+	if err = tx.Save(invoice).Error; err != nil {
+		return err
+	}
+
+	return tx.Model(&counter).UpdateColumn("value", strconv.FormatInt(next, 10)).Error
+}
+
+func (w *Website) GetOrderInvoiceData(order *model.Order) (*OrderInvoiceData, error) {
+	invoice, err := w.GetOrderInvoice(order)
+	if err != nil {
+		return nil, err
+	}
+	data := &OrderInvoiceData{
+		InvoiceNo:      invoice.InvoiceNo,
+		IssueDate:      time.Unix(invoice.CreatedTime, 0).Format("2006-01-02"),
+		SiteName:       w.System.SiteName,
+		Seller:         w.Contact,
+		OrderId:        order.OrderId,
+		PaidTime:       time.Unix(order.PaidTime, 0).Format("2006-01-02 15:04:05"),
+		DiscountAmount: formatInvoiceAmount(order.DiscountAmount),
+		ShippingAmount: formatInvoiceAmount(order.ShippingAmount),
+		RefundAmount:   formatInvoiceAmount(order.RefundAmount),
+		Amount:         formatInvoiceAmount(order.Amount),
+	}
+	payment, err := w.GetPaymentInfoByOrderId(order.OrderId)
+	if err == nil {
+		data.PayWay = w.getPayWayName(payment.PayWay)
+	}
+	if order.OrderAddress != nil {
+		data.BuyerName = order.OrderAddress.Name
+		data.BuyerPhone = order.OrderAddress.Phone
+		data.BuyerAddress = order.OrderAddress.Province + order.OrderAddress.City + order.OrderAddress.Country + order.OrderAddress.AddressInfo
+	}
+	if data.BuyerName == "" {
+		user, err := w.GetUserInfoById(order.UserId)
+		if err == nil {
+			data.BuyerName = user.UserName
+			data.BuyerPhone = user.Phone
+		}
+	}
+	var subtotal int64
+	for _, detail := range order.Details {
+		var title string
+		if detail.Group != nil {
+			title = "VIP：" + detail.Group.Title
+		} else if detail.Goods != nil {
+			title = detail.Goods.Title
+		}
+		subtotal += detail.Amount
+		data.Lines = append(data.Lines, OrderInvoiceLine{
+			Title:    title,
+			Price:    formatInvoiceAmount(detail.Price),
+			Quantity: detail.Quantity,
+			Amount:   formatInvoiceAmount(detail.Amount),
+		})
+	}
+	data.Subtotal = formatInvoiceAmount(subtotal)
+
+	return data, nil
+}
+
+var invoiceHtmlTemplate = `<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{lang "订单发票"}} {{.InvoiceNo}}</title>
+<style>
+body{font-family:sans-serif;color:#333;max-width:800px;margin:20px auto;padding:0 20px}
+h1{font-size:24px}
+table{width:100%;border-collapse:collapse;margin:15px 0}
+th,td{border-bottom:1px solid #ddd;padding:8px;text-align:left}
+.info td{border:none;padding:3px 8px 3px 0;vertical-align:top;width:50%}
+.right{text-align:right}
+</style>
+</head>
+<body>
+<h1>{{lang "订单发票"}}</h1>
+<table class="info">
+<tr>
+<td>
+<strong>{{.SiteName}}</strong><br>
+{{if .Seller.UserName}}{{lang "联系人："}}{{.Seller.UserName}}<br>{{end}}
+{{if .Seller.Cellphone}}{{lang "电话："}}{{.Seller.Cellphone}}<br>{{end}}
+{{if .Seller.Email}}{{lang "邮箱："}}{{.Seller.Email}}<br>{{end}}
+{{if .Seller.Address}}{{lang "地址："}}{{.Seller.Address}}<br>{{end}}
+</td>
+<td>
+{{lang "发票号："}}{{.InvoiceNo}}<br>
+{{lang "开具日期："}}{{.IssueDate}}<br>
+{{lang "订单ID："}}{{.OrderId}}<br>
+{{lang "支付时间："}}{{.PaidTime}}<br>
+{{if .PayWay}}{{lang "支付方式："}}{{.PayWay}}<br>{{end}}
+</td>
+</tr>
+<tr>
+<td colspan="2">
+<strong>{{lang "购买人"}}</strong><br>
+{{.BuyerName}} {{.BuyerPhone}}<br>
+{{.BuyerAddress}}
+</td>
+</tr>
+</table>
+<table>
+<thead>
+<tr><th>{{lang "商品"}}</th><th class="right">{{lang "单价"}}</th><th class="right">{{lang "数量"}}</th><th class="right">{{lang "金额"}}</th></tr>
+</thead>
+<tbody>
+{{range .Lines}}<tr><td>{{.Title}}</td><td class="right">{{.Price}}</td><td class="right">{{.Quantity}}</td><td class="right">{{.Amount}}</td></tr>
+{{end}}</tbody>
+</table>
+<table>
+<tr><td class="right">{{lang "商品小计："}}</td><td class="right">{{.Subtotal}}</td></tr>
+<tr><td class="right">{{lang "优惠金额："}}</td><td class="right">-{{.DiscountAmount}}</td></tr>
+<tr><td class="right">{{lang "运费："}}</td><td class="right">{{.ShippingAmount}}</td></tr>
+<tr><td class="right"><strong>{{lang "实付金额："}}</strong></td><td class="right"><strong>{{.Amount}}</strong></td></tr>
+{{if ne .RefundAmount "0.00"}}<tr><td class="right">{{lang "已退款："}}</td><td class="right">{{.RefundAmount}}</td></tr>{{end}}
+</table>
+</body>
+</html>`
+
+// RenderOrderInvoiceHtml 生成 HTML 格式的发票
+func (w *Website) RenderOrderInvoiceHtml(order *model.Order) ([]byte, error) {
+	data, err := w.GetOrderInvoiceData(order)
+	if err != nil {
+		return nil, err
+	}
+	tpl, err := template.New("invoice").Funcs(template.FuncMap{"lang": w.Lang}).Parse(invoiceHtmlTemplate)
+	if err != nil {
+		return nil, err
+	}
+	var buf bytes.Buffer
+	err = tpl.Execute(&buf, data)
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+// RenderOrderInvoicePdf 生成 PDF 格式的发票，内容绘制成图片后写入 PDF，以支持中文
+func (w *Website) RenderOrderInvoicePdf(order *model.Order) ([]byte, error) {
+	data, err := w.GetOrderInvoiceData(order)
+	if err != nil {
+		return nil, err
+	}
+	f := loadLocalFont(w.PublicPath + w.PluginTitleImage.FontPath)
+	if f == nil {
+		return nil, errors.New(w.Lang("未找到可用的字体"))
+	}
+	canvas := newInvoiceCanvas(f)
+	canvas.line(40, invoiceCol{100, w.Lang("订单发票")})
+	canvas.gap(10)
+	canvas.line(26, invoiceCol{100, data.SiteName}, invoiceCol{700, w.Lang("发票号：") + data.InvoiceNo})
+	canvas.line(22, invoiceCol{100, w.Lang("联系人：") + data.Seller.UserName}, invoiceCol{700, w.Lang("开具日期：") + data.IssueDate})
+	canvas.line(22, invoiceCol{100, w.Lang("电话：") + data.Seller.Cellphone}, invoiceCol{700, w.Lang("订单ID：") + data.OrderId})
+	canvas.line(22, invoiceCol{100, w.Lang("邮箱：") + data.Seller.Email}, invoiceCol{700, w.Lang("支付时间：") + data.PaidTime})
+	canvas.line(22, invoiceCol{100, w.Lang("地址：") + truncateInvoiceText(data.Seller.Address, 24)}, invoiceCol{700, w.Lang("支付方式：") + data.PayWay})
+	canvas.gap(20)
+	canvas.line(24, invoiceCol{100, w.Lang("购买人")})
+	canvas.line(22, invoiceCol{100, data.BuyerName + " " + data.BuyerPhone})
+	if data.BuyerAddress != "" {
+		canvas.line(22, invoiceCol{100, truncateInvoiceText(data.BuyerAddress, 44)})
+	}
+	canvas.gap(20)
+	canvas.rule()
+	canvas.line(22, invoiceCol{100, w.Lang("商品")}, invoiceCol{720, w.Lang("单价")}, invoiceCol{880, w.Lang("数量")}, invoiceCol{980, w.Lang("金额")})
+	canvas.rule()
+	for _, item := range data.Lines {
+		canvas.line(22, invoiceCol{100, truncateInvoiceText(item.Title, 24)}, invoiceCol{720, item.Price}, invoiceCol{880, fmt.Sprintf("%d", item.Quantity)}, invoiceCol{980, item.Amount})
+	}
+	canvas.rule()
+	canvas.line(22, invoiceCol{720, w.Lang("商品小计：")}, invoiceCol{980, data.Subtotal})
+	canvas.line(22, invoiceCol{720, w.Lang("优惠金额：")}, invoiceCol{980, "-" + data.DiscountAmount})
+	canvas.line(22, invoiceCol{720, w.Lang("运费：")}, invoiceCol{980, data.ShippingAmount})
+	canvas.line(26, invoiceCol{720, w.Lang("实付金额：")}, invoiceCol{980, data.Amount})
+	if data.RefundAmount != "0.00" {
+		canvas.line(22, invoiceCol{720, w.Lang("已退款：")}, invoiceCol{980, data.RefundAmount})
+	}
+
+	return library.ImagesToPdf(canvas.pages)
+}
+
+// SendOrderPaidMail 支付成功后，给用户发送带发票附件的确认邮件
+func (w *Website) SendOrderPaidMail(order *model.Order) {
+	user, err := w.GetUserInfoById(order.UserId)
+	if err != nil || user.Email == "" {
+		return
+	}
+	if len(order.Details) == 0 {
+		fullOrder, err := w.GetOrderInfoByOrderId(order.OrderId)
+		if err != nil {
+			return
+		}
+		order = fullOrder
+	}
+	subject := fmt.Sprintf(w.Lang("%s订单%s支付成功"), w.System.SiteName, order.OrderId)
+	content := fmt.Sprintf(w.Lang("您的订单%s已支付成功，支付金额：%s，发票见附件。"), order.OrderId, formatInvoiceAmount(order.Amount))
+	var attachments []MailAttachment
+	invoice, err := w.GetOrderInvoice(order)
+	if err != nil {
+		log.Println("生成发票失败：", err.Error())
+		return
+	}
+	pdf, err := w.RenderOrderInvoicePdf(order)
+	if err == nil {
+		attachments = append(attachments, MailAttachment{FileName: invoice.InvoiceNo + ".pdf", ContentType: "application/pdf", Content: pdf})
+	} else {
+		// 没有可用字体时，使用 HTML 发票
+		html, err := w.RenderOrderInvoiceHtml(order)
+		if err == nil {
+			attachments = append(attachments, MailAttachment{FileName: invoice.InvoiceNo + ".html", ContentType: "text/html", Content: html})
+		}
+	}
+	err = w.SendMailWithAttachments(subject, content, attachments, user.Email)
+	if err != nil {
+		log.Println("发送支付确认邮件失败：", err.Error())
+	}
+}
+
+func (w *Website) getPayWayName(payWay string) string {
+	switch payWay {
+	case config.PayWayWechat:
+		return w.Lang("微信支付")
+	case config.PayWayWeapp:
+		return w.Lang("小程序支付")
+	case config.PayWayAlipay:
+		return w.Lang("支付宝支付")
+	case config.PayWayOffline:
+		return w.Lang("线下支付")
+	}
+
+	return payWay
+}
+
+func formatInvoiceAmount(amount int64) string {
+	return fmt.Sprintf("%.2f", float64(amount)/100)
+}
+
+func truncateInvoiceText(text string, length int) string {
+	runes := []rune(text)
+	if len(runes) > length {
+		return string(runes[:length-1]) + "…"
+	}
+
+	return text
+}
+
+type invoiceCol struct {
+	x    int
+	text string
+}
+
+// invoiceCanvas 按 150DPI 的 A4 纸绘制发票，内容超出一页时自动换页
+type invoiceCanvas struct {
+	font    *truetype.Font
+	context *freetype.Context
+	pages   []image.Image
+	current *image.RGBA
+	y       int
+}
+
+const (
+	invoicePageWidth  = 1240
+	invoicePageHeight = 1754
+	invoicePageMargin = 100
+)
+
+func newInvoiceCanvas(f *truetype.Font) *invoiceCanvas {
+	c := &invoiceCanvas{font: f}
+	c.newPage()
+
+	return c
+}
+
+func (c *invoiceCanvas) newPage() {
+	c.current = image.NewRGBA(image.Rect(0, 0, invoicePageWidth, invoicePageHeight))
+	draw.Draw(c.current, c.current.Bounds(), image.White, image.Point{}, draw.Src)
+	c.pages = append(c.pages, c.current)
+	c.context = freetype.NewContext()
+	c.context.SetDPI(72)
+	c.context.SetFont(c.font)
+	c.context.SetHinting(font.HintingFull)
+	c.context.SetClip(c.current.Bounds())
+	c.context.SetDst(c.current)
+	c.context.SetSrc(image.NewUniform(color.RGBA{R: 51, G: 51, B: 51, A: 255}))
+	c.y = invoicePageMargin
+}
+
+func (c *invoiceCanvas) line(size int, cols ...invoiceCol) {
+	height := int(float64(size) * 1.6)
+	if c.y+height > invoicePageHeight-invoicePageMargin {
+		c.newPage()
+	}
+	c.y += height
+	c.context.SetFontSize(float64(size))
+	for _, col := range cols {
+		_, _ = c.context.DrawString(col.text, freetype.Pt(col.x, c.y))
+	}
+}
+
+func (c *invoiceCanvas) gap(height int) {
+	c.y += height
+}
+
+func (c *invoiceCanvas) rule() {
+	c.y += 12
+	rect := image.Rect(invoicePageMargin, c.y, invoicePageWidth-invoicePageMargin, c.y+2)
+	draw.Draw(c.current, rect, image.NewUniform(color.RGBA{R: 200, G: 200, B: 200, A: 255}), image.Point{}, draw.Src)
+}
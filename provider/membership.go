@@ -0,0 +1,175 @@
+package provider
+
+import (
+	"fmt"
+	"gorm.io/gorm"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"log"
+	"time"
+)
+
+// getMembershipCredit 当前付费会员组剩余时长对应的金额，按会员组价格和有效天数折算
+func (w *Website) getMembershipCredit(user *model.User) (*model.UserGroup, int64) {
+	nowStamp := time.Now().Unix()
+	if user.ExpireTime <= nowStamp {
+		return nil, 0
+	}
+	current, err := w.GetUserGroupInfo(user.GroupId)
+	if err != nil || current.Price <= 0 || current.Setting.ExpireDay <= 0 {
+		return current, 0
+	}
+	period := int64(current.Setting.ExpireDay) * 86400
+	remain := user.ExpireTime - nowStamp
+
+	return current, current.Price * remain / period
+}
+
+// GetVipOrderProration 升级到更高等级的会员组时，当前会员组剩余的金额可以抵扣新会员组的价格
+func (w *Website) GetVipOrderProration(user *model.User, group *model.UserGroup) int64 {
+	if user == nil || user.GroupId == group.Id {
+		return 0
+	}
+	current, credit := w.getMembershipCredit(user)
+	if current == nil || credit <= 0 || current.Level >= group.Level {
+		return 0
+	}
+
+	return credit
+}
+
+// applyVipOrder VIP订单完成后更新用户的会员组和到期时间：
+// 续费同一个会员组时，从原到期时间开始延长，宽限期内续费同样从原到期时间开始；
+// 升级时从现在开始计算，剩余金额已经在下单时抵扣；
+// 降级时从现在开始计算，剩余金额按新会员组的价格折算成时长。
+func (w *Website) applyVipOrder(tx *gorm.DB, order *model.Order) {
+	var user model.User
+	var orderDetail model.OrderDetail
+	err := tx.Model(model.User{}).Where("`id` = ?", order.UserId).Take(&user).Error
+	err2 := tx.Model(model.OrderDetail{}).Where("`order_id` = ?", order.OrderId).Take(&orderDetail).Error
+	if err != nil || err2 != nil {
+		return
+	}
+	var group model.UserGroup
+	err = tx.Model(model.UserGroup{}).Where("`id` = ?", orderDetail.GoodsId).Take(&group).Error
+	if err != nil {
+		group.Setting.ExpireDay = 365
+	}
+	quantity := int64(orderDetail.Quantity)
+	if quantity < 1 {
+		quantity = 1
+	}
+	nowStamp := time.Now().Unix()
+	graceStamp := int64(w.PluginUser.VipGraceDays) * 86400
+	membership := model.UserMembership{
+		UserId:      user.Id,
+		GroupId:     group.Id,
+		FromGroupId: user.GroupId,
+		OrderId:     order.OrderId,
+		StartTime:   nowStamp,
+	}
+	if user.GroupId == group.Id && user.ExpireTime+graceStamp > nowStamp {
+		membership.Action = config.MembershipActionRenew
+		membership.StartTime = user.ExpireTime
+	} else {
+		membership.Action = config.MembershipActionNew
+		current, credit := w.getMembershipCredit(&user)
+		if current != nil && current.Price > 0 {
+			if current.Level < group.Level {
+				membership.Action = config.MembershipActionUpgrade
+				// 下单时的优惠金额中，除积分抵扣外，都是升级抵扣
+				membership.ProrateAmount = order.DiscountAmount - order.PointAmount
+			} else {
+				membership.Action = config.MembershipActionDowngrade
+				if credit > 0 && group.Price > 0 && group.Setting.ExpireDay > 0 {
+					membership.ProrateAmount = credit
+					membership.StartTime += credit * int64(group.Setting.ExpireDay) * 86400 / group.Price
+				}
+			}
+		}
+	}
+	membership.ExpireTime = membership.StartTime + int64(group.Setting.ExpireDay)*86400*quantity
+	if membership.ProrateAmount < 0 {
+		membership.ProrateAmount = 0
+	}
+
+	user.ExpireTime = membership.ExpireTime
+	user.GroupId = group.Id
+	tx.Model(&user).UpdateColumns(map[string]interface{}{
+		"expire_time": user.ExpireTime,
+		"group_id":    user.GroupId,
+	})
+	tx.Create(&membership)
+}
+
+func (w *Website) addMembershipLog(userId, fromGroupId, groupId uint, action string, expireTime int64, remark string) {
+	membership := model.UserMembership{
+		UserId:      userId,
+		GroupId:     groupId,
+		FromGroupId: fromGroupId,
+		Action:      action,
+		StartTime:   time.Now().Unix(),
+		ExpireTime:  expireTime,
+		Remark:      remark,
+	}
+	w.DB.Create(&membership)
+}
+
+func (w *Website) GetUserMemberships(userId uint, page, pageSize int) ([]*model.UserMembership, int64) {
+	var memberships []*model.UserMembership
+	var total int64
+	offset := (page - 1) * pageSize
+	tx := w.DB.Model(&model.UserMembership{})
+	if userId > 0 {
+		tx = tx.Where("`user_id` = ?", userId)
+	}
+	tx.Count(&total).Order("id desc").Limit(pageSize).Offset(offset).Find(&memberships)
+	if len(memberships) > 0 {
+		groups := w.GetUserGroups()
+		for _, v := range memberships {
+			for _, g := range groups {
+				if g.Id == v.GroupId {
+					v.Group = g
+				}
+				if g.Id == v.FromGroupId {
+					v.FromGroup = g
+				}
+			}
+		}
+	}
+
+	return memberships, total
+}
+
+// RemindUserVip 会员到期前提醒续费，每个到期时间只提醒一次
+func (w *Website) RemindUserVip() {
+	if w.DB == nil || w.PluginUser.VipRemindDays <= 0 {
+		return
+	}
+	nowStamp := time.Now().Unix()
+	remindStamp := nowStamp + int64(w.PluginUser.VipRemindDays)*86400
+	var users []*model.User
+	w.DB.Where("`status` = 1 and `expire_time` > ? and `expire_time` <= ? and `group_id` != ?", nowStamp, remindStamp, w.PluginUser.DefaultGroupId).Find(&users)
+	for _, user := range users {
+		var exists int64
+		w.DB.Model(&model.UserMembership{}).Where("`user_id` = ? and `action` = ? and `expire_time` = ?", user.Id, config.MembershipActionRemind, user.ExpireTime).Count(&exists)
+		if exists > 0 {
+			continue
+		}
+		group, err := w.GetUserGroupInfo(user.GroupId)
+		if err != nil || group.Price <= 0 {
+			continue
+		}
+		expireDate := time.Unix(user.ExpireTime, 0).Format("2006-01-02 15:04")
+		title := fmt.Sprintf(w.Lang("%s即将到期"), group.Title)
+		content := fmt.Sprintf(w.Lang("您的%s将于%s到期，续费后将从到期时间开始延长。"), group.Title, expireDate)
+		_ = w.SendNotification(user.Id, config.NotificationTypeVip, title, content, "")
+		if user.Email != "" {
+			err = w.SendMail(title, content, user.Email)
+			if err != nil {
+				log.Println("发送会员到期提醒失败：", user.Id, err.Error())
+			}
+		}
+		w.addMembershipLog(user.Id, user.GroupId, user.GroupId, config.MembershipActionRemind, user.ExpireTime, "")
+	}
+}
@@ -100,6 +100,8 @@ func (w *Website) SaveModule(req *request.ModuleRequest) (module *model.Module,
 	module.TitleName = req.TitleName
 	module.UrlToken = req.UrlToken
 	module.Status = req.Status
+	module.DeliveryType = req.DeliveryType
+	module.ShippingId = req.ShippingId
 
 	err = w.DB.Save(module).Error
 	if err != nil {
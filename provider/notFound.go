@@ -0,0 +1,232 @@
+package provider
+
+import (
+	"errors"
+	"gorm.io/gorm"
+	"kandaoni.com/anqicms/library"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/response"
+	"net/url"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+const (
+	// 推荐跳转目标的最大数量
+	notFoundMaxSuggestions = 5
+	// 相似度低于此值的不推荐
+	notFoundMinScore = 0.3
+)
+
+var notFoundPageRe = regexp.MustCompile(`[_-]\d+$`)
+
+// notFoundScope 404记录，排除忽略列表中的链接
+func (w *Website) notFoundScope(tx *gorm.DB) *gorm.DB {
+	tx = tx.Where("`http_code` = 404")
+	var ignores []*model.NotFoundIgnore
+	w.DB.Find(&ignores)
+	for _, v := range ignores {
+		if strings.HasSuffix(v.Url, "*") {
+			tx = tx.Where("`url` NOT LIKE ?", strings.TrimSuffix(v.Url, "*")+"%")
+		} else {
+			tx = tx.Where("`url` != ?", v.Url)
+		}
+	}
+
+	return tx
+}
+
+// GetNotFoundList 按链接汇总的404记录，访问次数多的在前
+func (w *Website) GetNotFoundList(keyword string, currentPage, pageSize int) ([]*response.NotFoundUrl, int64) {
+	var list []*response.NotFoundUrl
+	var total int64
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	offset := (currentPage - 1) * pageSize
+	builder := func() *gorm.DB {
+		tx := w.DB.Model(&model.Statistic{}).Scopes(w.notFoundScope)
+		if keyword != "" {
+			tx = tx.Where("`url` LIKE ?", "%"+keyword+"%")
+		}
+		return tx
+	}
+	builder().Distinct("url").Count(&total)
+	builder().Select("`url`, count(1) AS total, min(`created_time`) AS first_time, max(`created_time`) AS last_time").
+		Group("url").Order("total desc").Limit(pageSize).Offset(offset).Scan(&list)
+	for _, v := range list {
+		v.Redirected = w.MatchRedirect(v.Url) != nil
+	}
+
+	return list, total
+}
+
+// GetNotFoundDetail 404链接的来源、蜘蛛分布和推荐的跳转目标
+func (w *Website) GetNotFoundDetail(link string) *response.NotFoundDetail {
+	detail := &response.NotFoundDetail{
+		Url:      link,
+		Referers: []response.NotFoundCount{},
+		Spiders:  []response.NotFoundCount{},
+	}
+	w.DB.Model(&model.Statistic{}).Where("`http_code` = 404 and `url` = ? and `referer` != ''", link).
+		Select("`referer` AS name, count(1) AS total").Group("referer").Order("total desc").Limit(10).Scan(&detail.Referers)
+	w.DB.Model(&model.Statistic{}).Where("`http_code` = 404 and `url` = ?", link).
+		Select("`spider` AS name, count(1) AS total").Group("spider").Order("total desc").Limit(10).Scan(&detail.Spiders)
+	detail.Suggestions = w.SuggestNotFoundTargets(link)
+
+	return detail
+}
+
+// SuggestNotFoundTargets 取链接的最后一段，与文档、分类、标签的 url_token 和标题比较相似度，推荐跳转目标
+func (w *Website) SuggestNotFoundTargets(link string) []*response.NotFoundSuggestion {
+	token := notFoundToken(link)
+	suggestions := make([]*response.NotFoundSuggestion, 0, notFoundMaxSuggestions)
+	if token == "" {
+		return suggestions
+	}
+	exists := map[string]struct{}{}
+	addSuggestion := func(match string, id uint, title, urlToken string, data interface{}) {
+		key := match + strconv.Itoa(int(id))
+		if _, ok := exists[key]; ok {
+			return
+		}
+		exists[key] = struct{}{}
+		score := library.StringSimilarity(token, strings.ToLower(urlToken))
+		if titleScore := library.StringSimilarity(token, strings.ToLower(title)); titleScore > score {
+			score = titleScore
+		}
+		if score < notFoundMinScore {
+			return
+		}
+		suggestions = append(suggestions, &response.NotFoundSuggestion{
+			Type:  match,
+			Id:    id,
+			Title: title,
+			Link:  w.GetUrl(match, data, 0),
+			Score: score,
+		})
+	}
+
+	var archives []*model.Archive
+	if id, err := strconv.Atoi(token); err == nil {
+		// 纯数字的链接一般是文档ID
+		w.DB.Where("`id` = ?", id).Limit(1).Find(&archives)
+		for _, v := range archives {
+			suggestions = append(suggestions, &response.NotFoundSuggestion{
+				Type:  "archive",
+				Id:    v.Id,
+				Title: v.Title,
+				Link:  w.GetUrl("archive", v, 0),
+				Score: 1,
+			})
+		}
+		return suggestions
+	}
+	words := []string{token}
+	for _, v := range strings.FieldsFunc(token, func(r rune) bool { return r == '-' || r == '_' }) {
+		if len(v) >= 3 && v != token {
+			words = append(words, v)
+		}
+	}
+	for _, word := range words {
+		archives = nil
+		w.DB.Model(&model.Archive{}).Select("id", "title", "url_token", "fixed_link", "category_id", "module_id", "created_time").
+			Where("`url_token` LIKE ? OR `title` LIKE ?", "%"+word+"%", "%"+word+"%").Order("`id` desc").Limit(10).Find(&archives)
+		for _, v := range archives {
+			addSuggestion("archive", v.Id, v.Title, v.UrlToken, v)
+		}
+		var tags []*model.Tag
+		w.DB.Where("`url_token` LIKE ?", "%"+word+"%").Limit(5).Find(&tags)
+		for _, v := range tags {
+			addSuggestion("tag", v.Id, v.Title, v.UrlToken, v)
+		}
+	}
+	categories := w.GetCacheCategories()
+	for i := range categories {
+		addSuggestion("category", categories[i].Id, categories[i].Title, categories[i].UrlToken, &categories[i])
+	}
+	sort.SliceStable(suggestions, func(i, j int) bool {
+		return suggestions[i].Score > suggestions[j].Score
+	})
+	if len(suggestions) > notFoundMaxSuggestions {
+		suggestions = suggestions[:notFoundMaxSuggestions]
+	}
+
+	return suggestions
+}
+
+// notFoundToken 取链接路径的最后一段，去掉扩展名和分页后缀
+func notFoundToken(link string) string {
+	link = GetRedirectPath(link)
+	if idx := strings.IndexAny(link, "?#"); idx >= 0 {
+		link = link[:idx]
+	}
+	if unescaped, err := url.PathUnescape(link); err == nil {
+		link = unescaped
+	}
+	token := path.Base(strings.TrimRight(link, "/"))
+	if token == "." || token == "/" {
+		return ""
+	}
+	token = strings.TrimSuffix(token, path.Ext(token))
+	if _, err := strconv.Atoi(token); err != nil {
+		token = notFoundPageRe.ReplaceAllString(token, "")
+	}
+
+	return strings.ToLower(token)
+}
+
+func (w *Website) GetNotFoundIgnores() []*model.NotFoundIgnore {
+	var ignores []*model.NotFoundIgnore
+	w.DB.Order("`id` desc").Find(&ignores)
+
+	return ignores
+}
+
+func (w *Website) SaveNotFoundIgnore(link string) (*model.NotFoundIgnore, error) {
+	link = strings.TrimSpace(link)
+	if link == "" {
+		return nil, errors.New(w.Lang("请填写链接"))
+	}
+	if !strings.HasSuffix(link, "*") {
+		link = GetRedirectPath(link)
+	}
+	if len(link) > 190 {
+		return nil, errors.New(w.Lang("链接过长"))
+	}
+	ignore := model.NotFoundIgnore{Url: link}
+	err := w.DB.Where("`url` = ?", link).FirstOrCreate(&ignore).Error
+
+	return &ignore, err
+}
+
+func (w *Website) DeleteNotFoundIgnore(id uint) error {
+	return w.DB.Unscoped().Where("`id` = ?", id).Delete(&model.NotFoundIgnore{}).Error
+}
+
+// CreateNotFoundRedirect 将404链接转为跳转规则
+func (w *Website) CreateNotFoundRedirect(link, toUrl string, statusCode int) error {
+	fromUrl := GetRedirectPath(link)
+	toUrl = strings.TrimSpace(toUrl)
+	if statusCode != 410 {
+		if toUrl == "" {
+			return errors.New(w.Lang("请填写跳转链接"))
+		}
+		// 站内链接只保留路径，站外链接保持原样
+		if !strings.HasPrefix(toUrl, "http") || strings.HasPrefix(toUrl, w.System.BaseUrl) {
+			toUrl = GetRedirectPath(toUrl)
+		}
+	}
+	if !w.addRedirect(fromUrl, toUrl, statusCode) {
+		return errors.New(w.Lang("跳转规则创建失败"))
+	}
+	w.DeleteCacheRedirects()
+
+	return nil
+}
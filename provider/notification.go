@@ -0,0 +1,282 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/library"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/request"
+	"log"
+	"time"
+)
+
+// SendNotification 给用户发送一条站内通知
+func (w *Website) SendNotification(userId uint, notifyType, title, content, link string) error {
+	if userId == 0 {
+		return nil
+	}
+	notification := model.Notification{
+		UserId:  userId,
+		Type:    notifyType,
+		Title:   title,
+		Content: content,
+		Link:    link,
+	}
+
+	return w.DB.Create(&notification).Error
+}
+
+// BroadcastNotification 后台发送广播，GroupId 为0时发给所有用户。广播只保存一条，已读状态单独记录
+func (w *Website) BroadcastNotification(req *request.NotificationRequest) (*model.Notification, error) {
+	if req.Title == "" {
+		return nil, errors.New(w.Lang("请填写标题"))
+	}
+	if req.GroupId > 0 {
+		_, err := w.GetUserGroupInfo(req.GroupId)
+		if err != nil {
+			return nil, err
+		}
+	}
+	notification := model.Notification{
+		GroupId: req.GroupId,
+		Type:    config.NotificationTypeSystem,
+		Title:   req.Title,
+		Content: req.Content,
+		Link:    req.Link,
+	}
+	err := w.DB.Create(&notification).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &notification, nil
+}
+
+// notificationScope 用户能看到的通知：发给自己的，以及注册之后发送给所有人或自己所在用户组的广播
+func (w *Website) notificationScope(user *model.User) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("`user_id` = ? OR (`user_id` = 0 AND `group_id` IN (?) AND `created_time` >= ?)", user.Id, []uint{0, user.GroupId}, user.CreatedTime)
+	}
+}
+
+func (w *Website) notificationUnreadScope(user *model.User) func(tx *gorm.DB) *gorm.DB {
+	return func(tx *gorm.DB) *gorm.DB {
+		return tx.Where("(`user_id` = ? AND `is_read` = 0) OR (`user_id` = 0 AND `group_id` IN (?) AND `created_time` >= ? AND `id` NOT IN (?))",
+			user.Id, []uint{0, user.GroupId}, user.CreatedTime,
+			w.DB.Model(&model.NotificationRead{}).Select("notification_id").Where("`user_id` = ?", user.Id))
+	}
+}
+
+func (w *Website) GetNotificationList(user *model.User, notifyType string, unread bool, page, pageSize int) ([]*model.Notification, int64) {
+	var notifications []*model.Notification
+	var total int64
+	offset := (page - 1) * pageSize
+	tx := w.DB.Model(&model.Notification{})
+	if unread {
+		tx = tx.Scopes(w.notificationUnreadScope(user))
+	} else {
+		tx = tx.Scopes(w.notificationScope(user))
+	}
+	if notifyType != "" {
+		tx = tx.Where("`type` = ?", notifyType)
+	}
+	tx.Count(&total).Order("id desc").Limit(pageSize).Offset(offset).Find(&notifications)
+
+	// 广播的已读状态
+	var broadcastIds []uint
+	for _, v := range notifications {
+		if v.UserId == 0 {
+			broadcastIds = append(broadcastIds, v.Id)
+		}
+	}
+	if len(broadcastIds) > 0 {
+		var reads []*model.NotificationRead
+		w.DB.Where("`user_id` = ? and `notification_id` IN(?)", user.Id, broadcastIds).Find(&reads)
+		for _, v := range notifications {
+			for _, r := range reads {
+				if r.NotificationId == v.Id {
+					v.IsRead = 1
+					v.ReadTime = r.ReadTime
+					break
+				}
+			}
+		}
+	}
+
+	return notifications, total
+}
+
+func (w *Website) GetUnreadNotificationCount(user *model.User) int64 {
+	if user == nil {
+		return 0
+	}
+	var total int64
+	w.DB.Model(&model.Notification{}).Scopes(w.notificationUnreadScope(user)).Count(&total)
+
+	return total
+}
+
+// ReadNotifications 标记为已读，ids 为空时，全部标记为已读
+func (w *Website) ReadNotifications(user *model.User, ids []uint) error {
+	nowStamp := time.Now().Unix()
+	var notifications []*model.Notification
+	tx := w.DB.Model(&model.Notification{}).Scopes(w.notificationUnreadScope(user))
+	if len(ids) > 0 {
+		tx = tx.Where("`id` IN(?)", ids)
+	}
+	tx.Select("id", "user_id").Find(&notifications)
+
+	var personalIds []uint
+	var reads []*model.NotificationRead
+	for _, v := range notifications {
+		if v.UserId == 0 {
+			reads = append(reads, &model.NotificationRead{
+				NotificationId: v.Id,
+				UserId:         user.Id,
+				ReadTime:       nowStamp,
+			})
+		} else {
+			personalIds = append(personalIds, v.Id)
+		}
+	}
+	if len(personalIds) > 0 {
+		err := w.DB.Model(&model.Notification{}).Where("`id` IN(?) and `user_id` = ?", personalIds, user.Id).UpdateColumns(map[string]interface{}{
+			"is_read":   1,
+			"read_time": nowStamp,
+		}).Error
+		if err != nil {
+			return err
+		}
+	}
+	if len(reads) > 0 {
+		err := w.DB.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(reads, 100).Error
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (w *Website) GetBroadcastNotifications(page, pageSize int) ([]*model.Notification, int64) {
+	var notifications []*model.Notification
+	var total int64
+	offset := (page - 1) * pageSize
+	w.DB.Model(&model.Notification{}).Where("`user_id` = 0").Count(&total).Order("id desc").Limit(pageSize).Offset(offset).Find(&notifications)
+
+	return notifications, total
+}
+
+func (w *Website) DeleteBroadcastNotification(id uint) error {
+	var notification model.Notification
+	err := w.DB.Where("`id` = ? and `user_id` = 0", id).Take(&notification).Error
+	if err != nil {
+		return err
+	}
+	err = w.DB.Delete(&notification).Error
+	if err != nil {
+		return err
+	}
+	w.DB.Where("`notification_id` = ?", notification.Id).Delete(&model.NotificationRead{})
+
+	return nil
+}
+
+// NotifyCommentReply 评论审核通过后，通知被回复的用户和文档作者，同一条评论对同一个用户只通知一次
+func (w *Website) NotifyCommentReply(comment *model.Comment) {
+	if comment.Status != model.StatusOk {
+		return
+	}
+	var link string
+	archive, err := w.GetArchiveById(comment.ArchiveId)
+	if err == nil {
+		link = w.GetUrl("archive", archive, 0)
+	}
+	link = fmt.Sprintf("%s#comment-%d", link, comment.Id)
+
+	var notifiedId uint
+	if comment.ParentId > 0 {
+		var parent model.Comment
+		if err := w.DB.Where("`id` = ?", comment.ParentId).Take(&parent).Error; err == nil && parent.UserId > 0 && parent.UserId != comment.UserId {
+			title := fmt.Sprintf(w.Lang("%s回复了你的评论"), comment.UserName)
+			if archive != nil {
+				title = fmt.Sprintf(w.Lang("%s在《%s》中回复了你的评论"), comment.UserName, archive.Title)
+			}
+			w.sendCommentNotice(parent.UserId, title, comment, link)
+			notifiedId = parent.UserId
+		}
+	}
+	if archive != nil && archive.UserId > 0 && archive.UserId != comment.UserId && archive.UserId != notifiedId {
+		title := fmt.Sprintf(w.Lang("%s评论了你的文档《%s》"), comment.UserName, archive.Title)
+		w.sendCommentNotice(archive.UserId, title, comment, link)
+	}
+}
+
+// sendCommentNotice 发送站内通知，用户有邮箱且没有退订时同时发送邮件
+func (w *Website) sendCommentNotice(userId uint, title string, comment *model.Comment, link string) {
+	var exists int64
+	w.DB.Model(&model.Notification{}).Where("`user_id` = ? and `type` = ? and `link` = ?", userId, config.NotificationTypeComment, link).Count(&exists)
+	if exists > 0 {
+		return
+	}
+	_ = w.SendNotification(userId, config.NotificationTypeComment, title, comment.Content, link)
+
+	user, err := w.GetUserInfoById(userId)
+	if err != nil || user.Email == "" || user.UnsubscribeComment == 1 || w.PluginSendmail.Account == "" {
+		return
+	}
+	unsubscribeLink := w.GetCommentUnsubscribeLink(userId)
+	htmlContent := fmt.Sprintf("%s<p><a href=\"%s\">%s</a></p><p>%s<a href=\"%s\">%s</a></p>",
+		library.RenderMarkdown(comment.Content), link, w.Lang("查看评论"),
+		w.Lang("不想再收到评论提醒邮件？"), unsubscribeLink, w.Lang("点击退订"))
+	textContent := fmt.Sprintf("%s\n\n%s%s\n\n%s%s",
+		comment.Content, w.Lang("查看评论："), link, w.Lang("退订评论提醒邮件："), unsubscribeLink)
+	go func() {
+		if err := w.SendHtmlMail(title, htmlContent, textContent, user.Email); err != nil {
+			log.Println("发送评论提醒邮件失败：", userId, err.Error())
+		}
+	}()
+}
+
+// GetCommentUnsubscribeLink 邮件中的退订链接，使用签名校验，不需要登录
+func (w *Website) GetCommentUnsubscribeLink(userId uint) string {
+	return fmt.Sprintf("%s/comment/unsubscribe?uid=%d&sign=%s", w.System.BaseUrl, userId, w.signCommentUnsubscribe(userId))
+}
+
+func (w *Website) signCommentUnsubscribe(userId uint) string {
+	mac := hmac.New(sha256.New, []byte(config.Server.Server.TokenSecret))
+	mac.Write([]byte(fmt.Sprintf("%d-comment-unsubscribe-%d", w.Id, userId)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// UnsubscribeCommentMail 退订或重新订阅评论提醒邮件，sign 为空时不校验签名，用于已登录的用户
+func (w *Website) UnsubscribeCommentMail(userId uint, sign string, unsubscribe bool) error {
+	if sign != "" && !hmac.Equal([]byte(sign), []byte(w.signCommentUnsubscribe(userId))) {
+		return errors.New(w.Lang("退订链接无效"))
+	}
+	user, err := w.GetUserInfoById(userId)
+	if err != nil {
+		return err
+	}
+	value := 0
+	if unsubscribe {
+		value = 1
+	}
+
+	return w.DB.Model(user).UpdateColumn("unsubscribe_comment", value).Error
+}
+
+// NotifyOrderStatus 订单状态变化时通知下单用户
+func (w *Website) NotifyOrderStatus(order *model.Order, content string) {
+	title := fmt.Sprintf(w.Lang("订单%s状态更新：%s"), order.OrderId, w.getOrderStatus(order.Status))
+	link := w.System.BaseUrl + "/account/order?order_id=" + order.OrderId
+
+	_ = w.SendNotification(order.UserId, config.NotificationTypeOrder, title, content, link)
+}
@@ -0,0 +1,710 @@
+package provider
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+type oauthEndpoints struct {
+	Issuer                string `json:"issuer"`
+	AuthorizationEndpoint string `json:"authorization_endpoint"`
+	TokenEndpoint         string `json:"token_endpoint"`
+	UserinfoEndpoint      string `json:"userinfo_endpoint"`
+
+	fetchTime int64
+}
+
+type oauthState struct {
+	Type     string `json:"t"`
+	BindId   uint   `json:"b,omitempty"`
+	Redirect string `json:"r,omitempty"`
+	Expire   int64  `json:"e"`
+	Nonce    string `json:"n"`
+}
+
+// OauthUserInfo 按照配置的 claim 映射后的第三方用户信息
+type OauthUserInfo struct {
+	Subject       string
+	UserName      string
+	RealName      string
+	Email         string
+	EmailVerified bool
+	Phone         string
+	AvatarURL     string
+	Groups        []string
+}
+
+var oauthDiscoveryCache = map[string]*oauthEndpoints{}
+var oauthDiscoveryMutex = sync.Mutex{}
+
+// 第三方登录只通过 https 和服务端之间通信，不跳过证书校验
+var oauthClient = &http.Client{Timeout: 10 * time.Second}
+
+// getOauthEndpoints 获取授权、token 和用户信息地址，自动发现的结果缓存1小时，手动填写的地址优先
+func (w *Website) getOauthEndpoints() (*oauthEndpoints, error) {
+	setting := w.PluginOauth
+	endpoints := &oauthEndpoints{}
+	if setting.DiscoveryUrl != "" {
+		oauthDiscoveryMutex.Lock()
+		cached, ok := oauthDiscoveryCache[setting.DiscoveryUrl]
+		oauthDiscoveryMutex.Unlock()
+		if !ok || cached.fetchTime < time.Now().Add(-time.Hour).Unix() {
+			var discovered oauthEndpoints
+			err := oauthRequest("GET", setting.DiscoveryUrl, nil, "", &discovered)
+			if err != nil {
+				return nil, err
+			}
+			discovered.fetchTime = time.Now().Unix()
+			oauthDiscoveryMutex.Lock()
+			oauthDiscoveryCache[setting.DiscoveryUrl] = &discovered
+			oauthDiscoveryMutex.Unlock()
+			cached = &discovered
+		}
+		*endpoints = *cached
+	}
+	if setting.AuthorizeUrl != "" {
+		endpoints.AuthorizationEndpoint = setting.AuthorizeUrl
+	}
+	if setting.TokenUrl != "" {
+		endpoints.TokenEndpoint = setting.TokenUrl
+	}
+	if setting.UserInfoUrl != "" {
+		endpoints.UserinfoEndpoint = setting.UserInfoUrl
+	}
+	if setting.Issuer != "" {
+		endpoints.Issuer = setting.Issuer
+	}
+	if endpoints.AuthorizationEndpoint == "" || endpoints.TokenEndpoint == "" {
+		return nil, errors.New(w.Lang("第三方登录配置不完整"))
+	}
+
+	return endpoints, nil
+}
+
+// GetOauthRedirectUri 回调地址，需要在第三方平台中登记
+func (w *Website) GetOauthRedirectUri(accountType string) string {
+	if accountType == config.OauthTypeAdmin {
+		return w.getAdminBaseUrl() + "/system/api/oauth/callback"
+	}
+
+	return w.System.BaseUrl + "/api/oauth/callback"
+}
+
+func (w *Website) getAdminBaseUrl() string {
+	if strings.HasPrefix(w.System.AdminUrl, "http") {
+		return strings.TrimRight(w.System.AdminUrl, "/")
+	}
+
+	return w.System.BaseUrl
+}
+
+// GetOauthAuthorizeUrl 生成跳转到第三方平台的授权地址，bindId 大于0时，表示已登录的账号发起绑定。
+// 返回的 nonce 需要保存到浏览器 cookie 中，回调时校验，防止被诱导登录到别人的账号
+func (w *Website) GetOauthAuthorizeUrl(accountType string, bindId uint, redirect string) (string, string, error) {
+	if accountType == config.OauthTypeAdmin && !w.PluginOauth.AdminOpen {
+		return "", "", errors.New(w.Lang("第三方登录未开启"))
+	}
+	if accountType == config.OauthTypeUser && !w.PluginOauth.Open {
+		return "", "", errors.New(w.Lang("第三方登录未开启"))
+	}
+	endpoints, err := w.getOauthEndpoints()
+	if err != nil {
+		return "", "", err
+	}
+	state := oauthState{
+		Type:     accountType,
+		BindId:   bindId,
+		Redirect: w.safeOauthRedirect(redirect),
+		Expire:   time.Now().Add(10 * time.Minute).Unix(),
+		Nonce:    oauthRandString(16),
+	}
+	query := url.Values{}
+	query.Set("response_type", "code")
+	query.Set("client_id", w.PluginOauth.ClientId)
+	query.Set("redirect_uri", w.GetOauthRedirectUri(accountType))
+	query.Set("scope", w.PluginOauth.Scopes)
+	query.Set("state", w.encodeOauthState(&state))
+	query.Set("nonce", state.Nonce)
+
+	link := endpoints.AuthorizationEndpoint
+	if strings.Contains(link, "?") {
+		link += "&"
+	} else {
+		link += "?"
+	}
+
+	return link + query.Encode(), state.Nonce, nil
+}
+
+// safeOauthRedirect 只允许跳转回本站
+func (w *Website) safeOauthRedirect(redirect string) string {
+	if strings.HasPrefix(redirect, w.System.BaseUrl+"/") {
+		redirect = strings.TrimPrefix(redirect, w.System.BaseUrl)
+	}
+	if !strings.HasPrefix(redirect, "/") || strings.HasPrefix(redirect, "//") || strings.HasPrefix(redirect, "/\\") {
+		return ""
+	}
+
+	return redirect
+}
+
+func (w *Website) encodeOauthState(state *oauthState) string {
+	buf, _ := json.Marshal(state)
+	payload := base64.RawURLEncoding.EncodeToString(buf)
+
+	return payload + "." + w.signOauthState(payload)
+}
+
+func (w *Website) decodeOauthState(value, nonce, accountType string) (*oauthState, error) {
+	invalid := errors.New(w.Lang("登录请求无效，请重新登录"))
+	payload, sign, ok := strings.Cut(value, ".")
+	if !ok || !hmac.Equal([]byte(sign), []byte(w.signOauthState(payload))) {
+		return nil, invalid
+	}
+	buf, err := base64.RawURLEncoding.DecodeString(payload)
+	if err != nil {
+		return nil, invalid
+	}
+	var state oauthState
+	if err = json.Unmarshal(buf, &state); err != nil {
+		return nil, invalid
+	}
+	if state.Type != accountType || state.Expire < time.Now().Unix() || state.Nonce != nonce {
+		return nil, invalid
+	}
+
+	return &state, nil
+}
+
+func (w *Website) signOauthState(payload string) string {
+	mac := hmac.New(sha256.New, []byte(config.Server.Server.TokenSecret))
+	mac.Write([]byte(fmt.Sprintf("%d-oauth-%s", w.Id, payload)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// fetchOauthUserInfo 使用授权码换取 token，并读取用户信息，nonce 是发起授权时生成的随机串
+func (w *Website) fetchOauthUserInfo(accountType, code, nonce string) (*OauthUserInfo, error) {
+	if code == "" {
+		return nil, errors.New(w.Lang("登录请求无效，请重新登录"))
+	}
+	endpoints, err := w.getOauthEndpoints()
+	if err != nil {
+		return nil, err
+	}
+	form := url.Values{}
+	form.Set("grant_type", "authorization_code")
+	form.Set("code", code)
+	form.Set("redirect_uri", w.GetOauthRedirectUri(accountType))
+	form.Set("client_id", w.PluginOauth.ClientId)
+	form.Set("client_secret", w.PluginOauth.ClientSecret)
+	var token struct {
+		AccessToken      string `json:"access_token"`
+		IdToken          string `json:"id_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	err = oauthRequest("POST", endpoints.TokenEndpoint, form, "", &token)
+	if err != nil {
+		return nil, err
+	}
+	if token.Error != "" || token.AccessToken == "" {
+		return nil, fmt.Errorf("%s %s %s", w.Lang("获取第三方登录信息失败"), token.Error, token.ErrorDescription)
+	}
+	claims := map[string]interface{}{}
+	if token.IdToken != "" {
+		// id_token 是服务端通过 https 直接从 token 地址获取的，可以不再校验签名，但仍需校验其中的声明
+		parts := strings.Split(token.IdToken, ".")
+		if len(parts) != 3 {
+			return nil, errors.New(w.Lang("第三方登录凭证校验失败"))
+		}
+		buf, err := base64.RawURLEncoding.DecodeString(parts[1])
+		if err != nil {
+			return nil, errors.New(w.Lang("第三方登录凭证校验失败"))
+		}
+		decoder := json.NewDecoder(bytes.NewReader(buf))
+		decoder.UseNumber()
+		if err = decoder.Decode(&claims); err != nil {
+			return nil, errors.New(w.Lang("第三方登录凭证校验失败"))
+		}
+		if err = verifyOauthIdTokenClaims(claims, endpoints.Issuer, w.PluginOauth.ClientId, nonce, time.Now()); err != nil {
+			return nil, fmt.Errorf("%s: %s", w.Lang("第三方登录凭证校验失败"), err.Error())
+		}
+	}
+	if endpoints.UserinfoEndpoint != "" {
+		var userInfo map[string]interface{}
+		err = oauthRequest("GET", endpoints.UserinfoEndpoint, nil, token.AccessToken, &userInfo)
+		if err != nil {
+			return nil, err
+		}
+		// userinfo 的 sub 必须与 id_token 的一致
+		if sub, ok := claims["sub"]; ok && fmt.Sprintf("%v", userInfo["sub"]) != fmt.Sprintf("%v", sub) {
+			return nil, errors.New(w.Lang("第三方登录凭证校验失败"))
+		}
+		// userinfo 的内容比 id_token 更完整
+		for k, v := range userInfo {
+			claims[k] = v
+		}
+	}
+
+	setting := w.PluginOauth
+	info := &OauthUserInfo{
+		Subject:   getOauthClaimString(claims, setting.ClaimId),
+		UserName:  getOauthClaimString(claims, defaultClaim(setting.ClaimUserName, "preferred_username")),
+		RealName:  getOauthClaimString(claims, defaultClaim(setting.ClaimRealName, "name")),
+		Email:     getOauthClaimString(claims, defaultClaim(setting.ClaimEmail, "email")),
+		Phone:     getOauthClaimString(claims, defaultClaim(setting.ClaimPhone, "phone_number")),
+		AvatarURL: getOauthClaimString(claims, defaultClaim(setting.ClaimAvatar, "picture")),
+	}
+	info.EmailVerified = getOauthClaimString(claims, "email_verified") == "true"
+	if setting.ClaimGroup != "" {
+		info.Groups = getOauthClaimStrings(claims, setting.ClaimGroup)
+	}
+	if info.Subject == "" {
+		return nil, errors.New(w.Lang("获取第三方登录信息失败"))
+	}
+
+	return info, nil
+}
+
+// verifyOauthIdTokenClaims 按 OIDC 规范校验 id_token 的签发者、受众、有效期和 nonce。
+// 没有自动发现也没有填写签发者时不校验 iss
+func verifyOauthIdTokenClaims(claims map[string]interface{}, issuer, clientId, nonce string, now time.Time) error {
+	if issuer != "" && getOauthClaimString(claims, "iss") != issuer {
+		return errors.New("iss mismatch")
+	}
+	// aud 可以是字符串或数组
+	var audiences []string
+	switch v := claims["aud"].(type) {
+	case string:
+		audiences = []string{v}
+	case []interface{}:
+		for _, item := range v {
+			if s, ok := item.(string); ok {
+				audiences = append(audiences, s)
+			}
+		}
+	}
+	found := false
+	for _, v := range audiences {
+		if v == clientId {
+			found = true
+			break
+		}
+	}
+	if !found {
+		return errors.New("aud mismatch")
+	}
+	azp := getOauthClaimString(claims, "azp")
+	if (len(audiences) > 1 || azp != "") && azp != clientId {
+		return errors.New("azp mismatch")
+	}
+	exp, err := strconv.ParseFloat(getOauthClaimString(claims, "exp"), 64)
+	// 允许1分钟的时钟误差
+	if err != nil || int64(exp) < now.Add(-time.Minute).Unix() {
+		return errors.New("token expired")
+	}
+	if nonce == "" || getOauthClaimString(claims, "nonce") != nonce {
+		return errors.New("nonce mismatch")
+	}
+
+	return nil
+}
+
+func oauthRequest(method, link string, form url.Values, accessToken string, result interface{}) error {
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+	req, err := http.NewRequest(method, link, body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Accept", "application/json")
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	if accessToken != "" {
+		req.Header.Set("Authorization", "Bearer "+accessToken)
+	}
+	resp, err := oauthClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	buf, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("%s: %s", link, resp.Status)
+	}
+	decoder := json.NewDecoder(bytes.NewReader(buf))
+	decoder.UseNumber()
+
+	return decoder.Decode(result)
+}
+
+func oauthRandString(n int) string {
+	buf := make([]byte, n)
+	_, _ = rand.Read(buf)
+
+	return hex.EncodeToString(buf)[:n]
+}
+
+func defaultClaim(claim, def string) string {
+	if claim == "" {
+		return def
+	}
+
+	return claim
+}
+
+// getOauthClaim 读取 claim，支持用 a.b 读取嵌套的字段
+func getOauthClaim(claims map[string]interface{}, key string) interface{} {
+	var current interface{} = claims
+	for _, name := range strings.Split(key, ".") {
+		m, ok := current.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		current = m[name]
+	}
+
+	return current
+}
+
+func getOauthClaimString(claims map[string]interface{}, key string) string {
+	switch v := getOauthClaim(claims, key).(type) {
+	case string:
+		return strings.TrimSpace(v)
+	case json.Number:
+		return v.String()
+	case bool:
+		if v {
+			return "true"
+		}
+		return "false"
+	}
+
+	return ""
+}
+
+func getOauthClaimStrings(claims map[string]interface{}, key string) []string {
+	var result []string
+	switch v := getOauthClaim(claims, key).(type) {
+	case string:
+		// 部分平台用空格或逗号分隔多个值
+		for _, item := range strings.FieldsFunc(v, func(r rune) bool { return r == ' ' || r == ',' }) {
+			result = append(result, item)
+		}
+	case []interface{}:
+		for _, item := range v {
+			switch s := item.(type) {
+			case string:
+				result = append(result, s)
+			case json.Number:
+				result = append(result, s.String())
+			}
+		}
+	}
+
+	return result
+}
+
+// matchOauthGroup 返回第一个匹配到的分组ID，没有匹配时返回0
+func matchOauthGroup(mappings []config.OauthGroupMapping, groups []string) uint {
+	for _, mapping := range mappings {
+		for _, group := range groups {
+			if mapping.Value == group && mapping.GroupId > 0 {
+				return mapping.GroupId
+			}
+		}
+	}
+
+	return 0
+}
+
+func (w *Website) GetOauthAccount(accountType, subject string) (*model.OauthAccount, error) {
+	var account model.OauthAccount
+	err := w.DB.Where("`type` = ? and `subject` = ?", accountType, subject).Take(&account).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &account, nil
+}
+
+func (w *Website) GetOauthAccounts(accountType string, targetId uint) []*model.OauthAccount {
+	var accounts []*model.OauthAccount
+	w.DB.Where("`type` = ? and `target_id` = ?", accountType, targetId).Order("id asc").Find(&accounts)
+
+	return accounts
+}
+
+func (w *Website) UnbindOauthAccount(accountType string, targetId uint, id uint) error {
+	return w.DB.Where("`id` = ? and `type` = ? and `target_id` = ?", id, accountType, targetId).Delete(&model.OauthAccount{}).Error
+}
+
+func (w *Website) bindOauthAccount(accountType string, targetId uint, info *OauthUserInfo) (*model.OauthAccount, error) {
+	account, err := w.GetOauthAccount(accountType, info.Subject)
+	if err == nil {
+		if account.TargetId != targetId {
+			return nil, errors.New(w.Lang("该第三方账号已绑定其他账号"))
+		}
+		return account, nil
+	}
+	account = &model.OauthAccount{
+		Type:     accountType,
+		Subject:  info.Subject,
+		TargetId: targetId,
+		Email:    info.Email,
+		Nickname: info.UserName,
+	}
+	err = w.DB.Create(account).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return account, nil
+}
+
+// LoginViaOauth 前台用户第三方登录回调，依次按已绑定账号、发起绑定的账号、已验证的邮箱查找用户，都找不到时自动注册
+func (w *Website) LoginViaOauth(stateValue, nonce, code string) (*model.User, string, error) {
+	state, err := w.decodeOauthState(stateValue, nonce, config.OauthTypeUser)
+	if err != nil {
+		return nil, "", err
+	}
+	info, err := w.fetchOauthUserInfo(config.OauthTypeUser, code, state.Nonce)
+	if err != nil {
+		return nil, state.Redirect, err
+	}
+	var user *model.User
+	account, err := w.GetOauthAccount(config.OauthTypeUser, info.Subject)
+	if err == nil {
+		if state.BindId > 0 && account.TargetId != state.BindId {
+			return nil, state.Redirect, errors.New(w.Lang("该第三方账号已绑定其他账号"))
+		}
+		user, err = w.GetUserInfoById(account.TargetId)
+		if err != nil {
+			// 用户已被删除，重新关联
+			w.DB.Delete(account)
+			user = nil
+		}
+	}
+	if user == nil && state.BindId > 0 {
+		user, err = w.GetUserInfoById(state.BindId)
+		if err != nil {
+			return nil, state.Redirect, errors.New(w.Lang("用户信息不完整"))
+		}
+	}
+	if user == nil && w.PluginOauth.LinkByEmail && info.Email != "" && info.EmailVerified {
+		user, _ = w.GetUserInfoByEmail(info.Email)
+	}
+	if user == nil {
+		if !w.PluginOauth.AutoRegister {
+			return nil, state.Redirect, errors.New(w.Lang("该第三方账号未绑定用户"))
+		}
+		user = w.createOauthUser(info)
+		if user == nil {
+			return nil, state.Redirect, errors.New(w.Lang("登录失败"))
+		}
+	}
+	if _, err = w.bindOauthAccount(config.OauthTypeUser, user.Id, info); err != nil {
+		return nil, state.Redirect, err
+	}
+	if user.Status != 1 {
+		return nil, state.Redirect, errors.New(w.Lang("该用户已被禁用"))
+	}
+	if groupId := matchOauthGroup(w.PluginOauth.GroupMapping, info.Groups); groupId > 0 && groupId != user.GroupId {
+		user.GroupId = groupId
+		w.DB.Model(user).UpdateColumn("group_id", groupId)
+	}
+
+	_ = user.EncodeToken(w.DB)
+
+	return user, state.Redirect, nil
+}
+
+func (w *Website) createOauthUser(info *OauthUserInfo) *model.User {
+	userName := info.UserName
+	if userName == "" {
+		userName = info.RealName
+	}
+	if userName == "" && info.Email != "" {
+		userName = strings.Split(info.Email, "@")[0]
+	}
+	if userName == "" {
+		userName = "user"
+	}
+	if runes := []rune(userName); len(runes) > 50 {
+		userName = string(runes[:50])
+	}
+	if _, err := w.GetUserInfoByUserName(userName); err == nil {
+		userName = userName + "_" + oauthRandString(4)
+	}
+	user := &model.User{
+		UserName:  userName,
+		RealName:  info.RealName,
+		AvatarURL: info.AvatarURL,
+		GroupId:   w.PluginUser.DefaultGroupId,
+		Status:    1,
+	}
+	if info.Email != "" {
+		if _, err := w.GetUserInfoByEmail(info.Email); err != nil {
+			user.Email = info.Email
+			if info.EmailVerified {
+				user.Verified = 1
+			}
+		}
+	}
+	if info.Phone != "" {
+		if _, err := w.GetUserInfoByPhone(info.Phone); err != nil {
+			user.Phone = info.Phone
+		}
+	}
+	if err := w.DB.Save(user).Error; err != nil {
+		return nil
+	}
+	w.AwardUserPoints(user.Id, config.PointActionRegister, 0)
+
+	return user
+}
+
+// LoginAdminViaOauth 后台管理员单点登录回调，没有绑定的管理员时，按分组映射自动创建管理员
+func (w *Website) LoginAdminViaOauth(stateValue, nonce, code string) (*model.Admin, error) {
+	state, err := w.decodeOauthState(stateValue, nonce, config.OauthTypeAdmin)
+	if err != nil {
+		return nil, err
+	}
+	info, err := w.fetchOauthUserInfo(config.OauthTypeAdmin, code, state.Nonce)
+	if err != nil {
+		return nil, err
+	}
+	var admin *model.Admin
+	account, err := w.GetOauthAccount(config.OauthTypeAdmin, info.Subject)
+	if err == nil {
+		if state.BindId > 0 && account.TargetId != state.BindId {
+			return nil, errors.New(w.Lang("该第三方账号已绑定其他账号"))
+		}
+		admin, err = w.GetAdminInfoById(account.TargetId)
+		if err != nil {
+			w.DB.Delete(account)
+			admin = nil
+		}
+	}
+	if admin == nil && state.BindId > 0 {
+		admin, err = w.GetAdminInfoById(state.BindId)
+		if err != nil {
+			return nil, errors.New(w.Lang("管理员不存在"))
+		}
+	}
+	groupId := matchOauthGroup(w.PluginOauth.AdminGroupMapping, info.Groups)
+	if admin == nil {
+		if groupId == 0 {
+			return nil, errors.New(w.Lang("该第三方账号未绑定管理员"))
+		}
+		userName := info.UserName
+		if userName == "" {
+			userName = info.Email
+		}
+		if runes := []rune(userName); len(runes) > 26 {
+			userName = string(runes[:26])
+		}
+		if _, err = w.GetAdminByUserName(userName); err == nil || userName == "" {
+			userName = userName + "_" + oauthRandString(4)
+		}
+		// 单点登录创建的管理员没有密码，只能通过单点登录进入后台
+		admin = &model.Admin{
+			UserName: userName,
+			Status:   1,
+			GroupId:  groupId,
+		}
+		if err = w.DB.Create(admin).Error; err != nil {
+			return nil, err
+		}
+	}
+	if _, err = w.bindOauthAccount(config.OauthTypeAdmin, admin.Id, info); err != nil {
+		return nil, err
+	}
+	// 超级管理员的分组不随第三方平台变化
+	if admin.Id != 1 && groupId > 0 && groupId != admin.GroupId {
+		admin.GroupId = groupId
+		w.DB.Model(admin).UpdateColumn("group_id", groupId)
+	}
+	admin.LoginTime = time.Now().Unix()
+	w.DB.Model(admin).UpdateColumn("login_time", admin.LoginTime)
+
+	return admin, nil
+}
+
+// adminOauthCode 单点登录成功后，后台用来换取 token 的一次性授权码
+type adminOauthCode struct {
+	siteId  uint
+	adminId uint
+	expire  int64
+}
+
+// 授权码有效期，单位秒
+const adminOauthCodeExpire = 60
+
+var adminOauthCodes sync.Map
+
+// CreateAdminOauthCode 生成一次性授权码，避免把 token 放在地址中
+func (w *Website) CreateAdminOauthCode(adminId uint) string {
+	nowStamp := time.Now().Unix()
+	adminOauthCodes.Range(func(key, value interface{}) bool {
+		if value.(adminOauthCode).expire < nowStamp {
+			adminOauthCodes.Delete(key)
+		}
+		return true
+	})
+	code := oauthRandString(32)
+	adminOauthCodes.Store(code, adminOauthCode{
+		siteId:  w.Id,
+		adminId: adminId,
+		expire:  nowStamp + adminOauthCodeExpire,
+	})
+
+	return code
+}
+
+// ExchangeAdminOauthCode 使用授权码换取后台 token，授权码只能使用一次
+func (w *Website) ExchangeAdminOauthCode(code string) (*model.Admin, error) {
+	value, ok := adminOauthCodes.LoadAndDelete(code)
+	if !ok || code == "" {
+		return nil, errors.New(w.Lang("授权码无效或已过期"))
+	}
+	item := value.(adminOauthCode)
+	if item.siteId != w.Id || item.expire < time.Now().Unix() {
+		return nil, errors.New(w.Lang("授权码无效或已过期"))
+	}
+	admin, err := w.GetAdminInfoById(item.adminId)
+	if err != nil {
+		return nil, err
+	}
+	admin.Token = w.GetAdminAuthToken(admin.Id, false)
+
+	return admin, nil
+}
+
+// GetAdminOauthLoginRedirect 登录成功后回到后台，后台页面通过 POST 使用地址中的一次性授权码换取 token
+func (w *Website) GetAdminOauthLoginRedirect(code string) string {
+	return w.getAdminBaseUrl() + "/system/?oauth_code=" + url.QueryEscape(code)
+}
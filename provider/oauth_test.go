@@ -0,0 +1,43 @@
+package provider
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestVerifyOauthIdTokenClaims(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	cases := []struct {
+		name   string
+		claims string
+		issuer string
+		valid  bool
+	}{
+		{"valid", `{"iss":"https://idp.example.com","aud":"client","exp":1700000600,"nonce":"n1"}`, "https://idp.example.com", true},
+		{"aud array with azp", `{"iss":"https://idp.example.com","aud":["client","other"],"azp":"client","exp":1700000600,"nonce":"n1"}`, "https://idp.example.com", true},
+		{"unknown issuer", `{"iss":"https://any.example.com","aud":"client","exp":1700000600,"nonce":"n1"}`, "", true},
+		{"clock skew", `{"iss":"https://idp.example.com","aud":"client","exp":1699999970,"nonce":"n1"}`, "https://idp.example.com", true},
+		{"wrong issuer", `{"iss":"https://evil.example.com","aud":"client","exp":1700000600,"nonce":"n1"}`, "https://idp.example.com", false},
+		{"wrong audience", `{"iss":"https://idp.example.com","aud":"other","exp":1700000600,"nonce":"n1"}`, "https://idp.example.com", false},
+		{"aud array without azp", `{"iss":"https://idp.example.com","aud":["client","other"],"exp":1700000600,"nonce":"n1"}`, "https://idp.example.com", false},
+		{"wrong azp", `{"iss":"https://idp.example.com","aud":"client","azp":"other","exp":1700000600,"nonce":"n1"}`, "https://idp.example.com", false},
+		{"expired", `{"iss":"https://idp.example.com","aud":"client","exp":1699999000,"nonce":"n1"}`, "https://idp.example.com", false},
+		{"missing exp", `{"iss":"https://idp.example.com","aud":"client","nonce":"n1"}`, "https://idp.example.com", false},
+		{"wrong nonce", `{"iss":"https://idp.example.com","aud":"client","exp":1700000600,"nonce":"n2"}`, "https://idp.example.com", false},
+		{"missing nonce", `{"iss":"https://idp.example.com","aud":"client","exp":1700000600}`, "https://idp.example.com", false},
+	}
+	for _, c := range cases {
+		claims := map[string]interface{}{}
+		decoder := json.NewDecoder(strings.NewReader(c.claims))
+		decoder.UseNumber()
+		if err := decoder.Decode(&claims); err != nil {
+			t.Fatal(err)
+		}
+		err := verifyOauthIdTokenClaims(claims, c.issuer, "client", "n1", now)
+		if (err == nil) != c.valid {
+			t.Errorf("%s: expected valid %v, got %v", c.name, c.valid, err)
+		}
+	}
+}
@@ -9,12 +9,14 @@ import (
 	"github.com/go-pay/gopay/pkg/util"
 	"github.com/go-pay/gopay/wechat"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"kandaoni.com/anqicms/config"
 	"kandaoni.com/anqicms/model"
 	"kandaoni.com/anqicms/request"
 	"kandaoni.com/anqicms/response"
 	"log"
 	"os"
+	"strings"
 	"time"
 )
 
@@ -135,6 +137,7 @@ func (w *Website) GetOrderInfoByOrderId(orderId string) (*model.Order, error) {
 	if err == nil {
 		order.OrderAddress = orderAddress
 	}
+	order.Refunds = w.GetOrderRefunds(order.OrderId)
 
 	return &order, nil
 }
@@ -198,6 +201,8 @@ func (w *Website) SetOrderDeliver(req *request.OrderRequest) error {
 	order.EndTime = time.Now().AddDate(0, 0, w.PluginOrder.AutoFinishDay).Unix()
 	w.DB.Save(order)
 
+	w.NotifyOrderStatus(order, fmt.Sprintf(w.Lang("快递公司：%s，快递单号：%s"), order.ExpressCompany, order.TrackingNumber))
+
 	return nil
 }
 
@@ -327,29 +332,12 @@ func (w *Website) SetOrderFinished(order *model.Order) error {
 	}
 	// 如果是vip订单，则还需要处理VIP信息
 	if order.Type == config.OrderTypeVip {
-		var user model.User
-		var orderDetail model.OrderDetail
-		err = tx.Model(model.User{}).Where("`id` = ?", order.UserId).Take(&user).Error
-		err2 := tx.Model(model.OrderDetail{}).Where("`order_id` = ?", order.OrderId).Take(&orderDetail).Error
-		if err == nil && err2 == nil {
-			startTime := user.ExpireTime
-			if startTime < time.Now().Unix() {
-				startTime = time.Now().Unix()
-			}
-			var group model.UserGroup
-			err = tx.Model(model.UserGroup{}).Where("`id` = ?", orderDetail.GoodsId).Take(&group).Error
-			if err != nil {
-				group.Setting.ExpireDay = 365
-			}
-			startTime += int64(group.Setting.ExpireDay) * 86400
-
-			user.ExpireTime = startTime
-			user.GroupId = group.Id
-			tx.Save(&user)
-		}
+		w.applyVipOrder(tx, order)
 	}
 	tx.Commit()
 
+	w.NotifyOrderStatus(order, w.Lang("订单已完成"))
+
 	return nil
 }
 
@@ -357,14 +345,24 @@ func (w *Website) SetOrderCanceled(order *model.Order) error {
 	order.Status = config.OrderStatusCanceled
 	order.FinishedTime = time.Now().Unix()
 	w.DB.Save(order)
+	w.RefundOrderPoints(order)
+
+	w.NotifyOrderStatus(order, w.Lang("订单已取消"))
 
 	return nil
 }
 
-func (w *Website) SetOrderRefund(order *model.Order, status int) error {
-	refund, err := w.GetOrderRefundByOrderId(order.OrderId)
-	if err != nil {
-		return err
+// SetOrderRefund 处理退款申请，refund 为空时，处理订单最早一条待处理的退款申请
+func (w *Website) SetOrderRefund(order *model.Order, refund *model.OrderRefund, status int) error {
+	var err error
+	if refund == nil {
+		refund, err = w.GetOrderRefundByOrderId(order.OrderId)
+		if err != nil {
+			return err
+		}
+	}
+	if refund.OrderId != order.OrderId || refund.Status != config.OrderRefundStatusWaiting {
+		return errors.New(w.Lang("该退款申请不可操作"))
 	}
 	// todo 金钱原路退回
 	if status == 1 {
@@ -511,47 +509,146 @@ func (w *Website) SetOrderRefund(order *model.Order, status int) error {
 		}
 	} else {
 		// 不同意
-		order.RefundStatus = 0
+		refund.Status = config.OrderRefundStatusFailed
+		w.DB.Save(refund)
+		// 没有其他待处理的退款时，才取消订单的退款状态
+		var waiting int64
+		w.DB.Model(&model.OrderRefund{}).Where("`order_id` = ? and `status` = ?", order.OrderId, config.OrderRefundStatusWaiting).Count(&waiting)
+		if waiting == 0 {
+			order.RefundStatus = 0
+		}
 		order.FinishedTime = time.Now().Unix()
 		w.DB.Save(order)
-		refund.Status = config.OrderStatusCanceled
-		w.DB.Save(refund)
+
+		w.NotifyOrderStatus(order, w.Lang("退款申请未通过"))
 	}
 
 	return nil
 }
 
-func (w *Website) ApplyOrderRefund(order *model.Order) error {
-	// 用户申请退款
-	refund, err := w.GetOrderRefundByOrderId(order.OrderId)
-	if err == nil {
-		return nil
+// ApplyOrderRefund 申请退款，支持整单、单个商品和部分金额退款，一个订单可以多次申请，但累计金额不能超过实付金额
+func (w *Website) ApplyOrderRefund(order *model.Order, req *request.OrderRefundRequest) (*model.OrderRefund, error) {
+	if order.PaidTime == 0 || order.Status == config.OrderStatusRefunded || order.Status == config.OrderStatusCanceled {
+		return nil, errors.New(w.Lang("该订单不可退款"))
+	}
+	refundable := order.Amount - w.GetOrderRefundingAmount(order.OrderId, 0)
+	if req.DetailId > 0 {
+		var detail *model.OrderDetail
+		for _, v := range order.Details {
+			if v.Id == req.DetailId {
+				detail = v
+				break
+			}
+		}
+		if detail == nil {
+			return nil, errors.New(w.Lang("订单商品不存在"))
+		}
+		detailRefundable := detail.Amount - w.GetOrderRefundingAmount(order.OrderId, detail.Id)
+		if detailRefundable < refundable {
+			refundable = detailRefundable
+		}
+	}
+	amount := req.Amount
+	if amount <= 0 {
+		amount = refundable
+	}
+	if amount <= 0 || amount > refundable {
+		return nil, errors.New(w.Lang("退款金额超出可退金额"))
 	}
 
-	refund = &model.OrderRefund{
+	refund := &model.OrderRefund{
 		OrderId:  order.OrderId,
-		DetailId: 0,
+		DetailId: req.DetailId,
 		UserId:   order.UserId,
-		Amount:   order.Amount,
-		Status:   0,
+		Amount:   amount,
+		Status:   config.OrderRefundStatusWaiting,
 		Remark:   w.Lang("用户申请退款"),
+		Reason:   req.Reason,
+	}
+	err := w.DB.Save(refund).Error
+	if err != nil {
+		return nil, err
 	}
-	w.DB.Save(refund)
 
 	//order.Status = config.OrderStatusRefunding
 	order.RefundStatus = config.OrderStatusRefunding
 	w.DB.Save(order)
 
-	return nil
+	return refund, nil
+}
+
+// GetOrderRefundingAmount 订单已退款和退款中的金额，detailId 大于0时，只计算该商品
+func (w *Website) GetOrderRefundingAmount(orderId string, detailId uint) int64 {
+	var total response.SumAmount
+	tx := w.DB.Model(&model.OrderRefund{}).Where("`order_id` = ? and `status` IN(?)", orderId, []int{config.OrderRefundStatusWaiting, config.OrderRefundStatusDone})
+	if detailId > 0 {
+		tx = tx.Where("`detail_id` = ?", detailId)
+	}
+	tx.Select("SUM(`amount`) as total").Take(&total)
+
+	return total.Total
 }
 
+// GetOrderRefundByOrderId 获取订单最早一条待处理的退款申请
 func (w *Website) GetOrderRefundByOrderId(orderId string) (*model.OrderRefund, error) {
-	var order model.OrderRefund
-	if err := w.DB.Model(&model.OrderRefund{}).Where("`order_id` = ?", orderId).First(&order).Error; err != nil {
+	var refund model.OrderRefund
+	if err := w.DB.Model(&model.OrderRefund{}).Where("`order_id` = ? and `status` = ?", orderId, config.OrderRefundStatusWaiting).Order("id asc").First(&refund).Error; err != nil {
 		return nil, err
 	}
 
-	return &order, nil
+	return &refund, nil
+}
+
+func (w *Website) GetOrderRefundByRefundId(refundId string) (*model.OrderRefund, error) {
+	var refund model.OrderRefund
+	if err := w.DB.Model(&model.OrderRefund{}).Where("`refund_id` = ?", refundId).Take(&refund).Error; err != nil {
+		return nil, err
+	}
+
+	return &refund, nil
+}
+
+func (w *Website) GetOrderRefunds(orderId string) []*model.OrderRefund {
+	var refunds []*model.OrderRefund
+	w.DB.Where("`order_id` = ?", orderId).Order("id asc").Find(&refunds)
+
+	return refunds
+}
+
+// GetOrderRefundList 退款流水
+func (w *Website) GetOrderRefundList(orderId string, status string, page, pageSize int) ([]*model.OrderRefund, int64) {
+	var refunds []*model.OrderRefund
+	var total int64
+	offset := (page - 1) * pageSize
+	tx := w.DB.Model(&model.OrderRefund{})
+	if orderId != "" {
+		tx = tx.Where("`order_id` = ?", orderId)
+	}
+	if status != "" {
+		tx = tx.Where("`status` = ?", w.getRefundStatusValue(status))
+	}
+	tx.Count(&total).Order("id desc").Limit(pageSize).Offset(offset).Find(&refunds)
+	if len(refunds) > 0 {
+		var detailIds = make([]uint, 0, len(refunds))
+		for _, v := range refunds {
+			if v.DetailId > 0 {
+				detailIds = append(detailIds, v.DetailId)
+			}
+		}
+		if len(detailIds) > 0 {
+			var details []*model.OrderDetail
+			w.DB.Where("`id` IN(?)", detailIds).Find(&details)
+			for _, v := range refunds {
+				for _, d := range details {
+					if d.Id == v.DetailId {
+						v.Detail = d
+					}
+				}
+			}
+		}
+	}
+
+	return refunds, total
 }
 
 func (w *Website) SuccessPaidOrder(order *model.Order) error {
@@ -571,15 +668,33 @@ func (w *Website) SuccessPaidOrder(order *model.Order) error {
 	}
 
 	db.Commit()
+	w.AwardOrderPoints(order)
+
+	// 数字商品在支付后直接交付，全部交付成功的订单不需要再走发货流程
+	digitalFinished := false
+	if order.Type != config.OrderTypeVip {
+		err := w.DeliverDigitalOrder(order)
+		if err != nil {
+			log.Println("数字商品交付失败：", order.OrderId, err.Error())
+		} else {
+			digitalFinished = w.CheckOrderIsDigital(order)
+		}
+	}
 
-	if w.PluginOrder.NoProcess || order.Type == config.OrderTypeVip {
+	if w.PluginOrder.NoProcess || order.Type == config.OrderTypeVip || digitalFinished {
 		// 如果订单自动完成，则在这里处理
 		w.SetOrderFinished(order)
+	} else {
+		w.NotifyOrderStatus(order, w.Lang("订单已支付成功"))
+	}
+	if w.PluginOrder.InvoiceMail {
+		go w.SendOrderPaidMail(order)
 	}
 
 	return nil
 }
 
+// SuccessRefundOrder 退款成功后的处理，部分退款时，按退款比例扣回卖家和分销佣金
 func (w *Website) SuccessRefundOrder(refund *model.OrderRefund, order *model.Order) error {
 	var err error
 	if order == nil {
@@ -588,51 +703,134 @@ func (w *Website) SuccessRefundOrder(refund *model.OrderRefund, order *model.Ord
 			return err
 		}
 	}
-	if order.Status == config.OrderStatusRefunded {
+
+	tx := w.DB.Begin()
+	var current model.OrderRefund
+	err = tx.Clauses(clause.Locking{Strength: "UPDATE"}).Where("`id` = ?", refund.Id).Take(&current).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if current.Status == config.OrderRefundStatusDone {
 		// already refunded
+		tx.Rollback()
 		return nil
 	}
-
-	tx := w.DB.Begin()
-	//退款成功，则标记订单完成
-	order.Status = config.OrderStatusRefunded
-	tx.Model(order).UpdateColumn("status", order.Status)
-	//refund
-	if refund.Status == config.OrderRefundStatusWaiting {
-		refund.Status = config.OrderRefundStatusDone
+	if refund.Status == config.OrderRefundStatusFailed {
+		tx.Save(refund)
+		tx.Commit()
+		return nil
 	}
+	//refund
+	refund.Status = config.OrderRefundStatusDone
 	if refund.RefundTime == 0 {
 		refund.RefundTime = time.Now().Unix()
 	}
-	w.DB.Updates(refund)
-	// todo 如果订单已成功的退款，需要额外处理佣金问题
-	// 退款后，如果有赠送佣金，要扣除
-	var userCommission model.Commission
-	err1 := tx.Where("order_id = ?", order.OrderId).Take(&userCommission).Error
-	if err1 == nil {
-		// 有记录
-		if userCommission.Status == config.CommissionStatusWait {
-			// 未提现，退款
-			userCommission.Status = config.CommissionStatusCancel
-			tx.Save(&userCommission)
-			//生成用户支付记录
-			var userBalance int64
-			err = tx.Model(&model.User{}).Where("`id` = ?", userCommission.UserId).Pluck("balance", &userBalance).Error
-			//状态更改了，增加一条记录到用户
-			finance := model.Finance{
-				UserId:      userCommission.UserId,
-				Direction:   config.FinanceOutput,
-				Amount:      userCommission.Amount,
-				AfterAmount: userBalance,
-				Action:      config.FinanceActionRefund,
+	err = tx.Save(refund).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	order.RefundAmount += refund.Amount
+	if order.RefundAmount >= order.Amount {
+		//全部退款，则标记订单已退款
+		order.Status = config.OrderStatusRefunded
+	}
+	var waiting int64
+	tx.Model(&model.OrderRefund{}).Where("`order_id` = ? and `status` = ?", order.OrderId, config.OrderRefundStatusWaiting).Count(&waiting)
+	if waiting == 0 {
+		order.RefundStatus = 0
+	}
+	// 分成和佣金都是按商品金额计算的，按商品的退款比例扣减，只退运费时不扣减
+	goodsAmount := order.Amount - order.ShippingAmount
+	var orderRefund, detailRefund response.SumAmount
+	tx.Model(&model.OrderRefund{}).Where("`order_id` = ? and `detail_id` = 0 and `status` = ?", order.OrderId, config.OrderRefundStatusDone).Select("SUM(`amount`) as total").Take(&orderRefund)
+	tx.Model(&model.OrderRefund{}).Where("`order_id` = ? and `detail_id` > 0 and `status` = ?", order.OrderId, config.OrderRefundStatusDone).Select("SUM(`amount`) as total").Take(&detailRefund)
+	refundedGoods := refundedGoodsAmount(goodsAmount, order.ShippingAmount, orderRefund.Total, detailRefund.Total)
+	if refund.DetailId > 0 {
+		detailRefund.Total -= refund.Amount
+	} else {
+		orderRefund.Total -= refund.Amount
+	}
+	previousGoods := refundedGoodsAmount(goodsAmount, order.ShippingAmount, orderRefund.Total, detailRefund.Total)
+	// 订单未完成时，还没有分钱，按比例减少待分配金额
+	var commissions []*model.Commission
+	tx.Where("`order_id` = ? and `amount` > 0 and `status` != ?", order.OrderId, config.CommissionStatusCancel).Find(&commissions)
+	if len(commissions) == 0 && refundedGoods > previousGoods {
+		remain := goodsAmount - refundedGoods
+		base := goodsAmount - previousGoods
+		order.SellerAmount = order.SellerAmount * remain / base
+		order.ShareAmount = order.ShareAmount * remain / base
+		order.ShareParentAmount = order.ShareParentAmount * remain / base
+	}
+	err = tx.Model(order).Select("status", "refund_status", "refund_amount", "seller_amount", "share_amount", "share_parent_amount").Updates(order).Error
+	if err != nil {
+		tx.Rollback()
+		return err
+	}
+	if refund.DetailId > 0 {
+		var detail model.OrderDetail
+		if tx.Where("`id` = ?", refund.DetailId).Take(&detail).Error == nil {
+			var detailRefund response.SumAmount
+			tx.Model(&model.OrderRefund{}).Where("`order_id` = ? and `detail_id` = ? and `status` = ?", order.OrderId, detail.Id, config.OrderRefundStatusDone).Select("SUM(`amount`) as total").Take(&detailRefund)
+			if detailRefund.Total >= detail.Amount {
+				tx.Model(&detail).UpdateColumn("status", config.OrderStatusRefunded)
+			}
+		}
+	}
+	// 订单已完成分钱的，按退款比例扣回佣金
+	// 未提现的佣金直接按比例减少，全额退款时取消；已提现的佣金记一笔负数佣金，在下次提现时抵扣
+	for _, commission := range commissions {
+		var reversed response.SumAmount
+		if commission.Status != config.CommissionStatusWait {
+			tx.Model(&model.Commission{}).Where("`order_id` = ? and `user_id` = ? and `amount` < 0", order.OrderId, commission.UserId).Select("SUM(`amount`) as total").Take(&reversed)
+		}
+		deduct, needReverse := refundCommission(commission, reversed.Total, goodsAmount, previousGoods, refundedGoods)
+		if deduct <= 0 {
+			continue
+		}
+		if !needReverse {
+			err = tx.Model(commission).Select("amount", "status").Updates(commission).Error
+			if err != nil {
+				tx.Rollback()
+				return err
+			}
+		} else {
+			reverse := model.Commission{
+				UserId:      commission.UserId,
 				OrderId:     order.OrderId,
-				Status:      1,
+				OrderAmount: order.Amount,
+				Amount:      -deduct,
+				Status:      config.CommissionStatusWait,
+				Remark:      "退款扣回",
 			}
-			err = tx.Create(&finance).Error
+			err = tx.Save(&reverse).Error
 			if err != nil {
-				//
+				tx.Rollback()
+				return err
 			}
 		}
+		// 佣金在订单完成时已计入余额，扣回时同步减少
+		tx.Model(model.User{}).Where("`id` = ?", commission.UserId).UpdateColumn("balance", gorm.Expr("`balance` - ?", deduct))
+		var userBalance int64
+		err = tx.Model(&model.User{}).Where("`id` = ?", commission.UserId).Pluck("balance", &userBalance).Error
+		//状态更改了，增加一条记录到用户
+		finance := model.Finance{
+			UserId:      commission.UserId,
+			Direction:   config.FinanceOutput,
+			Amount:      deduct,
+			AfterAmount: userBalance,
+			Action:      config.FinanceActionRefund,
+			OrderId:     order.OrderId,
+			Status:      1,
+		}
+		err = tx.Create(&finance).Error
+		if err != nil {
+			//
+		}
+		var totalReward response.SumAmount
+		tx.Model(model.Commission{}).Where("`user_id` = ?", commission.UserId).Select("SUM(`amount`) as total").Take(&totalReward)
+		tx.Model(model.User{}).Where("`id` = ?", commission.UserId).UpdateColumn("total_reward", totalReward.Total)
 	}
 	//生成用户支付记录
 	var userBalance int64
@@ -653,8 +851,50 @@ func (w *Website) SuccessRefundOrder(refund *model.OrderRefund, order *model.Ord
 	}
 
 	tx.Commit()
+	w.RefundOrderPoints(order)
+
+	w.NotifyOrderStatus(order, fmt.Sprintf(w.Lang("已退款%.2f元"), float64(refund.Amount)/100))
 
-	return err
+	return nil
+}
+
+// refundedGoodsAmount 已退款金额中属于商品的部分。退整单的金额先抵扣运费，退单个商品的金额都属于商品
+func refundedGoodsAmount(goodsAmount, shippingAmount, orderRefund, detailRefund int64) int64 {
+	goods := detailRefund
+	if orderRefund > shippingAmount {
+		goods += orderRefund - shippingAmount
+	}
+	if goods > goodsAmount {
+		goods = goodsAmount
+	}
+
+	return goods
+}
+
+// refundCommission 按商品的退款比例计算需要扣回的佣金，previousRefunded、refunded 为本次退款前后已退的商品金额。
+// 未提现的佣金直接减少，商品全部退款时取消；已提现的佣金不能修改，needReverse 为 true，
+// 需要记一笔负数佣金，reversed 为之前已扣回的金额（负数）
+func refundCommission(commission *model.Commission, reversed, goodsAmount, previousRefunded, refunded int64) (deduct int64, needReverse bool) {
+	if goodsAmount <= 0 || refunded <= previousRefunded {
+		return 0, false
+	}
+	if commission.Status == config.CommissionStatusWait {
+		if refunded >= goodsAmount {
+			deduct = commission.Amount
+			commission.Status = config.CommissionStatusCancel
+		} else {
+			remain := commission.Amount * (goodsAmount - refunded) / (goodsAmount - previousRefunded)
+			deduct = commission.Amount - remain
+			commission.Amount = remain
+		}
+		return deduct, false
+	}
+	deduct = commission.Amount*refunded/goodsAmount + reversed
+	if refunded >= goodsAmount {
+		deduct = commission.Amount + reversed
+	}
+
+	return deduct, true
 }
 
 func (w *Website) CreateOrder(userId uint, req *request.OrderRequest) (*model.Order, error) {
@@ -662,6 +902,9 @@ func (w *Website) CreateOrder(userId uint, req *request.OrderRequest) (*model.Or
 	if err != nil {
 		return nil, err
 	}
+	if w.PluginUser.VerifyBeforeOrder && user.Verified == 0 {
+		return nil, errors.New(w.Lang("请先完成账号验证"))
+	}
 	if len(req.Details) == 0 && req.GoodsId == 0 {
 		return nil, errors.New(w.Lang("请选择商品"))
 	}
@@ -670,9 +913,28 @@ func (w *Website) CreateOrder(userId uint, req *request.OrderRequest) (*model.Or
 	}
 	tx := w.DB.Begin()
 	var orderAddress *model.OrderAddress
-	if w.PluginOrder.NoProcess == false || req.Address != nil {
+	if req.AddressId == 0 && req.Address != nil && req.Address.Id > 0 && req.Address.Name == "" {
+		// 只传了地址ID
+		req.AddressId = req.Address.Id
+	}
+	if req.AddressId > 0 {
+		// 使用地址簿中的地址
+		bookAddress, err := w.GetOrderAddressById(req.AddressId)
+		if err != nil || bookAddress == nil || bookAddress.UserId != userId || bookAddress.Status != config.OrderAddressStatusBook {
+			tx.Rollback()
+			return nil, errors.New(w.Lang("地址不存在"))
+		}
+		orderAddress = bookAddress
+	} else if w.PluginOrder.NoProcess == false || req.Address != nil {
 		//保存订单地址
-		orderAddress, err = w.SaveOrderAddress(tx, userId, req.Address)
+		orderAddress, err = w.saveInlineOrderAddress(tx, userId, req.Address)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+	}
+	if orderAddress != nil {
+		orderAddress, err = w.copyOrderAddress(tx, orderAddress)
 		if err != nil {
 			tx.Rollback()
 			return nil, err
@@ -744,6 +1006,15 @@ func (w *Website) CreateOrder(userId uint, req *request.OrderRequest) (*model.Or
 		}
 		detailAmount := price * int64(req.Details[0].Quantity)
 		originDetailAmount := originPrice * int64(req.Details[0].Quantity)
+		// 升级会员组时，抵扣当前会员组剩余的金额，至少需要支付1分
+		prorate := w.GetVipOrderProration(user, group)
+		if prorate >= detailAmount {
+			prorate = detailAmount - 1
+		}
+		if prorate > 0 {
+			detailAmount -= prorate
+			order.DiscountAmount += prorate
+		}
 		amount += detailAmount
 		originAmount += originDetailAmount
 		//给每条子订单入库
@@ -802,6 +1073,21 @@ func (w *Website) CreateOrder(userId uint, req *request.OrderRequest) (*model.Or
 	}
 	order.Amount = amount
 	order.OriginAmount = originAmount
+	// 使用积分抵扣商品金额，运费不参与抵扣
+	if req.UsePoints > 0 {
+		usePoints, pointAmount := w.GetOrderPointDeduction(user, req.UsePoints, order.Amount)
+		if usePoints > 0 {
+			_, err = w.ChangeUserPoints(tx, userId, -usePoints, config.PointActionDeduct, 0, order.OrderId, w.Lang("下单抵扣"))
+			if err != nil {
+				tx.Rollback()
+				return nil, err
+			}
+			order.UsedPoints = usePoints
+			order.PointAmount = pointAmount
+			order.DiscountAmount += pointAmount
+			order.Amount -= pointAmount
+		}
+	}
 
 	shareId := user.ParentId
 	if w.PluginRetailer.AllowSelf == 1 && (w.PluginRetailer.BecomeRetailer == 1 || user.IsRetailer == 1) {
@@ -829,9 +1115,20 @@ func (w *Website) CreateOrder(userId uint, req *request.OrderRequest) (*model.Or
 			}
 		}
 	}
+	// 实物商品按收货地址计算运费
+	if req.Type != config.OrderTypeVip && orderAddress != nil {
+		shippingAmount, err := w.CalculateShippingFee(userId, req.Details, orderAddress.Province, orderAddress.City)
+		if err != nil {
+			tx.Rollback()
+			return nil, err
+		}
+		order.ShippingAmount = shippingAmount
+		order.Amount += shippingAmount
+	}
 	order.SellerId = sellerId
 	if sellerId > 0 && w.PluginOrder.SellerPercent > 0 {
-		sellerAmount := (order.Amount - order.ShareAmount - order.ShareParentAmount) * w.PluginOrder.SellerPercent / 100
+		// 运费不参与卖家分成
+		sellerAmount := (order.Amount - order.ShippingAmount - order.ShareAmount - order.ShareParentAmount) * w.PluginOrder.SellerPercent / 100
 		order.SellerAmount = sellerAmount
 	}
 
@@ -842,9 +1139,10 @@ func (w *Website) CreateOrder(userId uint, req *request.OrderRequest) (*model.Or
 	return &order, nil
 }
 
+// GetOrderAddressByUserId 获取用户的默认地址，没有设置默认地址时，返回最新的地址
 func (w *Website) GetOrderAddressByUserId(userId uint) (*model.OrderAddress, error) {
 	var orderAddress model.OrderAddress
-	err := w.DB.Where("`user_id` = ?", userId).Order("id desc").Take(&orderAddress).Error
+	err := w.DB.Where("`user_id` = ? and `status` = ?", userId, config.OrderAddressStatusBook).Order("is_default desc, id desc").Take(&orderAddress).Error
 	if err != nil {
 		return nil, err
 	}
@@ -865,21 +1163,42 @@ func (w *Website) GetOrderAddressById(id uint) (*model.OrderAddress, error) {
 	return &orderAddress, nil
 }
 
+// GetOrderAddressList 用户的地址簿
+func (w *Website) GetOrderAddressList(userId uint) []*model.OrderAddress {
+	var addresses []*model.OrderAddress
+	w.DB.Where("`user_id` = ? and `status` = ?", userId, config.OrderAddressStatusBook).Order("is_default desc, id desc").Find(&addresses)
+
+	return addresses
+}
+
+// SaveOrderAddress 保存地址簿中的地址，传了ID时更新该地址
 func (w *Website) SaveOrderAddress(tx *gorm.DB, userId uint, req *request.OrderAddressRequest) (*model.OrderAddress, error) {
 	if req == nil {
 		return nil, nil
 	}
+	err := w.ValidateOrderAddress(req)
+	if err != nil {
+		return nil, err
+	}
 	var orderAddress model.OrderAddress
-	var err error
 	if req.Id > 0 {
-		err = tx.Where("`id` = ?", req.Id).Take(&orderAddress).Error
+		err = tx.Where("`id` = ? and `status` = ?", req.Id, config.OrderAddressStatusBook).Take(&orderAddress).Error
 		if err != nil || orderAddress.UserId != userId {
 			return nil, errors.New(w.Lang("地址不存在"))
 		}
 	} else {
+		var total int64
+		tx.Model(&model.OrderAddress{}).Where("`user_id` = ? and `status` = ?", userId, config.OrderAddressStatusBook).Count(&total)
+		if total >= maxOrderAddressCount {
+			return nil, errors.New(w.Lang("地址数量已达上限"))
+		}
 		orderAddress = model.OrderAddress{
 			UserId: userId,
 		}
+		if total == 0 {
+			// 第一个地址作为默认地址
+			req.IsDefault = 1
+		}
 	}
 	orderAddress.Name = req.Name
 	orderAddress.Phone = req.Phone
@@ -888,16 +1207,177 @@ func (w *Website) SaveOrderAddress(tx *gorm.DB, userId uint, req *request.OrderA
 	orderAddress.Country = req.Country
 	orderAddress.AddressInfo = req.AddressInfo
 	orderAddress.Postcode = req.Postcode
-	orderAddress.Status = 1
+	orderAddress.Status = config.OrderAddressStatusBook
+	if req.IsDefault == 1 {
+		orderAddress.IsDefault = 1
+	}
 
 	err = tx.Save(&orderAddress).Error
 	if err != nil {
 		return nil, err
 	}
+	if orderAddress.IsDefault == 1 {
+		tx.Model(&model.OrderAddress{}).Where("`user_id` = ? and `id` != ?", userId, orderAddress.Id).UpdateColumn("is_default", 0)
+	}
+
+	return &orderAddress, nil
+}
+
+// saveInlineOrderAddress 下单时直接填写的地址，与地址簿中的地址相同时直接使用，地址簿已满时只给本订单使用
+func (w *Website) saveInlineOrderAddress(tx *gorm.DB, userId uint, req *request.OrderAddressRequest) (*model.OrderAddress, error) {
+	if req == nil || req.Id > 0 {
+		return w.SaveOrderAddress(tx, userId, req)
+	}
+	err := w.ValidateOrderAddress(req)
+	if err != nil {
+		return nil, err
+	}
+	var exists model.OrderAddress
+	err = tx.Where("`user_id` = ? and `status` = ?", userId, config.OrderAddressStatusBook).
+		Where("`name` = ? and `phone` = ? and `country` = ? and `province` = ? and `city` = ? and `address_info` = ? and `postcode` = ?",
+			req.Name, req.Phone, req.Country, req.Province, req.City, req.AddressInfo, req.Postcode).Take(&exists).Error
+	if err == nil {
+		return &exists, nil
+	}
+	var total int64
+	tx.Model(&model.OrderAddress{}).Where("`user_id` = ? and `status` = ?", userId, config.OrderAddressStatusBook).Count(&total)
+	if total >= maxOrderAddressCount {
+		return &model.OrderAddress{
+			UserId:      userId,
+			Name:        req.Name,
+			Phone:       req.Phone,
+			Province:    req.Province,
+			City:        req.City,
+			Country:     req.Country,
+			AddressInfo: req.AddressInfo,
+			Postcode:    req.Postcode,
+			Status:      config.OrderAddressStatusOrder,
+		}, nil
+	}
+
+	return w.SaveOrderAddress(tx, userId, req)
+}
+
+// DeleteOrderAddress 从地址簿中移除地址，已下单的订单仍然使用订单中的地址
+func (w *Website) DeleteOrderAddress(userId uint, id uint) error {
+	orderAddress, err := w.GetOrderAddressById(id)
+	if err != nil || orderAddress == nil || orderAddress.UserId != userId || orderAddress.Status != config.OrderAddressStatusBook {
+		return errors.New(w.Lang("地址不存在"))
+	}
+	w.DB.Model(orderAddress).Updates(map[string]interface{}{"status": config.OrderAddressStatusOrder, "is_default": 0})
+	if orderAddress.IsDefault == 1 {
+		// 删除了默认地址，则使用最新的地址作为默认地址
+		latest, err := w.GetOrderAddressByUserId(userId)
+		if err == nil {
+			w.DB.Model(latest).UpdateColumn("is_default", 1)
+		}
+	}
+
+	return nil
+}
+
+func (w *Website) SetDefaultOrderAddress(userId uint, id uint) error {
+	orderAddress, err := w.GetOrderAddressById(id)
+	if err != nil || orderAddress == nil || orderAddress.UserId != userId || orderAddress.Status != config.OrderAddressStatusBook {
+		return errors.New(w.Lang("地址不存在"))
+	}
+	w.DB.Model(&model.OrderAddress{}).Where("`user_id` = ?", userId).UpdateColumn("is_default", 0)
+	w.DB.Model(orderAddress).UpdateColumn("is_default", 1)
+
+	return nil
+}
+
+// ValidateOrderAddress 按国家/地区校验地址的必填项，没有对应规则时使用默认规则
+func (w *Website) ValidateOrderAddress(req *request.OrderAddressRequest) error {
+	rules := w.PluginOrder.AddressRules
+	if len(rules) == 0 {
+		rules = config.DefaultAddressRules
+	}
+	var required []string
+	for _, rule := range rules {
+		if rule.Country == "" && required == nil {
+			required = rule.Required
+		}
+		if rule.Country != "" && strings.EqualFold(rule.Country, req.Country) {
+			required = rule.Required
+			break
+		}
+	}
+	for _, field := range required {
+		var value, name string
+		switch field {
+		case "name":
+			value, name = req.Name, w.Lang("收件人")
+		case "phone":
+			value, name = req.Phone, w.Lang("联系电话")
+		case "country":
+			value, name = req.Country, w.Lang("国家/地区")
+		case "province":
+			value, name = req.Province, w.Lang("省份")
+		case "city":
+			value, name = req.City, w.Lang("城市")
+		case "address_info":
+			value, name = req.AddressInfo, w.Lang("详细地址")
+		case "postcode":
+			value, name = req.Postcode, w.Lang("邮编")
+		default:
+			continue
+		}
+		if strings.TrimSpace(value) == "" {
+			return fmt.Errorf(w.Lang("%s不能为空"), name)
+		}
+	}
+
+	return nil
+}
+
+// copyOrderAddress 下单时复制一份地址给订单使用，之后修改地址簿不影响已下单的订单
+func (w *Website) copyOrderAddress(tx *gorm.DB, address *model.OrderAddress) (*model.OrderAddress, error) {
+	orderAddress := *address
+	orderAddress.Id = 0
+	orderAddress.CreatedTime = 0
+	orderAddress.UpdatedTime = 0
+	orderAddress.IsDefault = 0
+	orderAddress.Status = config.OrderAddressStatusOrder
+	err := tx.Create(&orderAddress).Error
+	if err != nil {
+		return nil, err
+	}
 
 	return &orderAddress, nil
 }
 
+// 历史订单地址迁移完成的标记
+const orderAddressMigratedKey = "order_address_migrated"
+
+// migrateOrderAddresses 早期的订单直接引用地址簿中的地址，为这些订单各复制一份订单地址，之后修改或删除地址簿不影响历史订单
+func (w *Website) migrateOrderAddresses() {
+	if w.GetSettingValue(orderAddressMigratedKey) != "" {
+		return
+	}
+	var orders []*model.Order
+	w.DB.Model(&model.Order{}).Select("id", "address_id").
+		Where("`address_id` IN (SELECT `id` FROM `order_addresses` WHERE `status` = ?)", config.OrderAddressStatusBook).Find(&orders)
+	for _, order := range orders {
+		err := w.DB.Transaction(func(tx *gorm.DB) error {
+			var address model.OrderAddress
+			if err := tx.Where("`id` = ?", order.AddressId).Take(&address).Error; err != nil {
+				return err
+			}
+			snapshot, err := w.copyOrderAddress(tx, &address)
+			if err != nil {
+				return err
+			}
+			return tx.Model(order).UpdateColumn("address_id", snapshot.Id).Error
+		})
+		if err != nil {
+			log.Println("迁移订单地址失败：", order.Id, err.Error())
+			return
+		}
+	}
+	_ = w.SaveSettingValue(orderAddressMigratedKey, 1)
+}
+
 func (w *Website) GetRetailerOrders(retailerId uint, page, pageSize int) ([]*model.Order, int64) {
 	var orders []*model.Order
 	var total int64
@@ -1172,6 +1652,97 @@ func (w *Website) ExportOrders(req *request.OrderExportRequest) (header []string
 	return header, content
 }
 
+// ExportOrderRefunds 导出退款流水，用于对账
+func (w *Website) ExportOrderRefunds(req *request.OrderExportRequest) (header []string, content [][]interface{}) {
+	tx := w.DB.Model(&model.OrderRefund{}).Order("id asc")
+	if req.Status != "" {
+		tx = tx.Where("`status` = ?", w.getRefundStatusValue(req.Status))
+	}
+	if req.StartTime > 0 {
+		tx = tx.Where("`created_time` >= ?", req.StartTime)
+	}
+	if req.EndTime > 0 {
+		tx = tx.Where("`created_time` < ?", req.EndTime)
+	}
+
+	header = []string{w.Lang("申请时间"), w.Lang("退款时间"), w.Lang("退款单号"), w.Lang("订单ID"), w.Lang("退款状态"), w.Lang("退款金额"), w.Lang("退款商品"), w.Lang("购买用户"), w.Lang("退款原因"), w.Lang("备注")}
+	content = [][]interface{}{}
+	// 一次读取1000条
+	var lastId uint = 0
+	for {
+		var refunds []*model.OrderRefund
+		tx.Where("`id` > ?", lastId).Limit(1000).Find(&refunds)
+		if len(refunds) == 0 {
+			break
+		}
+		lastId = refunds[len(refunds)-1].Id
+
+		var userIds = make([]uint, 0, len(refunds))
+		var detailIds = make([]uint, 0, len(refunds))
+		for i := range refunds {
+			userIds = append(userIds, refunds[i].UserId)
+			if refunds[i].DetailId > 0 {
+				detailIds = append(detailIds, refunds[i].DetailId)
+			}
+		}
+		var details []*model.OrderDetail
+		if len(detailIds) > 0 {
+			w.DB.Where("`id` IN(?)", detailIds).Find(&details)
+		}
+		var archiveIds = make([]uint, 0, len(details))
+		for i := range details {
+			archiveIds = append(archiveIds, details[i].GoodsId)
+		}
+		var archives []*model.Archive
+		if len(archiveIds) > 0 {
+			archives, _, _ = w.GetArchiveList(func(tx *gorm.DB) *gorm.DB {
+				return tx.Where("`id` IN(?)", archiveIds)
+			}, 0, len(archiveIds))
+		}
+		users := w.GetUsersInfoByIds(userIds)
+		for i := range refunds {
+			var userName string
+			for u := range users {
+				if refunds[i].UserId == users[u].Id {
+					userName = users[u].UserName
+				}
+			}
+			goodsTitle := w.Lang("整单")
+			for d := range details {
+				if details[d].Id != refunds[i].DetailId {
+					continue
+				}
+				for x := range archives {
+					if archives[x].Id == details[d].GoodsId {
+						goodsTitle = archives[x].Title
+					}
+				}
+			}
+			var refundTime string
+			if refunds[i].RefundTime > 0 {
+				refundTime = time.Unix(refunds[i].RefundTime, 0).Format("2006-01-02 15:04:05")
+			}
+			content = append(content, []interface{}{
+				time.Unix(refunds[i].CreatedTime, 0).Format("2006-01-02 15:04:05"),
+				refundTime,
+				"," + refunds[i].RefundId,
+				"," + refunds[i].OrderId,
+				w.getRefundStatus(refunds[i].Status),
+				float64(refunds[i].Amount) / 100,
+				goodsTitle,
+				userName,
+				refunds[i].Reason,
+				refunds[i].Remark,
+			})
+		}
+	}
+
+	return header, content
+}
+
+// 每个用户地址簿中最多保存的地址数量
+const maxOrderAddressCount = 50
+
 var checkOrderRunning = false
 
 func (w *Website) AutoCheckOrders() {
@@ -1190,7 +1761,11 @@ func (w *Website) AutoCheckOrders() {
 	// auto close order
 	if w.PluginOrder.AutoCloseMinute > 0 {
 		closeStamp := currentStamp - w.PluginOrder.AutoCloseMinute*60
-		w.DB.Model(&model.Order{}).Where("`status` = ? and created_time < ?", config.OrderStatusWaiting, closeStamp)
+		var closeOrders []*model.Order
+		w.DB.Where("`status` = ? and created_time < ?", config.OrderStatusWaiting, closeStamp).Find(&closeOrders)
+		for _, v := range closeOrders {
+			w.SetOrderCanceled(v)
+		}
 	}
 	// auto finish order
 	var orders []*model.Order
@@ -1215,7 +1790,7 @@ func (w *Website) getOrderStatus(status int) string {
 		text = w.Lang("待发货")
 		break
 	case 2:
-		text = w.Lang("待支付")
+		text = w.Lang("待收货")
 		break
 	case 3:
 		text = w.Lang("已完成")
@@ -1230,3 +1805,29 @@ func (w *Website) getOrderStatus(status int) string {
 
 	return text
 }
+
+func (w *Website) getRefundStatus(status int) string {
+	var text string
+	switch status {
+	case config.OrderRefundStatusWaiting:
+		text = w.Lang("待处理")
+	case config.OrderRefundStatusDone:
+		text = w.Lang("已退款")
+	case config.OrderRefundStatusFailed:
+		text = w.Lang("已拒绝")
+	}
+
+	return text
+}
+
+// getRefundStatusValue status 可能会传 waiting,done,failed
+func (w *Website) getRefundStatusValue(status string) int {
+	switch status {
+	case "done":
+		return config.OrderRefundStatusDone
+	case "failed":
+		return config.OrderRefundStatusFailed
+	}
+
+	return config.OrderRefundStatusWaiting
+}
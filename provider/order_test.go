@@ -0,0 +1,69 @@
+package provider
+
+import (
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"testing"
+)
+
+func TestRefundedGoodsAmount(t *testing.T) {
+	cases := []struct {
+		name         string
+		orderRefund  int64
+		detailRefund int64
+		goods        int64
+	}{
+		// 商品 10000，运费 1000
+		{"shipping only", 1000, 0, 0},
+		{"part of shipping", 500, 0, 0},
+		{"whole order partial", 3000, 0, 2000},
+		{"detail", 0, 3000, 3000},
+		{"detail and shipping", 1000, 3000, 3000},
+		{"whole order", 11000, 0, 10000},
+		{"all details and shipping", 1000, 10000, 10000},
+	}
+	for _, c := range cases {
+		if goods := refundedGoodsAmount(10000, 1000, c.orderRefund, c.detailRefund); goods != c.goods {
+			t.Errorf("%s: refundedGoodsAmount = %d, expected %d", c.name, goods, c.goods)
+		}
+	}
+}
+
+func TestRefundCommission(t *testing.T) {
+	cases := []struct {
+		name             string
+		status           int
+		amount           int64
+		reversed         int64
+		previousRefunded int64
+		refunded         int64
+		deduct           int64
+		needReverse      bool
+		remainAmount     int64
+		remainStatus     int
+	}{
+		// 商品 10000，分成佣金 1000
+		{"share partial refund", config.CommissionStatusWait, 1000, 0, 0, 3000, 300, false, 700, config.CommissionStatusWait},
+		// 第二次退款按剩余的商品金额计算
+		{"share second partial refund", config.CommissionStatusWait, 700, 0, 3000, 5000, 200, false, 500, config.CommissionStatusWait},
+		{"share full refund", config.CommissionStatusWait, 700, 0, 3000, 10000, 700, false, 700, config.CommissionStatusCancel},
+		// 已提现的佣金不修改，记负数佣金，扣除之前已扣回的部分
+		{"withdrawn partial refund", config.CommissionStatusPaid, 1000, 0, 0, 3000, 300, true, 1000, config.CommissionStatusPaid},
+		{"withdrawn second partial refund", config.CommissionStatusPaid, 1000, -300, 3000, 5000, 200, true, 1000, config.CommissionStatusPaid},
+		{"withdrawn full refund", config.CommissionStatusPaid, 1000, -500, 5000, 10000, 500, true, 1000, config.CommissionStatusPaid},
+		// 只退运费时，已退的商品金额不变，不扣佣金
+		{"shipping only", config.CommissionStatusWait, 1000, 0, 0, 0, 0, false, 1000, config.CommissionStatusWait},
+		{"shipping after partial refund", config.CommissionStatusWait, 700, 0, 3000, 3000, 0, false, 700, config.CommissionStatusWait},
+		{"withdrawn shipping only", config.CommissionStatusPaid, 1000, 0, 0, 0, 0, false, 1000, config.CommissionStatusPaid},
+	}
+	for _, c := range cases {
+		commission := &model.Commission{Amount: c.amount, Status: c.status}
+		deduct, needReverse := refundCommission(commission, c.reversed, 10000, c.previousRefunded, c.refunded)
+		if deduct != c.deduct || needReverse != c.needReverse {
+			t.Errorf("%s: refundCommission = (%d, %v), expected (%d, %v)", c.name, deduct, needReverse, c.deduct, c.needReverse)
+		}
+		if commission.Amount != c.remainAmount || commission.Status != c.remainStatus {
+			t.Errorf("%s: commission = (%d, %d), expected (%d, %d)", c.name, commission.Amount, commission.Status, c.remainAmount, c.remainStatus)
+		}
+	}
+}
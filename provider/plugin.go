@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"github.com/parnurzeal/gorequest"
 	"io"
+	"kandaoni.com/anqicms/config"
 	"kandaoni.com/anqicms/library"
 	"kandaoni.com/anqicms/response"
 	"net/http"
@@ -24,6 +25,15 @@ type bingData struct {
 	UrlList []string `json:"urlList"`
 }
 
+type baiduResult struct {
+	Remain      int      `json:"remain"`
+	Success     int      `json:"success"`
+	NotSameSite []string `json:"not_same_site"`
+	NotValid    []string `json:"not_valid"`
+	Error       int      `json:"error"`
+	Message     string   `json:"message"`
+}
+
 type bingData2 struct {
 	Host        string   `json:"host"`
 	Key         string   `json:"key"`
@@ -31,87 +41,149 @@ type bingData2 struct {
 	UrlList     []string `json:"urlList"`
 }
 
+// PushArchive 新发布的链接加入推送队列
 func (w *Website) PushArchive(link string) {
-	_ = w.PushBaidu([]string{link})
-	_ = w.PushBing([]string{link})
+	w.AddPushQueue(config.PushActionAdd, link)
 }
 
 func (w *Website) PushBaidu(list []string) error {
+	_, _, err := w.pushBaidu(config.PushActionAdd, list)
+
+	return err
+}
+
+// pushBaidu 推送到百度，返回百度拒绝的链接，修改和删除分别使用百度的 update 和 del 接口
+func (w *Website) pushBaidu(action string, list []string) ([]string, string, error) {
 	baiduApi := w.PluginPush.BaiduApi
 	if baiduApi == "" {
-		return errors.New(w.Lang("没有配置百度主动推送"))
+		return nil, "", errors.New(w.Lang("没有配置百度主动推送"))
+	}
+	if action == config.PushActionUpdate {
+		baiduApi = strings.Replace(baiduApi, "/urls?", "/update?", 1)
+	} else if action == config.PushActionDelete {
+		baiduApi = strings.Replace(baiduApi, "/urls?", "/del?", 1)
 	}
-	urlString := strings.Replace(strings.Trim(fmt.Sprint(list), "[]"), " ", "\n", -1)
 
-	resp, err := http.Post(baiduApi, "text/plain", strings.NewReader(urlString))
+	resp, err := http.Post(baiduApi, "text/plain", strings.NewReader(strings.Join(list, "\n")))
 	if err != nil {
-		fmt.Println(err)
-		return err
+		return nil, "", err
 	}
 	defer resp.Body.Close()
 	body, _ := io.ReadAll(resp.Body)
 
 	w.logPushResult("baidu", fmt.Sprintf("%v, %s", list, string(body)))
-	return nil
+
+	var result baiduResult
+	_ = json.Unmarshal(body, &result)
+	if result.Error != 0 || resp.StatusCode != http.StatusOK {
+		if strings.Contains(result.Message, "over quota") {
+			w.setBaiduPushRemain(0)
+			return nil, result.Message, errPushQuota
+		}
+		if result.Message == "" {
+			result.Message = resp.Status
+		}
+		return nil, result.Message, errors.New(result.Message)
+	}
+	w.setBaiduPushRemain(result.Remain)
+
+	return append(result.NotSameSite, result.NotValid...), string(body), nil
 }
 
 func (w *Website) PushBing(list []string) error {
+	_, err := w.pushBing(list)
+
+	return err
+}
+
+func (w *Website) pushBing(list []string) (string, error) {
 	bingApi := w.PluginPush.BingApi
 	if bingApi == "" {
-		return errors.New(w.Lang("没有配置必应主动推送"))
+		return "", errors.New(w.Lang("没有配置必应主动推送"))
 	}
 
 	// bing 推送有2种方式，一种是传统的api，另一种是 IndexNow
 	if strings.HasPrefix(bingApi, "https://www.bing.com/indexnow") {
-		baseUrl, err := url.Parse(w.System.BaseUrl)
-		if err != nil {
-			return err
-		}
 		// IndexNow
 		// 验证以下是否存在txt
 		parsedUrl, err := url.Parse(bingApi)
 		if err != nil {
-			return err
+			return "", err
 		}
 		apiKey := parsedUrl.Query().Get("key")
-
-		txtFile := w.PublicPath + apiKey + ".txt"
-		_, err = os.Stat(txtFile)
-		if err != nil && os.IsNotExist(err) {
-			// 生成一个
-			_ = os.WriteFile(txtFile, []byte(apiKey), os.ModePerm)
-		}
-		// 开始推送
-		postData := bingData2{
-			Host:        baseUrl.Host,
-			Key:         apiKey,
-			KeyLocation: w.System.BaseUrl + "/" + apiKey + ".txt",
-			UrlList:     list,
-		}
-		resp, body, errs := gorequest.New().Timeout(10*time.Second).Set("Content-Type", "application/json; charset=utf-8").Post(bingApi).Send(postData).End()
-		if errs != nil {
-			fmt.Println(errs)
-			return errs[0]
-		}
-		if resp.StatusCode == 200 {
-			body = "URL submitted successfully"
-		}
+		body, err := w.postIndexNow(bingApi, apiKey, list)
 		w.logPushResult("bing", fmt.Sprintf("%v, %s", list, body))
-	} else {
-		postData := bingData{
-			SiteUrl: w.System.BaseUrl,
-			UrlList: list,
-		}
 
-		_, body, errs := gorequest.New().Timeout(10*time.Second).Set("Content-Type", "application/json; charset=utf-8").Post(bingApi).Send(postData).End()
-		if errs != nil {
-			fmt.Println(errs)
-			return errs[0]
-		}
-		w.logPushResult("bing", fmt.Sprintf("%v, %s", list, body))
+		return body, err
+	}
+	postData := bingData{
+		SiteUrl: w.System.BaseUrl,
+		UrlList: list,
 	}
 
-	return nil
+	resp, body, errs := gorequest.New().Timeout(10*time.Second).Set("Content-Type", "application/json; charset=utf-8").Post(bingApi).Send(postData).End()
+	if errs != nil {
+		return "", errs[0]
+	}
+	w.logPushResult("bing", fmt.Sprintf("%v, %s", list, body))
+	if resp.StatusCode != http.StatusOK {
+		return body, errors.New(resp.Status)
+	}
+
+	return body, nil
+}
+
+// pushIndexNow 使用站点的 IndexNow 密钥推送到指定的引擎
+func (w *Website) pushIndexNow(name string, list []string) (string, error) {
+	api, ok := config.IndexNowEngines[name]
+	if !ok {
+		return "", errors.New(w.Lang("不支持的推送引擎"))
+	}
+	if w.PluginPush.IndexNowKey == "" {
+		return "", errors.New(w.Lang("没有配置IndexNow密钥"))
+	}
+	body, err := w.postIndexNow(api, w.PluginPush.IndexNowKey, list)
+	w.logPushResult(config.PushEngineIndexNow+":"+name, fmt.Sprintf("%v, %s", list, body))
+
+	return body, err
+}
+
+func (w *Website) postIndexNow(api, apiKey string, list []string) (string, error) {
+	baseUrl, err := url.Parse(w.System.BaseUrl)
+	if err != nil {
+		return "", err
+	}
+	w.writeIndexNowKeyFile(apiKey)
+	// 开始推送
+	postData := bingData2{
+		Host:        baseUrl.Host,
+		Key:         apiKey,
+		KeyLocation: w.System.BaseUrl + "/" + apiKey + ".txt",
+		UrlList:     list,
+	}
+	resp, body, errs := gorequest.New().Timeout(10*time.Second).Set("Content-Type", "application/json; charset=utf-8").Post(api).Send(postData).End()
+	if errs != nil {
+		return "", errs[0]
+	}
+	// 200 和 202 都表示已接收
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusAccepted {
+		return body, errors.New(resp.Status)
+	}
+
+	return "URL submitted successfully", nil
+}
+
+// writeIndexNowKeyFile IndexNow 需要在网站根目录放置以密钥命名的验证文件
+func (w *Website) writeIndexNowKeyFile(apiKey string) {
+	if apiKey == "" {
+		return
+	}
+	txtFile := w.PublicPath + apiKey + ".txt"
+	_, err := os.Stat(txtFile)
+	if err != nil && os.IsNotExist(err) {
+		// 生成一个
+		_ = os.WriteFile(txtFile, []byte(apiKey), os.ModePerm)
+	}
 }
 
 func (w *Website) logPushResult(spider string, result string) {
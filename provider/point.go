@@ -0,0 +1,255 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/request"
+	"time"
+)
+
+// ChangeUserPoints 增加或扣减用户积分，并记录一条积分明细，扣减后积分不能小于0
+func (w *Website) ChangeUserPoints(tx *gorm.DB, userId uint, points int64, action string, targetId uint, orderId string, remark string) (*model.UserPoint, error) {
+	if userId == 0 || points == 0 {
+		return nil, nil
+	}
+	if tx == nil {
+		tx = w.DB
+	}
+	result := tx.Model(&model.User{}).Where("`id` = ? and `points` + ? >= 0", userId, points).UpdateColumn("points", gorm.Expr("`points` + ?", points))
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, errors.New(w.Lang("积分不足"))
+	}
+	var afterPoints int64
+	tx.Model(&model.User{}).Where("`id` = ?", userId).Pluck("points", &afterPoints)
+	record := model.UserPoint{
+		UserId:      userId,
+		Action:      action,
+		TargetId:    targetId,
+		OrderId:     orderId,
+		Points:      points,
+		AfterPoints: afterPoints,
+		Remark:      remark,
+	}
+	err := tx.Create(&record).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &record, nil
+}
+
+// AwardUserPoints 按积分规则奖励积分，同一个对象只奖励一次，每日登录每天只奖励一次
+func (w *Website) AwardUserPoints(userId uint, action string, targetId uint) {
+	if !w.PluginPoint.Open || userId == 0 {
+		return
+	}
+	var points int64
+	var remark string
+	switch action {
+	case config.PointActionRegister:
+		points = w.PluginPoint.RegisterPoints
+		remark = w.Lang("注册奖励")
+	case config.PointActionLogin:
+		points = w.PluginPoint.LoginPoints
+		remark = w.Lang("每日登录奖励")
+	case config.PointActionComment:
+		points = w.PluginPoint.CommentPoints
+		remark = w.Lang("评论奖励")
+	case config.PointActionPublish:
+		points = w.PluginPoint.PublishPoints
+		remark = w.Lang("投稿奖励")
+	}
+	if points <= 0 {
+		return
+	}
+	now := time.Now()
+	todayStamp := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location()).Unix()
+	var exists int64
+	tx := w.DB.Model(&model.UserPoint{}).Where("`user_id` = ? and `action` = ?", userId, action)
+	if action == config.PointActionLogin {
+		tx = tx.Where("`created_time` >= ?", todayStamp)
+	} else if action != config.PointActionRegister {
+		tx = tx.Where("`target_id` = ?", targetId)
+	}
+	tx.Count(&exists)
+	if exists > 0 {
+		return
+	}
+	if w.PluginPoint.DailyLimit > 0 && (action == config.PointActionComment || action == config.PointActionPublish) {
+		var today int64
+		w.DB.Model(&model.UserPoint{}).Where("`user_id` = ? and `action` IN(?) and `created_time` >= ?", userId, []string{config.PointActionComment, config.PointActionPublish}, todayStamp).
+			Select("COALESCE(SUM(`points`), 0)").Scan(&today)
+		if today >= w.PluginPoint.DailyLimit {
+			return
+		}
+		if today+points > w.PluginPoint.DailyLimit {
+			points = w.PluginPoint.DailyLimit - today
+		}
+	}
+
+	_, _ = w.ChangeUserPoints(nil, userId, points, action, targetId, "", remark)
+}
+
+// AwardDailyLoginPoints 每天第一次访问时奖励登录积分，使用缓存避免每次请求都查询数据库
+func (w *Website) AwardDailyLoginPoints(userId uint) {
+	if !w.PluginPoint.Open || w.PluginPoint.LoginPoints <= 0 || userId == 0 {
+		return
+	}
+	today := time.Now().Format("20060102")
+	cacheKey := fmt.Sprintf("point_login_%d", userId)
+	if val, ok := w.MemCache.Get(cacheKey).(string); ok && val == today {
+		return
+	}
+	w.MemCache.Set(cacheKey, today, 86400)
+
+	w.AwardUserPoints(userId, config.PointActionLogin, 0)
+}
+
+// AwardOrderPoints 订单支付后按支付金额奖励积分
+func (w *Website) AwardOrderPoints(order *model.Order) {
+	if !w.PluginPoint.Open || w.PluginPoint.OrderPoints <= 0 {
+		return
+	}
+	points := order.Amount * w.PluginPoint.OrderPoints / 100
+	if points <= 0 {
+		return
+	}
+	var exists int64
+	w.DB.Model(&model.UserPoint{}).Where("`user_id` = ? and `action` = ? and `order_id` = ?", order.UserId, config.PointActionOrder, order.OrderId).Count(&exists)
+	if exists > 0 {
+		return
+	}
+
+	_, _ = w.ChangeUserPoints(nil, order.UserId, points, config.PointActionOrder, 0, order.OrderId, w.Lang("消费奖励"))
+}
+
+// GetOrderPointDeduction 计算积分可以抵扣的金额，返回实际使用的积分和抵扣的金额
+func (w *Website) GetOrderPointDeduction(user *model.User, usePoints int64, amount int64) (int64, int64) {
+	if !w.PluginPoint.Open || w.PluginPoint.DeductRate <= 0 || usePoints <= 0 || amount <= 0 {
+		return 0, 0
+	}
+	if usePoints > user.Points {
+		usePoints = user.Points
+	}
+	maxAmount := amount * w.PluginPoint.DeductPercent / 100
+	// 积分按整分抵扣，DeductRate 积分抵扣1元，即100分
+	deductAmount := usePoints * 100 / w.PluginPoint.DeductRate
+	if deductAmount > maxAmount {
+		deductAmount = maxAmount
+	}
+	if deductAmount <= 0 {
+		return 0, 0
+	}
+	usePoints = (deductAmount*w.PluginPoint.DeductRate + 99) / 100
+
+	return usePoints, deductAmount
+}
+
+// RefundOrderPoints 订单取消或退款后，按退款比例退回抵扣使用的积分，并扣回消费奖励的积分，多次部分退款时累计计算
+func (w *Website) RefundOrderPoints(order *model.Order) {
+	numerator, denominator := order.RefundAmount, order.Amount
+	if order.Status == config.OrderStatusCanceled || order.Status == config.OrderStatusRefunded || numerator >= denominator {
+		numerator, denominator = 1, 1
+	}
+	if numerator <= 0 || denominator <= 0 {
+		return
+	}
+	if order.UsedPoints > 0 {
+		var returned int64
+		w.DB.Model(&model.UserPoint{}).Where("`user_id` = ? and `action` = ? and `order_id` = ? and `points` > 0", order.UserId, config.PointActionRefund, order.OrderId).
+			Select("COALESCE(SUM(`points`), 0)").Scan(&returned)
+		points := order.UsedPoints*numerator/denominator - returned
+		if points > 0 {
+			_, _ = w.ChangeUserPoints(nil, order.UserId, points, config.PointActionRefund, 0, order.OrderId, w.Lang("订单积分退回"))
+		}
+	}
+	var awarded model.UserPoint
+	if w.DB.Where("`user_id` = ? and `action` = ? and `order_id` = ?", order.UserId, config.PointActionOrder, order.OrderId).Take(&awarded).Error == nil {
+		var deducted int64
+		w.DB.Model(&model.UserPoint{}).Where("`user_id` = ? and `action` = ? and `order_id` = ? and `points` < 0", order.UserId, config.PointActionRefund, order.OrderId).
+			Select("COALESCE(SUM(`points`), 0)").Scan(&deducted)
+		points := awarded.Points*numerator/denominator + deducted
+		if points <= 0 {
+			return
+		}
+		var user model.User
+		if w.DB.Where("`id` = ?", order.UserId).Take(&user).Error != nil {
+			return
+		}
+		// 积分已经使用的部分不再扣回
+		if points > user.Points {
+			points = user.Points
+		}
+		_, _ = w.ChangeUserPoints(nil, order.UserId, -points, config.PointActionRefund, 0, order.OrderId, w.Lang("订单退款扣回消费奖励"))
+	}
+}
+
+// awardPublishPoints 投稿发布后奖励积分，审核通过后才发布的投稿在发布时奖励，同一篇只奖励一次
+func (w *Website) awardPublishPoints(archive *model.Archive) {
+	if archive.UserId == 0 || archive.Status != config.ContentStatusOK {
+		return
+	}
+	w.AwardUserPoints(archive.UserId, config.PointActionPublish, archive.Id)
+}
+
+// CheckArchivePointUnlocked 用户是否已经使用积分解锁了文档
+func (w *Website) CheckArchivePointUnlocked(userId, archiveId uint) bool {
+	if userId == 0 {
+		return false
+	}
+	var exists int64
+	w.DB.Model(&model.UserPoint{}).Where("`user_id` = ? and `action` = ? and `target_id` = ?", userId, config.PointActionUnlock, archiveId).Count(&exists)
+
+	return exists > 0
+}
+
+// UnlockArchiveByPoints 使用积分解锁需要积分阅读的文档，已解锁的不会重复扣除
+func (w *Website) UnlockArchiveByPoints(userId uint, archive *model.Archive) error {
+	if archive.ReadPoints <= 0 || archive.UserId == userId || w.CheckArchivePointUnlocked(userId, archive.Id) {
+		return nil
+	}
+	_, err := w.ChangeUserPoints(nil, userId, -archive.ReadPoints, config.PointActionUnlock, archive.Id, "", fmt.Sprintf(w.Lang("解锁文档：%s"), archive.Title))
+
+	return err
+}
+
+func (w *Website) GetUserPointList(userId uint, action string, page, pageSize int, offsets ...int) ([]*model.UserPoint, int64) {
+	var points []*model.UserPoint
+	var total int64
+	offset := (page - 1) * pageSize
+	if len(offsets) > 0 {
+		offset = offsets[0]
+	}
+	tx := w.DB.Model(&model.UserPoint{})
+	if userId > 0 {
+		tx = tx.Where("`user_id` = ?", userId)
+	}
+	if action != "" {
+		tx = tx.Where("`action` = ?", action)
+	}
+	tx.Count(&total).Order("id desc").Limit(pageSize).Offset(offset).Find(&points)
+
+	return points, total
+}
+
+// AdminAdjustUserPoints 后台调整用户积分，需要填写原因
+func (w *Website) AdminAdjustUserPoints(req *request.UserPointRequest) (*model.UserPoint, error) {
+	if req.Points == 0 {
+		return nil, errors.New(w.Lang("请填写要调整的积分"))
+	}
+	if req.Remark == "" {
+		return nil, errors.New(w.Lang("请填写调整原因"))
+	}
+	_, err := w.GetUserInfoById(req.UserId)
+	if err != nil {
+		return nil, err
+	}
+
+	return w.ChangeUserPoints(nil, req.UserId, req.Points, config.PointActionAdmin, 0, "", req.Remark)
+}
@@ -0,0 +1,307 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"github.com/jinzhu/now"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/library"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/response"
+	"os"
+	"strings"
+	"time"
+	"unicode/utf8"
+)
+
+const (
+	// 每个搜索引擎每次最多推送的链接数
+	pushQueueBatch = 100
+	// 失败后最多重试的次数，重试间隔从5分钟开始翻倍
+	pushQueueMaxRetries = 5
+	pushQueueRetryDelay = 300
+)
+
+// errPushQuota 当天的推送配额已用完，链接保留在队列中等第二天推送，不计入重试次数
+var errPushQuota = errors.New("push quota exceeded")
+
+// GetPushEngines 当前启用的推送引擎
+func (w *Website) GetPushEngines() []string {
+	var engines []string
+	if w.PluginPush.BaiduApi != "" {
+		engines = append(engines, config.PushEngineBaidu)
+	}
+	if w.PluginPush.BingApi != "" {
+		engines = append(engines, config.PushEngineBing)
+	}
+	for _, name := range w.PluginPush.IndexNowEngines {
+		if _, ok := config.IndexNowEngines[name]; ok {
+			engines = append(engines, config.PushEngineIndexNow+":"+name)
+		}
+	}
+
+	return engines
+}
+
+// PushChangedLinks 内容修改、删除或下线后，按配置加入推送队列
+func (w *Website) PushChangedLinks(action string, links ...string) {
+	if (action == config.PushActionUpdate && w.PluginPush.PushUpdate != 1) ||
+		(action == config.PushActionDelete && w.PluginPush.PushDelete != 1) {
+		return
+	}
+	w.AddPushQueue(action, links...)
+}
+
+// AddPushQueue 链接加入推送队列，由计划任务分批推送，队列中还没推送的链接不会重复添加
+func (w *Website) AddPushQueue(action string, links ...string) {
+	if w.DB == nil {
+		return
+	}
+	engines := w.GetPushEngines()
+	for _, link := range links {
+		if link == "" || len(link) > 250 {
+			continue
+		}
+		for _, engine := range engines {
+			var queue model.PushQueue
+			err := w.DB.Where("`url` = ? and `engine` = ? and `status` IN (?)", link, engine, []int{config.PushStatusWait, config.PushStatusRetry}).Take(&queue).Error
+			// 新发布后还没推送又修改的，仍然按新发布推送
+			if err == nil && queue.Action == config.PushActionAdd && action == config.PushActionUpdate {
+				continue
+			}
+			queue.Url = link
+			queue.Engine = engine
+			queue.Action = action
+			queue.Status = config.PushStatusWait
+			queue.Retries = 0
+			queue.NextTime = 0
+			w.DB.Save(&queue)
+		}
+	}
+}
+
+// ProcessPushQueue 计划任务推送队列中到期的链接，按搜索引擎和动作分批提交
+func (w *Website) ProcessPushQueue() {
+	if w.DB == nil {
+		return
+	}
+	// 上一次推送还没有结束时跳过本次
+	w.pushQueueMutex.Lock()
+	if w.pushQueueRunning {
+		w.pushQueueMutex.Unlock()
+		return
+	}
+	w.pushQueueRunning = true
+	w.pushQueueMutex.Unlock()
+	defer func() {
+		w.pushQueueMutex.Lock()
+		w.pushQueueRunning = false
+		w.pushQueueMutex.Unlock()
+	}()
+
+	for _, engine := range w.GetPushEngines() {
+		limit := pushQueueBatch
+		if engine == config.PushEngineBaidu {
+			remain := w.GetBaiduPushRemain()
+			if remain == 0 {
+				continue
+			}
+			if remain > 0 && remain < limit {
+				limit = remain
+			}
+		}
+		var queues []*model.PushQueue
+		w.DB.Where("`engine` = ? and `status` IN (?) and `next_time` <= ?", engine, []int{config.PushStatusWait, config.PushStatusRetry}, time.Now().Unix()).
+			Order("`id` asc").Limit(limit).Find(&queues)
+		if len(queues) == 0 {
+			continue
+		}
+		groups := map[string][]*model.PushQueue{}
+		for _, v := range queues {
+			groups[v.Action] = append(groups[v.Action], v)
+		}
+		for action, items := range groups {
+			links := make([]string, 0, len(items))
+			for _, v := range items {
+				links = append(links, v.Url)
+			}
+			rejected, result, err := w.pushToEngine(engine, action, links)
+			w.updatePushQueue(items, rejected, result, err)
+		}
+	}
+}
+
+func (w *Website) pushToEngine(engine, action string, links []string) ([]string, string, error) {
+	switch {
+	case engine == config.PushEngineBaidu:
+		return w.pushBaidu(action, links)
+	case engine == config.PushEngineBing:
+		result, err := w.pushBing(links)
+		return nil, result, err
+	case strings.HasPrefix(engine, config.PushEngineIndexNow+":"):
+		result, err := w.pushIndexNow(strings.TrimPrefix(engine, config.PushEngineIndexNow+":"), links)
+		return nil, result, err
+	}
+
+	return nil, "", errors.New(w.Lang("不支持的推送引擎"))
+}
+
+func (w *Website) updatePushQueue(items []*model.PushQueue, rejected []string, result string, err error) {
+	if errors.Is(err, errPushQuota) {
+		return
+	}
+	rejectedMap := map[string]struct{}{}
+	for _, v := range rejected {
+		rejectedMap[v] = struct{}{}
+	}
+	nowStamp := time.Now().Unix()
+	for _, item := range items {
+		if err != nil {
+			item.Retries++
+			item.Result = truncatePushResult(err.Error())
+			if item.Retries >= pushQueueMaxRetries {
+				item.Status = config.PushStatusFailed
+			} else {
+				item.Status = config.PushStatusRetry
+				item.NextTime = nowStamp + int64(pushQueueRetryDelay<<(item.Retries-1))
+			}
+		} else if _, ok := rejectedMap[item.Url]; ok {
+			item.Status = config.PushStatusFailed
+			item.Result = w.Lang("链接被搜索引擎拒绝")
+		} else {
+			item.Status = config.PushStatusOk
+			item.PushedTime = nowStamp
+			item.Result = truncatePushResult(result)
+		}
+		w.DB.Save(item)
+	}
+}
+
+func truncatePushResult(result string) string {
+	if utf8.RuneCountInString(result) > 250 {
+		result = string([]rune(result)[:250])
+	}
+
+	return result
+}
+
+// GetBaiduPushRemain 百度当天剩余的推送配额，-1 表示还不知道
+func (w *Website) GetBaiduPushRemain() int {
+	remain, ok := w.MemCache.Get("baiduPushRemain").(int)
+	if !ok {
+		return -1
+	}
+
+	return remain
+}
+
+// setBaiduPushRemain 百度的配额每天重置，缓存到当天结束
+func (w *Website) setBaiduPushRemain(remain int) {
+	w.MemCache.Delete("baiduPushRemain")
+	w.MemCache.Set("baiduPushRemain", remain, now.EndOfDay().Unix()-time.Now().Unix()+1)
+}
+
+// CheckIndexNowKey 开启 IndexNow 后没有密钥时自动生成，密钥变化后删除旧的验证文件
+func (w *Website) CheckIndexNowKey(oldKey string) {
+	if len(w.PluginPush.IndexNowEngines) > 0 && w.PluginPush.IndexNowKey == "" {
+		w.PluginPush.IndexNowKey = library.Md5(fmt.Sprintf("%s-%d-%d", w.System.BaseUrl, time.Now().UnixNano(), library.GenerateRandNumber(6)))
+	}
+	if oldKey != "" && oldKey != w.PluginPush.IndexNowKey {
+		_ = os.Remove(w.PublicPath + oldKey + ".txt")
+	}
+	w.writeIndexNowKeyFile(w.PluginPush.IndexNowKey)
+}
+
+func (w *Website) GetPushQueueList(engine string, status int, keyword string, currentPage, pageSize int) ([]*model.PushQueue, int64) {
+	var queues []*model.PushQueue
+	var total int64
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	offset := (currentPage - 1) * pageSize
+	builder := w.DB.Model(&model.PushQueue{})
+	if engine != "" {
+		builder = builder.Where("`engine` = ?", engine)
+	}
+	if status >= 0 {
+		builder = builder.Where("`status` = ?", status)
+	}
+	if keyword != "" {
+		builder = builder.Where("`url` LIKE ?", "%"+keyword+"%")
+	}
+	builder.Count(&total).Order("`id` desc").Limit(pageSize).Offset(offset).Find(&queues)
+
+	return queues, total
+}
+
+// GetPushQueueSummary 按搜索引擎统计各状态的链接数量
+func (w *Website) GetPushQueueSummary() *response.PushQueueSummary {
+	summary := &response.PushQueueSummary{
+		BaiduRemain: w.GetBaiduPushRemain(),
+		Engines:     []*response.PushEngineCount{},
+	}
+	var rows []struct {
+		Engine string
+		Status int
+		Total  int64
+	}
+	w.DB.Model(&model.PushQueue{}).Select("`engine`, `status`, count(1) AS total").Group("engine, status").Scan(&rows)
+	engines := map[string]*response.PushEngineCount{}
+	for _, engine := range w.GetPushEngines() {
+		engines[engine] = &response.PushEngineCount{Engine: engine}
+		summary.Engines = append(summary.Engines, engines[engine])
+	}
+	for _, v := range rows {
+		item, ok := engines[v.Engine]
+		if !ok {
+			item = &response.PushEngineCount{Engine: v.Engine}
+			engines[v.Engine] = item
+			summary.Engines = append(summary.Engines, item)
+		}
+		switch v.Status {
+		case config.PushStatusWait:
+			item.Wait = v.Total
+		case config.PushStatusOk:
+			item.Ok = v.Total
+		case config.PushStatusRetry:
+			item.Retry = v.Total
+		case config.PushStatusFailed:
+			item.Failed = v.Total
+		}
+	}
+
+	return summary
+}
+
+// RetryPushQueue 手动重新推送
+func (w *Website) RetryPushQueue(ids []uint) error {
+	if len(ids) == 0 {
+		return errors.New(w.Lang("请选择要推送的链接"))
+	}
+
+	return w.DB.Model(&model.PushQueue{}).Where("`id` IN (?)", ids).Updates(map[string]interface{}{
+		"status":    config.PushStatusWait,
+		"retries":   0,
+		"next_time": 0,
+	}).Error
+}
+
+func (w *Website) DeletePushQueue(ids []uint) error {
+	if len(ids) == 0 {
+		return errors.New(w.Lang("请选择要删除的链接"))
+	}
+
+	return w.DB.Unscoped().Where("`id` IN (?)", ids).Delete(&model.PushQueue{}).Error
+}
+
+// CleanPushQueue 清理30天前推送成功或已放弃的记录
+func (w *Website) CleanPushQueue() {
+	if w.DB == nil {
+		return
+	}
+	agoStamp := time.Now().AddDate(0, 0, -30).Unix()
+	w.DB.Unscoped().Where("`status` IN (?) and `updated_time` < ?", []int{config.PushStatusOk, config.PushStatusFailed}, agoStamp).Delete(&model.PushQueue{})
+}
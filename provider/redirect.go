@@ -2,11 +2,15 @@ package provider
 
 import (
 	"fmt"
+	"gorm.io/gorm"
 	"io"
+	"kandaoni.com/anqicms/config"
 	"kandaoni.com/anqicms/model"
 	"mime/multipart"
-	"net/url"
+	"regexp"
+	"strconv"
 	"strings"
+	"time"
 )
 
 func (w *Website) GetRedirectList(keyword string, currentPage, pageSize int) ([]*model.Redirect, int64, error) {
@@ -14,10 +18,10 @@ func (w *Website) GetRedirectList(keyword string, currentPage, pageSize int) ([]
 	offset := (currentPage - 1) * pageSize
 	var total int64
 
-	builder := w.DB.Model(&model.Redirect{}).Order("id desc")
+	builder := w.DB.Model(&model.Redirect{}).Order("priority desc, id desc")
 	if keyword != "" {
 		//模糊搜索
-		builder = builder.Where("(`from_url` like ?)", "%"+keyword+"%")
+		builder = builder.Where("(`from_url` like ? OR `to_url` like ?)", "%"+keyword+"%", "%"+keyword+"%")
 	}
 
 	err := builder.Count(&total).Limit(pageSize).Offset(offset).Find(&redirects).Error
@@ -60,7 +64,7 @@ func (w *Website) ImportRedirects(file multipart.File, info *multipart.FileHeade
 	var total int
 	for i, line := range lines {
 		line = strings.TrimSpace(line)
-		// 格式：from_url, to_url
+		// 格式：from_url, to_url[, status_code]
 		if i == 0 {
 			continue
 		}
@@ -91,6 +95,10 @@ func (w *Website) ImportRedirects(file multipart.File, info *multipart.FileHeade
 			total++
 		}
 		redirect.ToUrl = toUrl
+		if len(values) > 2 {
+			code, _ := strconv.Atoi(strings.TrimSpace(values[2]))
+			redirect.StatusCode = getRedirectStatusCode(code)
+		}
 		w.DB.Save(redirect)
 	}
 
@@ -110,55 +118,178 @@ func (w *Website) DeleteCacheRedirects() {
 	w.MemCache.Delete("redirects")
 }
 
-func (w *Website) GetCacheRedirects() map[string]string {
+type redirectPattern struct {
+	redirect  *model.Redirect
+	re        *regexp.Regexp
+	withQuery bool // 规则中包含查询参数时，匹配完整的 RequestURI，否则只匹配路径
+}
+
+type redirectRules struct {
+	exact    map[string]*model.Redirect
+	patterns []*redirectPattern
+}
+
+// RedirectResult 匹配到的跳转规则，以及替换后的跳转链接
+type RedirectResult struct {
+	Redirect   *model.Redirect `json:"redirect"`
+	ToUrl      string          `json:"to_url"`
+	StatusCode int             `json:"status_code"`
+}
+
+// GetRedirectPath 将完整的链接转换为 RequestURI，规则只按路径匹配
+func GetRedirectPath(link string) string {
+	if strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") {
+		link = link[strings.Index(link, "://")+3:]
+		if idx := strings.Index(link, "/"); idx >= 0 {
+			link = link[idx:]
+		} else {
+			link = "/"
+		}
+	}
+
+	return link
+}
+
+// CompileRedirectPattern 将通配符或正则规则编译为完整匹配的正则表达式
+func CompileRedirectPattern(fromUrl string, matchType int) (*regexp.Regexp, error) {
+	fromUrl = GetRedirectPath(fromUrl)
+	var pattern string
+	if matchType == config.RedirectMatchWildcard {
+		pattern = strings.ReplaceAll(regexp.QuoteMeta(fromUrl), `\*`, "(.*)")
+	} else {
+		pattern = fromUrl
+	}
+
+	return regexp.Compile("^(?:" + pattern + ")$")
+}
+
+func getRedirectStatusCode(code int) int {
+	switch code {
+	case 301, 302, 307, 308, 410:
+		return code
+	}
+
+	return 301
+}
+
+// newRedirectRules 按传入顺序编译规则，传入的规则需已按优先级排序
+func newRedirectRules(redirects []*model.Redirect) *redirectRules {
+	rules := &redirectRules{
+		exact: map[string]*model.Redirect{},
+	}
+	for _, v := range redirects {
+		if v.MatchType == config.RedirectMatchExact {
+			rules.exact[GetRedirectPath(v.FromUrl)] = v
+			continue
+		}
+		re, err := CompileRedirectPattern(v.FromUrl, v.MatchType)
+		if err != nil {
+			continue
+		}
+		withQuery := strings.Contains(v.FromUrl, "?")
+		if v.MatchType == config.RedirectMatchRegex {
+			withQuery = strings.Contains(v.FromUrl, `\?`)
+		}
+		rules.patterns = append(rules.patterns, &redirectPattern{
+			redirect:  v,
+			re:        re,
+			withQuery: withQuery,
+		})
+	}
+
+	return rules
+}
+
+func (w *Website) getRedirectRules() *redirectRules {
 	if w.DB == nil {
 		return nil
 	}
-	var redirects = map[string]string{}
 	result := w.MemCache.Get("redirects")
 	if result != nil {
-		var ok bool
-		redirects, ok = result.(map[string]string)
+		rules, ok := result.(*redirectRules)
 		if ok {
-			return redirects
+			return rules
 		}
 	}
 
-	baseUrl, err := url.Parse(w.System.BaseUrl)
-	if err == nil {
-		baseUrl.Host = "127.0.0.1:8001"
+	var tmpData []*model.Redirect
+	w.DB.Order("`priority` desc, `id` asc").Find(&tmpData)
+	rules := newRedirectRules(tmpData)
+	w.MemCache.Set("redirects", rules, 0)
+
+	return rules
+}
+
+// MatchRedirect 查找请求链接对应的跳转规则，完全匹配的规则优先，其次按优先级依次匹配通配符和正则规则
+func (w *Website) MatchRedirect(uri string) *RedirectResult {
+	rules := w.getRedirectRules()
+	if rules == nil {
+		return nil
+	}
+
+	return rules.match(uri)
+}
+
+func (rules *redirectRules) match(uri string) *RedirectResult {
+	path, query := uri, ""
+	if idx := strings.Index(uri, "?"); idx >= 0 {
+		path = uri[:idx]
+		query = uri[idx+1:]
 	}
 
-	var tmpData []model.Redirect
-	w.DB.Where(model.Redirect{}).Find(&tmpData)
-	for i := range tmpData {
-		fromUrl := tmpData[i].FromUrl
-		toUrl := tmpData[i].ToUrl
-		if strings.HasPrefix(fromUrl, "http") {
-			urlParsed, err := url.Parse(fromUrl)
-			if err == nil {
-				fromUrl = urlParsed.RequestURI()
+	var redirect *model.Redirect
+	var toUrl string
+	if v, ok := rules.exact[uri]; ok {
+		redirect = v
+		toUrl = v.ToUrl
+		// 完整链接已经包含了查询参数
+		query = ""
+	} else if v, ok = rules.exact[path]; ok {
+		redirect = v
+		toUrl = v.ToUrl
+	} else {
+		for _, p := range rules.patterns {
+			target := path
+			if p.withQuery {
+				target = uri
 			}
-		}
-		if strings.HasPrefix(toUrl, "http") {
-			urlParsed, err := url.Parse(toUrl)
-			if err == nil && urlParsed.Host == baseUrl.Host {
-				fromUrl = urlParsed.RequestURI()
+			match := p.re.FindStringSubmatchIndex(target)
+			if match == nil {
+				continue
 			}
+			redirect = p.redirect
+			toUrl = string(p.re.ExpandString(nil, p.redirect.ToUrl, target, match))
+			if p.withQuery {
+				query = ""
+			}
+			break
+		}
+	}
+	if redirect == nil {
+		return nil
+	}
+	result := &RedirectResult{
+		Redirect:   redirect,
+		ToUrl:      toUrl,
+		StatusCode: getRedirectStatusCode(redirect.StatusCode),
+	}
+	if result.StatusCode == 410 {
+		result.ToUrl = ""
+	} else if redirect.KeepQuery == 1 && query != "" {
+		if strings.Contains(result.ToUrl, "?") {
+			result.ToUrl += "&" + query
+		} else {
+			result.ToUrl += "?" + query
 		}
-		redirects[fromUrl] = toUrl
 	}
 
-	w.MemCache.Set("redirects", redirects, 0)
-
-	return redirects
+	return result
 }
 
-func (w *Website) GetRedirectFromCache(fromUrl string) string {
-	redirects := w.GetCacheRedirects()
-
-	if val, ok := redirects[fromUrl]; ok {
-		return val
-	}
-	return ""
+// RecordRedirectHit 记录规则的命中次数和最后命中时间
+func (w *Website) RecordRedirectHit(id uint) {
+	w.DB.Model(&model.Redirect{}).Where("`id` = ?", id).UpdateColumns(map[string]interface{}{
+		"hits":          gorm.Expr("`hits` + 1"),
+		"last_hit_time": time.Now().Unix(),
+	})
 }
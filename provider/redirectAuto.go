@@ -0,0 +1,167 @@
+package provider
+
+import (
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+)
+
+// linkSnapshot 链接调整前记录的旧链接，调整后与新链接对比生成跳转
+type linkSnapshot struct {
+	archives   map[uint]string
+	categories map[uint]string
+}
+
+// AddAutoRedirect 链接发生变化后自动添加301跳转，并清理缓存
+func (w *Website) AddAutoRedirect(oldLink, newLink string) {
+	if w.addAutoRedirect(oldLink, newLink) {
+		w.DeleteCacheRedirects()
+	}
+}
+
+func (w *Website) addAutoRedirect(oldLink, newLink string) bool {
+	return w.addRedirect(GetRedirectPath(oldLink), GetRedirectPath(newLink), 301)
+}
+
+// addRedirect 添加跳转时合并跳转链：原来跳到旧链接的规则直接跳到新链接，
+// 新链接上原有的跳转规则会被删除，避免形成循环
+func (w *Website) addRedirect(fromUrl, toUrl string, statusCode int) bool {
+	statusCode = getRedirectStatusCode(statusCode)
+	if statusCode == 410 {
+		toUrl = ""
+	} else if toUrl == "" || fromUrl == toUrl {
+		return false
+	}
+	if fromUrl == "" || fromUrl == "/" || len(fromUrl) > 190 {
+		return false
+	}
+	if toUrl != "" {
+		w.DB.Unscoped().Where("`from_url` = ? and `match_type` = ?", toUrl, config.RedirectMatchExact).Delete(&model.Redirect{})
+		w.DB.Model(&model.Redirect{}).Where("`to_url` = ? and `match_type` = ?", fromUrl, config.RedirectMatchExact).UpdateColumn("to_url", toUrl)
+	}
+	// from_url 是唯一索引，已删除的规则也需要清理
+	w.DB.Unscoped().Where("`from_url` = ?", fromUrl).Delete(&model.Redirect{})
+	redirect := model.Redirect{
+		FromUrl:    fromUrl,
+		ToUrl:      toUrl,
+		MatchType:  config.RedirectMatchExact,
+		StatusCode: statusCode,
+	}
+
+	return w.DB.Create(&redirect).Error == nil
+}
+
+// snapshotCategoryLinks 记录分类、下级分类以及其中文档的当前链接
+func (w *Website) snapshotCategoryLinks(categoryId uint) *linkSnapshot {
+	snapshot := &linkSnapshot{
+		archives:   map[uint]string{},
+		categories: map[uint]string{},
+	}
+	categoryIds := append([]uint{categoryId}, w.GetSubCategoryIds(categoryId, nil)...)
+	for _, id := range categoryIds {
+		category := w.GetCategoryFromCache(id)
+		if category != nil {
+			snapshot.categories[id] = w.GetUrl("category", category, 0)
+		}
+	}
+	w.eachArchives(func(archive *model.Archive) {
+		snapshot.archives[archive.Id] = w.GetUrl("archive", archive, 0)
+	}, "`category_id` IN (?)", categoryIds)
+
+	return snapshot
+}
+
+// snapshotArchiveLinks 记录文档的当前链接
+func (w *Website) snapshotArchiveLinks(ids []uint) *linkSnapshot {
+	snapshot := &linkSnapshot{
+		archives: map[uint]string{},
+	}
+	w.eachArchives(func(archive *model.Archive) {
+		snapshot.archives[archive.Id] = w.GetUrl("archive", archive, 0)
+	}, "`id` IN (?)", ids)
+
+	return snapshot
+}
+
+// redirectChangedLinks 对比快照中的旧链接和现在的链接，变化的添加跳转
+func (w *Website) redirectChangedLinks(snapshot *linkSnapshot) int {
+	var total int
+	for id, oldLink := range snapshot.categories {
+		category := w.GetCategoryFromCache(id)
+		if category != nil && w.addAutoRedirect(oldLink, w.GetUrl("category", category, 0)) {
+			total++
+		}
+	}
+	if len(snapshot.archives) > 0 {
+		ids := make([]uint, 0, len(snapshot.archives))
+		for id := range snapshot.archives {
+			ids = append(ids, id)
+		}
+		w.eachArchives(func(archive *model.Archive) {
+			if w.addAutoRedirect(snapshot.archives[archive.Id], w.GetUrl("archive", archive, 0)) {
+				total++
+			}
+		}, "`id` IN (?)", ids)
+	}
+	if total > 0 {
+		w.DeleteCacheRedirects()
+	}
+
+	return total
+}
+
+// RegenerateRedirectsFromRewrite 伪静态规则调整后，按旧规则生成所有文档、分类、标签和模型首页的链接，与现在的链接不同的添加跳转
+func (w *Website) RegenerateRedirectsFromRewrite(oldSetting config.PluginRewriteConfig) int {
+	oldPatten := GetRewritePattenBySetting(oldSetting)
+	var total int
+	compare := func(match string, data interface{}) {
+		if w.addAutoRedirect(w.getUrlByPatten(oldPatten, match, data, 0), w.GetUrl(match, data, 0)) {
+			total++
+		}
+	}
+	modules := w.GetCacheModules()
+	for i := range modules {
+		compare("archiveIndex", &modules[i])
+	}
+	categories := w.GetCacheCategories()
+	for i := range categories {
+		compare("category", &categories[i])
+	}
+	var lastId uint
+	for {
+		var tags []*model.Tag
+		w.DB.Where("`id` > ?", lastId).Order("`id` asc").Limit(1000).Find(&tags)
+		if len(tags) == 0 {
+			break
+		}
+		for _, tag := range tags {
+			compare("tag", tag)
+		}
+		lastId = tags[len(tags)-1].Id
+	}
+	w.eachArchives(func(archive *model.Archive) {
+		compare("archive", archive)
+	}, "")
+	w.DeleteCacheRedirects()
+
+	return total
+}
+
+// eachArchives 分批遍历文档，只读取生成链接需要的字段
+func (w *Website) eachArchives(fn func(archive *model.Archive), query string, args ...interface{}) {
+	var lastId uint
+	for {
+		var archives []*model.Archive
+		tx := w.DB.Model(&model.Archive{}).Select("id", "url_token", "fixed_link", "category_id", "module_id", "created_time", "title")
+		if query != "" {
+			tx = tx.Where(query, args...)
+		}
+		tx.Where("`id` > ?", lastId).Order("`id` asc").Limit(1000).Find(&archives)
+		if len(archives) == 0 {
+			break
+		}
+		for _, archive := range archives {
+			fn(archive)
+		}
+		lastId = archives[len(archives)-1].Id
+	}
+}
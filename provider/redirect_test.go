@@ -0,0 +1,96 @@
+package provider
+
+import (
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"testing"
+)
+
+func TestCompileRedirectPattern(t *testing.T) {
+	cases := []struct {
+		fromUrl   string
+		matchType int
+		target    string
+		matched   bool
+	}{
+		{"/news/*", config.RedirectMatchWildcard, "/news/123.html", true},
+		{"/news/*", config.RedirectMatchWildcard, "/old/news/123.html", false},
+		{"/a.html", config.RedirectMatchWildcard, "/aXhtml", false},
+		{"https://www.example.com/old/*", config.RedirectMatchWildcard, "/old/1", true},
+		{`/item-(\d+)\.html`, config.RedirectMatchRegex, "/item-12.html", true},
+		{`/item-(\d+)\.html`, config.RedirectMatchRegex, "/item-12.html.bak", false},
+		{`/a|/b`, config.RedirectMatchRegex, "/a/b", false},
+	}
+	for _, c := range cases {
+		re, err := CompileRedirectPattern(c.fromUrl, c.matchType)
+		if err != nil {
+			t.Fatalf("%s: %v", c.fromUrl, err)
+		}
+		if re.MatchString(c.target) != c.matched {
+			t.Errorf("%s match %s: expected %v", c.fromUrl, c.target, c.matched)
+		}
+	}
+
+	if _, err := CompileRedirectPattern(`/item-(\d+`, config.RedirectMatchRegex); err == nil {
+		t.Errorf("expected error for invalid regex")
+	}
+}
+
+func TestMatchRedirect(t *testing.T) {
+	rules := newRedirectRules([]*model.Redirect{
+		{Model: model.Model{Id: 1}, FromUrl: "/about.html", ToUrl: "/page/about.html", MatchType: config.RedirectMatchExact},
+		{Model: model.Model{Id: 2}, FromUrl: "/list.html?page=2", ToUrl: "/list-2.html", MatchType: config.RedirectMatchExact},
+		{Model: model.Model{Id: 3}, FromUrl: "/news/*/*.html", ToUrl: "/article/$1-${2}.html", MatchType: config.RedirectMatchWildcard, StatusCode: 302},
+		{Model: model.Model{Id: 4}, FromUrl: "/search?q=*", ToUrl: "/tags/$1", MatchType: config.RedirectMatchWildcard, KeepQuery: 1},
+		{Model: model.Model{Id: 5}, FromUrl: `/item-(\d+)\.html`, ToUrl: "/product/${1}.html?from=old", MatchType: config.RedirectMatchRegex, KeepQuery: 1},
+		{Model: model.Model{Id: 6}, FromUrl: "/about*", ToUrl: "/other", MatchType: config.RedirectMatchWildcard},
+		{Model: model.Model{Id: 7}, FromUrl: "/static/*", ToUrl: "/files/$1", MatchType: config.RedirectMatchWildcard},
+		{Model: model.Model{Id: 8}, FromUrl: "/static/old/*", ToUrl: "/archive/$1", MatchType: config.RedirectMatchWildcard},
+		{Model: model.Model{Id: 9}, FromUrl: "/removed/*", ToUrl: "/anywhere", MatchType: config.RedirectMatchWildcard, StatusCode: 410, KeepQuery: 1},
+	})
+
+	cases := []struct {
+		uri        string
+		id         uint
+		toUrl      string
+		statusCode int
+	}{
+		// 完全匹配优先于通配符
+		{"/about.html", 1, "/page/about.html", 301},
+		// 未开启保留参数时，完全匹配只按路径匹配并丢弃参数
+		{"/about.html?from=nav", 1, "/page/about.html", 301},
+		// 带参数的完全匹配规则
+		{"/list.html?page=2", 2, "/list-2.html", 301},
+		{"/list.html?page=3", 0, "", 0},
+		// 通配符捕获
+		{"/news/tech/123.html", 3, "/article/tech-123.html", 302},
+		// 规则包含参数时匹配完整链接，参数已被规则使用，不再追加
+		{"/search?q=golang", 4, "/tags/golang", 301},
+		// 正则捕获，保留参数时追加到已有参数之后
+		{"/item-42.html", 5, "/product/42.html?from=old", 301},
+		{"/item-42.html?utm=a&b=1", 5, "/product/42.html?from=old&utm=a&b=1", 301},
+		// 未被完全匹配的链接继续匹配通配符
+		{"/about-us.html", 6, "/other", 301},
+		// 按传入的优先级顺序匹配，先匹配的规则生效
+		{"/static/old/a.png", 7, "/files/old/a.png", 301},
+		// 410 不返回跳转链接
+		{"/removed/a.html?x=1", 9, "", 410},
+		{"/nothing.html", 0, "", 0},
+	}
+	for _, c := range cases {
+		result := rules.match(c.uri)
+		if c.id == 0 {
+			if result != nil {
+				t.Errorf("%s: expected no match, got rule %d", c.uri, result.Redirect.Id)
+			}
+			continue
+		}
+		if result == nil {
+			t.Errorf("%s: expected rule %d, got no match", c.uri, c.id)
+			continue
+		}
+		if result.Redirect.Id != c.id || result.ToUrl != c.toUrl || result.StatusCode != c.statusCode {
+			t.Errorf("%s: expected %d %s %d, got %d %s %d", c.uri, c.id, c.toUrl, c.statusCode, result.Redirect.Id, result.ToUrl, result.StatusCode)
+		}
+	}
+}
@@ -295,3 +295,62 @@ func (w *Website) ParsePatten(focus bool) *RewritePatten {
 
 	return w.parsedPatten
 }
+
+// GetRewritePattenBySetting 根据伪静态配置生成一份独立的规则，只解析生成链接需要的变量，不影响当前使用的规则
+func GetRewritePattenBySetting(setting config.PluginRewriteConfig) *RewritePatten {
+	var base RewritePatten
+	switch setting.Mode {
+	case config.RewriteNumberMode:
+		base = rewriteNumberModePatten
+	case config.RewriteStringMode1:
+		base = rewriteStringMode1Patten
+	case config.RewriteStringMode2:
+		base = rewriteStringMode2Patten
+	case config.RewriteStringMode3:
+		base = rewriteStringMode3Patten
+	case config.RewritePattenMode:
+		base = *parseRewritePatten(setting.Patten)
+	default:
+		base = rewriteNumberModePatten
+	}
+	parsed := &RewritePatten{
+		Archive:      base.Archive,
+		Category:     base.Category,
+		Page:         base.Page,
+		ArchiveIndex: base.ArchiveIndex,
+		TagIndex:     base.TagIndex,
+		Tag:          base.Tag,
+	}
+	parsed.ArchiveTags = parseRewriteTags(parsed.Archive)
+	parsed.CategoryTags = parseRewriteTags(parsed.Category)
+	parsed.PageTags = parseRewriteTags(parsed.Page)
+	parsed.ArchiveIndexTags = parseRewriteTags(parsed.ArchiveIndex)
+	parsed.TagIndexTags = parseRewriteTags(parsed.TagIndex)
+	parsed.TagTags = parseRewriteTags(parsed.Tag)
+
+	return parsed
+}
+
+// parseRewriteTags 解析规则中的变量，与 ParsePatten 的解析方式一致
+func parseRewriteTags(item string) map[int]string {
+	tags := map[int]string{}
+	n := 0
+	str := ""
+	for _, v := range item {
+		if v == '{' {
+			n++
+			str += string(v)
+		} else if v == '}' {
+			str = strings.TrimLeft(str, "{")
+			if str == "page" {
+				n++
+			}
+			tags[n] = str
+			str = ""
+		} else if str != "" {
+			str += string(v)
+		}
+	}
+
+	return tags
+}
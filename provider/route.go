@@ -0,0 +1,197 @@
+package provider
+
+import (
+	"fmt"
+	"kandaoni.com/anqicms/config"
+	"regexp"
+	"strings"
+)
+
+// ParseRoute 根据伪静态规则解析访问路径，路径不需要以 / 开头
+func (w *Website) ParseRoute(paramValue string) (map[string]string, bool) {
+	//这里总共有6条正则规则，需要逐一匹配
+	// 由于用户可能会采用相同的配置，因此这里需要尝试多次读取
+	matchMap := map[string]string{}
+	paramValue = strings.TrimLeft(strings.TrimPrefix(paramValue, strings.Trim(w.BaseURI, "/")), "/")
+	// index
+	if paramValue == "" {
+		matchMap["match"] = "index"
+		return matchMap, true
+	}
+	// 静态资源直接返回
+	if strings.HasPrefix(paramValue, "uploads/") ||
+		strings.HasPrefix(paramValue, "static/") ||
+		strings.HasPrefix(paramValue, "system/") {
+		return matchMap, true
+	}
+	// 如果匹配到固化链接，则直接返回
+	archiveId := w.GetFixedLinkFromCache("/" + paramValue)
+	if archiveId > 0 {
+		matchMap["match"] = "archive"
+		matchMap["id"] = fmt.Sprintf("%d", archiveId)
+		return matchMap, true
+	}
+	// 搜索
+	if paramValue == "search" {
+		matchMap["match"] = "search"
+		return matchMap, true
+	}
+	rewritePattern := w.ParsePatten(false)
+	//archivePage
+	reg := regexp.MustCompile(rewritePattern.ArchiveIndexRule)
+	match := reg.FindStringSubmatch(paramValue)
+	if len(match) > 0 {
+		matchMap["match"] = "archiveIndex"
+		for i, v := range match {
+			key := rewritePattern.ArchiveIndexTags[i]
+			if i == 0 {
+				key = "route"
+			}
+			matchMap[key] = v
+		}
+		// 这个规则可能与下面的冲突，因此检查一遍
+		module := w.GetModuleFromCacheByToken(matchMap["module"])
+		if module != nil {
+			return matchMap, true
+		}
+		matchMap = map[string]string{}
+	}
+	// people
+	reg = regexp.MustCompile("people/([\\d]+).html")
+	match = reg.FindStringSubmatch(paramValue)
+
+	if len(match) > 1 {
+		matchMap["match"] = "user"
+		for i, v := range match {
+			key := "id"
+			if i == 0 {
+				key = "route"
+			}
+			matchMap[key] = v
+		}
+		return matchMap, true
+	}
+	//tagIndex
+	reg = regexp.MustCompile(rewritePattern.TagIndexRule)
+	match = reg.FindStringSubmatch(paramValue)
+	if len(match) > 1 {
+		matchMap["match"] = "tagIndex"
+		for i, v := range match {
+			key := rewritePattern.TagIndexTags[i]
+			if i == 0 {
+				key = "route"
+			}
+			matchMap[key] = v
+		}
+		return matchMap, true
+	}
+	//tag
+	reg = regexp.MustCompile(rewritePattern.TagRule)
+	match = reg.FindStringSubmatch(paramValue)
+	if len(match) > 1 {
+		matchMap["match"] = "tag"
+		for i, v := range match {
+			key := rewritePattern.TagTags[i]
+			if i == 0 {
+				key = "route"
+			}
+			matchMap[key] = v
+		}
+		return matchMap, true
+	}
+	//page
+	reg = regexp.MustCompile(rewritePattern.PageRule)
+	match = reg.FindStringSubmatch(paramValue)
+	if len(match) > 1 {
+		matchMap["match"] = "page"
+		for i, v := range match {
+			key := rewritePattern.PageTags[i]
+			if i == 0 {
+				key = "route"
+			}
+			matchMap[key] = v
+		}
+		if matchMap["filename"] != "" {
+			// 这个规则可能与下面的冲突，因此检查一遍
+			category := w.GetCategoryFromCacheByToken(matchMap["filename"])
+			if category != nil && category.Type == config.CategoryTypePage {
+				return matchMap, true
+			}
+		} else {
+			return matchMap, true
+		}
+		matchMap = map[string]string{}
+	}
+	//category
+	reg = regexp.MustCompile(rewritePattern.CategoryRule)
+	match = reg.FindStringSubmatch(paramValue)
+	if len(match) > 1 {
+		matchMap["match"] = "category"
+		for i, v := range match {
+			key := rewritePattern.CategoryTags[i]
+			if i == 0 {
+				key = "route"
+			}
+			matchMap[key] = v
+		}
+		if matchMap["catname"] != "" {
+			matchMap["filename"] = matchMap["catname"]
+		}
+		if matchMap["multicatname"] != "" {
+			chunkCatNames := strings.Split(matchMap["multicatname"], "/")
+			matchMap["filename"] = chunkCatNames[len(chunkCatNames)-1]
+		}
+		if matchMap["module"] != "" {
+			// 需要先验证是否是module
+			module := w.GetModuleFromCacheByToken(matchMap["module"])
+			if module != nil {
+				if matchMap["filename"] != "" {
+					// 这个规则可能与下面的冲突，因此检查一遍
+					category := w.GetCategoryFromCacheByToken(matchMap["filename"])
+					if category != nil && category.Type != config.CategoryTypePage {
+						return matchMap, true
+					}
+				} else {
+					return matchMap, true
+				}
+			}
+		} else {
+			if matchMap["filename"] != "" {
+				// 这个规则可能与下面的冲突，因此检查一遍
+				category := w.GetCategoryFromCacheByToken(matchMap["filename"])
+				if category != nil && category.Type != config.CategoryTypePage {
+					return matchMap, true
+				}
+			} else {
+				return matchMap, true
+			}
+		}
+		matchMap = map[string]string{}
+	}
+	//最后archive
+	reg = regexp.MustCompile(rewritePattern.ArchiveRule)
+	match = reg.FindStringSubmatch(paramValue)
+	if len(match) > 1 {
+		matchMap["match"] = "archive"
+		for i, v := range match {
+			key := rewritePattern.ArchiveTags[i]
+			if i == 0 {
+				key = "route"
+			}
+			matchMap[key] = v
+		}
+		if matchMap["module"] != "" {
+			// 需要先验证是否是module
+			module := w.GetModuleFromCacheByToken(matchMap["module"])
+			if module != nil {
+				return matchMap, true
+			}
+		} else {
+			return matchMap, true
+		}
+	}
+
+	//不存在，定义到notfound
+	matchMap["match"] = "notfound"
+	return matchMap, true
+}
@@ -1,6 +1,7 @@
 package provider
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -78,7 +79,29 @@ func (w *Website) GetLastSendmailList() ([]*MailLog, error) {
 	return mailLogs, nil
 }
 
-func (w *Website) SendMail(subject, content string) error {
+// MailAttachment 邮件附件
+type MailAttachment struct {
+	FileName    string
+	ContentType string
+	Content     []byte
+}
+
+// SendMail 发送邮件，未指定收件人时，发送给插件中配置的收件人
+func (w *Website) SendMail(subject, content string, recipients ...string) error {
+	return w.SendMailWithAttachments(subject, content, nil, recipients...)
+}
+
+// SendMailWithAttachments 发送带附件的邮件
+func (w *Website) SendMailWithAttachments(subject, content string, attachments []MailAttachment, recipients ...string) error {
+	return w.sendMail(subject, content, "", attachments, recipients...)
+}
+
+// SendHtmlMail 发送 HTML 邮件，textContent 是不支持 HTML 的客户端显示的纯文本内容
+func (w *Website) SendHtmlMail(subject, htmlContent, textContent string, recipients ...string) error {
+	return w.sendMail(subject, textContent, htmlContent, nil, recipients...)
+}
+
+func (w *Website) sendMail(subject, content, htmlContent string, attachments []MailAttachment, recipients ...string) error {
 	setting := w.PluginSendmail
 	port := setting.Port
 	if port == 0 {
@@ -106,8 +129,7 @@ func (w *Website) SendMail(subject, content string) error {
 	}
 	email.Password = setting.Password
 
-	var recipients []string
-	if setting.Recipient != "" {
+	if len(recipients) == 0 && setting.Recipient != "" {
 		tmp := strings.Split(setting.Recipient, ",")
 		for _, v := range tmp {
 			v = strings.TrimSpace(v)
@@ -123,6 +145,13 @@ func (w *Website) SendMail(subject, content string) error {
 	email.To = recipients
 	email.Subject = subject
 	email.Text = content
+	email.HTML = htmlContent
+	for _, attachment := range attachments {
+		_, err := email.Attach(bytes.NewReader(attachment.Content), attachment.FileName, attachment.ContentType)
+		if err != nil {
+			return err
+		}
+	}
 
 	if err := email.Send(); err != nil {
 		w.logMailError(subject, err.Error())
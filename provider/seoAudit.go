@@ -0,0 +1,478 @@
+package provider
+
+import (
+	"errors"
+	"github.com/PuerkitoBio/goquery"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/response"
+	"math"
+	"regexp"
+	"strings"
+	"time"
+	"unicode"
+)
+
+// seoIssue 检查项，Penalty 是出现问题时扣掉的分数
+type seoIssue struct {
+	Code    string
+	Name    string
+	Penalty int
+}
+
+// seoIssues 所有检查项，汇总时按这个顺序输出
+var seoIssues = []seoIssue{
+	{"seo_title_missing", "未设置SEO标题", 5},
+	{"title_short", "标题过短", 10},
+	{"title_long", "标题过长", 10},
+	{"title_duplicate", "标题与其他内容重复", 15},
+	{"description_missing", "未填写简介", 15},
+	{"description_short", "简介过短", 5},
+	{"description_long", "简介过长", 5},
+	{"description_duplicate", "简介与其他内容重复", 10},
+	{"keywords_missing", "未填写关键词", 5},
+	{"keyword_not_in_title", "标题中没有包含关键词", 5},
+	{"keyword_not_in_content", "内容中没有包含关键词", 5},
+	{"image_no_alt", "图片缺少alt文字", 5},
+	{"heading_h1", "内容中使用了H1标题", 5},
+	{"heading_skip", "小标题层级不连续", 5},
+	{"heading_missing", "长内容没有小标题", 5},
+	{"no_internal_link", "没有内链", 10},
+	{"thin_content", "内容过少", 15},
+}
+
+const (
+	// 标题、简介的显示宽度，中文按2计算
+	seoTitleMinWidth       = 20
+	seoTitleMaxWidth       = 70
+	seoDescriptionMinWidth = 80
+	seoDescriptionMaxWidth = 320
+	// 少于这个字数的内容视为内容过少
+	seoThinContentWords = 300
+	// 超过这个字数的内容应该使用小标题
+	seoHeadingWords = 1500
+)
+
+var seoKeywordSplitRe = regexp.MustCompile(`[,，、;；|\s]+`)
+
+// seoAuditContent 参与检查的页面信息
+type seoAuditContent struct {
+	itemType    string
+	itemId      uint
+	title       string
+	seoTitle    string
+	keywords    string
+	description string
+	content     string
+	// 分类页可以没有内容，没有内容时不检查正文
+	optionalContent bool
+}
+
+// AuditArchive 检查文档并保存结果，content 是文档的正文
+func (w *Website) AuditArchive(archive *model.Archive, content string) *model.SeoAudit {
+	return w.saveSeoAudit(&seoAuditContent{
+		itemType:    "archive",
+		itemId:      archive.Id,
+		title:       archive.Title,
+		seoTitle:    archive.SeoTitle,
+		keywords:    archive.Keywords,
+		description: archive.Description,
+		content:     content,
+	})
+}
+
+// AuditCategory 检查分类或单页并保存结果
+func (w *Website) AuditCategory(category *model.Category) *model.SeoAudit {
+	return w.saveSeoAudit(&seoAuditContent{
+		itemType:        "category",
+		itemId:          category.Id,
+		title:           category.Title,
+		seoTitle:        category.SeoTitle,
+		keywords:        category.Keywords,
+		description:     category.Description,
+		content:         category.Content,
+		optionalContent: category.Type != config.CategoryTypePage,
+	})
+}
+
+func (w *Website) saveSeoAudit(item *seoAuditContent) *model.SeoAudit {
+	if w.DB == nil {
+		return nil
+	}
+	audit := w.checkSeoAudit(item)
+	var exist model.SeoAudit
+	if w.DB.Where("`item_type` = ? and `item_id` = ?", item.itemType, item.itemId).Take(&exist).Error == nil {
+		audit.Id = exist.Id
+		audit.CreatedTime = exist.CreatedTime
+	}
+	w.DB.Save(audit)
+	w.fillSeoAuditMessages(audit)
+
+	return audit
+}
+
+// checkSeoAudit 逐项检查，按问题扣分
+func (w *Website) checkSeoAudit(item *seoAuditContent) *model.SeoAudit {
+	audit := &model.SeoAudit{
+		ItemType: item.itemType,
+		ItemId:   item.itemId,
+		Title:    item.title,
+		Issues:   []string{},
+	}
+	issues := map[string]struct{}{}
+	addIssue := func(code string) {
+		issues[code] = struct{}{}
+	}
+
+	title := item.title
+	if item.seoTitle != "" {
+		title = item.seoTitle
+	} else {
+		addIssue("seo_title_missing")
+	}
+	if width := seoTextWidth(title); width < seoTitleMinWidth {
+		addIssue("title_short")
+	} else if width > seoTitleMaxWidth {
+		addIssue("title_long")
+	}
+	description := strings.TrimSpace(item.description)
+	if description == "" {
+		addIssue("description_missing")
+	} else if width := seoTextWidth(description); width < seoDescriptionMinWidth {
+		addIssue("description_short")
+	} else if width > seoDescriptionMaxWidth {
+		addIssue("description_long")
+	}
+	if w.seoDuplicated(item.itemType, item.itemId, "title", item.title) {
+		addIssue("title_duplicate")
+	}
+	if description != "" && w.seoDuplicated(item.itemType, item.itemId, "description", item.description) {
+		addIssue("description_duplicate")
+	}
+
+	hasContent := strings.TrimSpace(item.content) != ""
+	var text string
+	if hasContent {
+		doc, err := goquery.NewDocumentFromReader(strings.NewReader(item.content))
+		if err == nil {
+			text = doc.Text()
+			doc.Find("img").EachWithBreak(func(i int, s *goquery.Selection) bool {
+				if strings.TrimSpace(s.AttrOr("alt", "")) == "" {
+					addIssue("image_no_alt")
+					return false
+				}
+				return true
+			})
+			// 页面标题已经是 H1，正文从 H2 开始
+			lastLevel := 1
+			headings := doc.Find("h1,h2,h3,h4,h5,h6")
+			headings.Each(func(i int, s *goquery.Selection) {
+				level := int(goquery.NodeName(s)[1] - '0')
+				if level == 1 {
+					addIssue("heading_h1")
+				} else if level > lastLevel+1 {
+					addIssue("heading_skip")
+				}
+				lastLevel = level
+			})
+			doc.Find("a[href]").Each(func(i int, s *goquery.Selection) {
+				href := strings.TrimSpace(s.AttrOr("href", ""))
+				if href != "" && !strings.HasPrefix(href, "#") && w.isInternalLink(href) {
+					audit.InternalLinks++
+				}
+			})
+			audit.WordCount = seoWordCount(text)
+			if headings.Length() == 0 && audit.WordCount > seoHeadingWords {
+				addIssue("heading_missing")
+			}
+		}
+	}
+	// 锚文本插件替换的链接可能还没写入内容
+	var anchorCount int64
+	w.DB.Model(&model.AnchorData{}).Where("`item_type` = ? and `item_id` = ?", item.itemType, item.itemId).Count(&anchorCount)
+	if int(anchorCount) > audit.InternalLinks {
+		audit.InternalLinks = int(anchorCount)
+	}
+	if hasContent || !item.optionalContent {
+		if audit.WordCount < seoThinContentWords {
+			addIssue("thin_content")
+		}
+		if audit.InternalLinks == 0 {
+			addIssue("no_internal_link")
+		}
+	}
+
+	var keywords []string
+	for _, v := range seoKeywordSplitRe.Split(strings.ToLower(item.keywords), -1) {
+		if v != "" {
+			keywords = append(keywords, v)
+		}
+	}
+	if len(keywords) == 0 {
+		addIssue("keywords_missing")
+	} else {
+		if !seoContainsAny(strings.ToLower(title+" "+item.title), keywords) {
+			addIssue("keyword_not_in_title")
+		}
+		if (hasContent || !item.optionalContent) && !seoContainsAny(strings.ToLower(text), keywords) {
+			addIssue("keyword_not_in_content")
+		}
+	}
+
+	score := 100
+	for _, v := range seoIssues {
+		if _, ok := issues[v.Code]; ok {
+			audit.Issues = append(audit.Issues, v.Code)
+			score -= v.Penalty
+		}
+	}
+	if score < 0 {
+		score = 0
+	}
+	audit.Score = score
+
+	return audit
+}
+
+// seoDuplicated 同类内容中是否有相同的标题或简介
+func (w *Website) seoDuplicated(itemType string, itemId uint, column, value string) bool {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return false
+	}
+	var total int64
+	if itemType == "archive" {
+		w.DB.Model(&model.Archive{}).Where("`"+column+"` = ? and `id` != ?", value, itemId).Count(&total)
+	} else {
+		w.DB.Model(&model.Category{}).Where("`"+column+"` = ? and `id` != ?", value, itemId).Count(&total)
+	}
+
+	return total > 0
+}
+
+func seoContainsAny(text string, keywords []string) bool {
+	for _, v := range keywords {
+		if strings.Contains(text, v) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// seoTextWidth 文字的显示宽度，中日韩文字按2计算
+func seoTextWidth(text string) int {
+	var width int
+	for _, r := range strings.TrimSpace(text) {
+		if r > 0x2E80 {
+			width += 2
+		} else {
+			width++
+		}
+	}
+
+	return width
+}
+
+// seoWordCount 字数，中文每个字算一个，英文和数字按单词计算
+func seoWordCount(text string) int {
+	var count int
+	inWord := false
+	for _, r := range text {
+		if unicode.Is(unicode.Han, r) {
+			count++
+			inWord = false
+		} else if unicode.IsLetter(r) || unicode.IsDigit(r) {
+			if !inWord {
+				count++
+				inWord = true
+			}
+		} else {
+			inWord = false
+		}
+	}
+
+	return count
+}
+
+func (w *Website) fillSeoAuditMessages(audit *model.SeoAudit) {
+	audit.Messages = make([]string, 0, len(audit.Issues))
+	for _, code := range audit.Issues {
+		for _, v := range seoIssues {
+			if v.Code == code {
+				audit.Messages = append(audit.Messages, w.Lang(v.Name))
+				break
+			}
+		}
+	}
+}
+
+// AttachArchiveSeoAudits 文档列表附带SEO检查结果，还没有检查过的文档即时检查
+func (w *Website) AttachArchiveSeoAudits(archives []*model.Archive) {
+	if len(archives) == 0 {
+		return
+	}
+	ids := make([]uint, 0, len(archives))
+	for _, v := range archives {
+		ids = append(ids, v.Id)
+	}
+	var audits []*model.SeoAudit
+	w.DB.Where("`item_type` = ? and `item_id` IN (?)", "archive", ids).Find(&audits)
+	auditMap := map[uint]*model.SeoAudit{}
+	for _, v := range audits {
+		w.fillSeoAuditMessages(v)
+		auditMap[v.ItemId] = v
+	}
+	for _, archive := range archives {
+		audit, ok := auditMap[archive.Id]
+		if !ok {
+			var content string
+			if archiveData, err := w.GetArchiveDataById(archive.Id); err == nil {
+				content = archiveData.Content
+			}
+			audit = w.AuditArchive(archive, content)
+		}
+		archive.SeoAudit = audit
+	}
+}
+
+func (w *Website) DeleteSeoAudit(itemType string, itemId uint) {
+	if w.DB == nil {
+		return
+	}
+	w.DB.Unscoped().Where("`item_type` = ? and `item_id` = ?", itemType, itemId).Delete(&model.SeoAudit{})
+}
+
+// StartSeoAudit 后台重新检查全站的文档和分类
+func (w *Website) StartSeoAudit() error {
+	if w.DB == nil {
+		return errors.New(w.Lang("站点未初始化"))
+	}
+	w.seoAuditMutex.Lock()
+	if w.seoAuditRunning {
+		w.seoAuditMutex.Unlock()
+		return errors.New(w.Lang("正在检查中，请稍后再试"))
+	}
+	w.seoAuditRunning = true
+	w.seoAuditMutex.Unlock()
+	go func() {
+		defer func() {
+			w.seoAuditMutex.Lock()
+			w.seoAuditRunning = false
+			w.seoAuditMutex.Unlock()
+		}()
+		w.runSeoAudit()
+	}()
+
+	return nil
+}
+
+func (w *Website) runSeoAudit() {
+	startTime := time.Now().Unix()
+	var lastId uint
+	for {
+		var archives []*model.Archive
+		w.DB.Where("`id` > ?", lastId).Order("`id` asc").Limit(100).Find(&archives)
+		if len(archives) == 0 {
+			break
+		}
+		ids := make([]uint, 0, len(archives))
+		for _, v := range archives {
+			ids = append(ids, v.Id)
+		}
+		var archiveData []*model.ArchiveData
+		w.DB.Where("`id` IN (?)", ids).Find(&archiveData)
+		contents := map[uint]string{}
+		for _, v := range archiveData {
+			contents[v.Id] = v.Content
+		}
+		for _, archive := range archives {
+			w.AuditArchive(archive, contents[archive.Id])
+		}
+		lastId = archives[len(archives)-1].Id
+	}
+	var categories []*model.Category
+	w.DB.Find(&categories)
+	for _, category := range categories {
+		w.AuditCategory(category)
+	}
+	// 本次没有检查到的是已删除的内容
+	w.DB.Unscoped().Where("`updated_time` < ?", startTime).Delete(&model.SeoAudit{})
+}
+
+// RunSeoAudit 计划任务每天重新检查，标题、简介重复的情况会随其他内容的修改变化
+func (w *Website) RunSeoAudit() {
+	_ = w.StartSeoAudit()
+}
+
+// GetSeoAuditSummary 全站的平均分、分数分布和各问题的数量
+func (w *Website) GetSeoAuditSummary() *response.SeoAuditSummary {
+	w.seoAuditMutex.Lock()
+	summary := &response.SeoAuditSummary{
+		Running: w.seoAuditRunning,
+		Issues:  make([]*response.SeoIssueCount, 0, len(seoIssues)),
+	}
+	w.seoAuditMutex.Unlock()
+	var avg struct {
+		Total int64
+		Score float64
+	}
+	w.DB.Model(&model.SeoAudit{}).Select("count(1) AS total, avg(`score`) AS score").Scan(&avg)
+	summary.Total = avg.Total
+	summary.AverageScore = math.Round(avg.Score*10) / 10
+	w.DB.Model(&model.SeoAudit{}).Where("`score` >= 80").Count(&summary.Good)
+	w.DB.Model(&model.SeoAudit{}).Where("`score` >= 60 and `score` < 80").Count(&summary.Fair)
+	w.DB.Model(&model.SeoAudit{}).Where("`score` < 60").Count(&summary.Poor)
+	counts := map[string]int64{}
+	var lastId uint
+	for {
+		var audits []*model.SeoAudit
+		w.DB.Select("id", "issues").Where("`id` > ?", lastId).Order("`id` asc").Limit(1000).Find(&audits)
+		if len(audits) == 0 {
+			break
+		}
+		for _, v := range audits {
+			for _, code := range v.Issues {
+				counts[code]++
+			}
+		}
+		lastId = audits[len(audits)-1].Id
+	}
+	for _, v := range seoIssues {
+		summary.Issues = append(summary.Issues, &response.SeoIssueCount{
+			Code:  v.Code,
+			Name:  w.Lang(v.Name),
+			Total: counts[v.Code],
+		})
+	}
+
+	return summary
+}
+
+// GetSeoAuditList 检查结果，分数低的在前
+func (w *Website) GetSeoAuditList(itemType, issue string, currentPage, pageSize int) ([]*model.SeoAudit, int64) {
+	var audits []*model.SeoAudit
+	var total int64
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if pageSize < 1 {
+		pageSize = 20
+	}
+	offset := (currentPage - 1) * pageSize
+	builder := w.DB.Model(&model.SeoAudit{})
+	if itemType != "" {
+		builder = builder.Where("`item_type` = ?", itemType)
+	}
+	if issue != "" {
+		// issues 以 {a,b} 的格式保存
+		builder = builder.Where("(`issues` = ? OR `issues` LIKE ? OR `issues` LIKE ? OR `issues` LIKE ?)",
+			"{"+issue+"}", "{"+issue+",%", "%,"+issue+",%", "%,"+issue+"}")
+	}
+	builder.Count(&total).Order("`score` asc, `id` desc").Limit(pageSize).Offset(offset).Find(&audits)
+	for _, v := range audits {
+		w.fillSeoAuditMessages(v)
+	}
+
+	return audits, total
+}
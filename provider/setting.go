@@ -34,8 +34,12 @@ const (
 	RetailerSettingKey    = "retailer"
 	UserSettingKey        = "user"
 	OrderSettingKey       = "order"
+	OauthSettingKey       = "oauth"
+	PointSettingKey       = "point"
+	CommentSettingKey     = "comment"
 	FulltextSettingKey    = "fulltext"
 	TitleImageSettingKey  = "title_image"
+	BrokenLinkSettingKey  = "broken_link"
 	AnqiSettingKey        = "anqi"
 
 	CollectorSettingKey = "collector"
@@ -65,8 +69,12 @@ func (w *Website) InitSetting() {
 	w.LoadRetailerSetting()
 	w.LoadUserSetting()
 	w.LoadOrderSetting()
+	w.LoadOauthSetting()
+	w.LoadPointSetting()
+	w.LoadCommentSetting()
 	w.LoadFulltextSetting()
 	w.LoadTitleImageSetting()
+	w.LoadBrokenLinkSetting()
 	w.LoadAnqiUser()
 
 	w.LoadCollectorSetting()
@@ -251,6 +259,18 @@ func (w *Website) LoadUserSetting() {
 	if w.PluginUser.DefaultGroupId == 0 {
 		w.PluginUser.DefaultGroupId = 1
 	}
+	if w.PluginUser.LoginMaxFailures <= 0 {
+		w.PluginUser.LoginMaxFailures = 5
+	}
+	if w.PluginUser.LoginIpMaxFailures <= 0 {
+		w.PluginUser.LoginIpMaxFailures = 20
+	}
+	if w.PluginUser.LoginLockMinutes <= 0 {
+		w.PluginUser.LoginLockMinutes = 15
+	}
+	if w.PluginUser.PasswordMinLength < 6 {
+		w.PluginUser.PasswordMinLength = 6
+	}
 }
 
 func (w *Website) LoadOrderSetting() {
@@ -264,6 +284,45 @@ func (w *Website) LoadOrderSetting() {
 	}
 }
 
+func (w *Website) LoadOauthSetting() {
+	value := w.GetSettingValue(OauthSettingKey)
+	if value != "" {
+		_ = json.Unmarshal([]byte(value), &w.PluginOauth)
+	}
+	if w.PluginOauth.Scopes == "" {
+		w.PluginOauth.Scopes = "openid profile email"
+	}
+	if w.PluginOauth.ClaimId == "" {
+		w.PluginOauth.ClaimId = "sub"
+	}
+}
+
+func (w *Website) LoadPointSetting() {
+	value := w.GetSettingValue(PointSettingKey)
+	if value != "" {
+		_ = json.Unmarshal([]byte(value), &w.PluginPoint)
+	}
+	if w.PluginPoint.DeductPercent <= 0 || w.PluginPoint.DeductPercent > 99 {
+		w.PluginPoint.DeductPercent = 50
+	}
+}
+
+func (w *Website) LoadCommentSetting() {
+	value := w.GetSettingValue(CommentSettingKey)
+	if value != "" {
+		_ = json.Unmarshal([]byte(value), &w.PluginComment)
+	}
+	if w.PluginComment.HoneypotField == "" {
+		w.PluginComment.HoneypotField = "homepage"
+	}
+	if w.PluginComment.HoldScore <= 0 || w.PluginComment.HoldScore > 100 {
+		w.PluginComment.HoldScore = 50
+	}
+	if w.PluginComment.SpamScore <= 0 || w.PluginComment.SpamScore > 100 {
+		w.PluginComment.SpamScore = 90
+	}
+}
+
 func (w *Website) LoadFulltextSetting() {
 	value := w.GetSettingValue(FulltextSettingKey)
 	if value != "" {
@@ -271,6 +330,19 @@ func (w *Website) LoadFulltextSetting() {
 	}
 }
 
+func (w *Website) LoadBrokenLinkSetting() {
+	value := w.GetSettingValue(BrokenLinkSettingKey)
+	if value != "" {
+		_ = json.Unmarshal([]byte(value), &w.PluginBrokenLink)
+	}
+	if w.PluginBrokenLink.Concurrency <= 0 || w.PluginBrokenLink.Concurrency > 20 {
+		w.PluginBrokenLink.Concurrency = 5
+	}
+	if w.PluginBrokenLink.Timeout <= 0 {
+		w.PluginBrokenLink.Timeout = 10
+	}
+}
+
 func (w *Website) LoadTitleImageSetting() {
 	value := w.GetSettingValue(TitleImageSettingKey)
 	if value != "" {
@@ -338,6 +410,13 @@ func (w *Website) LoadCollectorSetting() {
 	w.CollectorConfig.Language = collector.Language
 	w.CollectorConfig.InsertImage = collector.InsertImage
 	w.CollectorConfig.Images = collector.Images
+	w.CollectorConfig.DuplicateThreshold = collector.DuplicateThreshold
+	if w.CollectorConfig.DuplicateThreshold > 100 {
+		w.CollectorConfig.DuplicateThreshold = 100
+	} else if w.CollectorConfig.DuplicateThreshold > 0 && w.CollectorConfig.DuplicateThreshold < 50 {
+		// 相似度太低的会把正常内容也拦下来
+		w.CollectorConfig.DuplicateThreshold = 50
+	}
 
 	if w.CollectorConfig.Language == "" {
 		w.CollectorConfig.Language = config.LanguageZh
@@ -0,0 +1,232 @@
+package provider
+
+import (
+	"errors"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/request"
+)
+
+func (w *Website) GetShippingTemplates() []*model.ShippingTemplate {
+	var templates []*model.ShippingTemplate
+	w.DB.Order("id asc").Find(&templates)
+
+	return templates
+}
+
+func (w *Website) GetShippingTemplateById(id uint) (*model.ShippingTemplate, error) {
+	var template model.ShippingTemplate
+	err := w.DB.Where("`id` = ?", id).Take(&template).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+func (w *Website) GetDefaultShippingTemplate() (*model.ShippingTemplate, error) {
+	var template model.ShippingTemplate
+	err := w.DB.Where("`is_default` = 1").Take(&template).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &template, nil
+}
+
+func (w *Website) SaveShippingTemplate(req *request.ShippingTemplateRequest) (*model.ShippingTemplate, error) {
+	var template *model.ShippingTemplate
+	var err error
+	if req.Id > 0 {
+		template, err = w.GetShippingTemplateById(req.Id)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		template = &model.ShippingTemplate{}
+	}
+	if req.Type != config.ShippingTypeWeight && req.Type != config.ShippingTypeQuantity {
+		req.Type = config.ShippingTypeFlat
+	}
+	template.Title = req.Title
+	template.Type = req.Type
+	template.FirstUnit = req.FirstUnit
+	template.FirstFee = req.FirstFee
+	template.AdditionalUnit = req.AdditionalUnit
+	template.AdditionalFee = req.AdditionalFee
+	template.FreeAmount = req.FreeAmount
+	template.Regions = req.Regions
+	template.IsDefault = req.IsDefault
+
+	err = w.DB.Save(template).Error
+	if err != nil {
+		return nil, err
+	}
+	if template.IsDefault == 1 {
+		// 默认模板只能有一个
+		w.DB.Model(&model.ShippingTemplate{}).Where("`id` != ?", template.Id).UpdateColumn("is_default", 0)
+	}
+
+	return template, nil
+}
+
+func (w *Website) DeleteShippingTemplate(id uint) error {
+	template, err := w.GetShippingTemplateById(id)
+	if err != nil {
+		return err
+	}
+	err = w.DB.Delete(template).Error
+	if err != nil {
+		return err
+	}
+	// 使用该模板的文档和模型，改为使用默认模板
+	w.DB.Model(&model.Archive{}).Where("`shipping_id` = ?", template.Id).UpdateColumn("shipping_id", 0)
+	w.DB.Model(&model.Module{}).Where("`shipping_id` = ?", template.Id).UpdateColumn("shipping_id", 0)
+
+	return nil
+}
+
+// GetArchiveShippingId 文档的运费模板，文档没有单独设置时，使用模型的设置，都没有设置时使用默认模板
+func (w *Website) GetArchiveShippingId(archive *model.Archive) uint {
+	if archive.ShippingId > 0 {
+		return archive.ShippingId
+	}
+	module := w.GetModuleFromCache(archive.ModuleId)
+	if module != nil {
+		return module.ShippingId
+	}
+
+	return 0
+}
+
+type shippingGroup struct {
+	quantity    int64
+	weight      int64
+	goodsAmount int64
+}
+
+// CalculateShippingFee 计算订单运费，使用同一个运费模板的商品合并计算，不同模板的运费相加
+func (w *Website) CalculateShippingFee(userId uint, details []request.OrderDetail, province, city string) (int64, error) {
+	var groups = map[uint]*shippingGroup{}
+	discount := w.GetUserDiscount(userId, nil)
+	for _, v := range details {
+		archive, err := w.GetArchiveById(v.GoodsId)
+		if err != nil {
+			return 0, err
+		}
+		if w.GetArchiveDeliveryType(archive) != config.DeliveryTypeNormal {
+			// 数字商品不需要运费
+			continue
+		}
+		quantity := int64(v.Quantity)
+		if quantity <= 0 {
+			quantity = 1
+		}
+		price := archive.Price
+		if discount > 0 {
+			price = price * discount / 100
+		}
+		shippingId := w.GetArchiveShippingId(archive)
+		group, ok := groups[shippingId]
+		if !ok {
+			group = &shippingGroup{}
+			groups[shippingId] = group
+		}
+		group.quantity += quantity
+		group.weight += archive.Weight * quantity
+		group.goodsAmount += price * quantity
+	}
+
+	var total int64
+	for shippingId, group := range groups {
+		var template *model.ShippingTemplate
+		var err error
+		if shippingId > 0 {
+			template, err = w.GetShippingTemplateById(shippingId)
+		}
+		if template == nil {
+			template, err = w.GetDefaultShippingTemplate()
+		}
+		if err != nil {
+			// 没有设置运费模板的商品，不收运费
+			continue
+		}
+		fee, err := w.calculateTemplateFee(template, group, province, city)
+		if err != nil {
+			return 0, err
+		}
+		total += fee
+	}
+
+	return total, nil
+}
+
+func (w *Website) calculateTemplateFee(template *model.ShippingTemplate, group *shippingGroup, province, city string) (int64, error) {
+	firstFee := template.FirstFee
+	additionalFee := template.AdditionalFee
+	freeAmount := template.FreeAmount
+	// 城市的设置优先于省份的设置
+	var matched *model.ShippingRegion
+	for i := range template.Regions {
+		region := &template.Regions[i]
+		if region.Province == "" || region.Province != province {
+			continue
+		}
+		if region.City == city {
+			matched = region
+			break
+		}
+		if region.City == "" && matched == nil {
+			matched = region
+		}
+	}
+	if matched != nil {
+		if matched.NoDelivery {
+			return 0, errors.New(w.Lang("该地区不支持配送"))
+		}
+		firstFee = matched.FirstFee
+		additionalFee = matched.AdditionalFee
+		freeAmount = matched.FreeAmount
+	}
+	if freeAmount > 0 && group.goodsAmount >= freeAmount {
+		return 0, nil
+	}
+	if template.Type == config.ShippingTypeFlat {
+		return firstFee, nil
+	}
+	units := group.quantity
+	if template.Type == config.ShippingTypeWeight {
+		units = group.weight
+	}
+	fee := firstFee
+	if units > template.FirstUnit && template.AdditionalUnit > 0 {
+		extra := units - template.FirstUnit
+		fee += (extra + template.AdditionalUnit - 1) / template.AdditionalUnit * additionalFee
+	}
+
+	return fee, nil
+}
+
+// PreviewShippingFee 下单前预览运费，地址可以传已保存的地址ID，也可以直接传省份和城市
+func (w *Website) PreviewShippingFee(userId uint, req *request.OrderRequest) (int64, error) {
+	if len(req.Details) == 0 && req.GoodsId == 0 {
+		return 0, errors.New(w.Lang("请选择商品"))
+	}
+	if len(req.Details) == 0 {
+		req.Details = []request.OrderDetail{{GoodsId: req.GoodsId, Quantity: req.Quantity}}
+	}
+	var province, city string
+	if req.Address != nil {
+		province = req.Address.Province
+		city = req.Address.City
+		if req.Address.Id > 0 && userId > 0 {
+			address, err := w.GetOrderAddressById(req.Address.Id)
+			if err == nil && address.UserId == userId {
+				province = address.Province
+				city = address.City
+			}
+		}
+	}
+
+	return w.CalculateShippingFee(userId, req.Details, province, city)
+}
@@ -4,9 +4,12 @@ import (
 	"bytes"
 	"encoding/xml"
 	"fmt"
+	"kandaoni.com/anqicms/config"
 	"kandaoni.com/anqicms/model"
 	"math"
 	"os"
+	"path/filepath"
+	"regexp"
 	"strconv"
 	"strings"
 	"time"
@@ -47,62 +50,72 @@ func (w *Website) BuildSitemap() error {
 	//如果所有数量多于50000，则按种类生成。
 	//sitemap将包含首页、分类首页、文章页、产品页
 	baseUrl := w.System.BaseUrl
-	var categoryCount int64
-	var archiveCount int64
-	var tagCount int64
-	categoryBuilder := w.DB.Model(&model.Category{}).Where("`status` = 1").Order("id asc").Count(&categoryCount)
-	archiveBuilder := w.DB.Model(&model.Archive{}).Where("`status` = 1").Order("id asc").Count(&archiveCount)
-	tagBuilder := w.DB.Model(&model.Tag{}).Where("`status` = 1").Order("id asc").Count(&tagCount)
+	sitemapType := w.PluginSitemap.Type
+	// 清理之前生成的文件，避免切换分模型生成后残留旧文件
+	w.removeSitemapFiles()
 
 	//index 和 category 存放在同一个文件，文章单独一个文件
-	indexFile := NewSitemapIndexGenerator(w.PluginSitemap.Type, fmt.Sprintf("%ssitemap.%s", w.PublicPath, w.PluginSitemap.Type), w.System.BaseUrl, false)
+	indexFile := NewSitemapIndexGenerator(sitemapType, w.sitemapPath("sitemap"), baseUrl, false)
 	defer indexFile.Save()
 
-	indexFile.AddIndex(fmt.Sprintf("%s/category.%s", baseUrl, w.PluginSitemap.Type))
+	indexFile.AddIndex(w.sitemapLink("category"))
 
-	categoryFile := NewSitemapGenerator(w.PluginSitemap.Type, fmt.Sprintf("%scategory.%s", w.PublicPath, w.PluginSitemap.Type), w.System.BaseUrl, false)
-	defer categoryFile.Save()
+	categoryFile := NewSitemapGenerator(sitemapType, w.sitemapPath("category"), baseUrl, false)
+	pageFile := categoryFile
+	if w.PluginSitemap.SplitPage == 1 {
+		indexFile.AddIndex(w.sitemapLink("page"))
+		pageFile = NewSitemapGenerator(sitemapType, w.sitemapPath("page"), baseUrl, false)
+	}
 	//写入首页
-	categoryFile.AddLoc(baseUrl, time.Now().Format("2006-01-02"))
-	//写入分类页
+	categoryFile.AddUrl(w.newSitemapUrl("index", 0, baseUrl, time.Now().Format("2006-01-02")))
+	//写入分类页和单页
 	var categories []*model.Category
-	categoryBuilder.Find(&categories)
+	w.DB.Model(&model.Category{}).Where("`status` = 1").Order("id asc").Find(&categories)
 	for _, v := range categories {
-		categoryFile.AddLoc(w.GetUrl("category", v, 0), time.Unix(v.UpdatedTime, 0).Format("2006-01-02"))
+		lastmod := time.Unix(v.UpdatedTime, 0).Format("2006-01-02")
+		if v.Type == config.CategoryTypePage {
+			pageFile.AddUrl(w.newSitemapUrl("page", 0, w.GetUrl("page", v, 0), lastmod))
+		} else {
+			categoryFile.AddUrl(w.newSitemapUrl("category", 0, w.GetUrl("category", v, 0), lastmod))
+		}
+	}
+	_ = categoryFile.Save()
+	if pageFile != categoryFile {
+		_ = pageFile.Save()
 	}
 	//写入文章
-	pager := int(math.Ceil(float64(archiveCount) / float64(SitemapLimit)))
-	var archives []*model.Archive
-	for i := 1; i <= pager; i++ {
-		//写入index
-		indexFile.AddIndex(fmt.Sprintf("%s/archive-%d.%s", baseUrl, i, w.PluginSitemap.Type))
-
-		//写入archive-sitemap
-		archiveFile := NewSitemapGenerator(w.PluginSitemap.Type, fmt.Sprintf("%sarchive-%d.%s", w.PublicPath, i, w.PluginSitemap.Type), w.System.BaseUrl, false)
-		err := archiveBuilder.Limit(SitemapLimit).Offset((i - 1) * SitemapLimit).Find(&archives).Error
-		if err == nil {
-			for _, v := range archives {
-				archiveFile.AddLoc(w.GetUrl("archive", v, 0), time.Unix(v.UpdatedTime, 0).Format("2006-01-02"))
-			}
+	if w.PluginSitemap.SplitModule == 1 {
+		modules := w.GetCacheModules()
+		for _, v := range modules {
+			w.buildArchiveSitemap(indexFile, v.Id)
 		}
-		archiveFile.Save()
+	} else {
+		w.buildArchiveSitemap(indexFile, 0)
 	}
 	//写入tag
-	pager = int(math.Ceil(float64(tagCount) / float64(SitemapLimit)))
+	var tagCount int64
+	tagBuilder := w.DB.Model(&model.Tag{}).Where("`status` = 1").Order("id asc").Count(&tagCount)
+	pager := int(math.Ceil(float64(tagCount) / float64(SitemapLimit)))
 	var tags []*model.Tag
 	for i := 1; i <= pager; i++ {
+		name := fmt.Sprintf("tag-%d", i)
 		//写入index
-		indexFile.AddIndex(fmt.Sprintf("%s/tag-%d.%s", baseUrl, i, w.PluginSitemap.Type))
+		indexFile.AddIndex(w.sitemapLink(name))
 
 		//写入tag-sitemap
-		tagFile := NewSitemapGenerator(w.PluginSitemap.Type, fmt.Sprintf("%stag-%d.%s", w.PublicPath, i, w.PluginSitemap.Type), w.System.BaseUrl, false)
+		tagFile := NewSitemapGenerator(sitemapType, w.sitemapPath(name), baseUrl, false)
 		err := tagBuilder.Limit(SitemapLimit).Offset((i - 1) * SitemapLimit).Find(&tags).Error
 		if err == nil {
 			for _, v := range tags {
-				tagFile.AddLoc(w.GetUrl("tag", v, 0), time.Unix(v.UpdatedTime, 0).Format("2006-01-02"))
+				tagFile.AddUrl(w.newSitemapUrl("tag", 0, w.GetUrl("tag", v, 0), time.Unix(v.UpdatedTime, 0).Format("2006-01-02")))
 			}
 		}
-		tagFile.Save()
+		_ = tagFile.Save()
+	}
+	//写入新闻
+	if w.newsSitemapEnabled() {
+		indexFile.AddIndex(w.sitemapLink("news"))
+		_ = w.buildNewsSitemap()
 	}
 
 	_ = w.UpdateSitemapTime()
@@ -110,90 +123,461 @@ func (w *Website) BuildSitemap() error {
 	return nil
 }
 
-// AddonSitemap 追加sitemap
-func (w *Website) AddonSitemap(itemType string, link string, lastmod string) error {
+// buildArchiveSitemap 生成文档sitemap，moduleId 为 0 时包含所有模型
+func (w *Website) buildArchiveSitemap(indexFile *SitemapIndexGenerator, moduleId uint) {
+	archiveBuilder := w.DB.Model(&model.Archive{}).Where("`status` = 1")
+	if moduleId > 0 {
+		archiveBuilder = archiveBuilder.Where("`module_id` = ?", moduleId)
+	}
+	var archiveCount int64
+	archiveBuilder.Count(&archiveCount)
+	pager := int(math.Ceil(float64(archiveCount) / float64(SitemapLimit)))
+	for i := 1; i <= pager; i++ {
+		name := w.archiveSitemapName(moduleId, i)
+		//写入index
+		indexFile.AddIndex(w.sitemapLink(name))
+
+		//写入archive-sitemap
+		archiveFile := NewSitemapGenerator(w.PluginSitemap.Type, w.sitemapPath(name), w.System.BaseUrl, false)
+		var archives []*model.Archive
+		err := archiveBuilder.Order("id asc").Limit(SitemapLimit).Offset((i - 1) * SitemapLimit).Find(&archives).Error
+		if err == nil {
+			contents := w.getSitemapArchiveContents(archives)
+			for _, v := range archives {
+				item := w.newSitemapUrl("archive", v.ModuleId, w.GetUrl("archive", v, 0), time.Unix(v.UpdatedTime, 0).Format("2006-01-02"))
+				item.Images = w.getSitemapImages(v, contents[v.Id])
+				archiveFile.AddUrl(item)
+			}
+		}
+		_ = archiveFile.Save()
+	}
+}
+
+// AddonSitemap 追加sitemap，data 为对应的文档、分类或标签，用于读取图片、模型等信息
+func (w *Website) AddonSitemap(itemType string, link string, lastmod string, data interface{}) error {
 	//index 和 category 存放在同一个文件，文章单独一个文件
+	var itemPath string
+	var item SitemapUrl
 	if itemType == "category" {
-		categoryPath := fmt.Sprintf("%scategory.%s", w.PublicPath, w.PluginSitemap.Type)
-		_, err := os.Stat(categoryPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return w.BuildSitemap()
-			} else {
-				return err
+		name := "category"
+		if category, ok := data.(*model.Category); ok && category.Type == config.CategoryTypePage {
+			itemType = "page"
+			if w.PluginSitemap.SplitPage == 1 {
+				name = "page"
 			}
 		}
-
-		categoryFile := NewSitemapGenerator(w.PluginSitemap.Type, categoryPath, w.System.BaseUrl, true)
-		if err != nil {
-			return err
+		itemPath = w.sitemapPath(name)
+		item = w.newSitemapUrl(itemType, 0, link, lastmod)
+	} else if itemType == "archive" {
+		archive, ok := data.(*model.Archive)
+		if !ok {
+			return nil
 		}
-		defer categoryFile.Save()
-		//写入分类页
-		categoryFile.AddLoc(link, lastmod)
-
-		if err == nil {
-			_ = w.UpdateSitemapTime()
+		//文章，由于本次统计的时候，这个文章已经存在，可以直接使用统计数量
+		var moduleId uint
+		archiveBuilder := w.DB.Model(&model.Archive{}).Where("`status` = 1")
+		if w.PluginSitemap.SplitModule == 1 {
+			moduleId = archive.ModuleId
+			archiveBuilder = archiveBuilder.Where("`module_id` = ?", moduleId)
 		}
-	} else if itemType == "archive" {
 		var archiveCount int64
-		w.DB.Model(&model.Archive{}).Where("`status` = 1").Count(&archiveCount)
-		//文章，由于本次统计的时候，这个文章已经存在，可以直接使用统计数量
+		archiveBuilder.Count(&archiveCount)
 		pager := int(math.Ceil(float64(archiveCount) / float64(SitemapLimit)))
-		archivePath := fmt.Sprintf("%sarchive-%d.%s", w.PublicPath, pager, w.PluginSitemap.Type)
-		_, err := os.Stat(archivePath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return w.BuildSitemap()
-			} else {
-				return err
-			}
-		}
-		archiveFile := NewSitemapGenerator(w.PluginSitemap.Type, archivePath, w.System.BaseUrl, true)
-		defer archiveFile.Save()
-		archiveFile.AddLoc(link, lastmod)
-
-		if err == nil {
-			_ = w.UpdateSitemapTime()
+		itemPath = w.sitemapPath(w.archiveSitemapName(moduleId, pager))
+		item = w.newSitemapUrl(itemType, archive.ModuleId, link, lastmod)
+		contents := w.getSitemapArchiveContents([]*model.Archive{archive})
+		item.Images = w.getSitemapImages(archive, contents[archive.Id])
+		if w.newsSitemapEnabled() && archive.ModuleId == w.PluginSitemap.NewsModuleId {
+			defer w.buildNewsSitemap()
 		}
 	} else if itemType == "tag" {
 		var tagCount int64
 		w.DB.Model(&model.Tag{}).Where("`status` = 1").Count(&tagCount)
 		//tag
 		pager := int(math.Ceil(float64(tagCount) / float64(SitemapLimit)))
-		tagPath := fmt.Sprintf("%stag-%d.%s", w.PublicPath, pager, w.PluginSitemap.Type)
-		_, err := os.Stat(tagPath)
-		if err != nil {
-			if os.IsNotExist(err) {
-				return w.BuildSitemap()
-			} else {
+		itemPath = w.sitemapPath(fmt.Sprintf("tag-%d", pager))
+		item = w.newSitemapUrl(itemType, 0, link, lastmod)
+	} else {
+		return nil
+	}
+	_, err := os.Stat(itemPath)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return w.BuildSitemap()
+		} else {
+			return err
+		}
+	}
+	// 已存在的链接可能在之前的分页中，先从其他分页移除，避免重复
+	for _, otherPath := range w.sitemapItemPaths(itemType) {
+		if otherPath == itemPath {
+			continue
+		}
+		if _, err = os.Stat(otherPath); err != nil {
+			continue
+		}
+		otherFile := NewSitemapGenerator(w.PluginSitemap.Type, otherPath, w.System.BaseUrl, true)
+		if otherFile.Remove(link) {
+			if err = otherFile.Save(); err != nil {
 				return err
 			}
 		}
-		tagFile := NewSitemapGenerator(w.PluginSitemap.Type, tagPath, w.System.BaseUrl, true)
-		defer tagFile.Save()
-		tagFile.AddLoc(link, lastmod)
-
+	}
+	itemFile := NewSitemapGenerator(w.PluginSitemap.Type, itemPath, w.System.BaseUrl, true)
+	if !itemFile.Update(item) {
+		itemFile.AddUrl(item)
+	}
+	err = itemFile.Save()
+	if err == nil {
 		_ = w.UpdateSitemapTime()
 	}
 
+	return err
+}
+
+// RemoveSitemap 从已生成的sitemap中移除删除或下线的内容，不需要重新生成全部sitemap
+func (w *Website) RemoveSitemap(itemType string, links ...string) error {
+	if len(links) == 0 {
+		return nil
+	}
+	for _, itemPath := range w.sitemapItemPaths(itemType) {
+		if _, err := os.Stat(itemPath); err != nil {
+			continue
+		}
+		itemFile := NewSitemapGenerator(w.PluginSitemap.Type, itemPath, w.System.BaseUrl, true)
+		var removed bool
+		for _, link := range links {
+			if itemFile.Remove(link) {
+				removed = true
+			}
+		}
+		if removed {
+			if err := itemFile.Save(); err != nil {
+				return err
+			}
+		}
+	}
+	if itemType == "archive" && w.newsSitemapEnabled() {
+		_ = w.buildNewsSitemap()
+	}
+	_ = w.UpdateSitemapTime()
+
+	return nil
+}
+
+// syncArchivesStatus 批量修改文档状态后，发布的文档加入sitemap和推送队列，其他的移出sitemap并推送删除
+func (w *Website) syncArchivesStatus(ids []uint) {
+	if len(ids) == 0 {
+		return
+	}
+	var archives []*model.Archive
+	w.DB.Where("`id` IN (?)", ids).Find(&archives)
+	var addLinks, removeLinks []string
+	for _, v := range archives {
+		link := w.GetUrl("archive", v, 0)
+		if v.Status == config.ContentStatusOK {
+			addLinks = append(addLinks, link)
+			w.awardPublishPoints(v)
+			if w.PluginSitemap.AutoBuild == 1 {
+				_ = w.AddonSitemap("archive", link, time.Unix(v.UpdatedTime, 0).Format("2006-01-02"), v)
+			}
+		} else {
+			removeLinks = append(removeLinks, link)
+		}
+	}
+	if w.PluginSitemap.AutoBuild == 1 {
+		_ = w.RemoveSitemap("archive", removeLinks...)
+	}
+	go w.AddPushQueue(config.PushActionAdd, addLinks...)
+	go w.PushChangedLinks(config.PushActionDelete, removeLinks...)
+}
+
+// sitemapItemPaths 某一类内容可能所在的全部sitemap文件
+func (w *Website) sitemapItemPaths(itemType string) []string {
+	var paths []string
+	switch itemType {
+	case "category", "page":
+		paths = []string{w.sitemapPath("category"), w.sitemapPath("page")}
+	case "archive", "tag":
+		paths, _ = filepath.Glob(w.sitemapPath(itemType + "-*"))
+	}
+
+	return paths
+}
+
+func (w *Website) sitemapPath(name string) string {
+	return fmt.Sprintf("%s%s.%s", w.PublicPath, name, w.PluginSitemap.Type)
+}
+
+func (w *Website) sitemapLink(name string) string {
+	return fmt.Sprintf("%s/%s.%s", w.System.BaseUrl, name, w.PluginSitemap.Type)
+}
+
+// archiveSitemapName 文档sitemap的文件名，按模型分别生成时带上模型表名
+func (w *Website) archiveSitemapName(moduleId uint, page int) string {
+	if moduleId > 0 {
+		module := w.GetModuleFromCache(moduleId)
+		if module != nil {
+			return fmt.Sprintf("archive-%s-%d", module.TableName, page)
+		}
+	}
+
+	return fmt.Sprintf("archive-%d", page)
+}
+
+func (w *Website) removeSitemapFiles() {
+	for _, pattern := range []string{"archive-*", "tag-*"} {
+		paths, _ := filepath.Glob(w.sitemapPath(pattern))
+		for _, v := range paths {
+			_ = os.Remove(v)
+		}
+	}
+	_ = os.Remove(w.sitemapPath("page"))
+	_ = os.Remove(w.sitemapPath("news"))
+}
+
+// newSitemapUrl 按规则设置 changefreq、priority，多语言站点加上 hreflang
+func (w *Website) newSitemapUrl(itemType string, moduleId uint, link, lastmod string) SitemapUrl {
+	item := SitemapUrl{
+		Loc:     link,
+		Lastmod: lastmod,
+	}
+	var matched *config.SitemapRule
+	for i := range w.PluginSitemap.Rules {
+		rule := &w.PluginSitemap.Rules[i]
+		if rule.Type != itemType {
+			continue
+		}
+		if rule.ModuleId == moduleId {
+			matched = rule
+			break
+		}
+		if rule.ModuleId == 0 {
+			matched = rule
+		}
+	}
+	if matched != nil {
+		item.ChangeFreq = matched.ChangeFreq
+		item.Priority = matched.Priority
+	}
+	if w.PluginSitemap.Type == "xml" && len(w.PluginSitemap.Alternates) > 0 && strings.HasPrefix(link, w.System.BaseUrl) {
+		uri := strings.TrimPrefix(link, w.System.BaseUrl)
+		language := w.PluginSitemap.Language
+		if language == "" {
+			language = w.System.Language
+		}
+		item.Alternates = append(item.Alternates, SitemapAlternateLink{
+			Rel:      "alternate",
+			Hreflang: language,
+			Href:     link,
+		})
+		for _, v := range w.PluginSitemap.Alternates {
+			item.Alternates = append(item.Alternates, SitemapAlternateLink{
+				Rel:      "alternate",
+				Hreflang: v.Language,
+				Href:     v.BaseUrl + uri,
+			})
+		}
+	}
+
+	return item
+}
+
+// getSitemapArchiveContents 开启图片sitemap时读取文档内容，用于提取内容中的图片
+func (w *Website) getSitemapArchiveContents(archives []*model.Archive) map[uint]string {
+	contents := map[uint]string{}
+	if w.PluginSitemap.Type != "xml" || w.PluginSitemap.ImageSitemap != 1 {
+		return contents
+	}
+	for i := 0; i < len(archives); i += 1000 {
+		end := i + 1000
+		if end > len(archives) {
+			end = len(archives)
+		}
+		ids := make([]uint, 0, end-i)
+		for _, v := range archives[i:end] {
+			ids = append(ids, v.Id)
+		}
+		var data []*model.ArchiveData
+		w.DB.Where("`id` IN (?)", ids).Find(&data)
+		for _, v := range data {
+			contents[v.Id] = v.Content
+		}
+	}
+
+	return contents
+}
+
+// getSitemapImages 文档的组图和内容中的图片
+func (w *Website) getSitemapImages(archive *model.Archive, content string) []SitemapImage {
+	if w.PluginSitemap.Type != "xml" || w.PluginSitemap.ImageSitemap != 1 {
+		return nil
+	}
+	links := append([]string{}, archive.Images...)
+	for _, match := range sitemapImageRe.FindAllStringSubmatch(content, -1) {
+		if match[1] != "" {
+			links = append(links, match[1])
+		} else {
+			links = append(links, match[2])
+		}
+	}
+	var images []SitemapImage
+	exists := map[string]struct{}{}
+	for _, v := range links {
+		v = strings.TrimSpace(v)
+		if v == "" || strings.HasPrefix(v, "data:") {
+			continue
+		}
+		if !strings.HasPrefix(v, "http") && !strings.HasPrefix(v, "//") {
+			v = w.PluginStorage.StorageUrl + "/" + strings.TrimPrefix(v, "/")
+		}
+		if _, ok := exists[v]; ok {
+			continue
+		}
+		exists[v] = struct{}{}
+		images = append(images, SitemapImage{Loc: v})
+		if len(images) >= sitemapImageLimit {
+			break
+		}
+	}
+
+	return images
+}
+
+func (w *Website) newsSitemapEnabled() bool {
+	return w.PluginSitemap.Type == "xml" && w.PluginSitemap.NewsModuleId > 0
+}
+
+// buildNewsSitemap 生成新闻sitemap，只包含指定模型最近48小时发布的文档
+func (w *Website) buildNewsSitemap() error {
+	newsPath := w.sitemapPath("news")
+	if !w.newsSitemapEnabled() {
+		_ = os.Remove(newsPath)
+		return nil
+	}
+	name := w.PluginSitemap.NewsName
+	if name == "" {
+		name = w.System.SiteName
+	}
+	language := w.PluginSitemap.NewsLanguage
+	if language == "" {
+		language = w.System.Language
+	}
+	var archives []*model.Archive
+	w.DB.Model(&model.Archive{}).Where("`status` = 1 and `module_id` = ? and `created_time` >= ?", w.PluginSitemap.NewsModuleId, time.Now().Add(-48*time.Hour).Unix()).
+		Order("created_time desc").Limit(SitemapNewsLimit).Find(&archives)
+	newsFile := &SitemapNewsGenerator{
+		Xmlns:     "http://www.sitemaps.org/schemas/sitemap/0.9",
+		XmlnsNews: "http://www.google.com/schemas/sitemap-news/0.9",
+		Urls:      make([]SitemapNewsUrl, 0, len(archives)),
+	}
+	for _, v := range archives {
+		newsFile.Urls = append(newsFile.Urls, SitemapNewsUrl{
+			Loc: w.GetUrl("archive", v, 0),
+			News: SitemapNews{
+				Name:            name,
+				Language:        language,
+				PublicationDate: time.Unix(v.CreatedTime, 0).Format(time.RFC3339),
+				Title:           v.Title,
+			},
+		})
+	}
+	output, err := xml.Marshal(newsFile)
+	if err != nil {
+		return err
+	}
+	err = os.WriteFile(newsPath, append([]byte(xml.Header), output...), os.ModePerm)
+	if err != nil {
+		return err
+	}
+	// 新开启新闻sitemap时，索引中还没有它
+	indexPath := w.sitemapPath("sitemap")
+	if _, err = os.Stat(indexPath); err == nil {
+		indexFile := NewSitemapIndexGenerator(w.PluginSitemap.Type, indexPath, w.System.BaseUrl, true)
+		if !indexFile.Exists(w.sitemapLink("news")) {
+			indexFile.AddIndex(w.sitemapLink("news"))
+			return indexFile.Save()
+		}
+	}
+
 	return nil
 }
 
+// BuildNewsSitemap 计划任务定时更新新闻sitemap，移除超过48小时的文档
+func (w *Website) BuildNewsSitemap() {
+	if w.PluginSitemap.AutoBuild != 1 || !w.newsSitemapEnabled() {
+		return
+	}
+	_ = w.buildNewsSitemap()
+}
+
+const (
+	sitemapImageNamespace = "http://www.google.com/schemas/sitemap-image/1.1"
+	sitemapXhtmlNamespace = "http://www.w3.org/1999/xhtml"
+	// 每个链接最多写入的图片数量
+	sitemapImageLimit = 20
+	// SitemapNewsLimit 新闻sitemap最多包含的链接数
+	SitemapNewsLimit = 1000
+)
+
+var sitemapImageRe = regexp.MustCompile(`(?i)<img[^>]+src=["']([^"']+)["']|!\[[^\]]*]\(([^)\s]+)`)
+
 type SitemapUrl struct {
-	Loc        string `xml:"loc"`
-	Lastmod    string `xml:"lastmod,omitempty"`
-	ChangeFreq string `xml:"changefreq,omitempty"`
-	Priority   string `xml:"priority,omitempty"`
+	Loc        string                 `xml:"loc"`
+	Lastmod    string                 `xml:"lastmod,omitempty"`
+	ChangeFreq string                 `xml:"changefreq,omitempty"`
+	Priority   string                 `xml:"priority,omitempty"`
+	Images     []SitemapImage         `xml:"image:image,omitempty"`
+	Alternates []SitemapAlternateLink `xml:"xhtml:link,omitempty"`
+}
+
+type SitemapImage struct {
+	Loc string `xml:"image:loc"`
+}
+
+type SitemapAlternateLink struct {
+	Rel      string `xml:"rel,attr"`
+	Hreflang string `xml:"hreflang,attr"`
+	Href     string `xml:"href,attr"`
+}
+
+// UnmarshalXML 读取时带前缀的元素按命名空间解析，需要单独处理图片和 hreflang
+func (u *SitemapUrl) UnmarshalXML(d *xml.Decoder, start xml.StartElement) error {
+	var item struct {
+		Loc        string `xml:"loc"`
+		Lastmod    string `xml:"lastmod"`
+		ChangeFreq string `xml:"changefreq"`
+		Priority   string `xml:"priority"`
+		Images     []struct {
+			Loc string `xml:"loc"`
+		} `xml:"http://www.google.com/schemas/sitemap-image/1.1 image"`
+		Alternates []SitemapAlternateLink `xml:"http://www.w3.org/1999/xhtml link"`
+	}
+	if err := d.DecodeElement(&item, &start); err != nil {
+		return err
+	}
+	u.Loc = item.Loc
+	u.Lastmod = item.Lastmod
+	u.ChangeFreq = item.ChangeFreq
+	u.Priority = item.Priority
+	u.Alternates = item.Alternates
+	u.Images = nil
+	for _, v := range item.Images {
+		u.Images = append(u.Images, SitemapImage{Loc: v.Loc})
+	}
+
+	return nil
 }
 
 type SitemapGenerator struct {
-	XMLName  xml.Name     `xml:"urlset"`
-	Xmlns    string       `xml:"xmlns,attr"`
-	Urls     []SitemapUrl `xml:"url"`
-	Type     string       `xml:"-"`
-	FilePath string       `xml:"-"`
-	BaseUrl  string       `xml:"-"`
+	XMLName    xml.Name     `xml:"urlset"`
+	Xmlns      string       `xml:"xmlns,attr"`
+	XmlnsImage string       `xml:"xmlns:image,attr,omitempty"`
+	XmlnsXhtml string       `xml:"xmlns:xhtml,attr,omitempty"`
+	Urls       []SitemapUrl `xml:"url"`
+	Type       string       `xml:"-"`
+	FilePath   string       `xml:"-"`
+	BaseUrl    string       `xml:"-"`
 }
 
 type SitemapIndexGenerator struct {
@@ -249,6 +633,34 @@ func (g *SitemapGenerator) AddLoc(loc string, lastMod string) {
 	})
 }
 
+func (g *SitemapGenerator) AddUrl(item SitemapUrl) {
+	g.Urls = append(g.Urls, item)
+}
+
+// Update 替换已存在的链接，不存在时返回 false
+func (g *SitemapGenerator) Update(item SitemapUrl) bool {
+	for i := range g.Urls {
+		if g.Urls[i].Loc == item.Loc {
+			g.Urls[i] = item
+			return true
+		}
+	}
+
+	return false
+}
+
+// Remove 移除链接，不存在时返回 false
+func (g *SitemapGenerator) Remove(link string) bool {
+	for i := range g.Urls {
+		if g.Urls[i].Loc == link {
+			g.Urls = append(g.Urls[:i], g.Urls[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
 func (g *SitemapGenerator) Exists(link string) bool {
 	for i := range g.Urls {
 		if g.Urls[i].Loc == link {
@@ -261,6 +673,16 @@ func (g *SitemapGenerator) Exists(link string) bool {
 
 func (g *SitemapGenerator) Save() error {
 	if g.Type == "xml" {
+		g.XmlnsImage = ""
+		g.XmlnsXhtml = ""
+		for i := range g.Urls {
+			if len(g.Urls[i].Images) > 0 {
+				g.XmlnsImage = sitemapImageNamespace
+			}
+			if len(g.Urls[i].Alternates) > 0 {
+				g.XmlnsXhtml = sitemapXhtmlNamespace
+			}
+		}
 		output, err := xml.Marshal(g)
 		if err == nil {
 			f, err := os.OpenFile(g.FilePath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, os.ModePerm)
@@ -288,6 +710,25 @@ func (g *SitemapGenerator) Save() error {
 	}
 }
 
+type SitemapNewsGenerator struct {
+	XMLName   xml.Name         `xml:"urlset"`
+	Xmlns     string           `xml:"xmlns,attr"`
+	XmlnsNews string           `xml:"xmlns:news,attr"`
+	Urls      []SitemapNewsUrl `xml:"url"`
+}
+
+type SitemapNewsUrl struct {
+	Loc  string      `xml:"loc"`
+	News SitemapNews `xml:"news:news"`
+}
+
+type SitemapNews struct {
+	Name            string `xml:"news:publication>news:name"`
+	Language        string `xml:"news:publication>news:language"`
+	PublicationDate string `xml:"news:publication_date"`
+	Title           string `xml:"news:title"`
+}
+
 func NewSitemapIndexGenerator(sitemapType string, filePath, baseUrl string, load bool) *SitemapIndexGenerator {
 	generator := &SitemapIndexGenerator{
 		Type:     sitemapType,
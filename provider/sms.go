@@ -0,0 +1,68 @@
+package provider
+
+import (
+	"errors"
+	"kandaoni.com/anqicms/library"
+	"sync"
+	"time"
+)
+
+const SmsLogFile = "sms.log"
+
+// SmsSender 短信发送接口，接入短信服务商时，实现该接口并通过 RegisterSmsDriver 注册
+type SmsSender interface {
+	Send(phone, content string) error
+}
+
+type SmsDriverFactory func(w *Website) SmsSender
+
+var smsDrivers = map[string]SmsDriverFactory{
+	"log": func(w *Website) SmsSender {
+		return &logSmsSender{cachePath: w.CachePath}
+	},
+}
+var smsDriverMu sync.RWMutex
+
+// RegisterSmsDriver 注册短信驱动，在后台的用户设置中选择使用
+func RegisterSmsDriver(name string, factory SmsDriverFactory) {
+	smsDriverMu.Lock()
+	smsDrivers[name] = factory
+	smsDriverMu.Unlock()
+}
+
+func GetSmsDrivers() []string {
+	smsDriverMu.RLock()
+	defer smsDriverMu.RUnlock()
+	var names = make([]string, 0, len(smsDrivers))
+	for name := range smsDrivers {
+		names = append(names, name)
+	}
+
+	return names
+}
+
+func (w *Website) SendSms(phone, content string) error {
+	name := w.PluginUser.SmsDriver
+	if name == "" {
+		name = "log"
+	}
+	smsDriverMu.RLock()
+	factory, ok := smsDrivers[name]
+	smsDriverMu.RUnlock()
+	if !ok {
+		return errors.New(w.Lang("短信驱动不存在"))
+	}
+
+	return factory(w).Send(phone, content)
+}
+
+// logSmsSender 不实际发送短信，只记录到日志，用于测试
+type logSmsSender struct {
+	cachePath string
+}
+
+func (s *logSmsSender) Send(phone, content string) error {
+	library.DebugLog(s.cachePath, SmsLogFile, time.Now().Format("2006-01-02 15:04:05"), phone, content)
+
+	return nil
+}
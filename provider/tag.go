@@ -84,17 +84,24 @@ func (w *Website) DeleteTag(id uint) error {
 	if err != nil {
 		return err
 	}
+	link := w.GetUrl("tag", tag, 0)
+	if w.PluginSitemap.AutoBuild == 1 {
+		_ = w.RemoveSitemap("tag", link)
+	}
+	go w.PushChangedLinks(config.PushActionDelete, link)
 
 	return nil
 }
 
 func (w *Website) SaveTag(req *request.PluginTag) (tag *model.Tag, err error) {
 	newPost := false
+	var oldLink string
 	if req.Id > 0 {
 		tag, err = w.GetTagById(req.Id)
 		if err != nil {
 			return nil, err
 		}
+		oldLink = w.GetUrl("tag", tag, 0)
 	} else {
 		tag = &model.Tag{
 			Status: 1,
@@ -129,12 +136,17 @@ func (w *Website) SaveTag(req *request.PluginTag) (tag *model.Tag, err error) {
 		return
 	}
 
+	if oldLink != "" {
+		w.AddAutoRedirect(oldLink, w.GetUrl("tag", tag, 0))
+	}
 	if newPost && tag.Status == config.ContentStatusOK {
 		link := w.GetUrl("tag", tag, 0)
 		go w.PushArchive(link)
 		if w.PluginSitemap.AutoBuild == 1 {
-			_ = w.AddonSitemap("tag", link, time.Unix(tag.CreatedTime, 0).Format("2006-01-02"))
+			_ = w.AddonSitemap("tag", link, time.Unix(tag.CreatedTime, 0).Format("2006-01-02"), tag)
 		}
+	} else if !newPost {
+		go w.PushChangedLinks(config.PushActionUpdate, w.GetUrl("tag", tag, 0))
 	}
 
 	return
@@ -169,7 +181,7 @@ func (w *Website) SaveTagData(itemId uint, tagNames []string) error {
 			link := w.GetUrl("tag", tag, 0)
 			go w.PushArchive(link)
 			if w.PluginSitemap.AutoBuild == 1 {
-				_ = w.AddonSitemap("tag", link, time.Unix(tag.CreatedTime, 0).Format("2006-01-02"))
+				_ = w.AddonSitemap("tag", link, time.Unix(tag.CreatedTime, 0).Format("2006-01-02"), tag)
 			}
 		}
 		tagIds = append(tagIds, tag.Id)
@@ -13,7 +13,11 @@ import (
 // 支持的规则：getUrl("archive"|"category"|"page"|"nav"|"archiveIndex", item, int)
 // 如果page == -1，则不对page进行转换。
 func (w *Website) GetUrl(match string, data interface{}, page int) string {
-	rewritePattern := w.ParsePatten(false)
+	return w.getUrlByPatten(w.ParsePatten(false), match, data, page)
+}
+
+// getUrlByPatten 按指定的伪静态规则生成链接，用于比较伪静态规则调整前后的链接
+func (w *Website) getUrlByPatten(rewritePattern *RewritePatten, match string, data interface{}, page int) string {
 	uri := ""
 	switch match {
 	case "archive":
@@ -119,7 +123,7 @@ func (w *Website) GetUrl(match string, data interface{}, page int) string {
 		if ok && item != nil {
 			//自动修正
 			if item.Type == config.CategoryTypePage {
-				uri = w.GetUrl("page", item, 0)
+				uri = w.getUrlByPatten(rewritePattern, "page", item, 0)
 			} else {
 				for _, v := range rewritePattern.CategoryTags {
 					if v == "id" {
@@ -188,12 +192,12 @@ func (w *Website) GetUrl(match string, data interface{}, page int) string {
 				} else if item.PageId > 0 {
 					//文档首页
 					module := w.GetModuleFromCache(item.PageId)
-					uri = w.GetUrl("archiveIndex", module, 0)
+					uri = w.getUrlByPatten(rewritePattern, "archiveIndex", module, 0)
 				}
 			} else if item.NavType == model.NavTypeCategory {
 				category := w.GetCategoryFromCache(item.PageId)
 				if category != nil {
-					uri = w.GetUrl("category", category, 0)
+					uri = w.getUrlByPatten(rewritePattern, "category", category, 0)
 				}
 			} else if item.NavType == model.NavTypeOutlink {
 				//外链
@@ -119,6 +119,9 @@ func (w *Website) SaveUserInfo(req *request.UserRequest) error {
 		ExpireTime: req.ExpireTime,
 		Status:     req.Status,
 	}
+	if req.Verified != nil {
+		user.Verified = *req.Verified
+	}
 	req.Password = strings.TrimSpace(req.Password)
 	if req.Password != "" {
 		user.EncryptPassword(req.Password)
@@ -127,18 +130,36 @@ func (w *Website) SaveUserInfo(req *request.UserRequest) error {
 		user.GroupId = w.PluginUser.DefaultGroupId
 	}
 	if req.Id > 0 {
-		_, err := w.GetUserInfoById(req.Id)
+		exists, err := w.GetUserInfoById(req.Id)
 		if err != nil {
 			// 用户不存在
 			return err
 		}
 		user.Id = req.Id
+		user.CreatedTime = exists.CreatedTime
+		// 表单中没有传验证状态时，保留原值
+		if req.Verified == nil {
+			user.Verified = exists.Verified
+		}
+		// 余额、积分等不在后台表单中修改，保留原值
+		user.Balance = exists.Balance
+		user.TotalReward = exists.TotalReward
+		user.Points = exists.Points
+		user.LastLogin = exists.LastLogin
+		user.UnsubscribeComment = exists.UnsubscribeComment
+		if req.Password == "" {
+			user.Password = exists.Password
+		}
+		if exists.GroupId != user.GroupId || exists.ExpireTime != user.ExpireTime {
+			w.addMembershipLog(user.Id, exists.GroupId, user.GroupId, config.MembershipActionAdmin, user.ExpireTime, "")
+		}
 	}
 	err := w.DB.Save(&user).Error
 
 	return err
 }
 
+// DeleteUserInfo 后台删除用户，和用户自己注销一样，清除个人资料并保留财务记录
 func (w *Website) DeleteUserInfo(userId uint) error {
 	var user model.User
 	err := w.DB.Where("`id` = ?", userId).Take(&user).Error
@@ -147,9 +168,17 @@ func (w *Website) DeleteUserInfo(userId uint) error {
 		return err
 	}
 
-	err = w.DB.Delete(&user).Error
+	err = w.AnonymizeUser(user.Id)
+	if err != nil {
+		return err
+	}
+	// 等待中的注销申请一并完成
+	w.DB.Model(&model.UserDeletion{}).Where("`user_id` = ? and `status` = ?", user.Id, config.UserDeletionStatusWaiting).UpdateColumns(map[string]interface{}{
+		"status":        config.UserDeletionStatusFinished,
+		"finished_time": time.Now().Unix(),
+	})
 
-	return err
+	return nil
 }
 
 func (w *Website) GetUserGroups() []*model.UserGroup {
@@ -242,8 +271,8 @@ func (w *Website) RegisterUser(req *request.ApiRegisterRequest) (*model.User, er
 	if req.UserName == "" || req.Password == "" {
 		return nil, errors.New(w.Lang("请正确填写用户名和密码"))
 	}
-	if len(req.Password) < 6 {
-		return nil, errors.New(w.Lang("请输入6位以上的密码"))
+	if err := w.CheckPasswordPolicy(req.Password); err != nil {
+		return nil, err
 	}
 	_, err := w.GetUserInfoByUserName(req.UserName)
 	if err == nil {
@@ -283,6 +312,12 @@ func (w *Website) RegisterUser(req *request.ApiRegisterRequest) (*model.User, er
 	}
 	user.EncryptPassword(req.Password)
 	w.DB.Save(&user)
+	w.AwardUserPoints(user.Id, config.PointActionRegister, 0)
+
+	// 注册后发送验证码，发送失败时，用户可以稍后重新发送
+	go func(user model.User) {
+		_ = w.SendUserVerification(&user, "")
+	}(user)
 
 	_ = user.EncodeToken(w.DB)
 
@@ -348,6 +383,7 @@ func (w *Website) LoginViaWeapp(req *request.ApiLoginRequest) (*model.User, erro
 			w.DB.Delete(user)
 			return nil, err
 		}
+		w.AwardUserPoints(user.Id, config.PointActionRegister, 0)
 
 		go w.DownloadAvatar(userWechat.AvatarURL, user)
 	} else {
@@ -400,6 +436,7 @@ func (w *Website) LoginViaWechat(req *request.ApiLoginRequest) (*model.User, err
 		w.DB.Save(user)
 		userWechat.UserId = user.Id
 		w.DB.Save(userWechat)
+		w.AwardUserPoints(user.Id, config.PointActionRegister, 0)
 	} else {
 		user, err = w.GetUserInfoById(userWechat.UserId)
 		if err != nil {
@@ -556,7 +593,23 @@ func (w *Website) CleanUserVip() {
 	if err != nil {
 		return
 	}
-	w.DB.Model(&model.User{}).Where("`status` = 1 and `group_id` != ? and `expire_time` < ?", group.Id, time.Now().Unix()).UpdateColumn("group_id", group.Id)
+	// 宽限期内保留会员组
+	expireStamp := time.Now().Unix() - int64(w.PluginUser.VipGraceDays)*86400
+	var users []*model.User
+	w.DB.Model(&model.User{}).Where("`status` = 1 and `group_id` != ? and `expire_time` < ?", group.Id, expireStamp).Select("id", "group_id", "expire_time").Find(&users)
+	if len(users) == 0 {
+		return
+	}
+	var userIds = make([]uint, 0, len(users))
+	for _, user := range users {
+		userIds = append(userIds, user.Id)
+	}
+	w.DB.Model(&model.User{}).Where("`id` IN(?)", userIds).UpdateColumn("group_id", group.Id)
+	for _, user := range users {
+		w.addMembershipLog(user.Id, user.GroupId, group.Id, config.MembershipActionExpire, user.ExpireTime, "")
+		_ = w.SendNotification(user.Id, config.NotificationTypeVip, w.Lang("VIP已到期"),
+			fmt.Sprintf(w.Lang("您的VIP已到期，已调整为%s。"), group.Title), "")
+	}
 }
 
 func (w *Website) GetUserDiscount(userId uint, user *model.User) int64 {
@@ -0,0 +1,328 @@
+package provider
+
+import (
+	"archive/zip"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"gorm.io/gorm"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/model"
+	"log"
+	"time"
+)
+
+// UserDataExport 用户可以导出的个人数据
+type UserDataExport struct {
+	ExportTime  int64                 `json:"export_time"`
+	Profile     *model.User           `json:"profile"`
+	Addresses   []*model.OrderAddress `json:"addresses"`
+	Orders      []*model.Order        `json:"orders"`
+	Comments    []*model.Comment      `json:"comments"`
+	Guestbooks  []*model.Guestbook    `json:"guestbooks"`
+	Commissions []*model.Commission   `json:"commissions"`
+	Withdraws   []*model.UserWithdraw `json:"withdraws"`
+	Finances    []*model.Finance      `json:"finances"`
+}
+
+// ExportUserData 汇总用户的个人资料、订单、地址、评论、留言和佣金等数据
+func (w *Website) ExportUserData(userId uint) (*UserDataExport, error) {
+	user, err := w.GetUserInfoById(userId)
+	if err != nil {
+		return nil, err
+	}
+	user.GetThumb(w.PluginStorage.StorageUrl)
+	result := UserDataExport{
+		ExportTime: time.Now().Unix(),
+		Profile:    user,
+	}
+	if user.GroupId > 0 {
+		user.Group, _ = w.GetUserGroupInfo(user.GroupId)
+	}
+	w.DB.Where("`user_id` = ? and `status` = 1", userId).Order("id asc").Find(&result.Addresses)
+
+	w.DB.Where("`user_id` = ?", userId).Order("id asc").Find(&result.Orders)
+	for _, order := range result.Orders {
+		w.DB.Where("`order_id` = ?", order.OrderId).Find(&order.Details)
+		order.OrderAddress, _ = w.GetOrderAddressById(order.AddressId)
+		order.Refunds = w.GetOrderRefunds(order.OrderId)
+	}
+
+	w.DB.Where("`user_id` = ?", userId).Order("id asc").Find(&result.Comments)
+	guestbookTx := w.DB.Where("`user_id` = ?", userId)
+	// 旧的留言没有记录用户ID，按已验证的联系方式匹配
+	if contacts := w.getUserVerifiedContacts(user); len(contacts) > 0 {
+		guestbookTx = guestbookTx.Or("`user_id` = 0 AND `contact` IN(?)", contacts)
+	}
+	guestbookTx.Order("id asc").Find(&result.Guestbooks)
+	w.DB.Where("`user_id` = ?", userId).Order("id asc").Find(&result.Commissions)
+	w.DB.Where("`user_id` = ?", userId).Order("id asc").Find(&result.Withdraws)
+	w.DB.Where("`user_id` = ?", userId).Order("id asc").Find(&result.Finances)
+
+	return &result, nil
+}
+
+// ExportUserDataZip 将用户数据打包成zip，每一类数据一个json文件
+func (w *Website) ExportUserDataZip(userId uint) ([]byte, error) {
+	data, err := w.ExportUserData(userId)
+	if err != nil {
+		return nil, err
+	}
+	files := []struct {
+		Name string
+		Data interface{}
+	}{
+		{"profile.json", data.Profile},
+		{"addresses.json", data.Addresses},
+		{"orders.json", data.Orders},
+		{"comments.json", data.Comments},
+		{"guestbooks.json", data.Guestbooks},
+		{"commissions.json", data.Commissions},
+		{"withdraws.json", data.Withdraws},
+		{"finances.json", data.Finances},
+	}
+	buf := new(bytes.Buffer)
+	zipWriter := zip.NewWriter(buf)
+	modified := time.Unix(data.ExportTime, 0)
+	for _, file := range files {
+		content, err := json.MarshalIndent(file.Data, "", "  ")
+		if err != nil {
+			return nil, err
+		}
+		header := &zip.FileHeader{
+			Name:     file.Name,
+			Method:   zip.Deflate,
+			Modified: modified,
+		}
+		writer, err := zipWriter.CreateHeader(header)
+		if err != nil {
+			return nil, err
+		}
+		_, err = writer.Write(content)
+		if err != nil {
+			return nil, err
+		}
+	}
+	err = zipWriter.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
+
+func (w *Website) getUserVerifiedContacts(user *model.User) []string {
+	var contacts []string
+	if user.Verified == 0 {
+		return contacts
+	}
+	if user.Email != "" {
+		contacts = append(contacts, user.Email)
+	}
+	if user.Phone != "" {
+		contacts = append(contacts, user.Phone)
+	}
+
+	return contacts
+}
+
+// checkUserCanDelete 还有进行中的订单或提现时，不能注销
+func (w *Website) checkUserCanDelete(userId uint) error {
+	var total int64
+	w.DB.Model(&model.Order{}).Where("`user_id` = ? and `status` IN(?)", userId, []int{config.OrderStatusPaid, config.OrderStatusDelivering, config.OrderStatusRefunding}).Count(&total)
+	if total > 0 {
+		return errors.New(w.Lang("你还有未完成的订单，请完成后再注销账号"))
+	}
+	w.DB.Model(&model.UserWithdraw{}).Where("`user_id` = ? and `status` IN(?)", userId, []int{config.WithdrawStatusWaiting, config.WithdrawStatusAgree}).Count(&total)
+	if total > 0 {
+		return errors.New(w.Lang("你还有正在处理的提现，请处理完成后再注销账号"))
+	}
+
+	return nil
+}
+
+// GetUserDeletion 用户当前等待执行的注销申请
+func (w *Website) GetUserDeletion(userId uint) (*model.UserDeletion, error) {
+	var deletion model.UserDeletion
+	err := w.DB.Where("`user_id` = ? and `status` = ?", userId, config.UserDeletionStatusWaiting).Take(&deletion).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &deletion, nil
+}
+
+// RequestUserDeletion 用户申请注销账号，等待期内可以撤销，等待天数为0时立即注销
+func (w *Website) RequestUserDeletion(user *model.User, reason string) (*model.UserDeletion, error) {
+	if err := w.checkUserCanDelete(user.Id); err != nil {
+		return nil, err
+	}
+	deletion, err := w.GetUserDeletion(user.Id)
+	if err == nil {
+		return deletion, nil
+	}
+	deletion = &model.UserDeletion{
+		UserId:      user.Id,
+		UserName:    user.UserName,
+		Reason:      reason,
+		Status:      config.UserDeletionStatusWaiting,
+		ExecuteTime: time.Now().AddDate(0, 0, w.PluginUser.DeletionWaitDays).Unix(),
+	}
+	err = w.DB.Create(deletion).Error
+	if err != nil {
+		return nil, err
+	}
+	if w.PluginUser.DeletionWaitDays <= 0 {
+		err = w.ExecuteUserDeletion(deletion)
+		if err != nil {
+			return nil, err
+		}
+	} else {
+		_ = w.SendNotification(user.Id, config.NotificationTypeSystem, w.Lang("已收到你的注销申请"),
+			fmt.Sprintf(w.Lang("你的账号将于%s注销，在此之前登录并撤销申请即可继续使用。"), time.Unix(deletion.ExecuteTime, 0).Format("2006-01-02 15:04")), "")
+	}
+
+	return deletion, nil
+}
+
+func (w *Website) CancelUserDeletion(userId uint) error {
+	deletion, err := w.GetUserDeletion(userId)
+	if err != nil {
+		return errors.New(w.Lang("没有等待执行的注销申请"))
+	}
+	deletion.Status = config.UserDeletionStatusCanceled
+
+	return w.DB.Model(deletion).UpdateColumn("status", deletion.Status).Error
+}
+
+func (w *Website) GetUserDeletionById(id uint) (*model.UserDeletion, error) {
+	var deletion model.UserDeletion
+	err := w.DB.Where("`id` = ?", id).Take(&deletion).Error
+	if err != nil {
+		return nil, err
+	}
+
+	return &deletion, nil
+}
+
+// GetUserDeletions status 为 -2 时返回全部
+func (w *Website) GetUserDeletions(status int, page, pageSize int) ([]*model.UserDeletion, int64) {
+	var deletions []*model.UserDeletion
+	var total int64
+	offset := (page - 1) * pageSize
+	tx := w.DB.Model(&model.UserDeletion{})
+	if status != -2 {
+		tx = tx.Where("`status` = ?", status)
+	}
+	tx.Count(&total).Order("id desc").Limit(pageSize).Offset(offset).Find(&deletions)
+
+	return deletions, total
+}
+
+// ExecuteUserDeletion 执行注销申请
+func (w *Website) ExecuteUserDeletion(deletion *model.UserDeletion) error {
+	if deletion.Status != config.UserDeletionStatusWaiting {
+		return errors.New(w.Lang("该申请已处理"))
+	}
+	if err := w.checkUserCanDelete(deletion.UserId); err != nil {
+		return err
+	}
+	err := w.AnonymizeUser(deletion.UserId)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return err
+	}
+	deletion.Status = config.UserDeletionStatusFinished
+	deletion.FinishedTime = time.Now().Unix()
+
+	return w.DB.Model(deletion).UpdateColumns(map[string]interface{}{
+		"status":        deletion.Status,
+		"finished_time": deletion.FinishedTime,
+	}).Error
+}
+
+// CheckUserDeletions 计划任务，执行到期的注销申请
+func (w *Website) CheckUserDeletions() {
+	if w.DB == nil {
+		return
+	}
+	var deletions []*model.UserDeletion
+	w.DB.Where("`status` = ? and `execute_time` <= ?", config.UserDeletionStatusWaiting, time.Now().Unix()).Find(&deletions)
+	for _, deletion := range deletions {
+		err := w.ExecuteUserDeletion(deletion)
+		if err != nil {
+			log.Println("注销用户失败：", deletion.UserId, err.Error())
+		}
+	}
+}
+
+// AnonymizeUser 清除用户的个人资料。
+// 订单、佣金、提现、资金记录需要用于对账，只保留记录，不保留可以识别到个人的信息。
+func (w *Website) AnonymizeUser(userId uint) error {
+	var user model.User
+	err := w.DB.Unscoped().Where("`id` = ?", userId).Take(&user).Error
+	if err != nil {
+		return err
+	}
+	contacts := w.getUserVerifiedContacts(&user)
+	anonymousName := w.Lang("已注销用户")
+
+	return w.DB.Transaction(func(tx *gorm.DB) error {
+		err = tx.Unscoped().Model(&user).UpdateColumns(map[string]interface{}{
+			"user_name":   fmt.Sprintf("deleted_%d", user.Id),
+			"real_name":   "",
+			"avatar_url":  "",
+			"email":       "",
+			"phone":       "",
+			"password":    "",
+			"invite_code": "",
+			"status":      0,
+			"verified":    0,
+			"is_retailer": 0,
+		}).Error
+		if err != nil {
+			return err
+		}
+		// 订单使用的地址保留记录，清除收货人信息，地址簿直接删除
+		err = tx.Model(&model.OrderAddress{}).Where("`user_id` = ? and `status` = 0", user.Id).UpdateColumns(map[string]interface{}{
+			"name":         anonymousName,
+			"phone":        "",
+			"address_info": "",
+			"postcode":     "",
+		}).Error
+		if err != nil {
+			return err
+		}
+		tx.Unscoped().Where("`user_id` = ? and `status` = 1", user.Id).Delete(&model.OrderAddress{})
+		// 评论内容属于公开内容，只清除署名和IP
+		err = tx.Model(&model.Comment{}).Where("`user_id` = ?", user.Id).UpdateColumns(map[string]interface{}{
+			"user_name": anonymousName,
+			"ip":        "",
+		}).Error
+		if err != nil {
+			return err
+		}
+		guestbookTx := tx.Unscoped().Where("`user_id` = ?", user.Id)
+		if len(contacts) > 0 {
+			guestbookTx = guestbookTx.Or("`user_id` = 0 AND `contact` IN(?)", contacts)
+		}
+		guestbookTx.Delete(&model.Guestbook{})
+		// 下级用户不再关联到该用户
+		tx.Model(&model.User{}).Where("`parent_id` = ?", user.Id).UpdateColumn("parent_id", 0)
+		for _, item := range []interface{}{
+			&model.UserWechat{},
+			&model.WeappQrcode{},
+			&model.SubscribedUser{},
+			&model.UserFavorite{},
+			&model.UserHistory{},
+			&model.Notification{},
+			&model.NotificationRead{},
+		} {
+			tx.Unscoped().Where("`user_id` = ?", user.Id).Delete(item)
+		}
+		tx.Unscoped().Where("`type` = ? and `target_id` = ?", config.OauthTypeUser, user.Id).Delete(&model.OauthAccount{})
+
+		// 用户记录软删除，保留ID供订单等记录关联
+		return tx.Delete(&user).Error
+	})
+}
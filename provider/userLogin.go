@@ -0,0 +1,163 @@
+package provider
+
+import (
+	"errors"
+	"fmt"
+	"kandaoni.com/anqicms/library"
+	"kandaoni.com/anqicms/model"
+	"log"
+	"strings"
+	"time"
+)
+
+// loginAttempt 用户登录失败记录，和管理员的登录失败记录一样，只保存在内存中
+type loginAttempt struct {
+	Times     int
+	LastTime  int64
+	LockUntil int64
+}
+
+// 连续失败时，每次尝试之间最多需要间隔的秒数
+const maxLoginDelaySeconds = 60
+
+// getUserLoginKeys 账号存在时按用户ID记录，这样用户名、邮箱、手机号登录共用一个失败次数
+func (w *Website) getUserLoginKeys(account, ip string) (string, string, *model.User) {
+	var user model.User
+	account = strings.TrimSpace(account)
+	err := w.DB.Where("`user_name` = ? OR `email` = ? OR `phone` = ?", account, account, account).Take(&user).Error
+	if err == nil {
+		return fmt.Sprintf("user:%d", user.Id), "ip:" + ip, &user
+	}
+
+	return "account:" + strings.ToLower(account), "ip:" + ip, nil
+}
+
+// getLoginAttempt 需要在加锁后调用，过期的记录会被清除
+func (w *Website) getLoginAttempt(key string) *loginAttempt {
+	if w.userLoginAttempts == nil {
+		w.userLoginAttempts = map[string]*loginAttempt{}
+	}
+	attempt, ok := w.userLoginAttempts[key]
+	if !ok {
+		return nil
+	}
+	nowStamp := time.Now().Unix()
+	if attempt.LockUntil < nowStamp && attempt.LastTime < nowStamp-int64(w.PluginUser.LoginLockMinutes)*60 {
+		delete(w.userLoginAttempts, key)
+		return nil
+	}
+
+	return attempt
+}
+
+// CheckUserLoginLimit 登录前检查账号和IP是否被临时锁定，连续失败时，每次尝试的间隔逐渐增加
+func (w *Website) CheckUserLoginLimit(account, ip string) error {
+	accountKey, ipKey, _ := w.getUserLoginKeys(account, ip)
+	w.loginAttemptMutex.Lock()
+	defer w.loginAttemptMutex.Unlock()
+
+	nowStamp := time.Now().Unix()
+	for _, key := range []string{accountKey, ipKey} {
+		attempt := w.getLoginAttempt(key)
+		if attempt == nil {
+			continue
+		}
+		if attempt.LockUntil > nowStamp {
+			minutes := (attempt.LockUntil - nowStamp + 59) / 60
+			return errors.New(fmt.Sprintf(w.Lang("登录失败次数过多，请%d分钟后重试"), minutes))
+		}
+	}
+	attempt := w.getLoginAttempt(accountKey)
+	if attempt != nil && attempt.Times >= 2 {
+		delay := int64(1) << (attempt.Times - 2)
+		if delay > maxLoginDelaySeconds {
+			delay = maxLoginDelaySeconds
+		}
+		if attempt.LastTime+delay > nowStamp {
+			return errors.New(fmt.Sprintf(w.Lang("操作过于频繁，请%d秒后重试"), attempt.LastTime+delay-nowStamp))
+		}
+	}
+
+	return nil
+}
+
+// RecordUserLoginFailure 记录登录失败，达到次数后临时锁定，账号被锁定时可以邮件通知用户
+func (w *Website) RecordUserLoginFailure(account, ip string) {
+	accountKey, ipKey, user := w.getUserLoginKeys(account, ip)
+	w.loginAttemptMutex.Lock()
+	defer w.loginAttemptMutex.Unlock()
+
+	if len(w.userLoginAttempts) > 10000 {
+		// 清理过期的记录，避免占用过多内存
+		for key := range w.userLoginAttempts {
+			w.getLoginAttempt(key)
+		}
+	}
+	nowStamp := time.Now().Unix()
+	lockUntil := nowStamp + int64(w.PluginUser.LoginLockMinutes)*60
+	var accountLocked bool
+	for _, key := range []string{accountKey, ipKey} {
+		attempt := w.getLoginAttempt(key)
+		if attempt == nil {
+			attempt = &loginAttempt{}
+			w.userLoginAttempts[key] = attempt
+		}
+		attempt.Times++
+		attempt.LastTime = nowStamp
+		maxFailures := w.PluginUser.LoginMaxFailures
+		if key == ipKey {
+			maxFailures = w.PluginUser.LoginIpMaxFailures
+		}
+		if attempt.Times >= maxFailures && attempt.LockUntil < nowStamp {
+			attempt.LockUntil = lockUntil
+			// 锁定结束后重新计数
+			attempt.Times = 0
+			if key == accountKey {
+				accountLocked = true
+			}
+		}
+	}
+
+	if accountLocked && user != nil && user.Email != "" && w.PluginUser.LoginLockMail {
+		go w.sendUserLockedMail(user, ip)
+	}
+}
+
+// ClearUserLoginFailure 登录成功或重置密码后，清除账号的失败记录，IP的记录不清除
+func (w *Website) ClearUserLoginFailure(account string) {
+	accountKey, _, _ := w.getUserLoginKeys(account, "")
+	w.loginAttemptMutex.Lock()
+	defer w.loginAttemptMutex.Unlock()
+
+	if w.userLoginAttempts != nil {
+		delete(w.userLoginAttempts, accountKey)
+	}
+}
+
+func (w *Website) sendUserLockedMail(user *model.User, ip string) {
+	subject := fmt.Sprintf(w.Lang("%s账号安全提醒"), w.System.SiteName)
+	content := fmt.Sprintf(w.Lang("您的账号 %s 因连续登录失败已被临时锁定%d分钟，最后一次尝试登录的IP为 %s。如果不是您本人操作，建议尽快修改密码。"), user.UserName, w.PluginUser.LoginLockMinutes, ip)
+	err := w.SendMail(subject, content, user.Email)
+	if err != nil {
+		log.Println("发送账号锁定邮件失败：", err.Error())
+	}
+}
+
+// CheckPasswordPolicy 检查用户设置的密码是否符合密码策略
+func (w *Website) CheckPasswordPolicy(password string) error {
+	minLength := w.PluginUser.PasswordMinLength
+	if minLength < 6 {
+		minLength = 6
+	}
+	if len(password) < minLength {
+		return errors.New(fmt.Sprintf(w.Lang("请填写%d位以上的密码"), minLength))
+	}
+	if w.PluginUser.PasswordComplexity > 1 && library.PasswordComplexity(password) < w.PluginUser.PasswordComplexity {
+		return errors.New(fmt.Sprintf(w.Lang("密码至少需要包含%d种字符：小写字母、大写字母、数字、符号"), w.PluginUser.PasswordComplexity))
+	}
+	if w.PluginUser.PasswordCheckBreach && library.IsBreachedPassword(password) {
+		return errors.New(w.Lang("该密码过于常见，请更换其他密码"))
+	}
+
+	return nil
+}
@@ -0,0 +1,243 @@
+package provider
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"kandaoni.com/anqicms/config"
+	"kandaoni.com/anqicms/library"
+	"kandaoni.com/anqicms/model"
+	"log"
+	"net/url"
+	"sync"
+	"time"
+)
+
+const (
+	verifyCodeInterval  = 60 // 同一个账号两次发送验证码的最小间隔，秒
+	verifyCodeMaxErrors = 5  // 验证码错误次数超过后失效
+	verifyLinkExpire    = 24 * time.Hour
+)
+
+// 记录验证码的发送时间和错误次数
+var verifySendTimes sync.Map
+var verifyErrorTimes sync.Map
+
+// GetUserByAccount 通过邮箱或手机号获取用户
+func (w *Website) GetUserByAccount(account string) (*model.User, error) {
+	if w.VerifyEmailFormat(account) {
+		return w.GetUserInfoByEmail(account)
+	}
+	if w.VerifyCellphoneFormat(account) {
+		return w.GetUserInfoByPhone(account)
+	}
+
+	return nil, errors.New(w.Lang("请填写正确的邮箱或手机号"))
+}
+
+// getVerifyTarget 根据验证方式获取发送的目标，未指定方式时，优先使用邮箱
+func (w *Website) getVerifyTarget(user *model.User, way string) (string, string, error) {
+	if way == "" {
+		way = config.VerifyWayEmail
+		if user.Email == "" {
+			way = config.VerifyWayPhone
+		}
+	}
+	if way == config.VerifyWayEmail && user.Email != "" {
+		return way, user.Email, nil
+	}
+	if way == config.VerifyWayPhone && user.Phone != "" {
+		return way, user.Phone, nil
+	}
+
+	return "", "", errors.New(w.Lang("该账号没有可用于验证的邮箱或手机号"))
+}
+
+// SendUserVerification 发送账号验证码，邮件中同时附带验证链接
+func (w *Website) SendUserVerification(user *model.User, way string) error {
+	way, target, err := w.getVerifyTarget(user, way)
+	if err != nil {
+		return err
+	}
+	var link string
+	if way == config.VerifyWayEmail {
+		link = w.GetUserVerifyLink(user)
+	}
+
+	return w.sendVerifyCode(config.VerifyPurposeVerify, way, target, link)
+}
+
+// VerifyUser 使用验证码完成账号验证
+func (w *Website) VerifyUser(user *model.User, way, code string) error {
+	way, target, err := w.getVerifyTarget(user, way)
+	if err != nil {
+		return err
+	}
+	if !w.checkVerifyCode(config.VerifyPurposeVerify, target, code) {
+		return errors.New(w.Lang("验证码不正确"))
+	}
+
+	return w.setUserVerified(user)
+}
+
+// GetUserVerifyLink 生成邮箱验证链接
+func (w *Website) GetUserVerifyLink(user *model.User) string {
+	expire := time.Now().Add(verifyLinkExpire).Unix()
+	query := url.Values{}
+	query.Set("user_id", fmt.Sprintf("%d", user.Id))
+	query.Set("expire", fmt.Sprintf("%d", expire))
+	query.Set("sign", w.signUserVerify(user.Id, user.Email, expire))
+
+	return w.System.BaseUrl + "/api/user/verify/link?" + query.Encode()
+}
+
+// VerifyUserLink 校验邮箱验证链接，邮箱变更后链接失效
+func (w *Website) VerifyUserLink(userId uint, expire int64, sign string) error {
+	if expire < time.Now().Unix() {
+		return errors.New(w.Lang("验证链接已过期"))
+	}
+	user, err := w.GetUserInfoById(userId)
+	if err != nil {
+		return errors.New(w.Lang("验证链接无效"))
+	}
+	if user.Email == "" || !hmac.Equal([]byte(sign), []byte(w.signUserVerify(user.Id, user.Email, expire))) {
+		return errors.New(w.Lang("验证链接无效"))
+	}
+
+	return w.setUserVerified(user)
+}
+
+// SendPasswordReset 发送重置密码的验证码，账号不存在时不提示，避免被用于探测账号
+func (w *Website) SendPasswordReset(account string) error {
+	user, err := w.GetUserByAccount(account)
+	if err != nil {
+		if w.VerifyEmailFormat(account) || w.VerifyCellphoneFormat(account) {
+			return nil
+		}
+		return err
+	}
+	way := config.VerifyWayEmail
+	if account != user.Email {
+		way = config.VerifyWayPhone
+	}
+
+	return w.sendVerifyCode(config.VerifyPurposeReset, way, account, "")
+}
+
+// ResetUserPassword 使用验证码重置密码，重置成功后账号同时视为已验证
+func (w *Website) ResetUserPassword(account, code, password string) error {
+	if err := w.CheckPasswordPolicy(password); err != nil {
+		return err
+	}
+	user, err := w.GetUserByAccount(account)
+	if err != nil {
+		return errors.New(w.Lang("验证码不正确"))
+	}
+	if !w.checkVerifyCode(config.VerifyPurposeReset, account, code) {
+		return errors.New(w.Lang("验证码不正确"))
+	}
+	err = user.EncryptPassword(password)
+	if err != nil {
+		return err
+	}
+	user.Verified = 1
+	w.DB.Model(user).Select("password", "verified").Updates(user)
+	// 重置密码后，解除登录锁定
+	w.ClearUserLoginFailure(account)
+
+	return nil
+}
+
+func (w *Website) setUserVerified(user *model.User) error {
+	user.Verified = 1
+
+	return w.DB.Model(user).UpdateColumn("verified", user.Verified).Error
+}
+
+func (w *Website) sendVerifyCode(purpose, way, target, link string) error {
+	key := w.verifyCodeKey(purpose, target)
+	if last, ok := verifySendTimes.Load(key); ok && last.(int64) > time.Now().Unix()-verifyCodeInterval {
+		return errors.New(w.Lang("发送太频繁，请稍后再试"))
+	}
+	library.CodeCache.Delete(key)
+	code := library.CodeCache.Generate(key)
+	verifySendTimes.Store(key, time.Now().Unix())
+	verifyErrorTimes.Delete(key)
+
+	var subject, content string
+	if purpose == config.VerifyPurposeReset {
+		subject = fmt.Sprintf(w.Lang("%s重置密码"), w.System.SiteName)
+		content = fmt.Sprintf(w.Lang("您正在重置密码，验证码：%s，30分钟内有效。如非本人操作，请忽略。"), code)
+	} else {
+		subject = fmt.Sprintf(w.Lang("%s账号验证"), w.System.SiteName)
+		content = fmt.Sprintf(w.Lang("您的验证码：%s，30分钟内有效。"), code)
+		if link != "" {
+			content += "\n" + w.Lang("也可以点击链接完成验证：") + link
+		}
+	}
+	var err error
+	if way == config.VerifyWayEmail {
+		err = w.SendMail(subject, content, target)
+	} else {
+		err = w.SendSms(target, content)
+	}
+	if err != nil {
+		log.Println("发送验证码失败：", target, err.Error())
+		verifySendTimes.Delete(key)
+		library.CodeCache.Delete(key)
+	}
+
+	return err
+}
+
+func (w *Website) checkVerifyCode(purpose, target, code string) bool {
+	key := w.verifyCodeKey(purpose, target)
+	if code == "" || library.CodeCache.Get(key, false) == "" {
+		return false
+	}
+	if !library.CodeCache.Verify(key, code, false) {
+		times := 1
+		if v, ok := verifyErrorTimes.Load(key); ok {
+			times += v.(int)
+		}
+		verifyErrorTimes.Store(key, times)
+		if times >= verifyCodeMaxErrors {
+			// 错误次数过多，验证码失效
+			library.CodeCache.Delete(key)
+			verifyErrorTimes.Delete(key)
+		}
+		return false
+	}
+	library.CodeCache.Delete(key)
+	verifyErrorTimes.Delete(key)
+
+	return true
+}
+
+func (w *Website) verifyCodeKey(purpose, target string) string {
+	return fmt.Sprintf("%d-%s-%s", w.Id, purpose, target)
+}
+
+func (w *Website) signUserVerify(userId uint, email string, expire int64) string {
+	mac := hmac.New(sha256.New, []byte(config.Server.Server.TokenSecret))
+	mac.Write([]byte(fmt.Sprintf("%d-verify-%d-%s-%d", w.Id, userId, email, expire)))
+
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// 已有用户验证状态迁移完成的标记
+const userVerifiedMigratedKey = "user_verified_migrated"
+
+// migrateUserVerified 账号验证功能上线前注册的用户都视为已验证，避免开启登录前验证后无法登录
+func (w *Website) migrateUserVerified() {
+	if w.GetSettingValue(userVerifiedMigratedKey) != "" {
+		return
+	}
+	err := w.DB.Model(&model.User{}).Where("`verified` = 0").UpdateColumn("verified", 1).Error
+	if err != nil {
+		return
+	}
+	_ = w.SaveSettingValue(userVerifiedMigratedKey, 1)
+}
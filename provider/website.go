@@ -14,6 +14,7 @@ import (
 	"net/url"
 	"os"
 	"strings"
+	"sync"
 )
 
 type Website struct {
@@ -31,12 +32,24 @@ type Website struct {
 	parsedPatten            *RewritePatten
 	searcher                *engine.Engine
 	fulltextStatus          int // 0 未启用，1初始化中，2 初始化完成
+	brokenLinkStatus        *response.BrokenLinkStatus
+	brokenLinkMutex         sync.Mutex
+	seoAuditRunning         bool
+	seoAuditMutex           sync.Mutex
+	internalLinkRebuilding  bool
+	internalLinkMutex       sync.Mutex
+	archiveExtracting       bool
+	archiveExtractMutex     sync.Mutex
 	cachedTodayArticleCount response.CacheArticleCount
 	transferWebsite         *TransferWebsite
 	weappClient             *weapp.Client
 	wechatServer            *wechat.Server
 	CachedStatistics        *response.Statistics
 	AdminLoginError         response.LoginError
+	userLoginAttempts       map[string]*loginAttempt
+	loginAttemptMutex       sync.Mutex
+	pushQueueRunning        bool
+	pushQueueMutex          sync.Mutex
 	MemCache                *memCache
 
 	System  config.SystemConfig  `json:"system"`
@@ -60,6 +73,10 @@ type Website struct {
 	PluginRetailer    config.PluginRetailerConfig   `json:"plugin_retailer"`
 	PluginUser        config.PluginUserConfig       `json:"plugin_user"`
 	PluginOrder       config.PluginOrderConfig      `json:"plugin_order"`
+	PluginOauth       config.PluginOauthConfig      `json:"plugin_oauth"`
+	PluginPoint       config.PluginPointConfig      `json:"plugin_point"`
+	PluginComment     config.PluginCommentConfig    `json:"plugin_comment"`
+	PluginBrokenLink  config.PluginBrokenLinkConfig `json:"plugin_broken_link"`
 	PluginFulltext    config.PluginFulltextConfig   `json:"plugin_fulltext"`
 	PluginTitleImage  config.PluginTitleImageConfig `json:"plugin_title_image"`
 
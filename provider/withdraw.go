@@ -73,6 +73,9 @@ func (w *Website) SetUserWithdrawApproval(req *request.UserWithdrawRequest) erro
 		//
 	}
 
+	_ = w.SendNotification(withdraw.UserId, config.NotificationTypeWithdraw, w.Lang("提现申请已通过"),
+		fmt.Sprintf(w.Lang("您申请提现的%.2f元已审核通过，将尽快打款。"), float64(withdraw.Amount)/100), "")
+
 	return nil
 }
 
@@ -23,8 +23,13 @@ type Archive struct {
 	UserId       uint                   `json:"user_id"`
 	Price        int64                  `json:"price"`
 	Stock        int64                  `json:"stock"`
-	ReadLevel    int                    `json:"read_level"` // 阅读关联 group level
-	Draft        bool                   `json:"draft"`      // 是否是存草稿
+	ReadLevel    int                    `json:"read_level"`  // 阅读关联 group level
+	ReadPoints   int64                  `json:"read_points"` // 阅读需要消耗的积分
+	Draft        bool                   `json:"draft"`       // 是否是存草稿
+	DeliveryType string                 `json:"delivery_type"`
+	DeliveryFile string                 `json:"delivery_file"`
+	ShippingId   uint                   `json:"shipping_id"`
+	Weight       int64                  `json:"weight"`
 
 	// 是否强制保存
 	ForceSave bool `json:"force_save"`
@@ -53,3 +58,25 @@ type ArchivesUpdateRequest struct {
 	Flag       string `json:"flag"`
 	Time       uint   `json:"time"`
 }
+
+type ArchiveSimilarRequest struct {
+	Id         uint   `json:"id"`
+	Title      string `json:"title"`
+	Content    string `json:"content"`
+	Similarity int    `json:"similarity"`
+}
+
+type ArchiveDuplicateRequest struct {
+	KeepId uint   `json:"keep_id"`
+	Ids    []uint `json:"ids"`
+	Action string `json:"action"` // redirect|merge
+}
+
+type ArchiveExtractRequest struct {
+	Ids        []uint   `json:"ids"`
+	CategoryId uint     `json:"category_id"`
+	Title      string   `json:"title"`
+	Content    string   `json:"content"`
+	Fields     []string `json:"fields"` // keywords|description|tags
+	Overwrite  bool     `json:"overwrite"`
+}
@@ -11,6 +11,9 @@ type ModuleRequest struct {
 	IsSystem  int                  `json:"is_system"`
 	TitleName string               `json:"title_name"`
 	Status    uint                 `json:"status"`
+
+	DeliveryType string `json:"delivery_type"`
+	ShippingId   uint   `json:"shipping_id"`
 }
 
 type ModuleFieldRequest struct {
@@ -1,5 +1,7 @@
 package request
 
+import "kandaoni.com/anqicms/model"
+
 type OrderRequest struct {
 	Id                uint                 `json:"id"`
 	OrderId           string               `json:"order_id"`
@@ -17,6 +19,7 @@ type OrderRequest struct {
 	DeliverTime       int64                `json:"deliver_time"`
 	FinishedTime      int64                `json:"finished_time"`
 	DiscountAmount    int64                `json:"discount_amount"` // 可能一个订单支持多个优惠
+	UsePoints         int64                `json:"use_points"`      // 下单时使用多少积分抵扣
 	CouponCodeId      string               `json:"-"`
 	ShareUserId       uint                 `json:"share_user_id"`       // 分享者
 	ShareAmount       int64                `json:"share_amount"`        // 分销可得金额
@@ -53,8 +56,12 @@ type OrderDetail struct {
 }
 
 type OrderRefundRequest struct {
-	OrderId string `json:"order_id"`
-	Status  int    `json:"status"`
+	OrderId  string `json:"order_id"`
+	RefundId string `json:"refund_id"`
+	DetailId uint   `json:"detail_id"` // 为0时退整单
+	Amount   int64  `json:"amount"`    // 为0时退剩余可退金额
+	Reason   string `json:"reason"`
+	Status   int    `json:"status"`
 }
 
 type OrderAddressRequest struct {
@@ -68,6 +75,26 @@ type OrderAddressRequest struct {
 	AddressInfo string `json:"address_info"`
 	Postcode    string `json:"postcode"`
 	Status      int    `json:"status"`
+	IsDefault   int    `json:"is_default"`
+}
+
+type LicenseKeyRequest struct {
+	ArchiveId uint   `json:"archive_id"`
+	Content   string `json:"content"`
+	Ids       []uint `json:"ids"`
+}
+
+type ShippingTemplateRequest struct {
+	Id             uint                  `json:"id"`
+	Title          string                `json:"title"`
+	Type           string                `json:"type"`
+	FirstUnit      int64                 `json:"first_unit"`
+	FirstFee       int64                 `json:"first_fee"`
+	AdditionalUnit int64                 `json:"additional_unit"`
+	AdditionalFee  int64                 `json:"additional_fee"`
+	FreeAmount     int64                 `json:"free_amount"`
+	Regions        model.ShippingRegions `json:"regions"`
+	IsDefault      int                   `json:"is_default"`
 }
 
 type OrderExportRequest struct {
@@ -31,11 +31,18 @@ type PluginComment struct {
 	ParentId  uint   `json:"parent_id"`
 	ToUid     uint   `json:"to_uid"`
 	Status    uint   `json:"status"`
+	// 垃圾评论检查结果，只能由服务端设置
+	SpamScore int    `json:"-"`
+	SpamInfo  string `json:"-"`
 
 	// 批量更新
 	Ids []uint `json:"ids"`
 }
 
+type CommentSubscribeRequest struct {
+	Subscribe bool `json:"subscribe"`
+}
+
 type PluginAnchor struct {
 	Id     uint   `json:"id"`
 	Title  string `json:"title"`
@@ -98,9 +105,13 @@ type PluginTag struct {
 }
 
 type PluginRedirectRequest struct {
-	Id      uint   `json:"id"`
-	FromUrl string `json:"from_url"`
-	ToUrl   string `json:"to_url"`
+	Id         uint   `json:"id"`
+	FromUrl    string `json:"from_url"`
+	ToUrl      string `json:"to_url"`
+	MatchType  int    `json:"match_type"`
+	StatusCode int    `json:"status_code"`
+	KeepQuery  int    `json:"keep_query"`
+	Priority   int    `json:"priority"`
 }
 
 type PluginRedirectsRequest struct {
@@ -116,3 +127,22 @@ type PluginReplaceRequest struct {
 	Places     []string                `json:"places"`
 	Keywords   []config.ReplaceKeyword `json:"keywords"`
 }
+
+type NotFoundRequest struct {
+	Id         uint   `json:"id"`
+	Url        string `json:"url"`
+	ToUrl      string `json:"to_url"`
+	StatusCode int    `json:"status_code"`
+}
+
+type PluginPushQueueRequest struct {
+	Ids    []uint   `json:"ids"`
+	Urls   []string `json:"urls"`
+	Action string   `json:"action"`
+}
+
+type PluginBrokenLinkRequest struct {
+	Ids    []uint `json:"ids"`
+	Action string `json:"action"`
+	ToUrl  string `json:"to_url"`
+}
@@ -19,6 +19,19 @@ type UserRequest struct {
 	Password   string `json:"password"`
 	InviteCode string `json:"invite_code"`
 	ExpireTime int64  `json:"expire_time"`
+	Verified   *int   `json:"verified"`
+}
+
+type ApiUserVerifyRequest struct {
+	Way     string `json:"way"`     // 验证方式，支持 email|phone
+	Account string `json:"account"` // 未登录时，填写邮箱或手机号
+	Code    string `json:"code"`
+}
+
+type ApiPasswordResetRequest struct {
+	Account  string `json:"account"` // 邮箱或手机号
+	Code     string `json:"code"`
+	Password string `json:"password"`
 }
 
 type UserPasswordRequest struct {
@@ -71,3 +84,39 @@ type ApiLoginRequest struct {
 	CaptchaId string `json:"captcha_id"`
 	Captcha   string `json:"captcha"`
 }
+
+type OauthAccountRequest struct {
+	Id uint `json:"id"`
+}
+
+type AdminOauthExchangeRequest struct {
+	Code string `json:"code"`
+}
+
+type UserDeletionRequest struct {
+	Id       uint   `json:"id"`
+	Password string `json:"password"`
+	Reason   string `json:"reason"`
+}
+
+type UserPointRequest struct {
+	UserId    uint   `json:"user_id"`
+	ArchiveId uint   `json:"archive_id"`
+	Points    int64  `json:"points"`
+	Remark    string `json:"remark"`
+}
+
+type UserFavoriteRequest struct {
+	ArchiveId  uint   `json:"archive_id"`
+	ArchiveIds []uint `json:"archive_ids"`
+	Progress   int    `json:"progress"`
+}
+
+type NotificationRequest struct {
+	Id      uint   `json:"id"`
+	Ids     []uint `json:"ids"`
+	GroupId uint   `json:"group_id"`
+	Title   string `json:"title"`
+	Content string `json:"content"`
+	Link    string `json:"link"`
+}
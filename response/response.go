@@ -1,5 +1,7 @@
 package response
 
+import "kandaoni.com/anqicms/model"
+
 type AuthResponse struct {
 	HashKey     string `json:"hash_key"`
 	CreatedTime int64  `json:"created_time"`
@@ -45,3 +47,85 @@ type PushLog struct {
 	Spider      string `json:"spider"`
 	Result      string `json:"result"`
 }
+
+type PushQueueSummary struct {
+	BaiduRemain int                `json:"baidu_remain"` // 百度当天剩余配额，-1 表示未知
+	Engines     []*PushEngineCount `json:"engines"`
+}
+
+type PushEngineCount struct {
+	Engine string `json:"engine"`
+	Wait   int64  `json:"wait"`
+	Ok     int64  `json:"ok"`
+	Retry  int64  `json:"retry"`
+	Failed int64  `json:"failed"`
+}
+
+// SeoAuditSummary 全站页面SEO检查的汇总
+type SeoAuditSummary struct {
+	Running      bool             `json:"running"`
+	Total        int64            `json:"total"`
+	AverageScore float64          `json:"average_score"`
+	Good         int64            `json:"good"` // 80分及以上
+	Fair         int64            `json:"fair"` // 60-79分
+	Poor         int64            `json:"poor"` // 60分以下
+	Issues       []*SeoIssueCount `json:"issues"`
+}
+
+type SeoIssueCount struct {
+	Code  string `json:"code"`
+	Name  string `json:"name"`
+	Total int64  `json:"total"`
+}
+
+// InternalLinkTarget 被链接的页面及入链数量
+type InternalLinkTarget struct {
+	TargetType string `json:"target_type"`
+	TargetId   uint   `json:"target_id"`
+	TargetUrl  string `json:"target_url"`
+	Title      string `json:"title"`
+	Total      int64  `json:"total"`   // 入链数量
+	Sources    int64  `json:"sources"` // 来源页面数量
+}
+
+// InternalLinkAnchor 指向同一页面的锚文字分布
+type InternalLinkAnchor struct {
+	AnchorText string  `json:"anchor_text"`
+	Total      int64   `json:"total"`
+	Percent    float64 `json:"percent"`
+}
+
+// InternalLinkSource 内链数量超过锚文本密度的页面
+type InternalLinkSource struct {
+	SourceType   string `json:"source_type"`
+	SourceId     uint   `json:"source_id"`
+	Title        string `json:"title"`
+	Link         string `json:"link"`
+	SourceLength int    `json:"source_length"`
+	Total        int64  `json:"total"`
+	MaxLinks     int    `json:"max_links"`
+}
+
+// SimilarArchive 相似的文档，Similarity 是相似度百分比
+type SimilarArchive struct {
+	Id          uint   `json:"id"`
+	Title       string `json:"title"`
+	Link        string `json:"link"`
+	Status      uint   `json:"status"`
+	Views       uint   `json:"views"`
+	CreatedTime int64  `json:"created_time"`
+	Similarity  int    `json:"similarity"`
+}
+
+// DuplicateGroup 相似文档分组，Id 是组内最早的文档
+type DuplicateGroup struct {
+	Id       uint              `json:"id"`
+	Archives []*SimilarArchive `json:"archives"`
+}
+
+// ArchiveExtract 从内容中提取的关键词、摘要和标签库中推荐的标签
+type ArchiveExtract struct {
+	Keywords    []string     `json:"keywords"`
+	Description string       `json:"description"`
+	Tags        []*model.Tag `json:"tags"`
+}
@@ -30,3 +30,44 @@ type Statistics struct {
 	PageCount       int64               `json:"page_count"`
 	AttachmentCount int64               `json:"attachment_count"`
 }
+
+// NotFoundUrl 404报告中按链接汇总的记录
+type NotFoundUrl struct {
+	Url        string `json:"url"`
+	Total      int64  `json:"total"`
+	FirstTime  int64  `json:"first_time"`
+	LastTime   int64  `json:"last_time"`
+	Redirected bool   `json:"redirected"` // 是否已经有跳转规则
+}
+
+type NotFoundCount struct {
+	Name  string `json:"name"`
+	Total int64  `json:"total"`
+}
+
+// NotFoundSuggestion 根据链接相似度推荐的跳转目标
+type NotFoundSuggestion struct {
+	Type  string  `json:"type"`
+	Id    uint    `json:"id"`
+	Title string  `json:"title"`
+	Link  string  `json:"link"`
+	Score float64 `json:"score"`
+}
+
+type NotFoundDetail struct {
+	Url         string                `json:"url"`
+	Referers    []NotFoundCount       `json:"referers"`
+	Spiders     []NotFoundCount       `json:"spiders"`
+	Suggestions []*NotFoundSuggestion `json:"suggestions"`
+}
+
+// BrokenLinkStatus 失效链接扫描进度
+type BrokenLinkStatus struct {
+	Running   bool   `json:"running"`
+	Total     int64  `json:"total"`    // 需要扫描的内容数量
+	Finished  int64  `json:"finished"` // 已扫描的内容数量
+	Found     int64  `json:"found"`    // 发现的失效链接数量
+	StartTime int64  `json:"start_time"`
+	EndTime   int64  `json:"end_time"`
+	Message   string `json:"message"`
+}
@@ -12,6 +12,7 @@ func Register(app *iris.Application) {
 	app.Use(controller.Inspect)
 	app.OnErrorCode(iris.StatusNotFound, controller.NotFound)
 	app.OnErrorCode(iris.StatusInternalServerError, controller.InternalServerError)
+	app.OnErrorCode(iris.StatusGone, controller.Gone)
 	app.Use(controller.CheckTemplateType)
 	app.Use(controller.CheckCloseSite)
 	app.Use(controller.Common)
@@ -26,6 +27,7 @@ func Register(app *iris.Application) {
 
 	app.HandleMany(iris.MethodPost, "/comment/publish /{base:string}/comment/publish", controller.LogAccess, middleware.ParseUserToken, controller.CommentPublish)
 	app.HandleMany(iris.MethodPost, "/comment/praise /{base:string}/comment/praise", controller.LogAccess, middleware.ParseUserToken, controller.CommentPraise)
+	app.HandleMany(iris.MethodGet, "/comment/unsubscribe /{base:string}/comment/unsubscribe", controller.LogAccess, controller.CommentUnsubscribe)
 	app.HandleMany(iris.MethodGet, "/comment/{id:uint} /{base:string}/comment/{id:uint}", controller.LogAccess, middleware.ParseUserToken, controller.CommentList)
 
 	app.HandleMany(iris.MethodGet, "/guestbook.html /{base:string}/guestbook.html", controller.LogAccess, middleware.ParseUserToken, controller.GuestbookPage)
@@ -60,16 +62,49 @@ func Register(app *iris.Application) {
 		// 前端api
 		api.Post("/login", controller.ApiLogin)
 		api.Post("/register", controller.ApiRegister)
+		api.Get("/oauth/login", controller.ApiOauthLogin)
+		api.Get("/oauth/callback", controller.ApiOauthCallback)
+		api.Get("/oauth/accounts", middleware.UserAuth, controller.ApiGetOauthAccounts)
+		api.Post("/oauth/unbind", middleware.UserAuth, controller.ApiUnbindOauthAccount)
+		api.Post("/user/verify/send", controller.ApiSendUserVerify)
+		api.Post("/user/verify", controller.ApiUserVerify)
+		api.Get("/user/verify/link", controller.ApiUserVerifyLink)
+		api.Post("/user/password/reset/request", controller.ApiPasswordResetRequest)
+		api.Post("/user/password/reset/confirm", controller.ApiPasswordResetConfirm)
 		api.Get("/user/detail", middleware.UserAuth, controller.ApiGetUserDetail)
 		api.Post("/user/detail", middleware.UserAuth, controller.ApiUpdateUserDetail)
 		api.Get("/user/groups", middleware.UserAuth, controller.ApiGetUserGroups)
 		api.Get("/user/group/detail", middleware.UserAuth, controller.ApiGetUserGroupDetail)
+		api.Get("/user/memberships", middleware.UserAuth, controller.ApiGetUserMemberships)
 		api.Post("/user/password", middleware.UserAuth, controller.ApiUpdateUserPassword)
+		api.Get("/user/export", middleware.UserAuth, controller.ApiExportUserData)
+		api.Get("/user/deletion", middleware.UserAuth, controller.ApiGetUserDeletion)
+		api.Post("/user/deletion", middleware.UserAuth, controller.ApiRequestUserDeletion)
+		api.Post("/user/deletion/cancel", middleware.UserAuth, controller.ApiCancelUserDeletion)
+		api.Get("/user/favorites", middleware.UserAuth, controller.ApiGetUserFavorites)
+		api.Post("/user/favorites", middleware.UserAuth, controller.ApiAddUserFavorite)
+		api.Post("/user/favorites/delete", middleware.UserAuth, controller.ApiDeleteUserFavorite)
+		api.Get("/user/favorites/check", middleware.UserAuth, controller.ApiCheckUserFavorite)
+		api.Get("/user/history", middleware.UserAuth, controller.ApiGetUserHistories)
+		api.Post("/user/history", middleware.UserAuth, controller.ApiRecordUserHistory)
+		api.Post("/user/history/delete", middleware.UserAuth, controller.ApiDeleteUserHistory)
+		api.Get("/user/history/continue", middleware.UserAuth, controller.ApiGetUserContinueReading)
+		api.Get("/user/points", middleware.UserAuth, controller.ApiGetUserPoints)
+		api.Post("/archive/unlock", middleware.UserAuth, controller.ApiUnlockArchiveByPoints)
+		api.Get("/notifications", middleware.UserAuth, controller.ApiGetNotifications)
+		api.Get("/notifications/unread", middleware.UserAuth, controller.ApiGetUnreadNotificationCount)
+		api.Post("/notifications/read", middleware.UserAuth, controller.ApiReadNotifications)
 		api.Get("/orders", middleware.UserAuth, controller.ApiGetOrders)
 		api.Post("/order/create", middleware.UserAuth, controller.ApiCreateOrder)
 		api.Get("/order/address", middleware.UserAuth, controller.ApiGetOrderAddress)
 		api.Post("/order/address", middleware.UserAuth, controller.ApiSaveOrderAddress)
+		api.Get("/order/address/list", middleware.UserAuth, controller.ApiGetOrderAddressList)
+		api.Post("/order/address/delete", middleware.UserAuth, controller.ApiDeleteOrderAddress)
+		api.Post("/order/address/default", middleware.UserAuth, controller.ApiSetDefaultOrderAddress)
 		api.Get("/order/detail", middleware.UserAuth, controller.ApiGetOrderDetail)
+		api.Get("/order/download", controller.ApiOrderDownload)
+		api.Post("/order/shipping", controller.ApiOrderShippingFee)
+		api.Get("/order/invoice", middleware.UserAuth, controller.ApiOrderInvoice)
 		api.Post("/order/cancel", middleware.UserAuth, controller.ApiCancelOrder)
 		api.Post("/order/refund", middleware.UserAuth, controller.ApiApplyRefundOrder)
 		api.Post("/order/finish", middleware.UserAuth, controller.ApiFinishedOrder)
@@ -96,6 +131,8 @@ func Register(app *iris.Application) {
 		api.Get("/category/detail", controller.CheckApiOpen, controller.ApiCategoryDetail)
 		api.Get("/category/list", controller.CheckApiOpen, controller.ApiCategoryList)
 		api.Get("/comment/list", controller.CheckApiOpen, controller.ApiCommentList)
+		api.Get("/comment/tree", controller.CheckApiOpen, controller.ApiCommentTree)
+		api.Get("/comment/replies", controller.CheckApiOpen, controller.ApiCommentReplies)
 		api.Get("/setting/contact", controller.CheckApiOpen, controller.ApiContact)
 		api.Get("/setting/system", controller.CheckApiOpen, controller.ApiSystem)
 		api.Get("/guestbook/fields", controller.CheckApiOpen, controller.CheckApiOpen, controller.ApiGuestbook)
@@ -111,6 +148,7 @@ func Register(app *iris.Application) {
 		api.Post("/attachment/upload", controller.CheckApiOpen, controller.ApiAttachmentUpload)
 		api.Post("/comment/publish", controller.CheckApiOpen, controller.ApiCommentPublish)
 		api.Post("/comment/praise", controller.CheckApiOpen, controller.ApiCommentPraise)
+		api.Post("/comment/subscribe", middleware.UserAuth, controller.ApiCommentSubscribe)
 		api.Post("/guestbook.html", controller.CheckApiOpen, controller.ApiGuestbookForm)
 	}
 
@@ -17,6 +17,12 @@ func manageRoute(app *iris.Application) {
 	manage := system.Party("/api", middleware.ParseAdminUrl)
 	{
 		manage.Post("/login", manageController.AdminLogin)
+		manage.Get("/oauth/login", manageController.AdminOauthLogin)
+		manage.Get("/oauth/callback", manageController.AdminOauthCallback)
+		manage.Post("/oauth/exchange", manageController.AdminOauthExchange)
+		manage.Post("/oauth/bind", middleware.ParseAdminToken, manageController.AdminOauthBind)
+		manage.Get("/oauth/accounts", middleware.ParseAdminToken, manageController.AdminOauthAccounts)
+		manage.Post("/oauth/unbind", middleware.ParseAdminToken, manageController.AdminOauthUnbind)
 		manage.Get("/captcha", controller.GenerateCaptcha)
 		manage.Get("/siteinfo", manageController.GetCurrentSiteInfo)
 
@@ -145,6 +151,11 @@ func manageRoute(app *iris.Application) {
 			archive.Post("/status", manageController.UpdateArchiveStatus)
 			archive.Post("/time", manageController.UpdateArchiveTime)
 			archive.Post("/category", manageController.UpdateArchiveCategory)
+			archive.Post("/similar", manageController.ArchiveSimilar)
+			archive.Get("/duplicate", manageController.ArchiveDuplicateGroups)
+			archive.Post("/duplicate/resolve", manageController.ArchiveDuplicateResolve)
+			archive.Post("/extract", manageController.ArchiveExtract)
+			archive.Post("/extract/regenerate", manageController.ArchiveExtractRegenerate)
 		}
 
 		statistic := manage.Party("/statistic", middleware.ParseAdminToken, middleware.AdminPermission)
@@ -156,6 +167,15 @@ func manageRoute(app *iris.Application) {
 			statistic.Get("/include/detail", manageController.GetSpiderIncludeDetail)
 			statistic.Get("/summary", manageController.GetStatisticsSummary)
 			statistic.Get("/dashboard", manageController.GetStatisticsDashboard)
+			statistic.Get("/notfound", manageController.StatisticNotFound)
+			statistic.Get("/notfound/detail", manageController.StatisticNotFoundDetail)
+			statistic.Get("/notfound/ignore", manageController.StatisticNotFoundIgnores)
+			statistic.Post("/notfound/ignore", manageController.StatisticNotFoundIgnoreForm)
+			statistic.Post("/notfound/ignore/delete", manageController.StatisticNotFoundIgnoreDelete)
+			statistic.Post("/notfound/redirect", manageController.StatisticNotFoundRedirect)
+			statistic.Get("/seo", manageController.StatisticSeoAudit)
+			statistic.Get("/seo/list", manageController.StatisticSeoAuditList)
+			statistic.Post("/seo/start", manageController.StatisticSeoAuditStart)
 		}
 
 		design := manage.Party("/design", middleware.ParseAdminToken, middleware.AdminPermission)
@@ -186,6 +206,18 @@ func manageRoute(app *iris.Application) {
 			plugin.Get("/push", manageController.PluginPush)
 			plugin.Post("/push", manageController.PluginPushForm)
 			plugin.Get("/push/logs", manageController.PluginPushLogList)
+			plugin.Get("/push/queue", manageController.PluginPushQueue)
+			plugin.Get("/push/queue/summary", manageController.PluginPushQueueSummary)
+			plugin.Post("/push/submit", manageController.PluginPushSubmit)
+			plugin.Post("/push/queue/retry", manageController.PluginPushQueueRetry)
+			plugin.Post("/push/queue/delete", manageController.PluginPushQueueDelete)
+
+			plugin.Get("/brokenlink", manageController.PluginBrokenLink)
+			plugin.Post("/brokenlink", manageController.PluginBrokenLinkForm)
+			plugin.Post("/brokenlink/start", manageController.PluginBrokenLinkStart)
+			plugin.Get("/brokenlink/list", manageController.PluginBrokenLinkList)
+			plugin.Post("/brokenlink/fix", manageController.PluginBrokenLinkFix)
+			plugin.Post("/brokenlink/delete", manageController.PluginBrokenLinkDelete)
 
 			plugin.Get("/robots", manageController.PluginRobots)
 			plugin.Post("/robots", manageController.PluginRobotsForm)
@@ -196,6 +228,7 @@ func manageRoute(app *iris.Application) {
 
 			plugin.Get("/rewrite", manageController.PluginRewrite)
 			plugin.Post("/rewrite", manageController.PluginRewriteForm)
+			plugin.Post("/rewrite/redirect", manageController.PluginRewriteRedirect)
 
 			plugin.Get("/storage", manageController.PluginStorageConfig)
 			plugin.Post("/storage", manageController.PluginStorageConfigForm)
@@ -216,6 +249,8 @@ func manageRoute(app *iris.Application) {
 				comment.Post("/detail", manageController.PluginCommentDetailForm)
 				comment.Post("/delete", manageController.PluginCommentDelete)
 				comment.Post("/check", manageController.PluginCommentCheck)
+				comment.Get("/config", manageController.PluginCommentConfig)
+				comment.Post("/config", manageController.PluginCommentConfigForm)
 			}
 
 			anchor := plugin.Party("/anchor")
@@ -229,6 +264,11 @@ func manageRoute(app *iris.Application) {
 				anchor.Post("/import", manageController.PluginAnchorImport)
 				anchor.Get("/setting", manageController.PluginAnchorSetting)
 				anchor.Post("/setting", manageController.PluginAnchorSettingForm)
+				anchor.Get("/links/orphan", manageController.PluginAnchorOrphans)
+				anchor.Get("/links/linked", manageController.PluginAnchorLinked)
+				anchor.Get("/links/anchors", manageController.PluginAnchorDistribution)
+				anchor.Get("/links/overuse", manageController.PluginAnchorOveruse)
+				anchor.Post("/links/rebuild", manageController.PluginAnchorRebuildLinks)
 			}
 
 			guestbook := plugin.Party("/guestbook")
@@ -299,6 +339,7 @@ func manageRoute(app *iris.Application) {
 				redirect.Post("/detail", manageController.PluginRedirectDetailForm)
 				redirect.Post("/delete", manageController.PluginRedirectDelete)
 				redirect.Post("/import", manageController.PluginRedirectImport)
+				redirect.Get("/test", manageController.PluginRedirectTest)
 			}
 
 			transfer := plugin.Party("/transfer")
@@ -313,16 +354,43 @@ func manageRoute(app *iris.Application) {
 			{
 				user.Get("/fields", manageController.PluginUserFieldsSetting)
 				user.Post("/fields", manageController.PluginUserFieldsSettingForm)
+				user.Get("/config", manageController.PluginUserConfig)
+				user.Post("/config", manageController.PluginUserConfigForm)
 				user.Get("/list", manageController.PluginUserList)
 				user.Get("/detail", manageController.PluginUserDetail)
 				user.Post("/detail", manageController.PluginUserDetailForm)
 				user.Post("/delete", manageController.PluginUserDelete)
+				user.Get("/export", manageController.PluginUserExport)
+				user.Get("/memberships", manageController.PluginUserMembershipList)
+				user.Get("/deletion/list", manageController.PluginUserDeletionList)
+				user.Post("/deletion/execute", manageController.PluginUserDeletionExecute)
 				user.Get("/group/list", manageController.PluginUserGroupList)
 				user.Get("/group/detail", manageController.PluginUserGroupDetail)
 				user.Post("/group/detail", manageController.PluginUserGroupDetailForm)
 				user.Post("/group/delete", manageController.PluginUserGroupDelete)
 			}
 
+			oauth := plugin.Party("/oauth")
+			{
+				oauth.Get("/config", manageController.PluginOauthConfig)
+				oauth.Post("/config", manageController.PluginOauthConfigForm)
+			}
+
+			notification := plugin.Party("/notification")
+			{
+				notification.Get("/list", manageController.PluginNotificationList)
+				notification.Post("/broadcast", manageController.PluginNotificationBroadcast)
+				notification.Post("/delete", manageController.PluginNotificationDelete)
+			}
+
+			point := plugin.Party("/point")
+			{
+				point.Get("/config", manageController.PluginPointConfig)
+				point.Post("/config", manageController.PluginPointConfigForm)
+				point.Get("/list", manageController.PluginPointList)
+				point.Post("/adjust", manageController.PluginPointAdjust)
+			}
+
 			weapp := plugin.Party("/weapp")
 			{
 				weapp.Get("/config", manageController.PluginWeappConfig)
@@ -372,7 +440,17 @@ func manageRoute(app *iris.Application) {
 				order.Post("/canceled", manageController.PluginOrderSetCanceled)
 				order.Post("/refund", manageController.PluginOrderSetRefund)
 				order.Post("/refund/apply", manageController.PluginOrderApplyRefund)
+				order.Get("/refund/list", manageController.PluginOrderRefundList)
+				order.Get("/invoice", manageController.PluginOrderInvoice)
+				order.Post("/refund/export", manageController.PluginOrderRefundExport)
 				order.Post("/export", manageController.PluginOrderExport)
+				order.Post("/delivery/upload", manageController.PluginOrderDeliveryUpload)
+				order.Get("/license/list", manageController.PluginLicenseKeyList)
+				order.Post("/license/import", manageController.PluginLicenseKeyImport)
+				order.Post("/license/delete", manageController.PluginLicenseKeyDelete)
+				order.Get("/shipping/list", manageController.PluginShippingTemplateList)
+				order.Post("/shipping/detail", manageController.PluginShippingTemplateForm)
+				order.Post("/shipping/delete", manageController.PluginShippingTemplateDelete)
 			}
 
 			withdraw := plugin.Party("/withdraw")
@@ -52,7 +52,7 @@ func (node *tagArchiveDetailNode) Execute(ctx *pongo2.ExecutionContext, writer p
 		// check has Order
 		if fieldName == "HasOrdered" && archiveDetail != nil {
 			// if read level larger than 0, then need to check permission
-			if archiveDetail.Price == 0 && archiveDetail.ReadLevel == 0 {
+			if archiveDetail.Price == 0 && archiveDetail.ReadLevel == 0 && archiveDetail.ReadPoints == 0 {
 				archiveDetail.HasOrdered = true
 			}
 			userInfo, ok := ctx.Public["userInfo"].(*model.User)
@@ -69,6 +69,9 @@ func (node *tagArchiveDetailNode) Execute(ctx *pongo2.ExecutionContext, writer p
 						archiveDetail.HasOrdered = true
 					}
 				}
+				if archiveDetail.ReadPoints > 0 && !archiveDetail.HasOrdered {
+					archiveDetail.HasOrdered = currentSite.CheckArchivePointUnlocked(userInfo.Id, archiveDetail.Id)
+				}
 				discount := currentSite.GetUserDiscount(userInfo.Id, userInfo)
 				if discount > 0 {
 					archiveDetail.FavorablePrice = archiveDetail.Price * discount / 100
@@ -101,6 +104,8 @@ func (node *tagArchiveDetailNode) Execute(ctx *pongo2.ExecutionContext, writer p
 				if userGroup == nil || userGroup.Level < archiveDetail.ReadLevel {
 					content = fmt.Sprintf(currentSite.Lang("该内容需要用户等级%d以上才能阅读"), archiveDetail.ReadLevel)
 				}
+			} else if archiveDetail.ReadPoints > 0 && !archiveDetail.HasOrdered && !checkArchivePointUnlocked(ctx, currentSite, archiveDetail) {
+				content = fmt.Sprintf(currentSite.Lang("该内容需要%d积分解锁后才能阅读"), archiveDetail.ReadPoints)
 			} else {
 				// 当读取content 的时候，再查询
 				archiveData, err := currentSite.GetArchiveDataById(archiveDetail.Id)
@@ -181,3 +186,16 @@ func TagArchiveDetailParser(doc *pongo2.Parser, start *pongo2.Token, arguments *
 
 	return tagNode, nil
 }
+
+// checkArchivePointUnlocked 当前登录用户是否已使用积分解锁文档，作者本人不需要解锁
+func checkArchivePointUnlocked(ctx *pongo2.ExecutionContext, currentSite *provider.Website, archive *model.Archive) bool {
+	userInfo, ok := ctx.Public["userInfo"].(*model.User)
+	if !ok || userInfo == nil || userInfo.Id == 0 {
+		return false
+	}
+	if archive.UserId == userInfo.Id {
+		return true
+	}
+
+	return currentSite.CheckArchivePointUnlocked(userInfo.Id, archive.Id)
+}
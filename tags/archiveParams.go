@@ -40,7 +40,7 @@ func (node *tagArchiveParamsNode) Execute(ctx *pongo2.ExecutionContext, writer p
 		archiveParams := currentSite.GetArchiveExtra(archiveDetail.ModuleId, archiveDetail.Id)
 		if len(archiveParams) > 0 {
 			// if read level larger than 0, then need to check permission
-			if archiveDetail.Price == 0 && archiveDetail.ReadLevel == 0 {
+			if archiveDetail.Price == 0 && archiveDetail.ReadLevel == 0 && archiveDetail.ReadPoints == 0 {
 				archiveDetail.HasOrdered = true
 			}
 			userInfo, ok := ctx.Public["userInfo"].(*model.User)
@@ -57,6 +57,9 @@ func (node *tagArchiveParamsNode) Execute(ctx *pongo2.ExecutionContext, writer p
 						archiveDetail.HasOrdered = true
 					}
 				}
+				if archiveDetail.ReadPoints > 0 && !archiveDetail.HasOrdered {
+					archiveDetail.HasOrdered = currentSite.CheckArchivePointUnlocked(userInfo.Id, archiveDetail.Id)
+				}
 			}
 			for i := range archiveParams {
 				if archiveParams[i].Value == nil || archiveParams[i].Value == "" {
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"github.com/flosch/pongo2/v6"
 	"github.com/kataras/iris/v12/context"
+	"kandaoni.com/anqicms/config"
 	"kandaoni.com/anqicms/model"
 	"kandaoni.com/anqicms/provider"
 	"strconv"
@@ -83,7 +84,25 @@ func (node *tagCommentListNode) Execute(ctx *pongo2.ExecutionContext, writer pon
 		authorId = uint(args["userId"].Integer())
 	}
 
-	commentList, total, _ := currentSite.GetCommentList(archiveId, authorId, order, currentPage, limit, offset)
+	// tree=true 时按评论树输出，分页按顶层评论计算，回复在 item.Children 中
+	var commentList []*model.Comment
+	var total int64
+	if args["tree"] != nil && args["tree"].Bool() && archiveId > 0 {
+		depth := config.CommentTreeDefaultDepth
+		replyLimit := 5
+		if args["depth"] != nil {
+			depth = args["depth"].Integer()
+		}
+		if args["replyLimit"] != nil {
+			replyLimit = args["replyLimit"].Integer()
+		}
+		if currentPage < 1 {
+			currentPage = 1
+		}
+		commentList, total = currentSite.GetCommentTree(archiveId, order, depth, currentPage, limit, replyLimit)
+	} else {
+		commentList, total, _ = currentSite.GetCommentList(archiveId, authorId, -1, order, currentPage, limit, offset)
+	}
 
 	if listType == "page" {
 		// 如果评论是在文章详情页或产品详情页，则根据具体来判断页码
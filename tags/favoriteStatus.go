@@ -0,0 +1,75 @@
+package tags
+
+import (
+	"fmt"
+	"github.com/flosch/pongo2/v6"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/provider"
+)
+
+type tagFavoriteStatusNode struct {
+	args map[string]pongo2.IEvaluator
+	name string
+}
+
+// Execute 当前登录用户是否收藏了文档，不传 archiveId 时，使用当前文档
+func (node *tagFavoriteStatusNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	currentSite, _ := ctx.Public["website"].(*provider.Website)
+	if currentSite == nil || currentSite.DB == nil {
+		return nil
+	}
+	args, err := parseArgs(node.args, ctx)
+	if err != nil {
+		return err
+	}
+	archiveId := uint(0)
+	archiveDetail, ok := ctx.Public["archive"].(*model.Archive)
+	if ok && archiveDetail != nil {
+		archiveId = archiveDetail.Id
+	}
+	if args["archiveId"] != nil {
+		archiveId = uint(args["archiveId"].Integer())
+	}
+
+	var favorited bool
+	userInfo, ok := ctx.Public["userInfo"].(*model.User)
+	if ok && userInfo != nil {
+		favorited = currentSite.CheckUserFavorite(userInfo.Id, archiveId)
+	}
+
+	if node.name == "" {
+		writer.WriteString(fmt.Sprintf("%v", favorited))
+	} else {
+		ctx.Private[node.name] = favorited
+	}
+
+	return nil
+}
+
+func TagFavoriteStatusParser(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+	tagNode := &tagFavoriteStatusNode{
+		args: make(map[string]pongo2.IEvaluator),
+	}
+
+	nameToken := arguments.MatchType(pongo2.TokenIdentifier)
+	if nameToken != nil {
+		if nameToken.Val == "with" {
+			//with 需要退回
+			arguments.ConsumeN(-1)
+		} else {
+			tagNode.name = nameToken.Val
+		}
+	}
+
+	args, err := parseWith(arguments)
+	if err != nil {
+		return nil, err
+	}
+	tagNode.args = args
+
+	for arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed favoriteStatus-tag arguments.", nil)
+	}
+
+	return tagNode, nil
+}
@@ -0,0 +1,63 @@
+package tags
+
+import (
+	"fmt"
+	"github.com/flosch/pongo2/v6"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/provider"
+)
+
+type tagNotificationCountNode struct {
+	args map[string]pongo2.IEvaluator
+	name string
+}
+
+// Execute 当前登录用户的未读通知数量
+func (node *tagNotificationCountNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	currentSite, _ := ctx.Public["website"].(*provider.Website)
+	if currentSite == nil || currentSite.DB == nil {
+		return nil
+	}
+
+	var total int64
+	userInfo, ok := ctx.Public["userInfo"].(*model.User)
+	if ok && userInfo != nil {
+		total = currentSite.GetUnreadNotificationCount(userInfo)
+	}
+
+	if node.name == "" {
+		writer.WriteString(fmt.Sprintf("%d", total))
+	} else {
+		ctx.Private[node.name] = total
+	}
+
+	return nil
+}
+
+func TagNotificationCountParser(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+	tagNode := &tagNotificationCountNode{
+		args: make(map[string]pongo2.IEvaluator),
+	}
+
+	nameToken := arguments.MatchType(pongo2.TokenIdentifier)
+	if nameToken != nil {
+		if nameToken.Val == "with" {
+			//with 需要退回
+			arguments.ConsumeN(-1)
+		} else {
+			tagNode.name = nameToken.Val
+		}
+	}
+
+	args, err := parseWith(arguments)
+	if err != nil {
+		return nil, err
+	}
+	tagNode.args = args
+
+	for arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed notificationCount-tag arguments.", nil)
+	}
+
+	return tagNode, nil
+}
@@ -0,0 +1,135 @@
+package tags
+
+import (
+	"fmt"
+	"github.com/flosch/pongo2/v6"
+	"github.com/kataras/iris/v12/context"
+	"kandaoni.com/anqicms/model"
+	"kandaoni.com/anqicms/provider"
+	"strconv"
+	"strings"
+)
+
+type tagPointListNode struct {
+	name    string
+	args    map[string]pongo2.IEvaluator
+	wrapper *pongo2.NodeWrapper
+}
+
+func (node *tagPointListNode) Execute(ctx *pongo2.ExecutionContext, writer pongo2.TemplateWriter) *pongo2.Error {
+	currentSite, _ := ctx.Public["website"].(*provider.Website)
+	if currentSite == nil || currentSite.DB == nil {
+		return nil
+	}
+	args, err := parseArgs(node.args, ctx)
+	if err != nil {
+		return err
+	}
+
+	limit := 10
+	offset := 0
+	currentPage := 1
+	listType := "list"
+
+	urlParams, ok := ctx.Public["urlParams"].(map[string]string)
+	if ok {
+		currentPage, _ = strconv.Atoi(urlParams["page"])
+	}
+	requestParams, ok := ctx.Public["requestParams"].(*context.RequestParams)
+	if ok {
+		paramPage := requestParams.GetIntDefault("page", 0)
+		if paramPage > 0 {
+			currentPage = paramPage
+		}
+	}
+	if currentPage < 1 {
+		currentPage = 1
+	}
+	if args["limit"] != nil {
+		limitArgs := strings.Split(args["limit"].String(), ",")
+		if len(limitArgs) == 2 {
+			offset, _ = strconv.Atoi(limitArgs[0])
+			limit, _ = strconv.Atoi(limitArgs[1])
+		} else if len(limitArgs) == 1 {
+			limit, _ = strconv.Atoi(limitArgs[0])
+		}
+		if limit > 100 {
+			limit = 100
+		}
+		if limit < 1 {
+			limit = 1
+		}
+	}
+	if args["type"] != nil {
+		listType = args["type"].String()
+	}
+	action := ""
+	if args["action"] != nil {
+		action = args["action"].String()
+	}
+
+	// 只能读取当前登录用户的积分明细
+	var points []*model.UserPoint
+	var total int64
+	userInfo, ok := ctx.Public["userInfo"].(*model.User)
+	if ok && userInfo != nil {
+		if listType == "page" {
+			points, total = currentSite.GetUserPointList(userInfo.Id, action, currentPage, limit)
+		} else {
+			points, total = currentSite.GetUserPointList(userInfo.Id, action, 0, limit, offset)
+		}
+	}
+
+	if listType == "page" {
+		ctx.Public["pagination"] = makePagination(currentSite, total, currentPage, limit, "", 5)
+	}
+	ctx.Private[node.name] = points
+	//execute
+	node.wrapper.Execute(ctx, writer)
+
+	return nil
+}
+
+func TagPointListParser(doc *pongo2.Parser, start *pongo2.Token, arguments *pongo2.Parser) (pongo2.INodeTag, *pongo2.Error) {
+	tagNode := &tagPointListNode{
+		args: make(map[string]pongo2.IEvaluator),
+	}
+
+	nameToken := arguments.MatchType(pongo2.TokenIdentifier)
+	if nameToken == nil {
+		return nil, arguments.Error("pointList-tag needs a accept name.", nil)
+	}
+
+	tagNode.name = nameToken.Val
+
+	// After having parsed the name we're gonna parse the with options
+	args, err := parseWith(arguments)
+	if err != nil {
+		return nil, err
+	}
+	tagNode.args = args
+
+	for arguments.Remaining() > 0 {
+		return nil, arguments.Error("Malformed pointList-tag arguments.", nil)
+	}
+	wrapper, endtagargs, err := doc.WrapUntilTag("endpointList")
+	if err != nil {
+		return nil, err
+	}
+	if endtagargs.Remaining() > 0 {
+		endtagnameToken := endtagargs.MatchType(pongo2.TokenIdentifier)
+		if endtagnameToken != nil {
+			if endtagnameToken.Val != nameToken.Val {
+				return nil, endtagargs.Error(fmt.Sprintf("Name for 'endpointList' must equal to 'pointList'-tag's name ('%s' != '%s').",
+					nameToken.Val, endtagnameToken.Val), nil)
+			}
+		}
+
+		if endtagnameToken == nil || endtagargs.Remaining() > 0 {
+			return nil, endtagargs.Error("Either no or only one argument (identifier) allowed for 'endpointList'.", nil)
+		}
+	}
+	tagNode.wrapper = wrapper
+
+	return tagNode, nil
+}